@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/zurustar/xylitol2/internal/database"
+)
+
+const backupUsage = `Usage: sipserver backup [-config file] <destination>
+
+Writes a consistent snapshot of the configured database to destination.
+The server may keep running while the backup is taken.
+`
+
+const restoreUsage = `Usage: sipserver restore [-config file] <backup>
+
+Replaces the configured database with backup after validating it.
+The server must be stopped; the previous database is kept with a
+.pre-restore suffix.
+`
+
+// runBackup implements the "backup" subcommand
+func runBackup(args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+	configFile := flags.String("config", "config.yaml", "Configuration file path")
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, backupUsage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected exactly one destination")
+	}
+
+	manager, err := openDatabase(*configFile)
+	if err != nil {
+		return err
+	}
+	defer manager.Close()
+
+	if err := manager.Backup(flags.Arg(0)); err != nil {
+		return err
+	}
+	fmt.Printf("Backed up %s to %s\n", manager.Path(), flags.Arg(0))
+	return nil
+}
+
+// runRestore implements the "restore" subcommand
+func runRestore(args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	configFile := flags.String("config", "config.yaml", "Configuration file path")
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, restoreUsage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected exactly one backup file")
+	}
+
+	cfg, err := loadDatabaseConfig(*configFile)
+	if err != nil {
+		return err
+	}
+
+	version, err := database.Restore(flags.Arg(0), cfg.Database.Path)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Restored %s from %s (schema version %d)\n", cfg.Database.Path, flags.Arg(0), version)
+	if latest := database.LatestSchemaVersion(); version < latest {
+		fmt.Printf("Pending migrations up to version %d will be applied on next start\n", latest)
+	}
+	return nil
+}
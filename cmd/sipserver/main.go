@@ -3,11 +3,33 @@ package main
 import (
 	"flag"
 	"log"
+	"os"
 
 	"github.com/zurustar/xylitol2/internal/server"
 )
 
 func main() {
+	// Administrative subcommands operate on the database and exit
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			if err := runMigrate(os.Args[2:]); err != nil {
+				log.Fatalf("Migration failed: %v", err)
+			}
+			return
+		case "backup":
+			if err := runBackup(os.Args[2:]); err != nil {
+				log.Fatalf("Backup failed: %v", err)
+			}
+			return
+		case "restore":
+			if err := runRestore(os.Args[2:]); err != nil {
+				log.Fatalf("Restore failed: %v", err)
+			}
+			return
+		}
+	}
+
 	var configFile = flag.String("config", "config.yaml", "Configuration file path")
 	flag.Parse()
 
@@ -23,4 +45,4 @@ func main() {
 	if err := sipServer.RunWithSignalHandling(); err != nil {
 		log.Fatalf("Server error: %v", err)
 	}
-}
\ No newline at end of file
+}
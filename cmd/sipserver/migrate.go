@@ -0,0 +1,126 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/zurustar/xylitol2/internal/config"
+	"github.com/zurustar/xylitol2/internal/database"
+)
+
+const migrateUsage = `Usage: sipserver migrate [-config file] status|up|down
+
+Commands:
+  status  show applied and pending schema migrations
+  up      apply all pending migrations
+  down    roll back the most recently applied migration
+`
+
+// runMigrate implements the "migrate" subcommand
+func runMigrate(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	configFile := flags.String("config", "config.yaml", "Configuration file path")
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected exactly one command")
+	}
+
+	manager, err := openDatabase(*configFile)
+	if err != nil {
+		return err
+	}
+	defer manager.Close()
+
+	migrator, err := manager.Migrator()
+	if err != nil {
+		return err
+	}
+
+	switch flags.Arg(0) {
+	case "status":
+		return printMigrationStatus(migrator)
+
+	case "up":
+		applied, err := migrator.Up()
+		for _, migration := range applied {
+			fmt.Printf("Applied migration %d: %s\n", migration.Version, migration.Description)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("Database schema is up to date")
+		}
+		return nil
+
+	case "down":
+		migration, err := migrator.Down()
+		if err != nil {
+			return err
+		}
+		if migration == nil {
+			fmt.Println("No migrations to roll back")
+			return nil
+		}
+		fmt.Printf("Rolled back migration %d: %s\n", migration.Version, migration.Description)
+		return nil
+
+	default:
+		flags.Usage()
+		return fmt.Errorf("unknown migrate command: %s", flags.Arg(0))
+	}
+}
+
+// loadDatabaseConfig loads configFile and checks that it uses an on-disk database
+func loadDatabaseConfig(configFile string) (*config.Config, error) {
+	cfg, err := config.NewManager().Load(configFile)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Database.Driver == config.DatabaseDriverMemory {
+		return nil, fmt.Errorf("database driver %q has no persistent storage", cfg.Database.Driver)
+	}
+	return cfg, nil
+}
+
+// openDatabase opens the database configured in configFile without applying migrations
+func openDatabase(configFile string) (*database.SQLiteManager, error) {
+	cfg, err := loadDatabaseConfig(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	manager := database.NewSQLiteManager(cfg.Database.Path)
+	if err := manager.Open(); err != nil {
+		return nil, err
+	}
+	return manager, nil
+}
+
+func printMigrationStatus(migrator *database.Migrator) error {
+	version, err := migrator.Version()
+	if err != nil {
+		return err
+	}
+	statuses, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Schema version: %d (latest %d)\n", version, database.LatestSchemaVersion())
+	for _, status := range statuses {
+		state := "pending"
+		if status.Applied {
+			state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("  %4d  %-28s  %s\n", status.Version, state, status.Description)
+	}
+	return nil
+}
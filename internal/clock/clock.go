@@ -0,0 +1,36 @@
+// Package clock abstracts the passage of time so that timer and expiry logic
+// can be driven by virtual time in tests.
+package clock
+
+import "time"
+
+// Clock tells the time and runs functions after a delay
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+	// AfterFunc calls f once d has elapsed. The real clock calls it in its
+	// own goroutine.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a pending call started by AfterFunc
+type Timer interface {
+	// Stop prevents the call and reports whether it was still pending
+	Stop() bool
+}
+
+// realClock is the system clock
+type realClock struct{}
+
+// Real returns the system clock
+func Real() Clock {
+	return realClock{}
+}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
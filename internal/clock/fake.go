@@ -0,0 +1,97 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Fake is a clock that only moves when advanced. Functions scheduled with
+// AfterFunc run synchronously in the goroutine calling Advance, in the order
+// of their deadlines, so that tests can step through timer and expiry logic
+// without sleeping.
+type Fake struct {
+	mutex  sync.Mutex
+	now    time.Time
+	timers []*fakeTimer // Pending timers ordered by deadline
+	seq    uint64
+}
+
+// fakeTimer is a function waiting on a fake clock
+type fakeTimer struct {
+	clock    *Fake
+	deadline time.Time
+	seq      uint64 // Orders timers with the same deadline by creation
+	f        func()
+}
+
+// NewFake creates a fake clock reading start
+func NewFake(start time.Time) *Fake {
+	return &Fake{now: start}
+}
+
+// Now returns the clock's current time
+func (c *Fake) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// AfterFunc schedules f to run when the clock has advanced by d. A
+// non-positive d runs f on the next call to Advance.
+func (c *Fake) AfterFunc(d time.Duration, f func()) Timer {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.seq++
+	timer := &fakeTimer{clock: c, deadline: c.now.Add(d), seq: c.seq, f: f}
+	i := sort.Search(len(c.timers), func(i int) bool {
+		return c.timers[i].deadline.After(timer.deadline)
+	})
+	c.timers = append(c.timers, nil)
+	copy(c.timers[i+1:], c.timers[i:])
+	c.timers[i] = timer
+	return timer
+}
+
+// Advance moves the clock forward by d, running each timer that falls due on
+// the way with the clock set to its deadline. Timers scheduled by those
+// functions run too if they fall due within d.
+func (c *Fake) Advance(d time.Duration) {
+	c.mutex.Lock()
+	target := c.now.Add(d)
+	for len(c.timers) > 0 && !c.timers[0].deadline.After(target) {
+		timer := c.timers[0]
+		c.timers = c.timers[1:]
+		if timer.deadline.After(c.now) {
+			c.now = timer.deadline
+		}
+		c.mutex.Unlock()
+		timer.f()
+		c.mutex.Lock()
+	}
+	c.now = target
+	c.mutex.Unlock()
+}
+
+// Pending returns the number of timers that have not fired or been stopped
+func (c *Fake) Pending() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.timers)
+}
+
+// Stop removes the timer from its clock
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for i, timer := range c.timers {
+		if timer == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
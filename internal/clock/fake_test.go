@@ -0,0 +1,74 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFake_Advance(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFake(start)
+
+	var fired []string
+	var firedAt []time.Duration
+	record := func(name string) func() {
+		return func() {
+			fired = append(fired, name)
+			firedAt = append(firedAt, c.Now().Sub(start))
+		}
+	}
+
+	c.AfterFunc(300*time.Millisecond, record("c"))
+	c.AfterFunc(100*time.Millisecond, record("a"))
+	c.AfterFunc(100*time.Millisecond, record("b"))
+	stopped := c.AfterFunc(200*time.Millisecond, record("stopped"))
+	c.AfterFunc(150*time.Millisecond, func() {
+		record("rearm")()
+		c.AfterFunc(100*time.Millisecond, record("rearmed"))
+	})
+
+	if !stopped.Stop() {
+		t.Error("Expected Stop to report a pending timer")
+	}
+	if stopped.Stop() {
+		t.Error("Expected second Stop to report nothing pending")
+	}
+
+	c.Advance(99 * time.Millisecond)
+	if len(fired) != 0 {
+		t.Fatalf("Expected nothing to fire before the first deadline, got %v", fired)
+	}
+
+	c.Advance(201 * time.Millisecond)
+	expected := []string{"a", "b", "rearm", "rearmed", "c"}
+	expectedAt := []time.Duration{100, 100, 150, 250, 300}
+	if len(fired) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, fired)
+	}
+	for i := range expected {
+		if fired[i] != expected[i] || firedAt[i] != expectedAt[i]*time.Millisecond {
+			t.Errorf("Expected %s at %dms, got %s at %v", expected[i], expectedAt[i], fired[i], firedAt[i])
+		}
+	}
+
+	if got := c.Now().Sub(start); got != 300*time.Millisecond {
+		t.Errorf("Expected clock at 300ms, got %v", got)
+	}
+	if c.Pending() != 0 {
+		t.Errorf("Expected no pending timers, got %d", c.Pending())
+	}
+}
+
+func TestReal_AfterFunc(t *testing.T) {
+	done := make(chan struct{})
+	Real().AfterFunc(time.Millisecond, func() { close(done) })
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Timer did not fire")
+	}
+
+	if !Real().AfterFunc(time.Hour, func() {}).Stop() {
+		t.Error("Expected Stop to report a pending timer")
+	}
+}
@@ -3,12 +3,41 @@ package config
 // Config represents the server configuration
 type Config struct {
 	Server struct {
-		UDPPort int `yaml:"udp_port"`
-		TCPPort int `yaml:"tcp_port"`
+		UDPPort       int              `yaml:"udp_port"`
+		TCPPort       int              `yaml:"tcp_port"`
+		Listeners     []ListenerConfig `yaml:"listeners"`      // Replace udp_port and tcp_port when set
+		ProxyProtocol bool             `yaml:"proxy_protocol"` // TCP and TLS connections start with a PROXY protocol header
 	} `yaml:"server"`
 	
+	// RFC3261 transaction timers in milliseconds; 0 takes the RFC default
+	Timers struct {
+		T1     int `yaml:"t1"`      // RTT estimate (500)
+		T2     int `yaml:"t2"`      // Maximum retransmit interval (4000)
+		T4     int `yaml:"t4"`      // Maximum time a message stays in the network (5000)
+		TimerB int `yaml:"timer_b"` // INVITE transaction timeout (64*T1)
+		TimerF int `yaml:"timer_f"` // Non-INVITE transaction timeout (64*T1)
+		TimerH int `yaml:"timer_h"` // Wait time for ACK receipt (64*T1)
+	} `yaml:"timers"`
+	
+	TLS struct {
+		Enabled      bool     `yaml:"enabled"`
+		Port         int      `yaml:"port"`
+		CertFile     string   `yaml:"cert_file"`
+		KeyFile      string   `yaml:"key_file"`
+		CAFile       string   `yaml:"ca_file"`       // Verifies peers we connect to; system roots if empty
+		MinVersion   string   `yaml:"min_version"`   // "1.2" (default) or "1.3"
+		CipherSuites []string `yaml:"cipher_suites"` // IANA names; Go defaults if empty
+	} `yaml:"tls"`
+	
+	WebSocket struct {
+		Enabled    bool `yaml:"enabled"`
+		Port       int  `yaml:"port"`        // WS listener; 0 disables it
+		SecurePort int  `yaml:"secure_port"` // WSS listener using the tls certificate; 0 disables it
+	} `yaml:"websocket"`
+	
 	Database struct {
-		Path string `yaml:"path"`
+		Driver string `yaml:"driver"` // "sqlite" (default) or "memory"
+		Path   string `yaml:"path"`
 	} `yaml:"database"`
 	
 	Authentication struct {
@@ -44,6 +73,22 @@ type Config struct {
 	} `yaml:"logging"`
 }
 
+// ListenerConfig is a SIP listening socket bound to one local address, for
+// servers with several interfaces or behind 1:1 NAT
+type ListenerConfig struct {
+	Address        string `yaml:"address"`         // Local IP to bind; all interfaces if empty
+	Port           int    `yaml:"port"`
+	Transport      string `yaml:"transport"`       // "udp", "tcp", "tls", "ws" or "wss"; udp and tcp if empty; tls and wss use the tls certificate
+	AdvertisedHost string `yaml:"advertised_host"` // Used in Via and B2BUA SDP instead of address, e.g. the public NAT address
+	AdvertisedPort int    `yaml:"advertised_port"` // Defaults to port
+}
+
+// Supported database drivers
+const (
+	DatabaseDriverSQLite = "sqlite"
+	DatabaseDriverMemory = "memory"
+)
+
 // ConfigManager defines the interface for configuration management
 type ConfigManager interface {
 	Load(filename string) (*Config, error)
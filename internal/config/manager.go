@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"strings"
 
@@ -44,10 +45,83 @@ func (m *Manager) Validate(config *Config) error {
 	if config.Server.TCPPort < 0 || config.Server.TCPPort > 65535 {
 		return fmt.Errorf("invalid TCP port: %d (must be 0-65535)", config.Server.TCPPort)
 	}
+	for i, listener := range config.Server.Listeners {
+		if listener.Port < 0 || listener.Port > 65535 {
+			return fmt.Errorf("invalid port for listener %d: %d (must be 0-65535)", i, listener.Port)
+		}
+		if listener.AdvertisedPort < 0 || listener.AdvertisedPort > 65535 {
+			return fmt.Errorf("invalid advertised port for listener %d: %d (must be 0-65535)", i, listener.AdvertisedPort)
+		}
+		switch strings.ToLower(listener.Transport) {
+		case "", "udp", "tcp", "ws":
+		case "tls", "wss":
+			if strings.TrimSpace(config.TLS.CertFile) == "" || strings.TrimSpace(config.TLS.KeyFile) == "" {
+				return fmt.Errorf("TLS certificate and key files are required for %s listener %d", listener.Transport, i)
+			}
+		default:
+			return fmt.Errorf("invalid transport for listener %d: %s (must be udp, tcp, tls, ws or wss)", i, listener.Transport)
+		}
+		if listener.Address != "" && net.ParseIP(listener.Address) == nil {
+			return fmt.Errorf("invalid address for listener %d: %s (must be an IP address)", i, listener.Address)
+		}
+	}
+
+	// Validate transaction timers (0 means the RFC3261 default)
+	timers := []struct {
+		name  string
+		value int
+	}{
+		{"T1", config.Timers.T1}, {"T2", config.Timers.T2}, {"T4", config.Timers.T4},
+		{"Timer B", config.Timers.TimerB}, {"Timer F", config.Timers.TimerF}, {"Timer H", config.Timers.TimerH},
+	}
+	for _, timer := range timers {
+		if timer.value < 0 {
+			return fmt.Errorf("invalid %s: %d ms (must not be negative)", timer.name, timer.value)
+		}
+	}
+	if config.Timers.T1 > 0 && config.Timers.T2 > 0 && config.Timers.T2 < config.Timers.T1 {
+		return fmt.Errorf("T2 (%d ms) cannot be less than T1 (%d ms)", config.Timers.T2, config.Timers.T1)
+	}
+
+	// Validate TLS settings
+	if config.TLS.Enabled {
+		if config.TLS.Port < 0 || config.TLS.Port > 65535 {
+			return fmt.Errorf("invalid TLS port: %d (must be 0-65535)", config.TLS.Port)
+		}
+		if strings.TrimSpace(config.TLS.CertFile) == "" || strings.TrimSpace(config.TLS.KeyFile) == "" {
+			return fmt.Errorf("TLS certificate and key files are required when TLS is enabled")
+		}
+	}
+
+	// Validate WebSocket settings
+	if config.WebSocket.Enabled {
+		if config.WebSocket.Port < 0 || config.WebSocket.Port > 65535 {
+			return fmt.Errorf("invalid WebSocket port: %d (must be 0-65535)", config.WebSocket.Port)
+		}
+		if config.WebSocket.SecurePort < 0 || config.WebSocket.SecurePort > 65535 {
+			return fmt.Errorf("invalid secure WebSocket port: %d (must be 0-65535)", config.WebSocket.SecurePort)
+		}
+		if config.WebSocket.Port == 0 && config.WebSocket.SecurePort == 0 {
+			return fmt.Errorf("WebSocket enabled without a port")
+		}
+		if config.WebSocket.Port > 0 && config.WebSocket.Port == config.WebSocket.SecurePort {
+			return fmt.Errorf("WebSocket port %d conflicts with secure WebSocket port", config.WebSocket.Port)
+		}
+		if config.WebSocket.SecurePort > 0 &&
+			(strings.TrimSpace(config.TLS.CertFile) == "" || strings.TrimSpace(config.TLS.KeyFile) == "") {
+			return fmt.Errorf("TLS certificate and key files are required for secure WebSocket")
+		}
+	}
 
-	// Validate database path
-	if strings.TrimSpace(config.Database.Path) == "" {
-		return fmt.Errorf("database path cannot be empty")
+	// Validate database settings (an empty driver means SQLite)
+	switch config.Database.Driver {
+	case "", DatabaseDriverSQLite:
+		if strings.TrimSpace(config.Database.Path) == "" {
+			return fmt.Errorf("database path cannot be empty")
+		}
+	case DatabaseDriverMemory:
+	default:
+		return fmt.Errorf("invalid database driver: %s (must be sqlite or memory)", config.Database.Driver)
 	}
 
 	// Validate authentication settings
@@ -95,6 +169,18 @@ func (m *Manager) Validate(config *Config) error {
 				return fmt.Errorf("web admin port %d conflicts with SIP server ports", config.WebAdmin.Port)
 			}
 		}
+		for _, listener := range config.Server.Listeners {
+			if config.WebAdmin.Port > 0 && config.WebAdmin.Port == listener.Port && !strings.EqualFold(listener.Transport, "udp") {
+				return fmt.Errorf("web admin port %d conflicts with SIP server ports", config.WebAdmin.Port)
+			}
+		}
+		if config.TLS.Enabled && config.WebAdmin.Port > 0 && config.WebAdmin.Port == config.TLS.Port {
+			return fmt.Errorf("web admin port %d conflicts with SIP TLS port", config.WebAdmin.Port)
+		}
+		if config.WebSocket.Enabled && config.WebAdmin.Port > 0 &&
+			(config.WebAdmin.Port == config.WebSocket.Port || config.WebAdmin.Port == config.WebSocket.SecurePort) {
+			return fmt.Errorf("web admin port %d conflicts with SIP WebSocket ports", config.WebAdmin.Port)
+		}
 	}
 
 	// Validate logging settings
@@ -116,16 +202,54 @@ func (m *Manager) Validate(config *Config) error {
 func GetDefaultConfig() *Config {
 	return &Config{
 		Server: struct {
-			UDPPort int `yaml:"udp_port"`
-			TCPPort int `yaml:"tcp_port"`
+			UDPPort       int              `yaml:"udp_port"`
+			TCPPort       int              `yaml:"tcp_port"`
+			Listeners     []ListenerConfig `yaml:"listeners"`
+			ProxyProtocol bool             `yaml:"proxy_protocol"`
 		}{
 			UDPPort: 5060,
 			TCPPort: 5060,
 		},
+		Timers: struct {
+			T1     int `yaml:"t1"`
+			T2     int `yaml:"t2"`
+			T4     int `yaml:"t4"`
+			TimerB int `yaml:"timer_b"`
+			TimerF int `yaml:"timer_f"`
+			TimerH int `yaml:"timer_h"`
+		}{
+			T1: 500,
+			T2: 4000,
+			T4: 5000,
+		},
+		TLS: struct {
+			Enabled      bool     `yaml:"enabled"`
+			Port         int      `yaml:"port"`
+			CertFile     string   `yaml:"cert_file"`
+			KeyFile      string   `yaml:"key_file"`
+			CAFile       string   `yaml:"ca_file"`
+			MinVersion   string   `yaml:"min_version"`
+			CipherSuites []string `yaml:"cipher_suites"`
+		}{
+			Enabled:    false,
+			Port:       5061,
+			MinVersion: "1.2",
+		},
+		WebSocket: struct {
+			Enabled    bool `yaml:"enabled"`
+			Port       int  `yaml:"port"`
+			SecurePort int  `yaml:"secure_port"`
+		}{
+			Enabled:    false,
+			Port:       8088,
+			SecurePort: 0,
+		},
 		Database: struct {
-			Path string `yaml:"path"`
+			Driver string `yaml:"driver"`
+			Path   string `yaml:"path"`
 		}{
-			Path: "./sipserver.db",
+			Driver: DatabaseDriverSQLite,
+			Path:   "./sipserver.db",
 		},
 		Authentication: struct {
 			Enabled     bool   `yaml:"enabled"`
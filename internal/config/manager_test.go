@@ -230,6 +230,92 @@ func TestManager_Validate(t *testing.T) {
 			expectError: true,
 			errorMsg:    "invalid TCP port",
 		},
+		{
+			name: "valid listeners",
+			config: func() *Config {
+				c := GetDefaultConfig()
+				c.Server.Listeners = []ListenerConfig{
+					{Address: "10.0.0.5", Port: 5060, Transport: "udp", AdvertisedHost: "203.0.113.5"},
+					{Address: "192.168.1.5", Port: 5060},
+					{Address: "10.0.0.5", Port: 8088, Transport: "ws", AdvertisedHost: "203.0.113.5"},
+				}
+				return c
+			}(),
+			expectError: false,
+		},
+		{
+			name: "invalid listener transport",
+			config: func() *Config {
+				c := GetDefaultConfig()
+				c.Server.Listeners = []ListenerConfig{{Address: "10.0.0.5", Port: 5060, Transport: "sctp"}}
+				return c
+			}(),
+			expectError: true,
+			errorMsg:    "invalid transport for listener 0",
+		},
+		{
+			name: "TLS listener without certificate",
+			config: func() *Config {
+				c := GetDefaultConfig()
+				c.TLS.CertFile = ""
+				c.Server.Listeners = []ListenerConfig{{Address: "10.0.0.5", Port: 5061, Transport: "tls"}}
+				return c
+			}(),
+			expectError: true,
+			errorMsg:    "TLS certificate and key files are required for tls listener 0",
+		},
+		{
+			name: "invalid listener address",
+			config: func() *Config {
+				c := GetDefaultConfig()
+				c.Server.Listeners = []ListenerConfig{{Address: "sip.example.com", Port: 5060}}
+				return c
+			}(),
+			expectError: true,
+			errorMsg:    "invalid address for listener 0",
+		},
+		{
+			name: "invalid listener advertised port",
+			config: func() *Config {
+				c := GetDefaultConfig()
+				c.Server.Listeners = []ListenerConfig{{Port: 5060, AdvertisedPort: 70000}}
+				return c
+			}(),
+			expectError: true,
+			errorMsg:    "invalid advertised port for listener 0",
+		},
+		{
+			name: "satellite timers",
+			config: func() *Config {
+				c := GetDefaultConfig()
+				c.Timers.T1 = 2000
+				c.Timers.T2 = 16000
+				c.Timers.TimerB = 0
+				return c
+			}(),
+			expectError: false,
+		},
+		{
+			name: "negative timer",
+			config: func() *Config {
+				c := GetDefaultConfig()
+				c.Timers.TimerH = -1
+				return c
+			}(),
+			expectError: true,
+			errorMsg:    "invalid Timer H",
+		},
+		{
+			name: "T2 less than T1",
+			config: func() *Config {
+				c := GetDefaultConfig()
+				c.Timers.T1 = 5000
+				c.Timers.T2 = 4000
+				return c
+			}(),
+			expectError: true,
+			errorMsg:    "T2 (4000 ms) cannot be less than T1",
+		},
 		{
 			name: "empty database path",
 			config: func() *Config {
@@ -240,6 +326,58 @@ func TestManager_Validate(t *testing.T) {
 			expectError: true,
 			errorMsg:    "database path cannot be empty",
 		},
+		{
+			name: "memory driver without path",
+			config: func() *Config {
+				c := GetDefaultConfig()
+				c.Database.Driver = DatabaseDriverMemory
+				c.Database.Path = ""
+				return c
+			}(),
+			expectError: false,
+		},
+		{
+			name: "invalid database driver",
+			config: func() *Config {
+				c := GetDefaultConfig()
+				c.Database.Driver = "postgres"
+				return c
+			}(),
+			expectError: true,
+			errorMsg:    "invalid database driver",
+		},
+		{
+			name: "TLS without certificate",
+			config: func() *Config {
+				c := GetDefaultConfig()
+				c.TLS.Enabled = true
+				return c
+			}(),
+			expectError: true,
+			errorMsg:    "TLS certificate and key files are required",
+		},
+		{
+			name: "secure WebSocket without certificate",
+			config: func() *Config {
+				c := GetDefaultConfig()
+				c.WebSocket.Enabled = true
+				c.WebSocket.SecurePort = 8089
+				return c
+			}(),
+			expectError: true,
+			errorMsg:    "required for secure WebSocket",
+		},
+		{
+			name: "web admin port conflict with WebSocket",
+			config: func() *Config {
+				c := GetDefaultConfig()
+				c.WebSocket.Enabled = true
+				c.WebSocket.Port = c.WebAdmin.Port
+				return c
+			}(),
+			expectError: true,
+			errorMsg:    "conflicts with SIP WebSocket ports",
+		},
 		{
 			name: "short nonce expiry",
 			config: func() *Config {
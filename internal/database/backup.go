@@ -0,0 +1,132 @@
+package database
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Backup writes a consistent snapshot of the database to destPath while the
+// database stays online. destPath must not already exist.
+func (m *SQLiteManager) Backup(destPath string) error {
+	db, err := m.conn()
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(destPath); err == nil {
+		return fmt.Errorf("backup destination %s already exists", destPath)
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to check backup destination %s: %w", destPath, err)
+	}
+
+	// VACUUM INTO runs inside a read transaction, so writers are not blocked
+	// for longer than a normal read and the copy is transactionally consistent.
+	if _, err := db.Exec("VACUUM INTO ?", destPath); err != nil {
+		os.Remove(destPath)
+		return fmt.Errorf("failed to back up database to %s: %w", destPath, err)
+	}
+	return nil
+}
+
+// InspectBackup validates a backup file and returns its schema version.
+// The backup must pass an integrity check and must not be newer than the
+// schema this build understands.
+func InspectBackup(backupPath string) (int, error) {
+	if _, err := os.Stat(backupPath); err != nil {
+		return 0, fmt.Errorf("failed to open backup %s: %w", backupPath, err)
+	}
+
+	backup := NewSQLiteManager(backupPath)
+	if err := backup.Open(); err != nil {
+		return 0, err
+	}
+	defer backup.Close()
+
+	var integrity string
+	if err := backup.QueryRow("PRAGMA integrity_check", []interface{}{&integrity}); err != nil {
+		return 0, fmt.Errorf("failed to check integrity of backup %s: %w", backupPath, err)
+	}
+	if integrity != "ok" {
+		return 0, fmt.Errorf("backup %s failed integrity check: %s", backupPath, integrity)
+	}
+
+	migrator, err := backup.Migrator()
+	if err != nil {
+		return 0, err
+	}
+	version, err := migrator.Version()
+	if err != nil {
+		return 0, err
+	}
+	if version == 0 {
+		return 0, fmt.Errorf("backup %s has no schema version; not a sipserver database", backupPath)
+	}
+	if latest := LatestSchemaVersion(); version > latest {
+		return 0, fmt.Errorf("backup %s has schema version %d, newer than supported version %d", backupPath, version, latest)
+	}
+	return version, nil
+}
+
+// Restore replaces the database file at dbPath with the backup at backupPath.
+// The backup is validated first; the previous database, if any, is kept as
+// dbPath + ".pre-restore". The server must not be running against dbPath.
+func Restore(backupPath, dbPath string) (int, error) {
+	version, err := InspectBackup(backupPath)
+	if err != nil {
+		return 0, err
+	}
+
+	// Copy next to the target first so the final swap is an atomic rename
+	tmpPath := dbPath + ".restore-tmp"
+	if err := copyFile(backupPath, tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return 0, fmt.Errorf("failed to stage backup: %w", err)
+	}
+
+	if _, err := os.Stat(dbPath); err == nil {
+		if err := os.Rename(dbPath, dbPath+".pre-restore"); err != nil {
+			os.Remove(tmpPath)
+			return 0, fmt.Errorf("failed to preserve existing database: %w", err)
+		}
+	}
+
+	// A leftover write-ahead log would be replayed against the restored file
+	for _, suffix := range []string{"-wal", "-shm", "-journal"} {
+		os.Remove(dbPath + suffix)
+	}
+
+	if err := os.Rename(tmpPath, dbPath); err != nil {
+		os.Remove(tmpPath)
+		return 0, fmt.Errorf("failed to swap in restored database: %w", err)
+	}
+	return version, nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
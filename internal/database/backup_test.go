@@ -0,0 +1,114 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLiteManager_BackupAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "sipserver.db")
+	backupPath := filepath.Join(dir, "backup.db")
+
+	manager := NewSQLiteManager(dbPath)
+	if err := manager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	if err := manager.CreateUser(&User{Username: "alice", Realm: "example.com", PasswordHash: "hash", Enabled: true}); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	if err := manager.Backup(backupPath); err != nil {
+		t.Fatalf("Failed to back up database: %v", err)
+	}
+	if err := manager.Backup(backupPath); err == nil {
+		t.Error("Expected error when backup destination already exists")
+	}
+
+	// Changes after the snapshot must not appear in the restored database
+	if err := manager.CreateUser(&User{Username: "bob", Realm: "example.com", PasswordHash: "hash", Enabled: true}); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	manager.Close()
+
+	version, err := Restore(backupPath, dbPath)
+	if err != nil {
+		t.Fatalf("Failed to restore backup: %v", err)
+	}
+	if version != LatestSchemaVersion() {
+		t.Errorf("Expected restored schema version %d, got %d", LatestSchemaVersion(), version)
+	}
+	if _, err := os.Stat(dbPath + ".pre-restore"); err != nil {
+		t.Errorf("Expected previous database to be preserved: %v", err)
+	}
+
+	restored := NewSQLiteManager(dbPath)
+	if err := restored.Initialize(); err != nil {
+		t.Fatalf("Failed to open restored database: %v", err)
+	}
+	defer restored.Close()
+
+	if _, err := restored.GetUser("alice", "example.com"); err != nil {
+		t.Errorf("Expected alice in restored database, got %v", err)
+	}
+	if _, err := restored.GetUser("bob", "example.com"); err != ErrNotFound {
+		t.Errorf("Expected bob to be absent from restored database, got %v", err)
+	}
+}
+
+func TestInspectBackup_RejectsInvalidBackups(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := InspectBackup(filepath.Join(dir, "missing.db")); err == nil {
+		t.Error("Expected error for missing backup file")
+	}
+
+	garbage := filepath.Join(dir, "garbage.db")
+	if err := os.WriteFile(garbage, []byte("this is not a database"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := InspectBackup(garbage); err == nil {
+		t.Error("Expected error for non-database file")
+	}
+
+	// A SQLite file without schema versioning is not a sipserver backup
+	unversioned := NewSQLiteManager(filepath.Join(dir, "unversioned.db"))
+	if err := unversioned.Open(); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := unversioned.Exec("CREATE TABLE other (id INTEGER)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	unversioned.Close()
+	if _, err := InspectBackup(filepath.Join(dir, "unversioned.db")); err == nil {
+		t.Error("Expected error for database without schema version")
+	}
+
+	newer := NewSQLiteManager(filepath.Join(dir, "newer.db"))
+	if err := newer.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	if err := newer.Exec(
+		"INSERT INTO schema_migrations (version, description, applied_at) VALUES (?, ?, CURRENT_TIMESTAMP)",
+		LatestSchemaVersion()+1, "from the future",
+	); err != nil {
+		t.Fatalf("Failed to insert future version: %v", err)
+	}
+	newer.Close()
+	if _, err := InspectBackup(filepath.Join(dir, "newer.db")); err == nil {
+		t.Error("Expected error for backup with newer schema version")
+	}
+
+	// A rejected restore must leave the target untouched
+	target := filepath.Join(dir, "target.db")
+	if err := os.WriteFile(target, []byte("original"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := Restore(garbage, target); err == nil {
+		t.Error("Expected restore of invalid backup to fail")
+	}
+	if data, _ := os.ReadFile(target); string(data) != "original" {
+		t.Error("Target database was modified by a rejected restore")
+	}
+}
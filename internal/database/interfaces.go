@@ -0,0 +1,172 @@
+package database
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when a requested record does not exist
+	ErrNotFound = errors.New("record not found")
+	// ErrDuplicateUser is returned when a user with the same username and realm already exists
+	ErrDuplicateUser = errors.New("user already exists")
+	// ErrDuplicateExtension is returned when a hunt group extension is already in use
+	ErrDuplicateExtension = errors.New("hunt group extension already exists")
+)
+
+// User represents a SIP user account
+type User struct {
+	ID           int64
+	Username     string
+	Realm        string
+	PasswordHash string
+	Enabled      bool
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// Contact represents a registered contact as stored in the contacts table
+type Contact struct {
+	ID         int64
+	AOR        string
+	URI        string
+	Expires    time.Time
+	CallID     string
+	CSeq       uint32
+	Source     string // Address the binding was registered from, empty if unknown
+	Transport  string // Transport the binding was registered over, empty if unknown
+	BehindNAT  bool   // Whether URI names an IP address other than Source
+	InstanceID string // SIP Outbound +sip.instance, empty for ordinary bindings
+	RegID      int    // SIP Outbound reg-id, 0 for ordinary bindings
+	CreatedAt  time.Time
+}
+
+// RegistrarContact represents a contact binding as seen by the registrar
+type RegistrarContact struct {
+	AOR       string
+	URI       string
+	Expires   time.Time
+	CallID    string
+	CSeq      uint32
+	Source    string // Address the REGISTER arrived from; WebSocket contacts are only reachable there
+	Transport string // Transport the REGISTER arrived over
+	BehindNAT bool   // Whether URI names an IP address other than Source, so requests go to Source instead
+	// InstanceID and RegID identify a SIP Outbound flow (RFC 5626). RegID is
+	// 0 for ordinary bindings; outbound bindings are only reachable over the
+	// flow they were registered on.
+	InstanceID string
+	RegID      int
+}
+
+// HuntGroup represents a hunt group as stored in the database
+type HuntGroup struct {
+	ID          string
+	Name        string
+	Extension   string
+	Description string
+	Strategy    string
+	Timeout     int
+	Enabled     bool
+	Members     []HuntGroupMember
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// HuntGroupMember represents a member of a hunt group as stored in the database
+type HuntGroupMember struct {
+	ID          string
+	URI         string
+	DisplayName string
+	Priority    int
+	Timeout     int
+	Enabled     bool
+}
+
+// HuntGroupCall represents a call delivered to a hunt group, kept for statistics
+type HuntGroupCall struct {
+	ID          string
+	HuntGroupID string
+	SessionID   string
+	CallerURI   string
+	CallerName  string
+	Status      string
+	AnsweredBy  string
+	AnsweredAt  *time.Time
+	Duration    int // seconds
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// Result summarizes an executed statement
+type Result interface {
+	LastInsertId() (int64, error)
+	RowsAffected() (int64, error)
+}
+
+// Rows is the result set of a query
+type Rows interface {
+	Next() bool
+	Scan(dest ...interface{}) error
+	Err() error
+	Close() error
+}
+
+// DatabaseManager defines the interface for the persistent storage backend
+type DatabaseManager interface {
+	Initialize() error
+	Close() error
+
+	// User operations
+	CreateUser(user *User) error
+	GetUser(username, realm string) (*User, error)
+	UpdateUser(user *User) error
+	DeleteUser(username, realm string) error
+	ListUsers() ([]*User, error)
+
+	// Contact operations
+	StoreContact(contact *Contact) error
+	RetrieveContacts(aor string, now time.Time) ([]*Contact, error)
+	DeleteContact(aor string, contactURI string) error
+	DeleteFlowContacts(transport, source string) (int, error)
+	CleanupExpiredContacts(now time.Time) error
+
+	// Hunt group operations
+	CreateHuntGroup(huntGroup *HuntGroup) error
+	GetHuntGroup(id string) (*HuntGroup, error)
+	GetHuntGroupByExtension(extension string) (*HuntGroup, error)
+	UpdateHuntGroup(huntGroup *HuntGroup) error
+	DeleteHuntGroup(id string) error
+	ListHuntGroups() ([]*HuntGroup, error)
+
+	// Hunt group call operations
+	CreateHuntGroupCall(call *HuntGroupCall) error
+	GetHuntGroupCall(id string) (*HuntGroupCall, error)
+	UpdateHuntGroupCall(call *HuntGroupCall) error
+	ListHuntGroupCalls(huntGroupID string) ([]*HuntGroupCall, error)
+
+	// Raw query operations
+	Exec(query string, args ...interface{}) error
+	ExecWithResult(query string, args ...interface{}) (Result, error)
+	Query(query string, args ...interface{}) (Rows, error)
+	QueryRow(query string, dest []interface{}, args ...interface{}) error
+}
+
+// UserManager defines the interface for user account management
+type UserManager interface {
+	CreateUser(username, realm, password string) error
+	AuthenticateUser(username, realm, password string) bool
+	UpdatePassword(username, realm, newPassword string) error
+	DeleteUser(username, realm string) error
+	ListUsers() ([]*User, error)
+	GeneratePasswordHash(username, realm, password string) string
+	GetUser(username, realm string) (*User, error)
+}
+
+// RegistrationDB defines the interface for registrar contact storage
+type RegistrationDB interface {
+	Store(contact *RegistrarContact) error
+	Retrieve(aor string, now time.Time) ([]*RegistrarContact, error)
+	Delete(aor string, contactURI string) error
+	DeleteFlow(transport, source string) (int, error)
+	CleanupExpired(now time.Time) error
+}
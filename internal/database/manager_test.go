@@ -0,0 +1,372 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// runDatabaseManagerTests exercises behavior every DatabaseManager backend must share
+func runDatabaseManagerTests(t *testing.T, newManager func(t *testing.T) DatabaseManager) {
+	t.Run("UserCRUD", func(t *testing.T) { testUserCRUD(t, newManager(t)) })
+	t.Run("Contacts", func(t *testing.T) { testContacts(t, newManager(t)) })
+	t.Run("OutboundContacts", func(t *testing.T) { testOutboundContacts(t, newManager(t)) })
+	t.Run("HuntGroups", func(t *testing.T) { testHuntGroups(t, newManager(t)) })
+	t.Run("HuntGroupCalls", func(t *testing.T) { testHuntGroupCalls(t, newManager(t)) })
+}
+
+func testUserCRUD(t *testing.T, manager DatabaseManager) {
+
+	user := &User{Username: "alice", Realm: "example.com", PasswordHash: "hash1", Enabled: true}
+	if err := manager.CreateUser(user); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	if user.ID == 0 {
+		t.Error("Expected user ID to be assigned")
+	}
+
+	duplicate := &User{Username: "alice", Realm: "example.com", PasswordHash: "hash2", Enabled: true}
+	if err := manager.CreateUser(duplicate); !errors.Is(err, ErrDuplicateUser) {
+		t.Errorf("Expected ErrDuplicateUser, got %v", err)
+	}
+
+	// Same username in another realm is a different user
+	other := &User{Username: "alice", Realm: "other.com", PasswordHash: "hash3", Enabled: true}
+	if err := manager.CreateUser(other); err != nil {
+		t.Fatalf("Failed to create user in other realm: %v", err)
+	}
+
+	retrieved, err := manager.GetUser("alice", "example.com")
+	if err != nil {
+		t.Fatalf("Failed to get user: %v", err)
+	}
+	if retrieved.PasswordHash != "hash1" || !retrieved.Enabled {
+		t.Errorf("Unexpected user: %+v", retrieved)
+	}
+	if retrieved.CreatedAt.IsZero() {
+		t.Error("Expected CreatedAt to be set")
+	}
+
+	retrieved.PasswordHash = "updated"
+	retrieved.Enabled = false
+	if err := manager.UpdateUser(retrieved); err != nil {
+		t.Fatalf("Failed to update user: %v", err)
+	}
+
+	updated, err := manager.GetUser("alice", "example.com")
+	if err != nil {
+		t.Fatalf("Failed to get updated user: %v", err)
+	}
+	if updated.PasswordHash != "updated" || updated.Enabled {
+		t.Errorf("Update not persisted: %+v", updated)
+	}
+
+	users, err := manager.ListUsers()
+	if err != nil {
+		t.Fatalf("Failed to list users: %v", err)
+	}
+	if len(users) != 2 {
+		t.Errorf("Expected 2 users, got %d", len(users))
+	}
+
+	if err := manager.DeleteUser("alice", "example.com"); err != nil {
+		t.Fatalf("Failed to delete user: %v", err)
+	}
+	if _, err := manager.GetUser("alice", "example.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after delete, got %v", err)
+	}
+	if err := manager.DeleteUser("alice", "example.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting missing user, got %v", err)
+	}
+	if err := manager.UpdateUser(&User{Username: "bob", Realm: "example.com"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound updating missing user, got %v", err)
+	}
+}
+
+func testContacts(t *testing.T, manager DatabaseManager) {
+	aor := "sip:alice@example.com"
+
+	contact := &Contact{
+		AOR:     aor,
+		URI:     "sip:alice@192.168.1.100:5060",
+		Expires: time.Now().UTC().Add(time.Hour),
+		CallID:  "call-1",
+		CSeq:    1,
+	}
+	if err := manager.StoreContact(contact); err != nil {
+		t.Fatalf("Failed to store contact: %v", err)
+	}
+
+	// Storing the same binding again refreshes it instead of duplicating it
+	refreshed := *contact
+	refreshed.CSeq = 2
+	refreshed.Source = "203.0.113.5:49152"
+	refreshed.Transport = "UDP"
+	refreshed.BehindNAT = true
+	if err := manager.StoreContact(&refreshed); err != nil {
+		t.Fatalf("Failed to refresh contact: %v", err)
+	}
+
+	expired := &Contact{
+		AOR:     aor,
+		URI:     "sip:alice@192.168.1.101:5060",
+		Expires: time.Now().UTC().Add(-time.Minute),
+		CallID:  "call-2",
+		CSeq:    1,
+	}
+	if err := manager.StoreContact(expired); err != nil {
+		t.Fatalf("Failed to store expired contact: %v", err)
+	}
+
+	contacts, err := manager.RetrieveContacts(aor, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to retrieve contacts: %v", err)
+	}
+	if len(contacts) != 1 {
+		t.Fatalf("Expected 1 unexpired contact, got %d", len(contacts))
+	}
+	if contacts[0].CSeq != 2 {
+		t.Errorf("Expected refreshed CSeq 2, got %d", contacts[0].CSeq)
+	}
+	if contacts[0].Source != refreshed.Source {
+		t.Errorf("Expected refreshed source %s, got %s", refreshed.Source, contacts[0].Source)
+	}
+	if contacts[0].Transport != "UDP" || !contacts[0].BehindNAT {
+		t.Errorf("Expected refreshed flow UDP behind NAT, got %s %v", contacts[0].Transport, contacts[0].BehindNAT)
+	}
+
+	// Expiry is judged by the caller's clock, not the system clock
+	later, err := manager.RetrieveContacts(aor, contacts[0].Expires.Add(time.Second))
+	if err != nil {
+		t.Fatalf("Failed to retrieve contacts: %v", err)
+	}
+	if len(later) != 0 {
+		t.Errorf("Expected no contacts after their expiry, got %d", len(later))
+	}
+
+	if err := manager.CleanupExpiredContacts(time.Now()); err != nil {
+		t.Fatalf("Failed to cleanup expired contacts: %v", err)
+	}
+	if err := manager.DeleteContact(aor, expired.URI); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected expired contact to be cleaned up, got %v", err)
+	}
+
+	if err := manager.DeleteContact(aor, contact.URI); err != nil {
+		t.Fatalf("Failed to delete contact: %v", err)
+	}
+	contacts, err = manager.RetrieveContacts(aor, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to retrieve contacts: %v", err)
+	}
+	if len(contacts) != 0 {
+		t.Errorf("Expected no contacts after delete, got %d", len(contacts))
+	}
+}
+
+func testOutboundContacts(t *testing.T, manager DatabaseManager) {
+	aor := "sip:alice@example.com"
+	instance := "urn:uuid:00000000-0000-1000-8000-AABBCCDDEEFF"
+	flow := func(uri string, regID int, source string) *Contact {
+		return &Contact{
+			AOR:        aor,
+			URI:        uri,
+			Expires:    time.Now().UTC().Add(time.Hour),
+			CallID:     "call-1",
+			CSeq:       1,
+			Source:     source,
+			Transport:  "TCP",
+			InstanceID: instance,
+			RegID:      regID,
+		}
+	}
+
+	// Two flows of one instance share a Contact URI and are told apart by reg-id
+	for _, contact := range []*Contact{
+		flow("sip:alice@192.168.1.100:5060;ob", 1, "203.0.113.5:49152"),
+		flow("sip:alice@192.168.1.100:5060;ob", 2, "198.51.100.7:50000"),
+	} {
+		if err := manager.StoreContact(contact); err != nil {
+			t.Fatalf("Failed to store flow: %v", err)
+		}
+	}
+	contacts, err := manager.RetrieveContacts(aor, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to retrieve contacts: %v", err)
+	}
+	if len(contacts) != 2 {
+		t.Fatalf("Expected 2 flows, got %d", len(contacts))
+	}
+	if contacts[0].InstanceID != instance || contacts[0].RegID != 1 || contacts[1].RegID != 2 {
+		t.Errorf("Unexpected flows: %+v %+v", contacts[0], contacts[1])
+	}
+
+	// Re-registering reg-id 1 from a new address replaces that flow
+	if err := manager.StoreContact(flow("sip:alice@10.0.0.9:5060;ob", 1, "203.0.113.9:40000")); err != nil {
+		t.Fatalf("Failed to store replacement flow: %v", err)
+	}
+	contacts, err = manager.RetrieveContacts(aor, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to retrieve contacts: %v", err)
+	}
+	if len(contacts) != 2 {
+		t.Fatalf("Expected replaced flow not to add a binding, got %d", len(contacts))
+	}
+
+	removed, err := manager.DeleteFlowContacts("TCP", "198.51.100.7:50000")
+	if err != nil {
+		t.Fatalf("Failed to delete flow contacts: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("Expected 1 flow contact removed, got %d", removed)
+	}
+	contacts, err = manager.RetrieveContacts(aor, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to retrieve contacts: %v", err)
+	}
+	if len(contacts) != 1 || contacts[0].Source != "203.0.113.9:40000" {
+		t.Errorf("Expected only the replacement flow to remain, got %+v", contacts)
+	}
+
+	// Ordinary bindings are not tied to their flow
+	ordinary := flow("sip:alice@192.168.1.101:5060", 0, "203.0.113.9:40000")
+	ordinary.InstanceID = ""
+	if err := manager.StoreContact(ordinary); err != nil {
+		t.Fatalf("Failed to store contact: %v", err)
+	}
+	if removed, err := manager.DeleteFlowContacts("TCP", "203.0.113.9:40000"); err != nil || removed != 1 {
+		t.Errorf("Expected only the outbound binding removed, got %d %v", removed, err)
+	}
+	if contacts, _ := manager.RetrieveContacts(aor, time.Now()); len(contacts) != 1 || contacts[0].URI != ordinary.URI {
+		t.Errorf("Expected the ordinary binding to remain, got %+v", contacts)
+	}
+}
+
+func testHuntGroups(t *testing.T, manager DatabaseManager) {
+
+	group := &HuntGroup{
+		ID:        "1",
+		Name:      "Sales",
+		Extension: "100",
+		Strategy:  "sequential",
+		Timeout:   30,
+		Enabled:   true,
+		Members: []HuntGroupMember{
+			{ID: "1", URI: "101", Priority: 1, Timeout: 20, Enabled: true},
+			{ID: "2", URI: "102", Priority: 2, Timeout: 20, Enabled: false},
+		},
+	}
+	if err := manager.CreateHuntGroup(group); err != nil {
+		t.Fatalf("Failed to create hunt group: %v", err)
+	}
+
+	conflicting := &HuntGroup{ID: "2", Name: "Support", Extension: "100", Strategy: "parallel", Timeout: 30}
+	if err := manager.CreateHuntGroup(conflicting); !errors.Is(err, ErrDuplicateExtension) {
+		t.Errorf("Expected ErrDuplicateExtension, got %v", err)
+	}
+
+	byExtension, err := manager.GetHuntGroupByExtension("100")
+	if err != nil {
+		t.Fatalf("Failed to get hunt group by extension: %v", err)
+	}
+	if byExtension.ID != "1" || len(byExtension.Members) != 2 {
+		t.Fatalf("Unexpected hunt group: %+v", byExtension)
+	}
+	if byExtension.Members[0].URI != "101" || byExtension.Members[1].Enabled {
+		t.Errorf("Members not round-tripped in order: %+v", byExtension.Members)
+	}
+
+	byExtension.Members = byExtension.Members[:1]
+	byExtension.Name = "Sales Team"
+	if err := manager.UpdateHuntGroup(byExtension); err != nil {
+		t.Fatalf("Failed to update hunt group: %v", err)
+	}
+
+	updated, err := manager.GetHuntGroup("1")
+	if err != nil {
+		t.Fatalf("Failed to get hunt group: %v", err)
+	}
+	if updated.Name != "Sales Team" || len(updated.Members) != 1 {
+		t.Errorf("Update not persisted: %+v", updated)
+	}
+
+	other := &HuntGroup{ID: "2", Name: "Support", Extension: "200", Strategy: "parallel", Timeout: 30}
+	if err := manager.CreateHuntGroup(other); err != nil {
+		t.Fatalf("Failed to create second hunt group: %v", err)
+	}
+	other.Extension = "100"
+	if err := manager.UpdateHuntGroup(other); !errors.Is(err, ErrDuplicateExtension) {
+		t.Errorf("Expected ErrDuplicateExtension on update, got %v", err)
+	}
+
+	groups, err := manager.ListHuntGroups()
+	if err != nil {
+		t.Fatalf("Failed to list hunt groups: %v", err)
+	}
+	if len(groups) != 2 {
+		t.Errorf("Expected 2 hunt groups, got %d", len(groups))
+	}
+
+	if err := manager.DeleteHuntGroup("2"); err != nil {
+		t.Fatalf("Failed to delete hunt group: %v", err)
+	}
+	if _, err := manager.GetHuntGroup("2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after delete, got %v", err)
+	}
+	if err := manager.DeleteHuntGroup("2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting missing hunt group, got %v", err)
+	}
+}
+
+func testHuntGroupCalls(t *testing.T, manager DatabaseManager) {
+
+	group := &HuntGroup{ID: "1", Name: "Sales", Extension: "100", Strategy: "parallel", Timeout: 30, Enabled: true}
+	if err := manager.CreateHuntGroup(group); err != nil {
+		t.Fatalf("Failed to create hunt group: %v", err)
+	}
+
+	orphan := &HuntGroupCall{ID: "call-0", HuntGroupID: "99", SessionID: "call-0", CallerURI: "sip:bob@example.com", Status: "ringing"}
+	if err := manager.CreateHuntGroupCall(orphan); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for unknown hunt group, got %v", err)
+	}
+
+	call := &HuntGroupCall{ID: "call-1", HuntGroupID: "1", SessionID: "call-1", CallerURI: "sip:bob@example.com", Status: "ringing"}
+	if err := manager.CreateHuntGroupCall(call); err != nil {
+		t.Fatalf("Failed to create hunt group call: %v", err)
+	}
+
+	answeredAt := time.Now().UTC()
+	call.Status = "answered"
+	call.AnsweredBy = "101"
+	call.AnsweredAt = &answeredAt
+	call.Duration = 42
+	if err := manager.UpdateHuntGroupCall(call); err != nil {
+		t.Fatalf("Failed to update hunt group call: %v", err)
+	}
+
+	retrieved, err := manager.GetHuntGroupCall("call-1")
+	if err != nil {
+		t.Fatalf("Failed to get hunt group call: %v", err)
+	}
+	if retrieved.Status != "answered" || retrieved.AnsweredBy != "101" || retrieved.Duration != 42 {
+		t.Errorf("Unexpected call: %+v", retrieved)
+	}
+	if retrieved.AnsweredAt == nil || !retrieved.AnsweredAt.Equal(answeredAt) {
+		t.Errorf("Expected AnsweredAt %v, got %v", answeredAt, retrieved.AnsweredAt)
+	}
+
+	calls, err := manager.ListHuntGroupCalls("1")
+	if err != nil {
+		t.Fatalf("Failed to list hunt group calls: %v", err)
+	}
+	if len(calls) != 1 {
+		t.Errorf("Expected 1 call, got %d", len(calls))
+	}
+
+	// Deleting the hunt group removes its call records
+	if err := manager.DeleteHuntGroup("1"); err != nil {
+		t.Fatalf("Failed to delete hunt group: %v", err)
+	}
+	if _, err := manager.GetHuntGroupCall("call-1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after hunt group delete, got %v", err)
+	}
+}
@@ -0,0 +1,529 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrNotSupported is returned for raw SQL operations on backends without SQL support
+var ErrNotSupported = errors.New("operation not supported by this database backend")
+
+// MemoryManager implements the DatabaseManager interface with in-process maps.
+// It mirrors the SQLite backend's semantics but keeps nothing on disk, which
+// makes it suitable for tests and ephemeral nodes.
+type MemoryManager struct {
+	users         map[string]*User
+	contacts      map[string]map[string]*Contact
+	huntGroups    map[string]*HuntGroup
+	calls         map[string]*HuntGroupCall
+	nextUserID    int64
+	nextContactID int64
+	mu            sync.RWMutex
+}
+
+// NewMemoryManager creates a new in-memory database manager
+func NewMemoryManager() *MemoryManager {
+	return &MemoryManager{
+		users:         make(map[string]*User),
+		contacts:      make(map[string]map[string]*Contact),
+		huntGroups:    make(map[string]*HuntGroup),
+		calls:         make(map[string]*HuntGroupCall),
+		nextUserID:    1,
+		nextContactID: 1,
+	}
+}
+
+// Initialize is a no-op for the in-memory backend
+func (m *MemoryManager) Initialize() error {
+	return nil
+}
+
+// Close is a no-op for the in-memory backend; stored data is kept
+func (m *MemoryManager) Close() error {
+	return nil
+}
+
+// User operations
+
+// CreateUser inserts a new user
+func (m *MemoryManager) CreateUser(user *User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := userKey(user.Username, user.Realm)
+	if _, exists := m.users[key]; exists {
+		return ErrDuplicateUser
+	}
+
+	now := time.Now().UTC()
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = now
+	}
+	if user.UpdatedAt.IsZero() {
+		user.UpdatedAt = now
+	}
+	user.ID = m.nextUserID
+	m.nextUserID++
+
+	stored := *user
+	stored.CreatedAt = stored.CreatedAt.UTC()
+	stored.UpdatedAt = stored.UpdatedAt.UTC()
+	m.users[key] = &stored
+	return nil
+}
+
+// GetUser retrieves a user by username and realm
+func (m *MemoryManager) GetUser(username, realm string) (*User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, exists := m.users[userKey(username, realm)]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	result := *user
+	return &result, nil
+}
+
+// UpdateUser updates the password hash and enabled flag of an existing user
+func (m *MemoryManager) UpdateUser(user *User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, exists := m.users[userKey(user.Username, user.Realm)]
+	if !exists {
+		return ErrNotFound
+	}
+
+	user.UpdatedAt = time.Now().UTC()
+	stored.PasswordHash = user.PasswordHash
+	stored.Enabled = user.Enabled
+	stored.UpdatedAt = user.UpdatedAt
+	return nil
+}
+
+// DeleteUser deletes a user by username and realm
+func (m *MemoryManager) DeleteUser(username, realm string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := userKey(username, realm)
+	if _, exists := m.users[key]; !exists {
+		return ErrNotFound
+	}
+	delete(m.users, key)
+	return nil
+}
+
+// ListUsers returns all users ordered by realm and username
+func (m *MemoryManager) ListUsers() ([]*User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	users := make([]*User, 0, len(m.users))
+	for _, user := range m.users {
+		result := *user
+		users = append(users, &result)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].Realm != users[j].Realm {
+			return users[i].Realm < users[j].Realm
+		}
+		return users[i].Username < users[j].Username
+	})
+	return users, nil
+}
+
+// Contact operations
+
+// StoreContact inserts a contact or replaces the existing binding for the same
+// AOR and URI. A SIP Outbound binding also replaces any earlier binding of the
+// same instance and reg-id, since it describes the same flow.
+func (m *MemoryManager) StoreContact(contact *Contact) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if contact.CreatedAt.IsZero() {
+		contact.CreatedAt = time.Now().UTC()
+	}
+
+	bindings, exists := m.contacts[contact.AOR]
+	if !exists {
+		bindings = make(map[string]*Contact)
+		m.contacts[contact.AOR] = bindings
+	}
+
+	key := contactKey(contact)
+	if contact.RegID > 0 {
+		for k, stored := range bindings {
+			if k != key && stored.InstanceID == contact.InstanceID && stored.RegID == contact.RegID {
+				delete(bindings, k)
+			}
+		}
+	}
+
+	if stored, exists := bindings[key]; exists {
+		stored.Expires = contact.Expires.UTC()
+		stored.CallID = contact.CallID
+		stored.CSeq = contact.CSeq
+		stored.Source = contact.Source
+		stored.Transport = contact.Transport
+		stored.BehindNAT = contact.BehindNAT
+		return nil
+	}
+
+	stored := *contact
+	stored.ID = m.nextContactID
+	stored.Expires = stored.Expires.UTC()
+	stored.CreatedAt = stored.CreatedAt.UTC()
+	m.nextContactID++
+	bindings[key] = &stored
+	return nil
+}
+
+// RetrieveContacts returns the contacts for an AOR that have not expired by now
+func (m *MemoryManager) RetrieveContacts(aor string, now time.Time) ([]*Contact, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var contacts []*Contact
+	for _, contact := range m.contacts[aor] {
+		if contact.Expires.After(now) {
+			result := *contact
+			contacts = append(contacts, &result)
+		}
+	}
+	sort.Slice(contacts, func(i, j int) bool {
+		return contacts[i].ID < contacts[j].ID
+	})
+	return contacts, nil
+}
+
+// DeleteContact removes the bindings of a contact URI
+func (m *MemoryManager) DeleteContact(aor string, contactURI string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bindings := m.contacts[aor]
+	found := false
+	for key, contact := range bindings {
+		if contact.URI == contactURI {
+			delete(bindings, key)
+			found = true
+		}
+	}
+	if !found {
+		return ErrNotFound
+	}
+	if len(bindings) == 0 {
+		delete(m.contacts, aor)
+	}
+	return nil
+}
+
+// DeleteFlowContacts removes the SIP Outbound bindings registered over the flow
+// from source on transport and returns how many were removed
+func (m *MemoryManager) DeleteFlowContacts(transport, source string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	removed := 0
+	for aor, bindings := range m.contacts {
+		for key, contact := range bindings {
+			if contact.RegID > 0 && contact.Transport == transport && contact.Source == source {
+				delete(bindings, key)
+				removed++
+			}
+		}
+		if len(bindings) == 0 {
+			delete(m.contacts, aor)
+		}
+	}
+	return removed, nil
+}
+
+// CleanupExpiredContacts removes the contact bindings that have expired by now
+func (m *MemoryManager) CleanupExpiredContacts(now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for aor, bindings := range m.contacts {
+		for key, contact := range bindings {
+			if !contact.Expires.After(now) {
+				delete(bindings, key)
+			}
+		}
+		if len(bindings) == 0 {
+			delete(m.contacts, aor)
+		}
+	}
+	return nil
+}
+
+// contactKey identifies a binding within an AOR the way the contacts table's
+// unique constraint does
+func contactKey(contact *Contact) string {
+	return contact.URI + "\x00" + contact.InstanceID + "\x00" + strconv.Itoa(contact.RegID)
+}
+
+// Hunt group operations
+
+// CreateHuntGroup inserts a new hunt group together with its members
+func (m *MemoryManager) CreateHuntGroup(huntGroup *HuntGroup) error {
+	if huntGroup.ID == "" {
+		return fmt.Errorf("hunt group ID cannot be empty")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkExtensionAvailable(huntGroup.Extension, huntGroup.ID); err != nil {
+		return err
+	}
+	if _, exists := m.huntGroups[huntGroup.ID]; exists {
+		return fmt.Errorf("hunt group %s already exists", huntGroup.ID)
+	}
+	if err := checkMemberIDs(huntGroup.Members); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	if huntGroup.CreatedAt.IsZero() {
+		huntGroup.CreatedAt = now
+	}
+	if huntGroup.UpdatedAt.IsZero() {
+		huntGroup.UpdatedAt = now
+	}
+
+	m.huntGroups[huntGroup.ID] = copyHuntGroup(huntGroup)
+	return nil
+}
+
+// GetHuntGroup retrieves a hunt group by ID
+func (m *MemoryManager) GetHuntGroup(id string) (*HuntGroup, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	huntGroup, exists := m.huntGroups[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return copyHuntGroup(huntGroup), nil
+}
+
+// GetHuntGroupByExtension retrieves a hunt group by extension
+func (m *MemoryManager) GetHuntGroupByExtension(extension string) (*HuntGroup, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, huntGroup := range m.huntGroups {
+		if huntGroup.Extension == extension {
+			return copyHuntGroup(huntGroup), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// UpdateHuntGroup updates an existing hunt group and replaces its members
+func (m *MemoryManager) UpdateHuntGroup(huntGroup *HuntGroup) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkExtensionAvailable(huntGroup.Extension, huntGroup.ID); err != nil {
+		return err
+	}
+	stored, exists := m.huntGroups[huntGroup.ID]
+	if !exists {
+		return ErrNotFound
+	}
+	if err := checkMemberIDs(huntGroup.Members); err != nil {
+		return err
+	}
+
+	huntGroup.UpdatedAt = time.Now().UTC()
+
+	updated := copyHuntGroup(huntGroup)
+	updated.CreatedAt = stored.CreatedAt
+	m.huntGroups[huntGroup.ID] = updated
+	return nil
+}
+
+// DeleteHuntGroup deletes a hunt group along with its call records
+func (m *MemoryManager) DeleteHuntGroup(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.huntGroups[id]; !exists {
+		return ErrNotFound
+	}
+	delete(m.huntGroups, id)
+
+	for callID, call := range m.calls {
+		if call.HuntGroupID == id {
+			delete(m.calls, callID)
+		}
+	}
+	return nil
+}
+
+// ListHuntGroups returns all hunt groups ordered by extension
+func (m *MemoryManager) ListHuntGroups() ([]*HuntGroup, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var huntGroups []*HuntGroup
+	for _, huntGroup := range m.huntGroups {
+		huntGroups = append(huntGroups, copyHuntGroup(huntGroup))
+	}
+	sort.Slice(huntGroups, func(i, j int) bool {
+		return huntGroups[i].Extension < huntGroups[j].Extension
+	})
+	return huntGroups, nil
+}
+
+// Hunt group call operations
+
+// CreateHuntGroupCall records a new call for a hunt group
+func (m *MemoryManager) CreateHuntGroupCall(call *HuntGroupCall) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.huntGroups[call.HuntGroupID]; !exists {
+		return ErrNotFound
+	}
+	if _, exists := m.calls[call.ID]; exists {
+		return fmt.Errorf("hunt group call %s already exists", call.ID)
+	}
+
+	now := time.Now().UTC()
+	if call.CreatedAt.IsZero() {
+		call.CreatedAt = now
+	}
+	if call.UpdatedAt.IsZero() {
+		call.UpdatedAt = now
+	}
+
+	m.calls[call.ID] = copyHuntGroupCall(call)
+	return nil
+}
+
+// GetHuntGroupCall retrieves a hunt group call by ID
+func (m *MemoryManager) GetHuntGroupCall(id string) (*HuntGroupCall, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	call, exists := m.calls[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return copyHuntGroupCall(call), nil
+}
+
+// UpdateHuntGroupCall updates the outcome of a hunt group call
+func (m *MemoryManager) UpdateHuntGroupCall(call *HuntGroupCall) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, exists := m.calls[call.ID]
+	if !exists {
+		return ErrNotFound
+	}
+
+	call.UpdatedAt = time.Now().UTC()
+
+	updated := copyHuntGroupCall(call)
+	stored.Status = updated.Status
+	stored.AnsweredBy = updated.AnsweredBy
+	stored.AnsweredAt = updated.AnsweredAt
+	stored.Duration = updated.Duration
+	stored.UpdatedAt = updated.UpdatedAt
+	return nil
+}
+
+// ListHuntGroupCalls returns all calls recorded for a hunt group, oldest first
+func (m *MemoryManager) ListHuntGroupCalls(huntGroupID string) ([]*HuntGroupCall, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var calls []*HuntGroupCall
+	for _, call := range m.calls {
+		if call.HuntGroupID == huntGroupID {
+			calls = append(calls, copyHuntGroupCall(call))
+		}
+	}
+	sort.Slice(calls, func(i, j int) bool {
+		return calls[i].CreatedAt.Before(calls[j].CreatedAt)
+	})
+	return calls, nil
+}
+
+// Raw query operations are not available without a SQL engine
+
+// Exec returns ErrNotSupported
+func (m *MemoryManager) Exec(query string, args ...interface{}) error {
+	return ErrNotSupported
+}
+
+// ExecWithResult returns ErrNotSupported
+func (m *MemoryManager) ExecWithResult(query string, args ...interface{}) (Result, error) {
+	return nil, ErrNotSupported
+}
+
+// Query returns ErrNotSupported
+func (m *MemoryManager) Query(query string, args ...interface{}) (Rows, error) {
+	return nil, ErrNotSupported
+}
+
+// QueryRow returns ErrNotSupported
+func (m *MemoryManager) QueryRow(query string, dest []interface{}, args ...interface{}) error {
+	return ErrNotSupported
+}
+
+// Helper functions
+
+func userKey(username, realm string) string {
+	return username + "@" + realm
+}
+
+func (m *MemoryManager) checkExtensionAvailable(extension, huntGroupID string) error {
+	for id, huntGroup := range m.huntGroups {
+		if id != huntGroupID && huntGroup.Extension == extension {
+			return ErrDuplicateExtension
+		}
+	}
+	return nil
+}
+
+func checkMemberIDs(members []HuntGroupMember) error {
+	seen := make(map[string]bool, len(members))
+	for _, member := range members {
+		if seen[member.ID] {
+			return fmt.Errorf("duplicate hunt group member ID %s", member.ID)
+		}
+		seen[member.ID] = true
+	}
+	return nil
+}
+
+func copyHuntGroup(huntGroup *HuntGroup) *HuntGroup {
+	result := *huntGroup
+	result.Members = append([]HuntGroupMember{}, huntGroup.Members...)
+	result.CreatedAt = result.CreatedAt.UTC()
+	result.UpdatedAt = result.UpdatedAt.UTC()
+	return &result
+}
+
+func copyHuntGroupCall(call *HuntGroupCall) *HuntGroupCall {
+	result := *call
+	if call.AnsweredAt != nil {
+		answeredAt := call.AnsweredAt.UTC()
+		result.AnsweredAt = &answeredAt
+	}
+	result.CreatedAt = result.CreatedAt.UTC()
+	result.UpdatedAt = result.UpdatedAt.UTC()
+	return &result
+}
@@ -0,0 +1,97 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryManager_Conformance(t *testing.T) {
+	runDatabaseManagerTests(t, func(t *testing.T) DatabaseManager {
+		return NewMemoryManager()
+	})
+}
+
+func TestMemoryManager_RawQueriesNotSupported(t *testing.T) {
+	manager := NewMemoryManager()
+
+	if err := manager.Exec("DELETE FROM users"); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Expected ErrNotSupported from Exec, got %v", err)
+	}
+	if _, err := manager.Query("SELECT * FROM users"); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Expected ErrNotSupported from Query, got %v", err)
+	}
+}
+
+func TestMemoryManager_ReturnsCopies(t *testing.T) {
+	manager := NewMemoryManager()
+
+	if err := manager.CreateUser(&User{Username: "alice", Realm: "example.com", PasswordHash: "hash", Enabled: true}); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	// Mutating a returned record must not change stored data without UpdateUser
+	user, err := manager.GetUser("alice", "example.com")
+	if err != nil {
+		t.Fatalf("Failed to get user: %v", err)
+	}
+	user.Enabled = false
+
+	stored, err := manager.GetUser("alice", "example.com")
+	if err != nil {
+		t.Fatalf("Failed to get user: %v", err)
+	}
+	if !stored.Enabled {
+		t.Error("Stored user was modified through a returned copy")
+	}
+
+	group := &HuntGroup{
+		ID:        "1",
+		Name:      "Sales",
+		Extension: "100",
+		Timeout:   30,
+		Members:   []HuntGroupMember{{ID: "1", URI: "101", Timeout: 20, Enabled: true}},
+	}
+	if err := manager.CreateHuntGroup(group); err != nil {
+		t.Fatalf("Failed to create hunt group: %v", err)
+	}
+	group.Members[0].URI = "999"
+
+	retrieved, err := manager.GetHuntGroup("1")
+	if err != nil {
+		t.Fatalf("Failed to get hunt group: %v", err)
+	}
+	if retrieved.Members[0].URI != "101" {
+		t.Error("Stored hunt group members were modified through the caller's slice")
+	}
+}
+
+func TestMemoryManager_RegistrationDBExpiry(t *testing.T) {
+	registrationDB := NewRegistrationDB(NewMemoryManager())
+	aor := "sip:alice@example.com"
+
+	contacts := []*RegistrarContact{
+		{AOR: aor, URI: "sip:alice@10.0.0.1", Expires: time.Now().UTC().Add(time.Hour), CallID: "a", CSeq: 1},
+		{AOR: aor, URI: "sip:alice@10.0.0.2", Expires: time.Now().UTC().Add(-time.Second), CallID: "b", CSeq: 1},
+	}
+	for _, contact := range contacts {
+		if err := registrationDB.Store(contact); err != nil {
+			t.Fatalf("Failed to store contact: %v", err)
+		}
+	}
+
+	retrieved, err := registrationDB.Retrieve(aor, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to retrieve contacts: %v", err)
+	}
+	if len(retrieved) != 1 || retrieved[0].URI != "sip:alice@10.0.0.1" {
+		t.Errorf("Expected only the unexpired contact, got %+v", retrieved)
+	}
+
+	if err := registrationDB.CleanupExpired(time.Now()); err != nil {
+		t.Fatalf("Failed to cleanup: %v", err)
+	}
+	if err := registrationDB.Delete(aor, "sip:alice@10.0.0.2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected expired contact to be removed by cleanup, got %v", err)
+	}
+}
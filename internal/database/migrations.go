@@ -0,0 +1,365 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Migration describes a single versioned schema change
+type Migration struct {
+	Version     int
+	Description string
+	Up          []string
+	Down        []string
+}
+
+// MigrationStatus reports whether a migration has been applied
+type MigrationStatus struct {
+	Version     int
+	Description string
+	Applied     bool
+	AppliedAt   *time.Time
+}
+
+// migrations lists all schema migrations in ascending version order.
+// Released migrations must never be edited; add a new version instead.
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "create users, contacts and hunt group tables",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS users (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				username TEXT NOT NULL,
+				realm TEXT NOT NULL,
+				password_hash TEXT NOT NULL,
+				enabled BOOLEAN NOT NULL DEFAULT TRUE,
+				created_at DATETIME NOT NULL,
+				updated_at DATETIME NOT NULL,
+				UNIQUE(username, realm)
+			)`,
+			`CREATE TABLE IF NOT EXISTS contacts (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				aor TEXT NOT NULL,
+				contact_uri TEXT NOT NULL,
+				expires DATETIME NOT NULL,
+				call_id TEXT NOT NULL,
+				cseq INTEGER NOT NULL,
+				created_at DATETIME NOT NULL,
+				UNIQUE(aor, contact_uri)
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_contacts_aor ON contacts(aor)`,
+			`CREATE INDEX IF NOT EXISTS idx_contacts_expires ON contacts(expires)`,
+			`CREATE TABLE IF NOT EXISTS hunt_groups (
+				id TEXT PRIMARY KEY,
+				name TEXT NOT NULL,
+				extension TEXT NOT NULL UNIQUE,
+				description TEXT NOT NULL DEFAULT '',
+				strategy TEXT NOT NULL DEFAULT 'parallel',
+				timeout INTEGER NOT NULL DEFAULT 30,
+				enabled BOOLEAN NOT NULL DEFAULT TRUE,
+				created_at DATETIME NOT NULL,
+				updated_at DATETIME NOT NULL
+			)`,
+			`CREATE TABLE IF NOT EXISTS hunt_group_members (
+				hunt_group_id TEXT NOT NULL REFERENCES hunt_groups(id) ON DELETE CASCADE,
+				id TEXT NOT NULL,
+				position INTEGER NOT NULL,
+				uri TEXT NOT NULL,
+				display_name TEXT NOT NULL DEFAULT '',
+				priority INTEGER NOT NULL DEFAULT 0,
+				timeout INTEGER NOT NULL DEFAULT 30,
+				enabled BOOLEAN NOT NULL DEFAULT TRUE,
+				PRIMARY KEY (hunt_group_id, id)
+			)`,
+			`CREATE TABLE IF NOT EXISTS hunt_group_calls (
+				id TEXT PRIMARY KEY,
+				hunt_group_id TEXT NOT NULL REFERENCES hunt_groups(id) ON DELETE CASCADE,
+				session_id TEXT NOT NULL,
+				caller_uri TEXT NOT NULL,
+				caller_name TEXT NOT NULL DEFAULT '',
+				status TEXT NOT NULL,
+				answered_by TEXT NOT NULL DEFAULT '',
+				answered_at DATETIME,
+				duration INTEGER NOT NULL DEFAULT 0,
+				created_at DATETIME NOT NULL,
+				updated_at DATETIME NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_hunt_group_calls_group ON hunt_group_calls(hunt_group_id)`,
+		},
+		Down: []string{
+			`DROP TABLE IF EXISTS hunt_group_calls`,
+			`DROP TABLE IF EXISTS hunt_group_members`,
+			`DROP TABLE IF EXISTS hunt_groups`,
+			`DROP TABLE IF EXISTS contacts`,
+			`DROP TABLE IF EXISTS users`,
+		},
+	},
+	{
+		Version:     2,
+		Description: "record the source address of contact bindings",
+		Up: []string{
+			`ALTER TABLE contacts ADD COLUMN source TEXT NOT NULL DEFAULT ''`,
+		},
+		Down: []string{
+			`ALTER TABLE contacts DROP COLUMN source`,
+		},
+	},
+	{
+		Version:     3,
+		Description: "record the transport and NAT state of contact bindings",
+		Up: []string{
+			`ALTER TABLE contacts ADD COLUMN transport TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE contacts ADD COLUMN behind_nat BOOLEAN NOT NULL DEFAULT FALSE`,
+		},
+		Down: []string{
+			`ALTER TABLE contacts DROP COLUMN behind_nat`,
+			`ALTER TABLE contacts DROP COLUMN transport`,
+		},
+	},
+	{
+		// SQLite cannot alter a UNIQUE constraint, so the table is rebuilt.
+		// Outbound flows of one instance usually share a Contact URI and
+		// are told apart by reg-id (RFC 5626 section 6).
+		Version:     4,
+		Description: "key contact bindings by SIP Outbound instance and reg-id",
+		Up: []string{
+			`CREATE TABLE contacts_new (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				aor TEXT NOT NULL,
+				contact_uri TEXT NOT NULL,
+				expires DATETIME NOT NULL,
+				call_id TEXT NOT NULL,
+				cseq INTEGER NOT NULL,
+				created_at DATETIME NOT NULL,
+				source TEXT NOT NULL DEFAULT '',
+				transport TEXT NOT NULL DEFAULT '',
+				behind_nat BOOLEAN NOT NULL DEFAULT FALSE,
+				instance_id TEXT NOT NULL DEFAULT '',
+				reg_id INTEGER NOT NULL DEFAULT 0,
+				UNIQUE(aor, contact_uri, instance_id, reg_id)
+			)`,
+			`INSERT INTO contacts_new (id, aor, contact_uri, expires, call_id, cseq, created_at, source, transport, behind_nat)
+				SELECT id, aor, contact_uri, expires, call_id, cseq, created_at, source, transport, behind_nat FROM contacts`,
+			`DROP TABLE contacts`,
+			`ALTER TABLE contacts_new RENAME TO contacts`,
+			`CREATE INDEX IF NOT EXISTS idx_contacts_aor ON contacts(aor)`,
+			`CREATE INDEX IF NOT EXISTS idx_contacts_expires ON contacts(expires)`,
+		},
+		Down: []string{
+			`CREATE TABLE contacts_old (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				aor TEXT NOT NULL,
+				contact_uri TEXT NOT NULL,
+				expires DATETIME NOT NULL,
+				call_id TEXT NOT NULL,
+				cseq INTEGER NOT NULL,
+				created_at DATETIME NOT NULL,
+				source TEXT NOT NULL DEFAULT '',
+				transport TEXT NOT NULL DEFAULT '',
+				behind_nat BOOLEAN NOT NULL DEFAULT FALSE,
+				UNIQUE(aor, contact_uri)
+			)`,
+			`INSERT OR IGNORE INTO contacts_old (id, aor, contact_uri, expires, call_id, cseq, created_at, source, transport, behind_nat)
+				SELECT id, aor, contact_uri, expires, call_id, cseq, created_at, source, transport, behind_nat FROM contacts ORDER BY id DESC`,
+			`DROP TABLE contacts`,
+			`ALTER TABLE contacts_old RENAME TO contacts`,
+			`CREATE INDEX IF NOT EXISTS idx_contacts_aor ON contacts(aor)`,
+			`CREATE INDEX IF NOT EXISTS idx_contacts_expires ON contacts(expires)`,
+		},
+	},
+}
+
+// LatestSchemaVersion returns the schema version produced by applying all migrations
+func LatestSchemaVersion() int {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+const createMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER PRIMARY KEY,
+	description TEXT NOT NULL,
+	applied_at DATETIME NOT NULL
+)`
+
+// Migrator applies and rolls back schema migrations on a SQLite database
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// NewMigrator creates a migrator for the given database using the built-in migrations
+func NewMigrator(db *sql.DB) *Migrator {
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+	}
+}
+
+// Version returns the highest applied schema version, or 0 for an empty database
+func (m *Migrator) Version() (int, error) {
+	exists, err := m.hasTable()
+	if err != nil || !exists {
+		return 0, err
+	}
+
+	var version sql.NullInt64
+	if err := m.db.QueryRow("SELECT MAX(version) FROM schema_migrations").Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+// Status returns the state of every known migration
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, len(m.migrations))
+	for i, migration := range m.migrations {
+		statuses[i] = MigrationStatus{
+			Version:     migration.Version,
+			Description: migration.Description,
+		}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			statuses[i].Applied = true
+			statuses[i].AppliedAt = &appliedAt
+		}
+	}
+	return statuses, nil
+}
+
+// Up applies all pending migrations in order and returns the ones that were applied
+func (m *Migrator) Up() ([]Migration, error) {
+	if err := m.ensureTable(); err != nil {
+		return nil, err
+	}
+
+	version, err := m.Version()
+	if err != nil {
+		return nil, err
+	}
+	if latest := m.latest(); version > latest {
+		return nil, fmt.Errorf("database schema version %d is newer than supported version %d", version, latest)
+	}
+
+	var appliedMigrations []Migration
+	for _, migration := range m.migrations {
+		if migration.Version <= version {
+			continue
+		}
+
+		err := withTx(m.db, func(tx *sql.Tx) error {
+			for _, stmt := range migration.Up {
+				if _, err := tx.Exec(stmt); err != nil {
+					return err
+				}
+			}
+			_, err := tx.Exec(
+				"INSERT INTO schema_migrations (version, description, applied_at) VALUES (?, ?, ?)",
+				migration.Version, migration.Description, time.Now().UTC(),
+			)
+			return err
+		})
+		if err != nil {
+			return appliedMigrations, fmt.Errorf("failed to apply migration %d (%s): %w", migration.Version, migration.Description, err)
+		}
+		appliedMigrations = append(appliedMigrations, migration)
+	}
+	return appliedMigrations, nil
+}
+
+// Down rolls back the most recently applied migration and returns it.
+// It returns nil if no migration has been applied.
+func (m *Migrator) Down() (*Migration, error) {
+	version, err := m.Version()
+	if err != nil {
+		return nil, err
+	}
+	if version == 0 {
+		return nil, nil
+	}
+
+	migration, err := m.find(version)
+	if err != nil {
+		return nil, err
+	}
+
+	err = withTx(m.db, func(tx *sql.Tx) error {
+		for _, stmt := range migration.Down {
+			if _, err := tx.Exec(stmt); err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec("DELETE FROM schema_migrations WHERE version = ?", migration.Version)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to roll back migration %d (%s): %w", migration.Version, migration.Description, err)
+	}
+	return migration, nil
+}
+
+// hasTable reports whether the schema_migrations table exists. Read-only
+// operations use it so that inspecting a database never modifies it.
+func (m *Migrator) hasTable() (bool, error) {
+	var count int
+	err := m.db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'").Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up schema_migrations table: %w", err)
+	}
+	return count > 0, nil
+}
+
+func (m *Migrator) ensureTable() error {
+	if _, err := m.db.Exec(createMigrationsTable); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+func (m *Migrator) applied() (map[int]time.Time, error) {
+	applied := make(map[int]time.Time)
+
+	exists, err := m.hasTable()
+	if err != nil || !exists {
+		return applied, err
+	}
+
+	rows, err := m.db.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan applied migration: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+func (m *Migrator) find(version int) (*Migration, error) {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unknown schema version %d", version)
+}
+
+func (m *Migrator) latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
@@ -0,0 +1,168 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func newTestOpenManager(t *testing.T) *SQLiteManager {
+	t.Helper()
+
+	manager := NewSQLiteManager(filepath.Join(t.TempDir(), "test.db"))
+	if err := manager.Open(); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { manager.Close() })
+	return manager
+}
+
+func TestMigrator_UpAndDown(t *testing.T) {
+	manager := newTestOpenManager(t)
+
+	migrator, err := manager.Migrator()
+	if err != nil {
+		t.Fatalf("Failed to create migrator: %v", err)
+	}
+
+	version, err := migrator.Version()
+	if err != nil {
+		t.Fatalf("Failed to read version: %v", err)
+	}
+	if version != 0 {
+		t.Errorf("Expected version 0 for empty database, got %d", version)
+	}
+
+	applied, err := migrator.Up()
+	if err != nil {
+		t.Fatalf("Failed to migrate up: %v", err)
+	}
+	if len(applied) != len(migrations) {
+		t.Errorf("Expected %d applied migrations, got %d", len(migrations), len(applied))
+	}
+
+	version, err = migrator.Version()
+	if err != nil {
+		t.Fatalf("Failed to read version: %v", err)
+	}
+	if version != LatestSchemaVersion() {
+		t.Errorf("Expected version %d, got %d", LatestSchemaVersion(), version)
+	}
+
+	// Running again is a no-op
+	applied, err = migrator.Up()
+	if err != nil {
+		t.Fatalf("Failed to re-run migrations: %v", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("Expected no migrations on second run, got %d", len(applied))
+	}
+
+	if err := manager.CreateUser(&User{Username: "alice", Realm: "example.com", PasswordHash: "hash", Enabled: true}); err != nil {
+		t.Fatalf("Failed to use migrated schema: %v", err)
+	}
+
+	rolledBack, err := migrator.Down()
+	if err != nil {
+		t.Fatalf("Failed to migrate down: %v", err)
+	}
+	if rolledBack == nil || rolledBack.Version != LatestSchemaVersion() {
+		t.Errorf("Expected to roll back version %d, got %+v", LatestSchemaVersion(), rolledBack)
+	}
+
+	version, err = migrator.Version()
+	if err != nil {
+		t.Fatalf("Failed to read version: %v", err)
+	}
+	if version != LatestSchemaVersion()-1 {
+		t.Errorf("Expected version %d after rollback, got %d", LatestSchemaVersion()-1, version)
+	}
+}
+
+func TestMigrator_DownOnEmptyDatabase(t *testing.T) {
+	manager := newTestOpenManager(t)
+
+	migrator, err := manager.Migrator()
+	if err != nil {
+		t.Fatalf("Failed to create migrator: %v", err)
+	}
+
+	migration, err := migrator.Down()
+	if err != nil {
+		t.Fatalf("Down on empty database should succeed, got %v", err)
+	}
+	if migration != nil {
+		t.Errorf("Expected nothing to roll back, got %+v", migration)
+	}
+}
+
+func TestMigrator_Status(t *testing.T) {
+	manager := newTestOpenManager(t)
+
+	migrator, err := manager.Migrator()
+	if err != nil {
+		t.Fatalf("Failed to create migrator: %v", err)
+	}
+
+	statuses, err := migrator.Status()
+	if err != nil {
+		t.Fatalf("Failed to read status: %v", err)
+	}
+	for _, status := range statuses {
+		if status.Applied {
+			t.Errorf("Migration %d should be pending", status.Version)
+		}
+	}
+
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("Failed to migrate up: %v", err)
+	}
+
+	statuses, err = migrator.Status()
+	if err != nil {
+		t.Fatalf("Failed to read status: %v", err)
+	}
+	for _, status := range statuses {
+		if !status.Applied || status.AppliedAt == nil {
+			t.Errorf("Migration %d should be applied", status.Version)
+		}
+	}
+}
+
+func TestMigrator_RejectsNewerSchema(t *testing.T) {
+	manager := newTestOpenManager(t)
+
+	if _, err := manager.Migrate(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	if err := manager.Exec(
+		"INSERT INTO schema_migrations (version, description, applied_at) VALUES (?, ?, CURRENT_TIMESTAMP)",
+		LatestSchemaVersion()+1, "from the future",
+	); err != nil {
+		t.Fatalf("Failed to insert future version: %v", err)
+	}
+
+	if _, err := manager.Migrate(); err == nil {
+		t.Error("Expected error migrating a database with a newer schema")
+	}
+}
+
+func TestMigrator_AdoptsUnversionedDatabase(t *testing.T) {
+	manager := newTestOpenManager(t)
+
+	// Simulate a database created before schema versioning existed
+	for _, stmt := range migrations[0].Up {
+		if err := manager.Exec(stmt); err != nil {
+			t.Fatalf("Failed to create legacy schema: %v", err)
+		}
+	}
+	if err := manager.CreateUser(&User{Username: "alice", Realm: "example.com", PasswordHash: "hash", Enabled: true}); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	if _, err := manager.Migrate(); err != nil {
+		t.Fatalf("Failed to migrate legacy database: %v", err)
+	}
+	if _, err := manager.GetUser("alice", "example.com"); err != nil {
+		t.Errorf("Existing data should survive migration, got %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// SQLRegistrationDB implements the RegistrationDB interface using the contacts table
+type SQLRegistrationDB struct {
+	db DatabaseManager
+}
+
+// NewRegistrationDB creates a registration store backed by the given database
+func NewRegistrationDB(db DatabaseManager) *SQLRegistrationDB {
+	return &SQLRegistrationDB{
+		db: db,
+	}
+}
+
+// Store adds or refreshes a contact binding
+func (r *SQLRegistrationDB) Store(contact *RegistrarContact) error {
+	if contact.AOR == "" || contact.URI == "" {
+		return fmt.Errorf("contact AOR and URI cannot be empty")
+	}
+
+	return r.db.StoreContact(&Contact{
+		AOR:        contact.AOR,
+		URI:        contact.URI,
+		Expires:    contact.Expires,
+		CallID:     contact.CallID,
+		CSeq:       contact.CSeq,
+		Source:     contact.Source,
+		Transport:  contact.Transport,
+		BehindNAT:  contact.BehindNAT,
+		InstanceID: contact.InstanceID,
+		RegID:      contact.RegID,
+	})
+}
+
+// Retrieve returns the contact bindings for an AOR that have not expired by now
+func (r *SQLRegistrationDB) Retrieve(aor string, now time.Time) ([]*RegistrarContact, error) {
+	contacts, err := r.db.RetrieveContacts(aor, now)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*RegistrarContact, 0, len(contacts))
+	for _, contact := range contacts {
+		result = append(result, &RegistrarContact{
+			AOR:        contact.AOR,
+			URI:        contact.URI,
+			Expires:    contact.Expires,
+			CallID:     contact.CallID,
+			CSeq:       contact.CSeq,
+			Source:     contact.Source,
+			Transport:  contact.Transport,
+			BehindNAT:  contact.BehindNAT,
+			InstanceID: contact.InstanceID,
+			RegID:      contact.RegID,
+		})
+	}
+	return result, nil
+}
+
+// Delete removes a contact binding, returning ErrNotFound if it does not exist
+func (r *SQLRegistrationDB) Delete(aor string, contactURI string) error {
+	return r.db.DeleteContact(aor, contactURI)
+}
+
+// DeleteFlow removes the SIP Outbound bindings registered over a flow that has
+// failed and returns how many were removed
+func (r *SQLRegistrationDB) DeleteFlow(transport, source string) (int, error) {
+	return r.db.DeleteFlowContacts(transport, source)
+}
+
+// CleanupExpired removes the contact bindings that have expired by now
+func (r *SQLRegistrationDB) CleanupExpired(now time.Time) error {
+	return r.db.CleanupExpiredContacts(now)
+}
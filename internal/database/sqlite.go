@@ -0,0 +1,801 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteManager implements the DatabaseManager interface using SQLite
+type SQLiteManager struct {
+	path string
+	db   *sql.DB
+	mu   sync.RWMutex
+}
+
+// NewSQLiteManager creates a new SQLite database manager for the given file path
+func NewSQLiteManager(path string) *SQLiteManager {
+	return &SQLiteManager{
+		path: path,
+	}
+}
+
+// Initialize opens the database and applies any pending schema migrations
+func (m *SQLiteManager) Initialize() error {
+	if err := m.Open(); err != nil {
+		return err
+	}
+
+	if _, err := m.Migrate(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Open opens the database connection without touching the schema
+func (m *SQLiteManager) Open() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.db != nil {
+		return nil
+	}
+
+	db, err := sql.Open("sqlite", m.path)
+	if err != nil {
+		return fmt.Errorf("failed to open database %s: %w", m.path, err)
+	}
+
+	// SQLite serializes writers; a single connection avoids "database is locked"
+	// errors and keeps in-memory databases consistent across calls.
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return fmt.Errorf("failed to connect to database %s: %w", m.path, err)
+	}
+
+	pragmas := []string{
+		"PRAGMA foreign_keys = ON",
+		"PRAGMA busy_timeout = 5000",
+	}
+	for _, pragma := range pragmas {
+		if _, err := db.Exec(pragma); err != nil {
+			db.Close()
+			return fmt.Errorf("failed to apply %q: %w", pragma, err)
+		}
+	}
+
+	m.db = db
+	return nil
+}
+
+// Migrate applies all pending schema migrations and returns the ones that were applied
+func (m *SQLiteManager) Migrate() ([]Migration, error) {
+	migrator, err := m.Migrator()
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := migrator.Up()
+	if err != nil {
+		return applied, fmt.Errorf("failed to migrate database %s: %w", m.path, err)
+	}
+	return applied, nil
+}
+
+// Migrator returns a migrator bound to the open database
+func (m *SQLiteManager) Migrator() (*Migrator, error) {
+	db, err := m.conn()
+	if err != nil {
+		return nil, err
+	}
+	return NewMigrator(db), nil
+}
+
+// Path returns the database file path
+func (m *SQLiteManager) Path() string {
+	return m.path
+}
+
+// Close closes the database connection
+func (m *SQLiteManager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.db == nil {
+		return nil
+	}
+
+	err := m.db.Close()
+	m.db = nil
+	return err
+}
+
+// conn returns the open database handle
+func (m *SQLiteManager) conn() (*sql.DB, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	return m.db, nil
+}
+
+// User operations
+
+// CreateUser inserts a new user
+func (m *SQLiteManager) CreateUser(user *User) error {
+	db, err := m.conn()
+	if err != nil {
+		return err
+	}
+
+	var existing int64
+	err = db.QueryRow("SELECT id FROM users WHERE username = ? AND realm = ?", user.Username, user.Realm).Scan(&existing)
+	if err == nil {
+		return ErrDuplicateUser
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to check for existing user: %w", err)
+	}
+
+	now := time.Now().UTC()
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = now
+	}
+	if user.UpdatedAt.IsZero() {
+		user.UpdatedAt = now
+	}
+
+	result, err := db.Exec(
+		"INSERT INTO users (username, realm, password_hash, enabled, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)",
+		user.Username, user.Realm, user.PasswordHash, user.Enabled, user.CreatedAt.UTC(), user.UpdatedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert user: %w", err)
+	}
+
+	if id, err := result.LastInsertId(); err == nil {
+		user.ID = id
+	}
+	return nil
+}
+
+// GetUser retrieves a user by username and realm
+func (m *SQLiteManager) GetUser(username, realm string) (*User, error) {
+	db, err := m.conn()
+	if err != nil {
+		return nil, err
+	}
+
+	row := db.QueryRow(
+		"SELECT id, username, realm, password_hash, enabled, created_at, updated_at FROM users WHERE username = ? AND realm = ?",
+		username, realm,
+	)
+	user, err := scanUser(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return user, nil
+}
+
+// UpdateUser updates the password hash and enabled flag of an existing user
+func (m *SQLiteManager) UpdateUser(user *User) error {
+	db, err := m.conn()
+	if err != nil {
+		return err
+	}
+
+	user.UpdatedAt = time.Now().UTC()
+	result, err := db.Exec(
+		"UPDATE users SET password_hash = ?, enabled = ?, updated_at = ? WHERE username = ? AND realm = ?",
+		user.PasswordHash, user.Enabled, user.UpdatedAt, user.Username, user.Realm,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	return requireAffected(result)
+}
+
+// DeleteUser deletes a user by username and realm
+func (m *SQLiteManager) DeleteUser(username, realm string) error {
+	db, err := m.conn()
+	if err != nil {
+		return err
+	}
+
+	result, err := db.Exec("DELETE FROM users WHERE username = ? AND realm = ?", username, realm)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	return requireAffected(result)
+}
+
+// ListUsers returns all users ordered by realm and username
+func (m *SQLiteManager) ListUsers() ([]*User, error) {
+	db, err := m.conn()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("SELECT id, username, realm, password_hash, enabled, created_at, updated_at FROM users ORDER BY realm, username")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []*User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	return users, nil
+}
+
+// Contact operations
+
+// StoreContact inserts a contact or replaces the existing binding for the same
+// AOR and URI. A SIP Outbound binding also replaces any earlier binding of the
+// same instance and reg-id, since it describes the same flow.
+func (m *SQLiteManager) StoreContact(contact *Contact) error {
+	db, err := m.conn()
+	if err != nil {
+		return err
+	}
+
+	if contact.CreatedAt.IsZero() {
+		contact.CreatedAt = time.Now().UTC()
+	}
+
+	err = withTx(db, func(tx *sql.Tx) error {
+		if contact.RegID > 0 {
+			if _, err := tx.Exec(
+				"DELETE FROM contacts WHERE aor = ? AND instance_id = ? AND reg_id = ? AND contact_uri <> ?",
+				contact.AOR, contact.InstanceID, contact.RegID, contact.URI,
+			); err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec(
+			`INSERT INTO contacts (aor, contact_uri, expires, call_id, cseq, source, transport, behind_nat, instance_id, reg_id, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(aor, contact_uri, instance_id, reg_id) DO UPDATE SET expires = excluded.expires, call_id = excluded.call_id, cseq = excluded.cseq,
+				source = excluded.source, transport = excluded.transport, behind_nat = excluded.behind_nat`,
+			contact.AOR, contact.URI, contact.Expires.UTC(), contact.CallID, contact.CSeq, contact.Source, contact.Transport, contact.BehindNAT,
+			contact.InstanceID, contact.RegID, contact.CreatedAt.UTC(),
+		)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store contact: %w", err)
+	}
+	return nil
+}
+
+// RetrieveContacts returns the contacts for an AOR that have not expired by now
+func (m *SQLiteManager) RetrieveContacts(aor string, now time.Time) ([]*Contact, error) {
+	db, err := m.conn()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(
+		"SELECT id, aor, contact_uri, expires, call_id, cseq, source, transport, behind_nat, instance_id, reg_id, created_at FROM contacts WHERE aor = ? AND expires > ? ORDER BY id",
+		aor, now.UTC(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve contacts: %w", err)
+	}
+	defer rows.Close()
+
+	var contacts []*Contact
+	for rows.Next() {
+		contact := &Contact{}
+		if err := rows.Scan(&contact.ID, &contact.AOR, &contact.URI, &contact.Expires, &contact.CallID, &contact.CSeq, &contact.Source, &contact.Transport, &contact.BehindNAT, &contact.InstanceID, &contact.RegID, &contact.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan contact: %w", err)
+		}
+		contacts = append(contacts, contact)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to retrieve contacts: %w", err)
+	}
+	return contacts, nil
+}
+
+// DeleteContact removes a single contact binding
+func (m *SQLiteManager) DeleteContact(aor string, contactURI string) error {
+	db, err := m.conn()
+	if err != nil {
+		return err
+	}
+
+	result, err := db.Exec("DELETE FROM contacts WHERE aor = ? AND contact_uri = ?", aor, contactURI)
+	if err != nil {
+		return fmt.Errorf("failed to delete contact: %w", err)
+	}
+	return requireAffected(result)
+}
+
+// DeleteFlowContacts removes the SIP Outbound bindings registered over the flow
+// from source on transport and returns how many were removed
+func (m *SQLiteManager) DeleteFlowContacts(transport, source string) (int, error) {
+	db, err := m.conn()
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := db.Exec("DELETE FROM contacts WHERE transport = ? AND source = ? AND reg_id > 0", transport, source)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete flow contacts: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete flow contacts: %w", err)
+	}
+	return int(affected), nil
+}
+
+// CleanupExpiredContacts removes the contact bindings that have expired by now
+func (m *SQLiteManager) CleanupExpiredContacts(now time.Time) error {
+	db, err := m.conn()
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.Exec("DELETE FROM contacts WHERE expires <= ?", now.UTC()); err != nil {
+		return fmt.Errorf("failed to cleanup expired contacts: %w", err)
+	}
+	return nil
+}
+
+// Hunt group operations
+
+// CreateHuntGroup inserts a new hunt group together with its members
+func (m *SQLiteManager) CreateHuntGroup(huntGroup *HuntGroup) error {
+	if huntGroup.ID == "" {
+		return fmt.Errorf("hunt group ID cannot be empty")
+	}
+
+	db, err := m.conn()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	if huntGroup.CreatedAt.IsZero() {
+		huntGroup.CreatedAt = now
+	}
+	if huntGroup.UpdatedAt.IsZero() {
+		huntGroup.UpdatedAt = now
+	}
+
+	return withTx(db, func(tx *sql.Tx) error {
+		if err := checkExtensionAvailable(tx, huntGroup.Extension, huntGroup.ID); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(
+			`INSERT INTO hunt_groups (id, name, extension, description, strategy, timeout, enabled, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			huntGroup.ID, huntGroup.Name, huntGroup.Extension, huntGroup.Description, huntGroup.Strategy,
+			huntGroup.Timeout, huntGroup.Enabled, huntGroup.CreatedAt.UTC(), huntGroup.UpdatedAt.UTC(),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert hunt group: %w", err)
+		}
+
+		return insertHuntGroupMembers(tx, huntGroup.ID, huntGroup.Members)
+	})
+}
+
+// GetHuntGroup retrieves a hunt group by ID
+func (m *SQLiteManager) GetHuntGroup(id string) (*HuntGroup, error) {
+	return m.getHuntGroup("id = ?", id)
+}
+
+// GetHuntGroupByExtension retrieves a hunt group by extension
+func (m *SQLiteManager) GetHuntGroupByExtension(extension string) (*HuntGroup, error) {
+	return m.getHuntGroup("extension = ?", extension)
+}
+
+func (m *SQLiteManager) getHuntGroup(where string, arg interface{}) (*HuntGroup, error) {
+	db, err := m.conn()
+	if err != nil {
+		return nil, err
+	}
+
+	row := db.QueryRow(
+		"SELECT id, name, extension, description, strategy, timeout, enabled, created_at, updated_at FROM hunt_groups WHERE "+where,
+		arg,
+	)
+	huntGroup, err := scanHuntGroup(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hunt group: %w", err)
+	}
+
+	if huntGroup.Members, err = loadHuntGroupMembers(db, huntGroup.ID); err != nil {
+		return nil, err
+	}
+	return huntGroup, nil
+}
+
+// UpdateHuntGroup updates an existing hunt group and replaces its members
+func (m *SQLiteManager) UpdateHuntGroup(huntGroup *HuntGroup) error {
+	db, err := m.conn()
+	if err != nil {
+		return err
+	}
+
+	huntGroup.UpdatedAt = time.Now().UTC()
+
+	return withTx(db, func(tx *sql.Tx) error {
+		if err := checkExtensionAvailable(tx, huntGroup.Extension, huntGroup.ID); err != nil {
+			return err
+		}
+
+		result, err := tx.Exec(
+			`UPDATE hunt_groups SET name = ?, extension = ?, description = ?, strategy = ?, timeout = ?, enabled = ?, updated_at = ?
+			WHERE id = ?`,
+			huntGroup.Name, huntGroup.Extension, huntGroup.Description, huntGroup.Strategy,
+			huntGroup.Timeout, huntGroup.Enabled, huntGroup.UpdatedAt, huntGroup.ID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to update hunt group: %w", err)
+		}
+		if err := requireAffected(result); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec("DELETE FROM hunt_group_members WHERE hunt_group_id = ?", huntGroup.ID); err != nil {
+			return fmt.Errorf("failed to replace hunt group members: %w", err)
+		}
+		return insertHuntGroupMembers(tx, huntGroup.ID, huntGroup.Members)
+	})
+}
+
+// DeleteHuntGroup deletes a hunt group along with its members and call records
+func (m *SQLiteManager) DeleteHuntGroup(id string) error {
+	db, err := m.conn()
+	if err != nil {
+		return err
+	}
+
+	result, err := db.Exec("DELETE FROM hunt_groups WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete hunt group: %w", err)
+	}
+	return requireAffected(result)
+}
+
+// ListHuntGroups returns all hunt groups ordered by extension
+func (m *SQLiteManager) ListHuntGroups() ([]*HuntGroup, error) {
+	db, err := m.conn()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("SELECT id, name, extension, description, strategy, timeout, enabled, created_at, updated_at FROM hunt_groups ORDER BY extension")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list hunt groups: %w", err)
+	}
+
+	var huntGroups []*HuntGroup
+	for rows.Next() {
+		huntGroup, err := scanHuntGroup(rows)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan hunt group: %w", err)
+		}
+		huntGroups = append(huntGroups, huntGroup)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list hunt groups: %w", err)
+	}
+
+	// Members are loaded after the cursor is closed since only one connection is open
+	for _, huntGroup := range huntGroups {
+		if huntGroup.Members, err = loadHuntGroupMembers(db, huntGroup.ID); err != nil {
+			return nil, err
+		}
+	}
+	return huntGroups, nil
+}
+
+// Hunt group call operations
+
+// CreateHuntGroupCall records a new call for a hunt group
+func (m *SQLiteManager) CreateHuntGroupCall(call *HuntGroupCall) error {
+	db, err := m.conn()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	if call.CreatedAt.IsZero() {
+		call.CreatedAt = now
+	}
+	if call.UpdatedAt.IsZero() {
+		call.UpdatedAt = now
+	}
+
+	return withTx(db, func(tx *sql.Tx) error {
+		var groupID string
+		err := tx.QueryRow("SELECT id FROM hunt_groups WHERE id = ?", call.HuntGroupID).Scan(&groupID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to look up hunt group: %w", err)
+		}
+
+		_, err = tx.Exec(
+			`INSERT INTO hunt_group_calls (id, hunt_group_id, session_id, caller_uri, caller_name, status, answered_by, answered_at, duration, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			call.ID, call.HuntGroupID, call.SessionID, call.CallerURI, call.CallerName, call.Status,
+			call.AnsweredBy, nullTime(call.AnsweredAt), call.Duration, call.CreatedAt.UTC(), call.UpdatedAt.UTC(),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert hunt group call: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetHuntGroupCall retrieves a hunt group call by ID
+func (m *SQLiteManager) GetHuntGroupCall(id string) (*HuntGroupCall, error) {
+	db, err := m.conn()
+	if err != nil {
+		return nil, err
+	}
+
+	row := db.QueryRow(
+		`SELECT id, hunt_group_id, session_id, caller_uri, caller_name, status, answered_by, answered_at, duration, created_at, updated_at
+		FROM hunt_group_calls WHERE id = ?`,
+		id,
+	)
+	call, err := scanHuntGroupCall(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hunt group call: %w", err)
+	}
+	return call, nil
+}
+
+// UpdateHuntGroupCall updates the outcome of a hunt group call
+func (m *SQLiteManager) UpdateHuntGroupCall(call *HuntGroupCall) error {
+	db, err := m.conn()
+	if err != nil {
+		return err
+	}
+
+	call.UpdatedAt = time.Now().UTC()
+	result, err := db.Exec(
+		"UPDATE hunt_group_calls SET status = ?, answered_by = ?, answered_at = ?, duration = ?, updated_at = ? WHERE id = ?",
+		call.Status, call.AnsweredBy, nullTime(call.AnsweredAt), call.Duration, call.UpdatedAt, call.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update hunt group call: %w", err)
+	}
+	return requireAffected(result)
+}
+
+// ListHuntGroupCalls returns all calls recorded for a hunt group, oldest first
+func (m *SQLiteManager) ListHuntGroupCalls(huntGroupID string) ([]*HuntGroupCall, error) {
+	db, err := m.conn()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(
+		`SELECT id, hunt_group_id, session_id, caller_uri, caller_name, status, answered_by, answered_at, duration, created_at, updated_at
+		FROM hunt_group_calls WHERE hunt_group_id = ? ORDER BY created_at`,
+		huntGroupID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list hunt group calls: %w", err)
+	}
+	defer rows.Close()
+
+	var calls []*HuntGroupCall
+	for rows.Next() {
+		call, err := scanHuntGroupCall(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan hunt group call: %w", err)
+		}
+		calls = append(calls, call)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list hunt group calls: %w", err)
+	}
+	return calls, nil
+}
+
+// Raw query operations
+
+// Exec executes a statement without returning any rows
+func (m *SQLiteManager) Exec(query string, args ...interface{}) error {
+	_, err := m.ExecWithResult(query, args...)
+	return err
+}
+
+// ExecWithResult executes a statement and returns its result
+func (m *SQLiteManager) ExecWithResult(query string, args ...interface{}) (Result, error) {
+	db, err := m.conn()
+	if err != nil {
+		return nil, err
+	}
+	return db.Exec(query, args...)
+}
+
+// Query executes a query that returns rows. The caller must close the returned rows.
+func (m *SQLiteManager) Query(query string, args ...interface{}) (Rows, error) {
+	db, err := m.conn()
+	if err != nil {
+		return nil, err
+	}
+	return db.Query(query, args...)
+}
+
+// QueryRow executes a query expected to return a single row and scans it into dest
+func (m *SQLiteManager) QueryRow(query string, dest []interface{}, args ...interface{}) error {
+	db, err := m.conn()
+	if err != nil {
+		return err
+	}
+
+	err = db.QueryRow(query, args...).Scan(dest...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// Helper functions
+
+// scanner is implemented by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(s scanner) (*User, error) {
+	user := &User{}
+	if err := s.Scan(&user.ID, &user.Username, &user.Realm, &user.PasswordHash, &user.Enabled, &user.CreatedAt, &user.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func scanHuntGroup(s scanner) (*HuntGroup, error) {
+	huntGroup := &HuntGroup{}
+	err := s.Scan(&huntGroup.ID, &huntGroup.Name, &huntGroup.Extension, &huntGroup.Description, &huntGroup.Strategy,
+		&huntGroup.Timeout, &huntGroup.Enabled, &huntGroup.CreatedAt, &huntGroup.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return huntGroup, nil
+}
+
+func scanHuntGroupCall(s scanner) (*HuntGroupCall, error) {
+	call := &HuntGroupCall{}
+	var answeredAt sql.NullTime
+	err := s.Scan(&call.ID, &call.HuntGroupID, &call.SessionID, &call.CallerURI, &call.CallerName, &call.Status,
+		&call.AnsweredBy, &answeredAt, &call.Duration, &call.CreatedAt, &call.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if answeredAt.Valid {
+		t := answeredAt.Time
+		call.AnsweredAt = &t
+	}
+	return call, nil
+}
+
+func loadHuntGroupMembers(db *sql.DB, huntGroupID string) ([]HuntGroupMember, error) {
+	rows, err := db.Query(
+		"SELECT id, uri, display_name, priority, timeout, enabled FROM hunt_group_members WHERE hunt_group_id = ? ORDER BY position",
+		huntGroupID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load hunt group members: %w", err)
+	}
+	defer rows.Close()
+
+	members := []HuntGroupMember{}
+	for rows.Next() {
+		var member HuntGroupMember
+		if err := rows.Scan(&member.ID, &member.URI, &member.DisplayName, &member.Priority, &member.Timeout, &member.Enabled); err != nil {
+			return nil, fmt.Errorf("failed to scan hunt group member: %w", err)
+		}
+		members = append(members, member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load hunt group members: %w", err)
+	}
+	return members, nil
+}
+
+func insertHuntGroupMembers(tx *sql.Tx, huntGroupID string, members []HuntGroupMember) error {
+	for i, member := range members {
+		_, err := tx.Exec(
+			`INSERT INTO hunt_group_members (hunt_group_id, id, position, uri, display_name, priority, timeout, enabled)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			huntGroupID, member.ID, i, member.URI, member.DisplayName, member.Priority, member.Timeout, member.Enabled,
+		)
+		if err != nil {
+			if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+				return fmt.Errorf("duplicate hunt group member ID %s", member.ID)
+			}
+			return fmt.Errorf("failed to insert hunt group member: %w", err)
+		}
+	}
+	return nil
+}
+
+func checkExtensionAvailable(tx *sql.Tx, extension, huntGroupID string) error {
+	var existingID string
+	err := tx.QueryRow("SELECT id FROM hunt_groups WHERE extension = ? AND id <> ?", extension, huntGroupID).Scan(&existingID)
+	if err == nil {
+		return ErrDuplicateExtension
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to check hunt group extension: %w", err)
+	}
+	return nil
+}
+
+func withTx(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func requireAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read affected rows: %w", err)
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
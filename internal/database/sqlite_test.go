@@ -0,0 +1,106 @@
+package database
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestSQLiteManager(t *testing.T) *SQLiteManager {
+	t.Helper()
+
+	manager := NewSQLiteManager(filepath.Join(t.TempDir(), "test.db"))
+	if err := manager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { manager.Close() })
+	return manager
+}
+
+func TestSQLiteManager_NotInitialized(t *testing.T) {
+	manager := NewSQLiteManager(filepath.Join(t.TempDir(), "test.db"))
+
+	if _, err := manager.GetUser("alice", "example.com"); err == nil {
+		t.Error("Expected error when database is not initialized")
+	}
+	if err := manager.Close(); err != nil {
+		t.Errorf("Close on uninitialized manager should succeed, got %v", err)
+	}
+}
+
+func TestSQLiteManager_InitializeIsIdempotent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	manager := NewSQLiteManager(path)
+	if err := manager.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	if err := manager.CreateUser(&User{Username: "alice", Realm: "example.com", PasswordHash: "hash", Enabled: true}); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	manager.Close()
+
+	// Reopening an existing file must keep its data
+	reopened := NewSQLiteManager(path)
+	if err := reopened.Initialize(); err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer reopened.Close()
+
+	if _, err := reopened.GetUser("alice", "example.com"); err != nil {
+		t.Errorf("Expected user to survive reopen, got %v", err)
+	}
+}
+
+func TestSQLiteManager_Conformance(t *testing.T) {
+	runDatabaseManagerTests(t, func(t *testing.T) DatabaseManager {
+		return newTestSQLiteManager(t)
+	})
+}
+
+func TestSQLiteManager_RawQueries(t *testing.T) {
+	manager := newTestSQLiteManager(t)
+
+	result, err := manager.ExecWithResult(
+		"INSERT INTO users (username, realm, password_hash, enabled, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)",
+		"alice", "example.com", "hash", true, time.Now().UTC(), time.Now().UTC(),
+	)
+	if err != nil {
+		t.Fatalf("Failed to exec insert: %v", err)
+	}
+	if affected, _ := result.RowsAffected(); affected != 1 {
+		t.Errorf("Expected 1 affected row, got %d", affected)
+	}
+
+	var count int
+	if err := manager.QueryRow("SELECT COUNT(*) FROM users", []interface{}{&count}); err != nil {
+		t.Fatalf("Failed to query row: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 user, got %d", count)
+	}
+
+	var username string
+	err = manager.QueryRow("SELECT username FROM users WHERE realm = ?", []interface{}{&username}, "missing.com")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for empty result, got %v", err)
+	}
+
+	rows, err := manager.Query("SELECT username FROM users")
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		if err := rows.Scan(&username); err != nil {
+			t.Fatalf("Failed to scan: %v", err)
+		}
+		names = append(names, username)
+	}
+	if len(names) != 1 || names[0] != "alice" {
+		t.Errorf("Unexpected rows: %v", names)
+	}
+}
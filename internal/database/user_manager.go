@@ -0,0 +1,108 @@
+package database
+
+import (
+	"crypto/md5"
+	"fmt"
+	"strings"
+)
+
+// SIPUserManager implements the UserManager interface on top of a DatabaseManager
+type SIPUserManager struct {
+	db DatabaseManager
+}
+
+// NewSIPUserManager creates a new user manager backed by the given database
+func NewSIPUserManager(db DatabaseManager) *SIPUserManager {
+	return &SIPUserManager{
+		db: db,
+	}
+}
+
+// CreateUser creates a new enabled user with the given password
+func (u *SIPUserManager) CreateUser(username, realm, password string) error {
+	if err := validateUserFields(username, realm); err != nil {
+		return err
+	}
+	if password == "" {
+		return fmt.Errorf("password cannot be empty")
+	}
+
+	user := &User{
+		Username:     username,
+		Realm:        realm,
+		PasswordHash: u.GeneratePasswordHash(username, realm, password),
+		Enabled:      true,
+	}
+
+	if err := u.db.CreateUser(user); err != nil {
+		return fmt.Errorf("failed to create user %s@%s: %w", username, realm, err)
+	}
+	return nil
+}
+
+// AuthenticateUser reports whether the password is valid for an enabled user
+func (u *SIPUserManager) AuthenticateUser(username, realm, password string) bool {
+	user, err := u.db.GetUser(username, realm)
+	if err != nil {
+		return false
+	}
+	if !user.Enabled {
+		return false
+	}
+	return user.PasswordHash == u.GeneratePasswordHash(username, realm, password)
+}
+
+// UpdatePassword replaces the password of an existing user
+func (u *SIPUserManager) UpdatePassword(username, realm, newPassword string) error {
+	if newPassword == "" {
+		return fmt.Errorf("password cannot be empty")
+	}
+
+	user, err := u.db.GetUser(username, realm)
+	if err != nil {
+		return fmt.Errorf("failed to get user %s@%s: %w", username, realm, err)
+	}
+
+	user.PasswordHash = u.GeneratePasswordHash(username, realm, newPassword)
+	if err := u.db.UpdateUser(user); err != nil {
+		return fmt.Errorf("failed to update password for %s@%s: %w", username, realm, err)
+	}
+	return nil
+}
+
+// DeleteUser deletes a user
+func (u *SIPUserManager) DeleteUser(username, realm string) error {
+	if err := u.db.DeleteUser(username, realm); err != nil {
+		return fmt.Errorf("failed to delete user %s@%s: %w", username, realm, err)
+	}
+	return nil
+}
+
+// ListUsers returns all users
+func (u *SIPUserManager) ListUsers() ([]*User, error) {
+	return u.db.ListUsers()
+}
+
+// GetUser retrieves a user by username and realm
+func (u *SIPUserManager) GetUser(username, realm string) (*User, error) {
+	return u.db.GetUser(username, realm)
+}
+
+// GeneratePasswordHash returns the digest HA1 value MD5(username:realm:password).
+// Storing HA1 lets the digest authenticator verify responses without the plain password.
+func (u *SIPUserManager) GeneratePasswordHash(username, realm, password string) string {
+	return fmt.Sprintf("%x", md5.Sum([]byte(username+":"+realm+":"+password)))
+}
+
+func validateUserFields(username, realm string) error {
+	if strings.TrimSpace(username) == "" {
+		return fmt.Errorf("username cannot be empty")
+	}
+	if strings.TrimSpace(realm) == "" {
+		return fmt.Errorf("realm cannot be empty")
+	}
+	if strings.ContainsAny(username, ":@") {
+		return fmt.Errorf("username cannot contain ':' or '@'")
+	}
+	return nil
+}
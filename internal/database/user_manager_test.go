@@ -0,0 +1,165 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSIPUserManager_GeneratePasswordHash(t *testing.T) {
+	userManager := NewSIPUserManager(nil)
+
+	// MD5("alice:example.com:secret")
+	expected := "b1726872c344b6dc8365b774f8fd6412"
+	hash := userManager.GeneratePasswordHash("alice", "example.com", "secret")
+	if hash != expected {
+		t.Errorf("Expected hash %s, got %s", expected, hash)
+	}
+	if hash == userManager.GeneratePasswordHash("alice", "other.com", "secret") {
+		t.Error("Password hash should depend on realm")
+	}
+}
+
+func TestSIPUserManager_Lifecycle(t *testing.T) {
+	userManager := NewSIPUserManager(newTestSQLiteManager(t))
+
+	if err := userManager.CreateUser("alice", "example.com", "secret"); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	if err := userManager.CreateUser("alice", "example.com", "other"); !errors.Is(err, ErrDuplicateUser) {
+		t.Errorf("Expected ErrDuplicateUser, got %v", err)
+	}
+
+	if !userManager.AuthenticateUser("alice", "example.com", "secret") {
+		t.Error("Expected authentication to succeed")
+	}
+	if userManager.AuthenticateUser("alice", "example.com", "wrong") {
+		t.Error("Expected authentication with wrong password to fail")
+	}
+	if userManager.AuthenticateUser("bob", "example.com", "secret") {
+		t.Error("Expected authentication of unknown user to fail")
+	}
+
+	if err := userManager.UpdatePassword("alice", "example.com", "newsecret"); err != nil {
+		t.Fatalf("Failed to update password: %v", err)
+	}
+	if userManager.AuthenticateUser("alice", "example.com", "secret") {
+		t.Error("Old password should no longer work")
+	}
+	if !userManager.AuthenticateUser("alice", "example.com", "newsecret") {
+		t.Error("New password should work")
+	}
+	if err := userManager.UpdatePassword("bob", "example.com", "secret"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound updating unknown user, got %v", err)
+	}
+
+	user, err := userManager.GetUser("alice", "example.com")
+	if err != nil {
+		t.Fatalf("Failed to get user: %v", err)
+	}
+	if user.PasswordHash != userManager.GeneratePasswordHash("alice", "example.com", "newsecret") {
+		t.Error("Stored password hash should be the digest HA1 value")
+	}
+
+	users, err := userManager.ListUsers()
+	if err != nil {
+		t.Fatalf("Failed to list users: %v", err)
+	}
+	if len(users) != 1 {
+		t.Errorf("Expected 1 user, got %d", len(users))
+	}
+
+	if err := userManager.DeleteUser("alice", "example.com"); err != nil {
+		t.Fatalf("Failed to delete user: %v", err)
+	}
+	if err := userManager.DeleteUser("alice", "example.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting unknown user, got %v", err)
+	}
+}
+
+func TestSIPUserManager_DisabledUserCannotAuthenticate(t *testing.T) {
+	db := newTestSQLiteManager(t)
+	userManager := NewSIPUserManager(db)
+
+	if err := userManager.CreateUser("alice", "example.com", "secret"); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	user, err := db.GetUser("alice", "example.com")
+	if err != nil {
+		t.Fatalf("Failed to get user: %v", err)
+	}
+	user.Enabled = false
+	if err := db.UpdateUser(user); err != nil {
+		t.Fatalf("Failed to disable user: %v", err)
+	}
+
+	if userManager.AuthenticateUser("alice", "example.com", "secret") {
+		t.Error("Disabled user should not authenticate")
+	}
+}
+
+func TestSIPUserManager_CreateUserValidation(t *testing.T) {
+	userManager := NewSIPUserManager(newTestSQLiteManager(t))
+
+	tests := []struct {
+		name     string
+		username string
+		realm    string
+		password string
+	}{
+		{"empty username", "", "example.com", "secret"},
+		{"empty realm", "alice", "", "secret"},
+		{"empty password", "alice", "example.com", ""},
+		{"username with colon", "al:ice", "example.com", "secret"},
+		{"username with at sign", "alice@home", "example.com", "secret"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := userManager.CreateUser(tt.username, tt.realm, tt.password); err == nil {
+				t.Error("Expected validation error")
+			}
+		})
+	}
+}
+
+func TestSQLRegistrationDB(t *testing.T) {
+	registrationDB := NewRegistrationDB(newTestSQLiteManager(t))
+	aor := "sip:alice@example.com"
+
+	contact := &RegistrarContact{
+		AOR:     aor,
+		URI:     "sip:alice@192.168.1.100:5060",
+		Expires: time.Now().UTC().Add(time.Hour),
+		CallID:  "call-1",
+		CSeq:    7,
+	}
+	if err := registrationDB.Store(contact); err != nil {
+		t.Fatalf("Failed to store contact: %v", err)
+	}
+	if err := registrationDB.Store(&RegistrarContact{AOR: aor}); err == nil {
+		t.Error("Expected error storing contact without URI")
+	}
+
+	contacts, err := registrationDB.Retrieve(aor, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to retrieve contacts: %v", err)
+	}
+	if len(contacts) != 1 {
+		t.Fatalf("Expected 1 contact, got %d", len(contacts))
+	}
+	if contacts[0].URI != contact.URI || contacts[0].CallID != "call-1" || contacts[0].CSeq != 7 {
+		t.Errorf("Unexpected contact: %+v", contacts[0])
+	}
+
+	if err := registrationDB.CleanupExpired(time.Now()); err != nil {
+		t.Fatalf("Failed to cleanup expired contacts: %v", err)
+	}
+	if err := registrationDB.Delete(aor, contact.URI); err != nil {
+		t.Fatalf("Failed to delete contact: %v", err)
+	}
+	if err := registrationDB.Delete(aor, contact.URI); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting missing contact, got %v", err)
+	}
+}
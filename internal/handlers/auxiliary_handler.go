@@ -95,8 +95,13 @@ func (h *AuxiliaryHandler) handleOptions(req *parser.SIPMessage, txn transaction
 func (h *AuxiliaryHandler) handleInfo(req *parser.SIPMessage, txn transaction.Transaction) error {
 	// INFO requests should be forwarded within established dialogs
 	// Extract target URI from Request-URI
-	targetURI := req.GetRequestURI()
-	aor := h.extractAOR(targetURI)
+	targetURI, err := parser.ParseURI(req.GetRequestURI())
+	if err != nil {
+		response := parser.NewResponseMessage(parser.StatusBadRequest, parser.GetReasonPhraseForCode(parser.StatusBadRequest))
+		h.copyResponseHeaders(req, response)
+		return txn.SendResponse(response)
+	}
+	aor := targetURI.AOR()
 
 	// Find registered contacts for the target
 	contacts, err := h.registrar.FindContacts(aor)
@@ -138,25 +143,4 @@ func (h *AuxiliaryHandler) copyResponseHeaders(req *parser.SIPMessage, resp *par
 	
 	// Set Content-Length to 0 for responses without body
 	resp.SetHeader(parser.HeaderContentLength, "0")
-}
-
-// extractAOR extracts the Address of Record from a SIP URI
-func (h *AuxiliaryHandler) extractAOR(uri string) string {
-	// Simple AOR extraction - in a real implementation, this would be more sophisticated
-	// Remove sip: or sips: prefix
-	if strings.HasPrefix(uri, "sip:") {
-		uri = uri[4:]
-	} else if strings.HasPrefix(uri, "sips:") {
-		uri = uri[5:]
-	}
-	
-	// Remove parameters and headers
-	if idx := strings.Index(uri, ";"); idx != -1 {
-		uri = uri[:idx]
-	}
-	if idx := strings.Index(uri, "?"); idx != -1 {
-		uri = uri[:idx]
-	}
-	
-	return uri
 }
\ No newline at end of file
@@ -277,27 +277,39 @@ func TestAuxiliaryHandler_HandleUnsupportedMethod(t *testing.T) {
 	}
 }
 
-func TestAuxiliaryHandler_ExtractAOR(t *testing.T) {
-	handler := NewAuxiliaryHandler(nil, nil)
-
+func TestAuxiliaryHandler_LookupAOR(t *testing.T) {
 	tests := []struct {
 		uri      string
 		expected string
 	}{
-		{"sip:user@example.com", "user@example.com"},
-		{"sips:user@example.com", "user@example.com"},
-		{"sip:user@example.com:5060", "user@example.com:5060"},
-		{"sip:user@example.com;transport=tcp", "user@example.com"},
-		{"sip:user@example.com?header=value", "user@example.com"},
-		{"sip:user@example.com;transport=tcp?header=value", "user@example.com"},
-		{"user@example.com", "user@example.com"},
+		{"sip:user@example.com", "sip:user@example.com"},
+		{"sips:user@example.com", "sips:user@example.com"},
+		{"sip:user@example.com:5060", "sip:user@example.com:5060"},
+		{"sip:user@EXAMPLE.com", "sip:user@example.com"},
+		{"sip:user@example.com;transport=tcp", "sip:user@example.com"},
+		{"sip:user@example.com?header=value", "sip:user@example.com"},
+		{"sip:user@example.com;transport=tcp?header=value", "sip:user@example.com"},
 	}
 
 	for _, test := range tests {
 		t.Run(test.uri, func(t *testing.T) {
-			result := handler.extractAOR(test.uri)
-			if result != test.expected {
-				t.Errorf("Expected '%s', got '%s' for URI '%s'", test.expected, result, test.uri)
+			var aor string
+			mockReg := &mockRegistrar{
+				findContactsFunc: func(a string) ([]*database.RegistrarContact, error) {
+					aor = a
+					return nil, nil
+				},
+			}
+			handler := NewAuxiliaryHandler(nil, mockReg)
+
+			info := parser.NewRequestMessage(parser.MethodINFO, test.uri)
+			info.SetHeader(parser.HeaderVia, "SIP/2.0/UDP client.example.com:5060;branch=z9hG4bK123")
+			info.SetHeader(parser.HeaderCallID, "test-call-id@example.com")
+			info.SetHeader(parser.HeaderCSeq, "2 INFO")
+
+			handler.HandleRequest(info, &mockTransaction{})
+			if aor != test.expected {
+				t.Errorf("Expected '%s', got '%s' for URI '%s'", test.expected, aor, test.uri)
 			}
 		})
 	}
@@ -451,8 +451,8 @@ func (ehv *EnhancedHeaderValidator) ValidateHostPort(hostPort string) string {
 	
 	host, port, err := net.SplitHostPort(hostPort)
 	if err != nil {
-		// Try without port
-		if net.ParseIP(hostPort) != nil || isValidHostname(hostPort) {
+		// Try without port; IPv6 references may be bracketed
+		if net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(hostPort, "["), "]")) != nil || isValidHostname(hostPort) {
 			return "" // Valid host without port
 		}
 		return "invalid host:port format"
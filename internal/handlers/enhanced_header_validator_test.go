@@ -504,6 +504,16 @@ func TestValidateHostPort(t *testing.T) {
 			hostPort:    "example.com",
 			expectError: false,
 		},
+		{
+			name:        "Valid IPv6 reference:port",
+			hostPort:    "[2001:db8::1]:5060",
+			expectError: false,
+		},
+		{
+			name:        "Valid IPv6 reference without port",
+			hostPort:    "[2001:db8::1]",
+			expectError: false,
+		},
 		{
 			name:        "Empty host:port",
 			hostPort:    "",
@@ -1,10 +1,13 @@
 package handlers
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"regexp"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/zurustar/xylitol2/internal/parser"
 )
@@ -62,11 +65,16 @@ func (mt MalformedType) String() string {
 	}
 }
 
-// DetectMalformedMessage analyzes raw message data to detect specific malformation issues
+// DetectMalformedMessage analyzes raw message data to detect specific malformation issues.
+// Only the start line and headers are inspected by the heuristic checks; the
+// body may hold arbitrary bytes. The message is then run through the parser so
+// that anything it rejects is reported too. Missing mandatory headers are not
+// malformations and are left to header validation.
 func (mmd *MalformedMessageDetector) DetectMalformedMessage(rawMessage []byte) []MalformedMessageError {
 	var errors []MalformedMessageError
 	
-	messageStr := string(rawMessage)
+	head := headerSection(rawMessage)
+	messageStr := string(head)
 	lines := strings.Split(messageStr, "\n")
 	
 	// Check for proper line endings
@@ -86,13 +94,81 @@ func (mmd *MalformedMessageDetector) DetectMalformedMessage(rawMessage []byte) [
 	errors = append(errors, headerErrors...)
 	
 	// Check for encoding issues
-	if encodingErrors := mmd.checkEncoding(rawMessage); len(encodingErrors) > 0 {
+	if encodingErrors := mmd.checkEncoding(head); len(encodingErrors) > 0 {
 		errors = append(errors, encodingErrors...)
 	}
 	
+	// Report what the parser rejects unless a check above already found a problem of that type
+	if parserError := mmd.checkParser(rawMessage); parserError != nil && !hasMalformedType(errors, parserError.Type) {
+		errors = append(errors, *parserError)
+	}
+	
 	return errors
 }
 
+// headerSection returns the start line and headers of a raw message, up to
+// and including the line break before the empty line that ends them
+func headerSection(rawMessage []byte) []byte {
+	offset := 0
+	for offset < len(rawMessage) {
+		lineEnd := bytes.IndexByte(rawMessage[offset:], '\n')
+		if lineEnd < 0 {
+			break
+		}
+		line := bytes.TrimSuffix(rawMessage[offset:offset+lineEnd], []byte("\r"))
+		if len(line) == 0 && offset > 0 {
+			return rawMessage[:offset]
+		}
+		offset += lineEnd + 1
+	}
+	return rawMessage
+}
+
+// hasMalformedType checks if errors contain an error of the given type
+func hasMalformedType(errors []MalformedMessageError, malformedType MalformedType) bool {
+	for _, err := range errors {
+		if err.Type == malformedType {
+			return true
+		}
+	}
+	return false
+}
+
+// checkParser parses and validates the message, classifying a failure by the
+// parser error it produced
+func (mmd *MalformedMessageDetector) checkParser(rawMessage []byte) *MalformedMessageError {
+	p := parser.NewParser()
+	msg, err := p.Parse(rawMessage)
+	if err == nil {
+		err = p.Validate(msg)
+	}
+	if err == nil || errors.Is(err, parser.ErrMissingHeader) {
+		return nil
+	}
+	
+	malformedType, location := MalformedHeaderValue, "header value"
+	switch {
+	case errors.Is(err, parser.ErrInvalidStartLine), errors.Is(err, parser.ErrInvalidMethod),
+		errors.Is(err, parser.ErrInvalidRequestURI), errors.Is(err, parser.ErrUnsupportedVersion),
+		errors.Is(err, parser.ErrInvalidStatusCode):
+		malformedType, location = MalformedStartLine, "first line"
+	case errors.Is(err, parser.ErrInvalidHeader):
+		malformedType, location = MalformedHeader, "headers"
+	case errors.Is(err, parser.ErrIncompleteBody):
+		malformedType, location = MalformedBody, "message body"
+	}
+	
+	return &MalformedMessageError{
+		Type:        malformedType,
+		Description: err.Error(),
+		Location:    location,
+		Suggestion:  "Format the message according to the RFC 3261 grammar",
+		Context: map[string]interface{}{
+			"parser_error": err.Error(),
+		},
+	}
+}
+
 // checkLineEndings verifies proper CRLF line endings
 func (mmd *MalformedMessageDetector) checkLineEndings(message string) []MalformedMessageError {
 	var errors []MalformedMessageError
@@ -147,8 +223,10 @@ func (mmd *MalformedMessageDetector) checkStartLine(startLine string) []Malforme
 		return errors
 	}
 	
+	// A response may have an empty reason phrase
 	parts := strings.Fields(startLine)
-	if len(parts) < 3 {
+	isResponse := strings.HasPrefix(startLine, "SIP/")
+	if len(parts) < 3 && !(isResponse && len(parts) == 2) {
 		errors = append(errors, MalformedMessageError{
 			Type:        MalformedStartLine,
 			Description: "Start line must have at least 3 parts",
@@ -163,7 +241,7 @@ func (mmd *MalformedMessageDetector) checkStartLine(startLine string) []Malforme
 	}
 	
 	// Check if it's a request or response
-	if strings.HasPrefix(startLine, "SIP/2.0") {
+	if isResponse {
 		// Response line validation
 		errors = append(errors, mmd.validateResponseLine(parts)...)
 	} else {
@@ -178,38 +256,28 @@ func (mmd *MalformedMessageDetector) checkStartLine(startLine string) []Malforme
 func (mmd *MalformedMessageDetector) validateRequestLine(parts []string) []MalformedMessageError {
 	var errors []MalformedMessageError
 	
-	// Check method
+	// Check method; extension methods are any token (RFC 3261 §25.1)
 	method := parts[0]
-	validMethods := []string{"INVITE", "ACK", "BYE", "CANCEL", "REGISTER", "OPTIONS", "INFO", "PRACK", "UPDATE", "REFER", "NOTIFY", "SUBSCRIBE"}
-	isValidMethod := false
-	for _, validMethod := range validMethods {
-		if method == validMethod {
-			isValidMethod = true
-			break
-		}
-	}
-	
-	if !isValidMethod {
+	if !parser.IsToken(method) {
 		errors = append(errors, MalformedMessageError{
 			Type:        MalformedStartLine,
-			Description: fmt.Sprintf("Invalid or unknown SIP method: %s", method),
+			Description: fmt.Sprintf("Invalid SIP method: %s", method),
 			Location:    "request method",
-			Suggestion:  fmt.Sprintf("Use a valid SIP method: %s", strings.Join(validMethods, ", ")),
+			Suggestion:  "Use a method name made of token characters, e.g. INVITE",
 			Context: map[string]interface{}{
 				"invalid_method": method,
-				"valid_methods":  validMethods,
 			},
 		})
 	}
 	
 	// Check Request-URI
 	requestURI := parts[1]
-	if !strings.HasPrefix(requestURI, "sip:") && !strings.HasPrefix(requestURI, "sips:") {
+	if !strings.HasPrefix(requestURI, "sip:") && !strings.HasPrefix(requestURI, "sips:") && !strings.HasPrefix(requestURI, "tel:") {
 		errors = append(errors, MalformedMessageError{
 			Type:        MalformedStartLine,
 			Description: "Request-URI must be a SIP or SIPS URI",
 			Location:    "request URI",
-			Suggestion:  "Use format: sip:user@domain or sips:user@domain",
+			Suggestion:  "Use format: sip:user@domain, sips:user@domain or tel:number",
 			Context: map[string]interface{}{
 				"invalid_uri": requestURI,
 			},
@@ -268,36 +336,26 @@ func (mmd *MalformedMessageDetector) validateResponseLine(parts []string) []Malf
 	return errors
 }
 
+// headerNameRegex matches a header name token followed by optional whitespace and a colon
+var headerNameRegex = regexp.MustCompile("^([A-Za-z0-9\\-.!%*_+`'~]+)[ \t]*:[ \t]*(.*)$")
+
 // checkHeaders validates SIP header format
 func (mmd *MalformedMessageDetector) checkHeaders(lines []string) []MalformedMessageError {
 	var errors []MalformedMessageError
 	
-	headerRegex := regexp.MustCompile(`^([a-zA-Z0-9\-]+):\s*(.*)$`)
-	
-	for i, line := range lines {
-		if i == 0 {
-			continue // Skip start line
-		}
-		
-		line = strings.TrimRight(line, "\r")
-		if line == "" {
-			break // End of headers
-		}
-		
-		// Check for header continuation (folding)
-		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
-			continue // Header folding is valid
-		}
+	for _, header := range unfoldHeaderLines(lines) {
+		line := header.text
 		
 		// Check header format
-		if !headerRegex.MatchString(line) {
+		matches := headerNameRegex.FindStringSubmatch(line)
+		if matches == nil {
 			errors = append(errors, MalformedMessageError{
 				Type:        MalformedHeader,
 				Description: "Invalid header format",
-				Location:    fmt.Sprintf("line %d", i+1),
+				Location:    fmt.Sprintf("line %d", header.lineNumber),
 				Suggestion:  "Use format: Header-Name: header-value",
 				Context: map[string]interface{}{
-					"line_number":   i + 1,
+					"line_number":   header.lineNumber,
 					"invalid_line":  line,
 					"expected_format": "Header-Name: header-value",
 				},
@@ -305,29 +363,55 @@ func (mmd *MalformedMessageDetector) checkHeaders(lines []string) []MalformedMes
 			continue
 		}
 		
-		// Extract header name and value
-		matches := headerRegex.FindStringSubmatch(line)
-		if len(matches) >= 3 {
-			headerName := matches[1]
-			headerValue := matches[2]
-			
-			// Validate specific headers
-			if headerErrors := mmd.validateSpecificHeader(headerName, headerValue, i+1); len(headerErrors) > 0 {
-				errors = append(errors, headerErrors...)
-			}
+		// Validate specific headers
+		headerName := matches[1]
+		headerValue := strings.TrimSpace(matches[2])
+		if headerErrors := mmd.validateSpecificHeader(headerName, headerValue, header.lineNumber); len(headerErrors) > 0 {
+			errors = append(errors, headerErrors...)
 		}
 	}
 	
 	return errors
 }
 
+// foldedHeader is a header with its continuation lines joined, and the line
+// number it starts on
+type foldedHeader struct {
+	text       string
+	lineNumber int
+}
+
+// unfoldHeaderLines joins continuation lines (starting with space or tab)
+// to the header they continue. lines[0] is the start line and is skipped.
+func unfoldHeaderLines(lines []string) []foldedHeader {
+	var headers []foldedHeader
+	for i, line := range lines {
+		if i == 0 {
+			continue // Skip start line
+		}
+		
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			break // End of headers
+		}
+		
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(headers) > 0 {
+			last := &headers[len(headers)-1]
+			last.text = strings.TrimRight(last.text, " \t") + " " + strings.TrimSpace(line)
+			continue
+		}
+		headers = append(headers, foldedHeader{text: line, lineNumber: i + 1})
+	}
+	return headers
+}
+
 // validateSpecificHeader validates specific header values
 func (mmd *MalformedMessageDetector) validateSpecificHeader(name, value string, lineNumber int) []MalformedMessageError {
 	var errors []MalformedMessageError
 	
 	switch strings.ToLower(name) {
-	case "content-length":
-		if _, err := strconv.Atoi(strings.TrimSpace(value)); err != nil {
+	case "content-length", "l":
+		if _, err := strconv.ParseUint(strings.TrimSpace(value), 10, 31); err != nil {
 			errors = append(errors, MalformedMessageError{
 				Type:        MalformedHeaderValue,
 				Description: "Content-Length must be a non-negative integer",
@@ -354,7 +438,7 @@ func (mmd *MalformedMessageDetector) validateSpecificHeader(name, value string,
 				},
 			})
 		} else {
-			if _, err := strconv.Atoi(parts[0]); err != nil {
+			if _, err := strconv.ParseUint(parts[0], 10, 31); err != nil {
 				errors = append(errors, MalformedMessageError{
 					Type:        MalformedHeaderValue,
 					Description: "CSeq sequence number must be an integer",
@@ -384,8 +468,21 @@ func (mmd *MalformedMessageDetector) validateSpecificHeader(name, value string,
 			}
 		}
 		
-	case "via":
-		if !strings.Contains(value, "SIP/2.0/") {
+	case "via", "v":
+		// LWS is allowed around the '/' separators, so check the parsed values
+		vias, err := parser.ParseViaList(value)
+		sipVersion := err == nil
+		hasBranch := err == nil
+		for _, via := range vias {
+			if !strings.EqualFold(via.Protocol, "SIP") || via.Version != "2.0" {
+				sipVersion = false
+			}
+			if !via.Params.Has("branch") {
+				hasBranch = false
+			}
+		}
+		
+		if !sipVersion {
 			errors = append(errors, MalformedMessageError{
 				Type:        MalformedHeaderValue,
 				Description: "Via header must contain SIP/2.0 protocol version",
@@ -396,9 +493,7 @@ func (mmd *MalformedMessageDetector) validateSpecificHeader(name, value string,
 					"invalid_value": value,
 				},
 			})
-		}
-		
-		if !strings.Contains(value, "branch=") {
+		} else if !hasBranch {
 			errors = append(errors, MalformedMessageError{
 				Type:        MalformedHeaderValue,
 				Description: "Via header must contain branch parameter",
@@ -415,13 +510,25 @@ func (mmd *MalformedMessageDetector) validateSpecificHeader(name, value string,
 	return errors
 }
 
-// checkEncoding checks for encoding issues in the message
-func (mmd *MalformedMessageDetector) checkEncoding(rawMessage []byte) []MalformedMessageError {
+// structuredHeaders lists headers (including compact forms) whose values
+// may only contain non-ASCII characters inside quoted strings
+var structuredHeaders = map[string]bool{
+	"via": true, "v": true, "from": true, "f": true, "to": true, "t": true,
+	"contact": true, "m": true, "call-id": true, "i": true, "cseq": true,
+	"max-forwards": true, "content-length": true, "l": true,
+	"content-type": true, "c": true, "route": true, "record-route": true,
+	"expires": true,
+}
+
+// checkEncoding checks for encoding issues in the start line and headers.
+// UTF-8 is allowed in reason phrases, quoted strings and free-text header
+// values, and a null byte only as an escaped character in a quoted string.
+func (mmd *MalformedMessageDetector) checkEncoding(head []byte) []MalformedMessageError {
 	var errors []MalformedMessageError
 	
 	// Check for null bytes
-	for i, b := range rawMessage {
-		if b == 0 {
+	for i, b := range head {
+		if b == 0 && (i == 0 || head[i-1] != '\\') {
 			errors = append(errors, MalformedMessageError{
 				Type:        MalformedEncoding,
 				Description: "Null bytes found in message",
@@ -435,36 +542,66 @@ func (mmd *MalformedMessageDetector) checkEncoding(rawMessage []byte) []Malforme
 		}
 	}
 	
-	// Check for non-ASCII characters in headers (basic check)
-	messageStr := string(rawMessage)
-	lines := strings.Split(messageStr, "\n")
+	if !utf8.Valid(head) {
+		errors = append(errors, MalformedMessageError{
+			Type:        MalformedEncoding,
+			Description: "Invalid UTF-8 found in headers",
+			Location:    "headers",
+			Suggestion:  "Encode non-ASCII text in headers as UTF-8",
+		})
+	}
 	
-	for i, line := range lines {
-		if line == "" {
-			break // End of headers
+	// Check for non-ASCII characters where the grammar only allows ASCII
+	lines := strings.Split(string(head), "\n")
+	if !strings.HasPrefix(lines[0], "SIP/") {
+		if position := nonASCIIPosition(lines[0], false); position >= 0 {
+			errors = append(errors, nonASCIIError(1, position, lines[0]))
 		}
-		
-		for j, r := range line {
-			if r > 127 {
-				errors = append(errors, MalformedMessageError{
-					Type:        MalformedEncoding,
-					Description: "Non-ASCII characters found in headers",
-					Location:    fmt.Sprintf("line %d, position %d", i+1, j+1),
-					Suggestion:  "Use only ASCII characters in SIP headers",
-					Context: map[string]interface{}{
-						"line_number": i + 1,
-						"char_position": j + 1,
-						"character": string(r),
-					},
-				})
-				break
-			}
+	}
+	for _, header := range unfoldHeaderLines(lines) {
+		name, _, _ := strings.Cut(header.text, ":")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if position := nonASCIIPosition(header.text, true); position >= 0 && (structuredHeaders[name] || position < len(name)) {
+			errors = append(errors, nonASCIIError(header.lineNumber, position, header.text))
+			break
 		}
 	}
 	
 	return errors
 }
 
+// nonASCIIPosition returns the byte offset of the first non-ASCII character
+// in line, skipping quoted strings if skipQuoted is set, or -1 if there is none
+func nonASCIIPosition(line string, skipQuoted bool) int {
+	inQuotes := false
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case skipQuoted && inQuotes && c == '\\':
+			i++
+		case skipQuoted && c == '"':
+			inQuotes = !inQuotes
+		case c > 127 && !inQuotes:
+			return i
+		}
+	}
+	return -1
+}
+
+func nonASCIIError(lineNumber, position int, line string) MalformedMessageError {
+	r, _ := utf8.DecodeRuneInString(line[position:])
+	return MalformedMessageError{
+		Type:        MalformedEncoding,
+		Description: "Non-ASCII characters found in headers",
+		Location:    fmt.Sprintf("line %d, position %d", lineNumber, position+1),
+		Suggestion:  "Use only ASCII characters in SIP headers",
+		Context: map[string]interface{}{
+			"line_number": lineNumber,
+			"char_position": position + 1,
+			"character": string(r),
+		},
+	}
+}
+
 // GenerateMalformedMessageResponse generates a detailed response for malformed messages
 func (mmd *MalformedMessageDetector) GenerateMalformedMessageResponse(malformedErrors []MalformedMessageError, rawMessage []byte) *parser.SIPMessage {
 	if len(malformedErrors) == 0 {
@@ -1,6 +1,9 @@
 package handlers
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -132,9 +135,9 @@ func TestDetectMalformedMessage_StartLine(t *testing.T) {
 		},
 		{
 			name:         "Invalid method",
-			message:      "INVALID sip:test@example.com SIP/2.0\r\n\r\n",
+			message:      "INV@LID sip:test@example.com SIP/2.0\r\n\r\n",
 			expectErrors: true,
-			description:  "Invalid or unknown SIP method",
+			description:  "Invalid SIP method",
 		},
 		{
 			name:         "Invalid Request-URI",
@@ -154,6 +157,16 @@ func TestDetectMalformedMessage_StartLine(t *testing.T) {
 			expectErrors: true,
 			description:  "Invalid status code",
 		},
+		{
+			name:         "Extension method",
+			message:      "FOO.bar sip:test@example.com SIP/2.0\r\n\r\n",
+			expectErrors: false,
+		},
+		{
+			name:         "Response without reason phrase",
+			message:      "SIP/2.0 100 \r\n\r\n",
+			expectErrors: false,
+		},
 		{
 			name:         "Too few parts",
 			message:      "INVITE sip:test@example.com\r\n\r\n",
@@ -306,6 +319,21 @@ func TestDetectMalformedMessage_Encoding(t *testing.T) {
 			message:      []byte("INVITE sip:test@example.com SIP/2.0\r\nVia: SIP/2.0/UDP host:5060\r\n\r\n"),
 			expectErrors: false,
 		},
+		{
+			name:         "UTF-8 in quoted string and free text",
+			message:      []byte("INVITE sip:test@example.com SIP/2.0\r\nFrom: \"Jos\xc3\xa9\" <sip:jose@example.com>\r\nSubject: caf\xc3\xa9\r\n\r\n"),
+			expectErrors: false,
+		},
+		{
+			name:         "Escaped null byte in quoted string",
+			message:      []byte("INVITE sip:test@example.com SIP/2.0\r\nTo: \"NUL:\\\x00\" <sip:test@example.com>\r\n\r\n"),
+			expectErrors: false,
+		},
+		{
+			name:         "Binary body",
+			message:      []byte("INVITE sip:test@example.com SIP/2.0\r\nContent-Length: 4\r\n\r\n\x00\xff\n\x80"),
+			expectErrors: false,
+		},
 		{
 			name:         "Message with null bytes",
 			message:      []byte("INVITE sip:test@example.com SIP/2.0\r\n\x00Via: SIP/2.0/UDP host:5060\r\n\r\n"),
@@ -487,4 +515,46 @@ func TestValidateSpecificHeader_EdgeCases(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+// TestDetectMalformedMessage_RFC4475 feeds the RFC 4475 torture test messages
+// shipped with the parser to the detector. Its verdict must match the
+// parser's, except that missing mandatory headers are not malformations.
+func TestDetectMalformedMessage_RFC4475(t *testing.T) {
+	errorHandler := NewDefaultErrorHandler()
+	errorGenerator := NewDetailedErrorResponseGenerator(errorHandler)
+	detector := NewMalformedMessageDetector(errorGenerator)
+	
+	files, err := filepath.Glob(filepath.Join("..", "parser", "testdata", "rfc4475", "*", "*.sip"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("No torture test messages found: %v", err)
+	}
+	
+	p := parser.NewParser()
+	for _, file := range files {
+		name := filepath.Base(filepath.Dir(file)) + "/" + filepath.Base(file)
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatalf("Failed to read message: %v", err)
+			}
+			
+			msg, parseErr := p.Parse(data)
+			if parseErr == nil {
+				parseErr = p.Validate(msg)
+			}
+			if valid := strings.Contains(file, string(filepath.Separator)+"valid"+string(filepath.Separator)); valid != (parseErr == nil) {
+				t.Fatalf("Parser verdict does not match corpus: %v", parseErr)
+			}
+			
+			malformed := detector.DetectMalformedMessage(data)
+			expectMalformed := parseErr != nil && !errors.Is(parseErr, parser.ErrMissingHeader)
+			if expectMalformed && len(malformed) == 0 {
+				t.Errorf("Expected malformed message errors for parser error: %v", parseErr)
+			}
+			if !expectMalformed && len(malformed) > 0 {
+				t.Errorf("Expected no errors, got %d errors: %v", len(malformed), malformed)
+			}
+		})
+	}
+}
@@ -44,12 +44,17 @@ func (h *RegisterHandler) HandleRequest(req *parser.SIPMessage, txn transaction.
 		return txn.SendResponse(response)
 	}
 
-	aor := h.extractAOR(toHeader)
-	if aor == "" {
+	to, err := parser.ParseNameAddr(toHeader)
+	var toURI *parser.URI
+	if err == nil {
+		toURI, err = parser.ParseURI(to.URI)
+	}
+	if err != nil {
 		response := parser.NewResponseMessage(parser.StatusBadRequest, parser.GetReasonPhraseForCode(parser.StatusBadRequest))
 		h.copyResponseHeaders(req, response)
 		return txn.SendResponse(response)
 	}
+	aor := toURI.AOR()
 
 	// Get Contact header(s)
 	contactHeaders := req.GetHeaders(parser.HeaderContact)
@@ -65,8 +70,10 @@ func (h *RegisterHandler) HandleRequest(req *parser.SIPMessage, txn transaction.
 
 	// Process each contact
 	var registeredContacts []string
+	var bindings []*database.RegistrarContact
 	for _, contactHeader := range contactHeaders {
-		if err := h.processContact(req, aor, contactHeader, expiresHeader, defaultExpires); err != nil {
+		binding, err := h.processContact(req, aor, contactHeader, expiresHeader, defaultExpires)
+		if err != nil {
 			h.logger.Error("Failed to process contact", 
 				logging.Field{Key: "error", Value: err},
 				logging.Field{Key: "contact", Value: contactHeader})
@@ -75,6 +82,9 @@ func (h *RegisterHandler) HandleRequest(req *parser.SIPMessage, txn transaction.
 			return txn.SendResponse(response)
 		}
 		registeredContacts = append(registeredContacts, contactHeader)
+		if binding != nil {
+			bindings = append(bindings, binding)
+		}
 	}
 
 	// Create successful response
@@ -85,6 +95,7 @@ func (h *RegisterHandler) HandleRequest(req *parser.SIPMessage, txn transaction.
 	for _, contact := range registeredContacts {
 		response.AddHeader(parser.HeaderContact, contact)
 	}
+	registrar.SetOutboundRequire(response, bindings)
 
 	// Add Date header
 	response.SetHeader("Date", time.Now().UTC().Format(time.RFC1123))
@@ -96,17 +107,18 @@ func (h *RegisterHandler) HandleRequest(req *parser.SIPMessage, txn transaction.
 	return txn.SendResponse(response)
 }
 
-// processContact processes a single contact header for registration
-func (h *RegisterHandler) processContact(req *parser.SIPMessage, aor, contactHeader, expiresHeader string, defaultExpires int) error {
+// processContact processes a single contact header for registration and
+// returns the binding it created, or nil if it removed bindings instead
+func (h *RegisterHandler) processContact(req *parser.SIPMessage, aor, contactHeader, expiresHeader string, defaultExpires int) (*database.RegistrarContact, error) {
 	// Parse contact URI and parameters
 	contactURI, params := h.parseContactHeader(contactHeader)
 	if contactURI == "" {
-		return fmt.Errorf("invalid contact URI")
+		return nil, fmt.Errorf("invalid contact URI")
 	}
 
 	// Check for wildcard contact (unregister all)
 	if contactURI == "*" {
-		return h.registrar.Unregister(aor)
+		return nil, h.registrar.Unregister(aor)
 	}
 
 	// Get expires value (from contact parameter, Expires header, or default)
@@ -126,16 +138,16 @@ func (h *RegisterHandler) processContact(req *parser.SIPMessage, aor, contactHea
 		// Find and remove specific contact
 		contacts, err := h.registrar.FindContacts(aor)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		for _, contact := range contacts {
 			if contact.URI == contactURI {
 				// Remove this specific contact
-				return h.registrar.Unregister(aor) // Simplified - in real implementation, remove specific contact
+				return nil, h.registrar.Unregister(aor) // Simplified - in real implementation, remove specific contact
 			}
 		}
-		return nil // Contact not found, but that's OK
+		return nil, nil // Contact not found, but that's OK
 	}
 
 	// Create contact for registration
@@ -146,8 +158,13 @@ func (h *RegisterHandler) processContact(req *parser.SIPMessage, aor, contactHea
 		CallID:  req.GetHeader(parser.HeaderCallID),
 		CSeq:    h.parseCSeq(req.GetHeader(parser.HeaderCSeq)),
 	}
+	registrar.SetObservedFlow(contact, req)
+	registrar.SetOutboundParams(contact, contactHeader, req)
 
-	return h.registrar.Register(contact, expires)
+	if err := h.registrar.Register(contact, expires); err != nil {
+		return nil, err
+	}
+	return contact, nil
 }
 
 // parseContactHeader parses a Contact header and returns URI and parameters
@@ -243,32 +260,6 @@ func (h *RegisterHandler) copyResponseHeaders(req *parser.SIPMessage, resp *pars
 	resp.SetHeader(parser.HeaderContentLength, "0")
 }
 
-// extractAOR extracts the Address of Record from a SIP URI
-func (h *RegisterHandler) extractAOR(uri string) string {
-	// Remove display name if present
-	if idx := strings.Index(uri, "<"); idx != -1 {
-		if endIdx := strings.Index(uri[idx:], ">"); endIdx != -1 {
-			uri = uri[idx+1 : idx+endIdx]
-		}
-	}
-	
-	// Remove sip: or sips: prefix
-	if strings.HasPrefix(uri, "sip:") {
-		uri = uri[4:]
-	} else if strings.HasPrefix(uri, "sips:") {
-		uri = uri[5:]
-	}
-	
-	// Remove parameters and headers
-	if idx := strings.Index(uri, ";"); idx != -1 {
-		uri = uri[:idx]
-	}
-	if idx := strings.Index(uri, "?"); idx != -1 {
-		uri = uri[:idx]
-	}
-	
-	return uri
-}
 
 // containsTag checks if a header contains a tag parameter
 func (h *RegisterHandler) containsTag(header string) bool {
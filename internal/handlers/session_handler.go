@@ -96,8 +96,13 @@ func (h *SessionHandler) handleInvite(req *parser.SIPMessage, txn transaction.Tr
 	}
 
 	// Extract target URI from Request-URI
-	targetURI := req.GetRequestURI()
-	aor := h.extractAOR(targetURI)
+	targetURI, err := parser.ParseURI(req.GetRequestURI())
+	if err != nil {
+		response := parser.NewResponseMessage(parser.StatusBadRequest, parser.GetReasonPhraseForCode(parser.StatusBadRequest))
+		h.copyResponseHeaders(req, response)
+		return txn.SendResponse(response)
+	}
+	aor := targetURI.AOR()
 
 	// Find registered contacts for the target
 	contacts, err := h.registrar.FindContacts(aor)
@@ -134,8 +139,12 @@ func (h *SessionHandler) handleAck(req *parser.SIPMessage, txn transaction.Trans
 	// For non-2xx responses, ACK is hop-by-hop and terminates the transaction
 	
 	// Extract target URI from Request-URI
-	targetURI := req.GetRequestURI()
-	aor := h.extractAOR(targetURI)
+	targetURI, err := parser.ParseURI(req.GetRequestURI())
+	if err != nil {
+		// ACK doesn't generate error responses, just log and return
+		return fmt.Errorf("invalid Request-URI for ACK: %w", err)
+	}
+	aor := targetURI.AOR()
 
 	// Find registered contacts for the target
 	contacts, err := h.registrar.FindContacts(aor)
@@ -163,8 +172,13 @@ func (h *SessionHandler) handleBye(req *parser.SIPMessage, txn transaction.Trans
 	}
 
 	// Extract target URI from Request-URI
-	targetURI := req.GetRequestURI()
-	aor := h.extractAOR(targetURI)
+	targetURI, err := parser.ParseURI(req.GetRequestURI())
+	if err != nil {
+		response := parser.NewResponseMessage(parser.StatusBadRequest, parser.GetReasonPhraseForCode(parser.StatusBadRequest))
+		h.copyResponseHeaders(req, response)
+		return txn.SendResponse(response)
+	}
+	aor := targetURI.AOR()
 
 	// Find registered contacts for the target
 	contacts, err := h.registrar.FindContacts(aor)
@@ -242,25 +256,4 @@ func (h *SessionHandler) getMinSE(req *parser.SIPMessage) int {
 	}
 	
 	return minSE
-}
-
-// extractAOR extracts the Address of Record from a SIP URI
-func (h *SessionHandler) extractAOR(uri string) string {
-	// Simple AOR extraction - in a real implementation, this would be more sophisticated
-	// Remove sip: or sips: prefix
-	if strings.HasPrefix(uri, "sip:") {
-		uri = uri[4:]
-	} else if strings.HasPrefix(uri, "sips:") {
-		uri = uri[5:]
-	}
-	
-	// Remove parameters and headers
-	if idx := strings.Index(uri, ";"); idx != -1 {
-		uri = uri[:idx]
-	}
-	if idx := strings.Index(uri, "?"); idx != -1 {
-		uri = uri[:idx]
-	}
-	
-	return uri
 }
\ No newline at end of file
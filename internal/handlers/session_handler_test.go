@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"errors"
+	"net"
 	"testing"
 	"time"
 
@@ -53,6 +54,8 @@ func (m *mockRegistrar) FindContacts(aor string) ([]*database.RegistrarContact,
 
 func (m *mockRegistrar) CleanupExpired() {}
 
+func (m *mockRegistrar) FlowFailed(transport string, addr net.Addr) {}
+
 type mockSessionTimerManager struct {
 	isSessionTimerRequiredFunc func(msg *parser.SIPMessage) bool
 	createSessionFunc          func(callID string, sessionExpires int) *sessiontimer.Session
@@ -533,28 +536,39 @@ func TestSessionHandler_ParseSessionExpires(t *testing.T) {
 	}
 }
 
-func TestSessionHandler_ExtractAOR(t *testing.T) {
-	mockSessionTimer := &mockSessionTimerManager{}
-	handler := NewSessionHandler(nil, nil, mockSessionTimer)
-
+func TestSessionHandler_LookupAOR(t *testing.T) {
 	tests := []struct {
 		uri      string
 		expected string
 	}{
-		{"sip:user@example.com", "user@example.com"},
-		{"sips:user@example.com", "user@example.com"},
-		{"sip:user@example.com:5060", "user@example.com:5060"},
-		{"sip:user@example.com;transport=tcp", "user@example.com"},
-		{"sip:user@example.com?header=value", "user@example.com"},
-		{"sip:user@example.com;transport=tcp?header=value", "user@example.com"},
-		{"user@example.com", "user@example.com"},
+		{"sip:user@example.com", "sip:user@example.com"},
+		{"sips:user@example.com", "sips:user@example.com"},
+		{"sip:user@example.com:5060", "sip:user@example.com:5060"},
+		{"sip:user@EXAMPLE.com", "sip:user@example.com"},
+		{"sip:user@example.com;transport=tcp", "sip:user@example.com"},
+		{"sip:user@example.com?header=value", "sip:user@example.com"},
+		{"sip:user@example.com;transport=tcp?header=value", "sip:user@example.com"},
 	}
 
 	for _, test := range tests {
 		t.Run(test.uri, func(t *testing.T) {
-			result := handler.extractAOR(test.uri)
-			if result != test.expected {
-				t.Errorf("Expected '%s', got '%s' for URI '%s'", test.expected, result, test.uri)
+			var aor string
+			mockReg := &mockRegistrar{
+				findContactsFunc: func(a string) ([]*database.RegistrarContact, error) {
+					aor = a
+					return nil, nil
+				},
+			}
+			handler := NewSessionHandler(nil, mockReg, &mockSessionTimerManager{})
+
+			bye := parser.NewRequestMessage(parser.MethodBYE, test.uri)
+			bye.SetHeader(parser.HeaderVia, "SIP/2.0/UDP client.example.com:5060;branch=z9hG4bK123")
+			bye.SetHeader(parser.HeaderCallID, "test-call-id@example.com")
+			bye.SetHeader(parser.HeaderCSeq, "2 BYE")
+
+			handler.HandleRequest(bye, &mockTransaction{})
+			if aor != test.expected {
+				t.Errorf("Expected '%s', got '%s' for URI '%s'", test.expected, aor, test.uri)
 			}
 		})
 	}
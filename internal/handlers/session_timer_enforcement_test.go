@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"net"
 	"testing"
 
 	"github.com/zurustar/xylitol2/internal/database"
@@ -53,6 +54,8 @@ func (m *MockRegistrar) FindContacts(aor string) ([]*database.RegistrarContact,
 
 func (m *MockRegistrar) CleanupExpired() {}
 
+func (m *MockRegistrar) FlowFailed(transport string, addr net.Addr) {}
+
 func (m *MockRegistrar) AddContact(aor string, contact *database.RegistrarContact) {
 	m.contacts[aor] = append(m.contacts[aor], contact)
 }
@@ -151,10 +154,10 @@ func TestSessionTimerEnforcementInINVITE(t *testing.T) {
 
 	// Add a contact for the target user
 	contact := &database.RegistrarContact{
-		AOR: "user@example.com",
+		AOR: "sip:user@example.com",
 		URI: "sip:user@192.168.1.100:5060",
 	}
-	registrar.AddContact("user@example.com", contact)
+	registrar.AddContact("sip:user@example.com", contact)
 
 	tests := []struct {
 		name           string
@@ -324,10 +327,10 @@ func TestBYESessionCleanup(t *testing.T) {
 
 	// Add a contact for the target user
 	contact := &database.RegistrarContact{
-		AOR: "user@example.com",
+		AOR: "sip:user@example.com",
 		URI: "sip:user@192.168.1.100:5060",
 	}
-	registrar.AddContact("user@example.com", contact)
+	registrar.AddContact("sip:user@example.com", contact)
 
 	// Create a session first
 	callID := "test-bye-cleanup"
@@ -369,10 +372,10 @@ func TestACKHandling(t *testing.T) {
 
 	// Add a contact for the target user
 	contact := &database.RegistrarContact{
-		AOR: "user@example.com",
+		AOR: "sip:user@example.com",
 		URI: "sip:user@192.168.1.100:5060",
 	}
-	registrar.AddContact("user@example.com", contact)
+	registrar.AddContact("sip:user@example.com", contact)
 
 	// Create ACK request
 	req := parser.NewRequestMessage(parser.MethodACK, "sip:user@example.com")
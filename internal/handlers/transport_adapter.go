@@ -3,6 +3,7 @@ package handlers
 import (
 	"fmt"
 	"net"
+	"strconv"
 	"strings"
 
 	"github.com/zurustar/xylitol2/internal/parser"
@@ -45,6 +46,13 @@ func (ta *TransportAdapter) HandleMessage(data []byte, transportType string, add
 	msg.Transport = transportType
 	msg.Source = addr
 
+	// Record the packet source in the top Via so responses reach NATed clients (RFC 3581)
+	if msg.IsRequest() && addr != nil {
+		if err := msg.SetViaReceived(addr); err != nil {
+			return fmt.Errorf("failed to record request source: %w", err)
+		}
+	}
+
 	// Handle the parsed message
 	return ta.handleParsedMessage(msg)
 }
@@ -60,6 +68,12 @@ func (ta *TransportAdapter) handleParsedMessage(msg *parser.SIPMessage) error {
 
 // handleRequest processes SIP request messages
 func (ta *TransportAdapter) handleRequest(req *parser.SIPMessage) error {
+	// CANCEL is answered by the transaction layer, which cancels the INVITE it matches
+	if cancelManager, ok := ta.txnManager.(transaction.CancelManager); ok && req.GetMethod() == parser.MethodCANCEL {
+		_, err := cancelManager.ProcessCancel(req)
+		return err
+	}
+
 	// Find or create transaction for this request
 	txn := ta.txnManager.FindTransaction(req)
 	if txn == nil {
@@ -139,6 +153,60 @@ func (ta *TransportAdapter) createErrorResponse(req *parser.SIPMessage, code int
 	return resp
 }
 
+// SendMessage sends a message handed down by the transaction layer. Only
+// responses are routed here; requests are sent by the proxy engine.
+func (ta *TransportAdapter) SendMessage(msg *parser.SIPMessage) error {
+	if !msg.IsResponse() {
+		return fmt.Errorf("cannot route %s request from the transaction layer", msg.GetMethod())
+	}
+
+	transportType, addr, err := responseTarget(msg)
+	if err != nil {
+		return err
+	}
+
+	data, err := ta.parser.Serialize(msg)
+	if err != nil {
+		return fmt.Errorf("failed to serialize response: %w", err)
+	}
+	return ta.transport.SendMessage(data, transportType, addr)
+}
+
+// responseTarget selects where a response is sent (RFC 3261 section 18.2.2).
+// Stream transports reuse the connection the request arrived on; UDP goes to
+// the received address and rport of the top Via (RFC 3581 section 4).
+func responseTarget(resp *parser.SIPMessage) (string, net.Addr, error) {
+	via, err := resp.GetTopVia()
+	if err != nil {
+		return "", nil, fmt.Errorf("cannot route response: %w", err)
+	}
+	transportType := strings.ToUpper(via.Transport)
+
+	if transportType != "UDP" && resp.Destination != nil {
+		return transportType, resp.Destination, nil
+	}
+
+	host, port := via.ResponseAddress()
+	if port == 0 {
+		port = 5060
+		if transportType == "TLS" {
+			port = transport.DefaultTLSPort
+		}
+	}
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+
+	var addr net.Addr
+	if transportType == "UDP" {
+		addr, err = net.ResolveUDPAddr("udp", address)
+	} else {
+		addr, err = net.ResolveTCPAddr("tcp", address)
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to resolve response address %s: %w", address, err)
+	}
+	return transportType, addr, nil
+}
+
 // RegisterMethodHandler registers a method handler with the handler manager
 func (ta *TransportAdapter) RegisterMethodHandler(handler MethodHandler) {
 	ta.handlerManager.RegisterHandler(handler)
@@ -3,6 +3,7 @@ package handlers
 import (
 	"bytes"
 	"net"
+	"strings"
 	"testing"
 
 	"github.com/zurustar/xylitol2/internal/logging"
@@ -386,6 +387,58 @@ func TestValidationChainWithTransportAdapter(t *testing.T) {
 	}
 }
 
+// TestTransportAdapterSymmetricResponse tests that responses reach a NATed UDP
+// client on the address and port its request came from (RFC 3581)
+func TestTransportAdapterSymmetricResponse(t *testing.T) {
+	messageParser := parser.NewParser()
+	transportManager := &mockTransportManagerIntegration{}
+
+	handlerManager := NewManager()
+	handlerManager.RegisterHandler(&mockMethodHandler{canHandleMethods: []string{parser.MethodOPTIONS}})
+
+	var adapter *TransportAdapter
+	transactionManager := transaction.NewManager(func(msg *parser.SIPMessage) error {
+		return adapter.SendMessage(msg)
+	})
+	defer transactionManager.Stop()
+	adapter = NewTransportAdapter(handlerManager, transactionManager, messageParser, transportManager)
+
+	tests := []struct {
+		name     string
+		via      string
+		expected string
+	}{
+		{"rport", "SIP/2.0/UDP 10.0.0.1:5060;rport;branch=z9hG4bK130", "203.0.113.5:40000"},
+		{"received only", "SIP/2.0/UDP 10.0.0.1:5070;branch=z9hG4bK131", "203.0.113.5:5070"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := createValidOptions()
+			options.SetHeader("Via", tt.via)
+			data, err := messageParser.Serialize(options)
+			if err != nil {
+				t.Fatalf("Failed to serialize message: %v", err)
+			}
+
+			source, _ := net.ResolveUDPAddr("udp", "203.0.113.5:40000")
+			if err := adapter.HandleMessage(data, "UDP", source); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if transportManager.sentTransport != "UDP" {
+				t.Errorf("Expected response over UDP, got %s", transportManager.sentTransport)
+			}
+			if transportManager.sentAddr == nil || transportManager.sentAddr.String() != tt.expected {
+				t.Errorf("Expected response to %s, got %v", tt.expected, transportManager.sentAddr)
+			}
+			if !strings.Contains(string(transportManager.sentData), "received=203.0.113.5") {
+				t.Errorf("Expected received parameter in response Via, got:\n%s", transportManager.sentData)
+			}
+		})
+	}
+}
+
 // Helper functions to create test messages
 
 func createValidInviteWithSessionTimer() *parser.SIPMessage {
@@ -523,7 +576,10 @@ func (m *mockTransactionManagerIntegration) CleanupExpired() {
 }
 
 type mockTransportManagerIntegration struct {
-	handler transport.MessageHandler
+	handler       transport.MessageHandler
+	sentData      []byte
+	sentTransport string
+	sentAddr      net.Addr
 }
 
 func (m *mockTransportManagerIntegration) StartUDP(port int) error {
@@ -534,10 +590,45 @@ func (m *mockTransportManagerIntegration) StartTCP(port int) error {
 	return nil
 }
 
+func (m *mockTransportManagerIntegration) StartTLS(port int, config transport.TLSConfig) error {
+	return nil
+}
+
+func (m *mockTransportManagerIntegration) ReloadTLSCertificates() error {
+	return nil
+}
+
+func (m *mockTransportManagerIntegration) StartWebSocket(port int) error {
+	return nil
+}
+
+func (m *mockTransportManagerIntegration) StartSecureWebSocket(port int, config transport.TLSConfig) error {
+	return nil
+}
+
 func (m *mockTransportManagerIntegration) SendMessage(msg []byte, transport string, addr net.Addr) error {
+	m.sentData = msg
+	m.sentTransport = transport
+	m.sentAddr = addr
 	return nil
 }
 
+func (m *mockTransportManagerIntegration) HasConnection(transport string, addr net.Addr) bool {
+	return false
+}
+
+func (m *mockTransportManagerIntegration) RegisterFlowHandler(handler transport.FlowHandler) {}
+
+func (m *mockTransportManagerIntegration) SetProxyProtocol(enabled bool) {}
+
+func (m *mockTransportManagerIntegration) StartListener(listener transport.Listener) error {
+	return nil
+}
+
+func (m *mockTransportManagerIntegration) AdvertisedAddress(transport string, addr net.Addr) (string, int) {
+	return "", 0
+}
+
 func (m *mockTransportManagerIntegration) RegisterHandler(handler transport.MessageHandler) {
 	m.handler = handler
 }
@@ -82,6 +82,22 @@ func NewB2BUA(
 	return b2bua
 }
 
+// hostPort returns the B2BUA's own address for Via, From and Contact headers
+func (b *B2BUA) hostPort() string {
+	return parser.JoinHostPort(b.serverHost, b.serverPort)
+}
+
+// advertisedHost returns the address the B2BUA is known by on the listener
+// that reaches leg, for the media address of SDP sent there
+func (b *B2BUA) advertisedHost(leg *CallLeg) string {
+	if leg != nil && leg.RemoteAddr != nil {
+		if host, _ := b.transportManager.AdvertisedAddress(leg.transport(), leg.RemoteAddr); host != "" {
+			return host
+		}
+	}
+	return b.serverHost
+}
+
 // startCleanupRoutine starts the session cleanup routine
 func (b *B2BUA) startCleanupRoutine() {
 	b.cleanupTicker = time.NewTicker(5 * time.Minute) // Cleanup every 5 minutes
@@ -124,10 +140,7 @@ func (b *B2BUA) CreateSession(callerInvite *parser.SIPMessage, calleeURI string)
 	now := time.Now().UTC()
 
 	// Extract SDP from caller INVITE
-	var sdpOffer string
-	if len(callerInvite.Body) > 0 {
-		sdpOffer = string(callerInvite.Body)
-	}
+	sdpOffer := ExtractSDP(callerInvite)
 
 	// Extract dialog information from caller INVITE
 	callerFromTag := ExtractTagFromHeader(callerInvite.GetHeader(parser.HeaderFrom))
@@ -163,6 +176,7 @@ func (b *B2BUA) CreateSession(callerInvite *parser.SIPMessage, calleeURI string)
 		ContactURI:  callerInvite.GetHeader(parser.HeaderContact),
 		Status:      CallLegStatusInitial,
 		RemoteAddr:  callerInvite.Source,
+		Transport:   callerInvite.Transport,
 		RemoteSDP:   sdpOffer,
 		LastCSeq:    ExtractCSeqNumber(callerInvite.GetHeader(parser.HeaderCSeq)),
 		DialogID:    callerDialog.DialogID,
@@ -172,7 +186,7 @@ func (b *B2BUA) CreateSession(callerInvite *parser.SIPMessage, calleeURI string)
 	// Create callee dialog (we are the UAC for this leg)
 	calleeCallID := b.generateCallID()
 	calleeFromTag := b.generateTag()
-	calleeFromURI := fmt.Sprintf("sip:%s:%d", b.serverHost, b.serverPort)
+	calleeFromURI := "sip:" + b.hostPort()
 	
 	calleeDialog := b.dialogManager.CreateDialog(
 		calleeCallID,
@@ -190,7 +204,7 @@ func (b *B2BUA) CreateSession(callerInvite *parser.SIPMessage, calleeURI string)
 		ToURI:      fmt.Sprintf("<%s>", calleeURI),
 		FromTag:    calleeFromTag,
 		ToTag:      "", // Will be set when callee responds
-		ContactURI: fmt.Sprintf("<sip:%s:%d>", b.serverHost, b.serverPort),
+		ContactURI: fmt.Sprintf("<sip:%s>", b.hostPort()),
 		Status:     CallLegStatusInitial,
 		LocalSDP:   sdpOffer, // Forward caller's SDP
 		LastCSeq:   1,        // Start with CSeq 1 for new dialog
@@ -241,10 +255,7 @@ func (b *B2BUA) CreateHuntGroupSession(callerInvite *parser.SIPMessage, huntGrou
 	now := time.Now().UTC()
 
 	// Extract SDP from caller INVITE
-	var sdpOffer string
-	if len(callerInvite.Body) > 0 {
-		sdpOffer = string(callerInvite.Body)
-	}
+	sdpOffer := ExtractSDP(callerInvite)
 
 	// Create caller leg
 	callerLeg := &CallLeg{
@@ -257,6 +268,7 @@ func (b *B2BUA) CreateHuntGroupSession(callerInvite *parser.SIPMessage, huntGrou
 		ContactURI:  callerInvite.GetHeader(parser.HeaderContact),
 		Status:      CallLegStatusInitial,
 		RemoteAddr:  callerInvite.Source,
+		Transport:   callerInvite.Transport,
 		RemoteSDP:   sdpOffer,
 		LastCSeq:    b.extractCSeq(callerInvite.GetHeader(parser.HeaderCSeq)),
 		CreatedAt:   now,
@@ -591,6 +603,17 @@ func (b *B2BUA) handleCallerInvite(session *B2BUASession, invite *parser.SIPMess
 		return fmt.Errorf("failed to send INVITE to callee: %w", err)
 	}
 
+	// A CANCEL of the caller INVITE cancels the callee INVITE
+	if cancelManager, ok := b.transactionManager.(transaction.CancelManager); ok {
+		cancelManager.OnCancel(invite, func(*parser.SIPMessage) {
+			if err := b.sendMessageToCallee(session, transaction.NewCancel(calleeInvite)); err != nil {
+				b.logger.Warn("Failed to send CANCEL to callee",
+					logging.Field{Key: "session_id", Value: session.SessionID},
+					logging.Field{Key: "error", Value: err.Error()})
+			}
+		})
+	}
+
 	// Update session and leg states
 	session.SetStatus(B2BUAStatusInitiating)
 	session.CallerLeg.SetStatus(CallLegStatusProceeding)
@@ -660,6 +683,13 @@ func (b *B2BUA) handleCallerBye(session *B2BUASession, bye *parser.SIPMessage) e
 }
 
 func (b *B2BUA) handleCallerCancel(session *B2BUASession, cancel *parser.SIPMessage) error {
+	// The transaction manager answers the CANCEL with 200 and the caller INVITE
+	// with 487, and runs the callback handleCallerInvite registered
+	if cancelManager, ok := b.transactionManager.(transaction.CancelManager); ok {
+		_, err := cancelManager.ProcessCancel(cancel)
+		return err
+	}
+
 	// Create CANCEL for callee leg
 	calleeCancel := b.createCalleeCancel(session, cancel)
 	
@@ -832,24 +862,23 @@ func (b *B2BUA) createCalleeInvite(session *B2BUASession, callerInvite *parser.S
 	
 	// Clear existing Via headers and add our own
 	invite.RemoveHeader(parser.HeaderVia)
-	viaHeader := fmt.Sprintf("SIP/2.0/UDP %s:%d;branch=z9hG4bK-%d", 
-		b.serverHost, b.serverPort, time.Now().UnixNano())
+	viaHeader := fmt.Sprintf("SIP/2.0/UDP %s;branch=z9hG4bK-%d", 
+		b.hostPort(), time.Now().UnixNano())
 	invite.AddHeader(parser.HeaderVia, viaHeader)
 	
 	// Set Max-Forwards
 	invite.SetHeader(parser.HeaderMaxForwards, "70")
 	
 	// Process SDP if present
-	if len(invite.Body) > 0 {
-		originalSDP := string(invite.Body)
-		modifiedSDP, err := b.sdpProcessor.RelaySDPOffer(originalSDP, session)
+	if originalSDP := ExtractSDP(invite); originalSDP != "" {
+		modifiedSDP, err := b.sdpProcessor.RelaySDPOffer(originalSDP, session, b.advertisedHost(session.CalleeLeg))
+		if err == nil {
+			err = ReplaceSDP(invite, modifiedSDP)
+		}
 		if err != nil {
 			b.logger.Warn("Failed to process SDP offer, using original",
 				logging.Field{Key: "session_id", Value: session.SessionID},
 				logging.Field{Key: "error", Value: err.Error()})
-		} else {
-			invite.Body = []byte(modifiedSDP)
-			invite.SetHeader(parser.HeaderContentLength, fmt.Sprintf("%d", len(modifiedSDP)))
 		}
 	}
 	
@@ -899,8 +928,8 @@ func (b *B2BUA) createCalleeBye(session *B2BUASession, callerBye *parser.SIPMess
 	bye.SetHeader(parser.HeaderContentLength, "0")
 	
 	// Add Via header
-	viaHeader := fmt.Sprintf("SIP/2.0/UDP %s:%d;branch=z9hG4bK-%d", 
-		b.serverHost, b.serverPort, time.Now().UnixNano())
+	viaHeader := fmt.Sprintf("SIP/2.0/UDP %s;branch=z9hG4bK-%d", 
+		b.hostPort(), time.Now().UnixNano())
 	bye.AddHeader(parser.HeaderVia, viaHeader)
 	
 	// Use remote target if available, otherwise use To URI
@@ -952,16 +981,16 @@ func (b *B2BUA) createCallerResponse(session *B2BUASession, calleeResponse *pars
 	response.SetHeader(parser.HeaderContact, session.CallerLeg.ContactURI)
 	
 	// Process SDP answer if present in 2xx response
-	if response.GetStatusCode() >= 200 && response.GetStatusCode() < 300 && len(response.Body) > 0 {
-		originalSDP := string(response.Body)
-		modifiedSDP, err := b.sdpProcessor.RelaySDPAnswer(originalSDP, session)
+	originalSDP := ExtractSDP(response)
+	if response.GetStatusCode() >= 200 && response.GetStatusCode() < 300 && originalSDP != "" {
+		modifiedSDP, err := b.sdpProcessor.RelaySDPAnswer(originalSDP, session, b.advertisedHost(session.CallerLeg))
+		if err == nil {
+			err = ReplaceSDP(response, modifiedSDP)
+		}
 		if err != nil {
 			b.logger.Warn("Failed to process SDP answer, using original",
 				logging.Field{Key: "session_id", Value: session.SessionID},
 				logging.Field{Key: "error", Value: err.Error()})
-		} else {
-			response.Body = []byte(modifiedSDP)
-			response.SetHeader(parser.HeaderContentLength, fmt.Sprintf("%d", len(modifiedSDP)))
 		}
 	}
 	
@@ -1107,11 +1136,11 @@ func (b *B2BUA) AddPendingLeg(sessionID string, memberURI string) (*CallLeg, err
 	leg := &CallLeg{
 		LegID:      b.generateLegID("member"),
 		CallID:     b.generateCallID(),
-		FromURI:    fmt.Sprintf("<sip:%s:%d>", b.serverHost, b.serverPort), // B2BUA as From
+		FromURI:    fmt.Sprintf("<sip:%s>", b.hostPort()), // B2BUA as From
 		ToURI:      fmt.Sprintf("<%s>", memberURI),
 		FromTag:    b.generateTag(),
 		ToTag:      "", // Will be set when member responds
-		ContactURI: fmt.Sprintf("<sip:%s:%d>", b.serverHost, b.serverPort),
+		ContactURI: fmt.Sprintf("<sip:%s>", b.hostPort()),
 		Status:     CallLegStatusInitial,
 		LocalSDP:   session.SDPOffer, // Forward caller's SDP
 		LastCSeq:   1,                // Start with CSeq 1 for new dialog
@@ -1166,8 +1195,8 @@ func (b *B2BUA) HandleMemberAnswer(sessionID string, legID string, response *par
 		}
 
 		// Extract SDP answer if present
-		if len(response.Body) > 0 {
-			session.SDPAnswer = string(response.Body)
+		if sdpAnswer := ExtractSDP(response); sdpAnswer != "" {
+			session.SDPAnswer = sdpAnswer
 		}
 
 		// Bridge the calls
@@ -1291,8 +1320,8 @@ func (b *B2BUA) createCancelForLeg(leg *CallLeg) *parser.SIPMessage {
 	cancel.SetHeader(parser.HeaderContentLength, "0")
 	
 	// Add Via header
-	viaHeader := fmt.Sprintf("SIP/2.0/UDP %s:%d;branch=z9hG4bK-%d", 
-		b.serverHost, b.serverPort, time.Now().UnixNano())
+	viaHeader := fmt.Sprintf("SIP/2.0/UDP %s;branch=z9hG4bK-%d", 
+		b.hostPort(), time.Now().UnixNano())
 	cancel.AddHeader(parser.HeaderVia, viaHeader)
 	
 	return cancel
@@ -1308,7 +1337,7 @@ func (b *B2BUA) sendMessageToLeg(leg *CallLeg, message *parser.SIPMessage) error
 		logging.Field{Key: "leg_id", Value: leg.LegID},
 		logging.Field{Key: "method", Value: message.GetMethod()})
 
-	return b.transportManager.SendMessage(data, "udp", leg.RemoteAddr)
+	return b.transportManager.SendMessage(data, leg.transport(), leg.RemoteAddr)
 }
 
 // Hunt Group Timeout Management
@@ -1399,8 +1428,8 @@ func (b *B2BUA) sendTimeoutResponseToCaller(session *B2BUASession) error {
 	response.SetHeader(parser.HeaderContentLength, "0")
 	
 	// Add Via headers
-	viaHeader := fmt.Sprintf("SIP/2.0/UDP %s:%d;branch=z9hG4bK-%d", 
-		b.serverHost, b.serverPort, time.Now().UnixNano())
+	viaHeader := fmt.Sprintf("SIP/2.0/UDP %s;branch=z9hG4bK-%d", 
+		b.hostPort(), time.Now().UnixNano())
 	response.AddHeader(parser.HeaderVia, viaHeader)
 
 	return b.sendMessageToCaller(session, response)
@@ -1581,8 +1610,8 @@ func (b *B2BUA) sendErrorResponseToCaller(session *B2BUASession, statusCode int,
 	response.SetHeader(parser.HeaderContentLength, "0")
 	
 	// Add Via headers
-	viaHeader := fmt.Sprintf("SIP/2.0/UDP %s:%d;branch=z9hG4bK-%d", 
-		b.serverHost, b.serverPort, time.Now().UnixNano())
+	viaHeader := fmt.Sprintf("SIP/2.0/UDP %s;branch=z9hG4bK-%d", 
+		b.hostPort(), time.Now().UnixNano())
 	response.AddHeader(parser.HeaderVia, viaHeader)
 
 	return b.sendMessageToCaller(session, response)
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/zurustar/xylitol2/internal/logging"
+	"github.com/zurustar/xylitol2/internal/parser"
 	"github.com/zurustar/xylitol2/internal/transaction"
 )
 
@@ -334,15 +335,11 @@ func (dm *DialogManager) generateDialogID(callID, localTag, remoteTag string) st
 
 // ExtractTagFromHeader extracts tag parameter from From/To header
 func ExtractTagFromHeader(headerValue string) string {
-	if tagStart := strings.Index(headerValue, "tag="); tagStart != -1 {
-		tagStart += 4
-		tagEnd := strings.Index(headerValue[tagStart:], ";")
-		if tagEnd == -1 {
-			return headerValue[tagStart:]
-		}
-		return headerValue[tagStart : tagStart+tagEnd]
+	addr, err := parser.ParseNameAddr(headerValue)
+	if err != nil {
+		return ""
 	}
-	return ""
+	return addr.Tag()
 }
 
 // ExtractURIFromHeader extracts URI from From/To header
@@ -2,6 +2,7 @@ package huntgroup
 
 import (
 	"net"
+	"strings"
 	"testing"
 
 	"github.com/zurustar/xylitol2/internal/logging"
@@ -15,10 +16,32 @@ type mockTransportManager struct{}
 
 func (m *mockTransportManager) StartUDP(port int) error                                    { return nil }
 func (m *mockTransportManager) StartTCP(port int) error                                    { return nil }
+func (m *mockTransportManager) StartTLS(port int, config transport.TLSConfig) error       { return nil }
+func (m *mockTransportManager) ReloadTLSCertificates() error                               { return nil }
+func (m *mockTransportManager) StartWebSocket(port int) error                              { return nil }
+func (m *mockTransportManager) StartSecureWebSocket(port int, config transport.TLSConfig) error { return nil }
 func (m *mockTransportManager) SendMessage(data []byte, protocol string, addr net.Addr) error { return nil }
+func (m *mockTransportManager) HasConnection(protocol string, addr net.Addr) bool        { return false }
 func (m *mockTransportManager) RegisterHandler(handler transport.MessageHandler)          {}
+func (m *mockTransportManager) RegisterFlowHandler(handler transport.FlowHandler)         {}
+func (m *mockTransportManager) SetProxyProtocol(enabled bool)                              {}
+func (m *mockTransportManager) StartListener(listener transport.Listener) error            { return nil }
+func (m *mockTransportManager) AdvertisedAddress(protocol string, addr net.Addr) (string, int) { return "", 0 }
 func (m *mockTransportManager) Stop() error                                               { return nil }
 
+// advertisingTransportManager advertises host on every listener and records
+// the transport it was asked about
+type advertisingTransportManager struct {
+	mockTransportManager
+	host     string
+	protocol string
+}
+
+func (m *advertisingTransportManager) AdvertisedAddress(protocol string, addr net.Addr) (string, int) {
+	m.protocol = protocol
+	return m.host, 5060
+}
+
 type mockTransactionManager struct{}
 
 func (m *mockTransactionManager) CreateTransaction(msg *parser.SIPMessage) transaction.Transaction { return nil }
@@ -490,4 +513,39 @@ func TestB2BUACSeqExtraction(t *testing.T) {
 			t.Errorf("extractCSeq(%s) = %d, expected %d", test.header, result, test.expected)
 		}
 	}
+}
+
+func TestB2BUAAdvertisedSDPAddress(t *testing.T) {
+	transportManager := &advertisingTransportManager{host: "203.0.113.5"}
+	b2bua := NewB2BUA(
+		transportManager,
+		&mockTransactionManager{},
+		&mockParser{},
+		&mockLogger{},
+		"127.0.0.1",
+		5060,
+	)
+	defer b2bua.Stop()
+
+	host := b2bua.advertisedHost(&CallLeg{RemoteAddr: &net.UDPAddr{IP: net.IPv4(198, 51, 100, 7), Port: 5060}})
+	if host != "203.0.113.5" || transportManager.protocol != "udp" {
+		t.Errorf("Expected the advertised host 203.0.113.5 over udp, got %s over %s", host, transportManager.protocol)
+	}
+	// The listener is looked up for the transport the leg is reached over
+	b2bua.advertisedHost(&CallLeg{RemoteAddr: &net.TCPAddr{IP: net.IPv4(198, 51, 100, 7), Port: 5061}, Transport: "TLS"})
+	if transportManager.protocol != "tls" {
+		t.Errorf("Expected the advertised host to be looked up for tls, got %s", transportManager.protocol)
+	}
+	if host := b2bua.advertisedHost(&CallLeg{}); host != "127.0.0.1" {
+		t.Errorf("Expected the server host without a remote address, got %s", host)
+	}
+
+	offer := "v=0\r\no=alice 1 1 IN IP4 192.0.2.10\r\ns=-\r\nc=IN IP4 192.0.2.10\r\nt=0 0\r\nm=audio 49170 RTP/AVP 0\r\n"
+	relayed, err := b2bua.sdpProcessor.RelaySDPOffer(offer, &B2BUASession{SessionID: "session"}, host)
+	if err != nil {
+		t.Fatalf("Failed to relay SDP offer: %v", err)
+	}
+	if !strings.Contains(relayed, "c=IN IP4 203.0.113.5") {
+		t.Errorf("Expected the advertised host as media address, got %s", relayed)
+	}
 }
\ No newline at end of file
@@ -5,6 +5,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/zurustar/xylitol2/internal/clock"
 	"github.com/zurustar/xylitol2/internal/database"
 	"github.com/zurustar/xylitol2/internal/logging"
 	"github.com/zurustar/xylitol2/internal/parser"
@@ -21,6 +22,7 @@ type Engine struct {
 	transactionManager transaction.TransactionManager
 	parser             parser.MessageParser
 	logger             logging.Logger
+	clock              clock.Clock
 	
 	// Active sessions
 	activeSessions map[string]*CallSession
@@ -48,6 +50,7 @@ func NewEngine(
 		transactionManager: transactionManager,
 		parser:             parser,
 		logger:             logger,
+		clock:              clock.Real(),
 		activeSessions:     make(map[string]*CallSession),
 		maxConcurrent:      10,
 		defaultTimeout:     30,
@@ -55,6 +58,11 @@ func NewEngine(
 	}
 }
 
+// SetClock sets the clock ring timeouts and call waiting run on
+func (e *Engine) SetClock(c clock.Clock) {
+	e.clock = c
+}
+
 // SetConfiguration sets engine configuration
 func (e *Engine) SetConfiguration(maxConcurrent, defaultTimeout, callWaitingTime int) {
 	e.maxConcurrent = maxConcurrent
@@ -86,7 +94,7 @@ func (e *Engine) ProcessIncomingCall(invite *parser.SIPMessage, group *HuntGroup
 		CallerURI:      callerURI,
 		OriginalINVITE: invite,
 		MemberCalls:    make(map[string]*MemberCall),
-		StartTime:      time.Now().UTC(),
+		StartTime:      e.clock.Now().UTC(),
 		Status:         SessionStatusRinging,
 	}
 
@@ -95,6 +103,13 @@ func (e *Engine) ProcessIncomingCall(invite *parser.SIPMessage, group *HuntGroup
 	e.activeSessions[sessionID] = session
 	e.sessionMutex.Unlock()
 
+	// A CANCEL of the caller's INVITE cancels the session
+	if cancelManager, ok := e.transactionManager.(transaction.CancelManager); ok {
+		cancelManager.OnCancel(invite, func(*parser.SIPMessage) {
+			e.CancelSession(sessionID)
+		})
+	}
+
 	// Log session creation
 	if err := e.manager.CreateSession(session); err != nil {
 		e.logger.Warn("Failed to log call session", 
@@ -144,7 +159,7 @@ func (e *Engine) callMembersSimultaneously(session *CallSession, group *HuntGrou
 	}
 
 	// Start timeout timer for the session
-	go e.startSessionTimeout(session, group)
+	e.startSessionTimeout(session, group)
 
 	return nil
 }
@@ -166,7 +181,7 @@ func (e *Engine) callMembersSequentially(session *CallSession, group *HuntGroup)
 	}
 
 	// Start sequential calling process
-	go e.continueSequentialCalling(session, group, enabledMembers, 0)
+	e.scheduleSequentialCalling(session, group, enabledMembers, 0)
 
 	return nil
 }
@@ -194,7 +209,7 @@ func (e *Engine) callMember(session *CallSession, member *HuntGroupMember, group
 	}
 
 	contact := contacts[0]
-	if contact.Expires.Before(time.Now().UTC()) {
+	if contact.Expires.Before(e.clock.Now().UTC()) {
 		return fmt.Errorf("member %s registration expired", member.Extension)
 	}
 
@@ -203,7 +218,7 @@ func (e *Engine) callMember(session *CallSession, member *HuntGroupMember, group
 		MemberExtension: member.Extension,
 		CallID:          e.generateCallID(),
 		Status:          MemberCallStatusRinging,
-		StartTime:       time.Now().UTC(),
+		StartTime:       e.clock.Now().UTC(),
 	}
 
 	// Store member call
@@ -228,7 +243,7 @@ func (e *Engine) callMember(session *CallSession, member *HuntGroupMember, group
 	if timeout == 0 {
 		timeout = group.RingTimeout
 	}
-	go e.startMemberTimeout(session, member.Extension, timeout)
+	e.startMemberTimeout(session, member.Extension, timeout)
 
 	return nil
 }
@@ -262,17 +277,17 @@ func (e *Engine) HandleMemberResponse(sessionID string, memberExtension string,
 	case statusCode == 486 || statusCode == 600:
 		// Busy
 		memberCall.Status = MemberCallStatusBusy
-		memberCall.EndTime = &[]time.Time{time.Now().UTC()}[0]
+		memberCall.EndTime = &[]time.Time{e.clock.Now().UTC()}[0]
 		return e.checkSessionCompletion(session)
 	case statusCode == 408 || statusCode == 480:
 		// No answer / timeout
 		memberCall.Status = MemberCallStatusNoAnswer
-		memberCall.EndTime = &[]time.Time{time.Now().UTC()}[0]
+		memberCall.EndTime = &[]time.Time{e.clock.Now().UTC()}[0]
 		return e.checkSessionCompletion(session)
 	case statusCode >= 400:
 		// Error response
 		memberCall.Status = MemberCallStatusFailed
-		memberCall.EndTime = &[]time.Time{time.Now().UTC()}[0]
+		memberCall.EndTime = &[]time.Time{e.clock.Now().UTC()}[0]
 		return e.checkSessionCompletion(session)
 	default:
 		// Provisional response - continue ringing
@@ -283,7 +298,7 @@ func (e *Engine) HandleMemberResponse(sessionID string, memberExtension string,
 // handleMemberAnswer handles when a member answers the call
 func (e *Engine) handleMemberAnswer(session *CallSession, memberExtension string, response *parser.SIPMessage) error {
 	memberCall := session.MemberCalls[memberExtension]
-	now := time.Now().UTC()
+	now := e.clock.Now().UTC()
 	
 	memberCall.Status = MemberCallStatusAnswered
 	memberCall.AnswerTime = &now
@@ -326,7 +341,7 @@ func (e *Engine) CancelSession(sessionID string) error {
 		return fmt.Errorf("session not found: %s", sessionID)
 	}
 
-	now := time.Now().UTC()
+	now := e.clock.Now().UTC()
 	session.Status = SessionStatusCancelled
 
 	// Cancel all member calls
@@ -431,60 +446,61 @@ func (e *Engine) forwardResponseToCaller(session *CallSession, response *parser.
 	return nil
 }
 
+// startSessionTimeout cancels the session if it is still ringing after the
+// group's ring timeout
 func (e *Engine) startSessionTimeout(session *CallSession, group *HuntGroup) {
 	timeout := time.Duration(group.RingTimeout) * time.Second
-	timer := time.NewTimer(timeout)
-	defer timer.Stop()
-
-	<-timer.C
-
-	// Check if session is still ringing
-	e.sessionMutex.RLock()
-	currentSession, exists := e.activeSessions[session.ID]
-	e.sessionMutex.RUnlock()
-
-	if exists && currentSession.Status == SessionStatusRinging {
-		e.logger.Info("Hunt group session timed out",
-			logging.Field{Key: "session_id", Value: session.ID})
-		
-		// Cancel the session
-		e.CancelSession(session.ID)
-	}
+	e.clock.AfterFunc(timeout, func() {
+		// Check if session is still ringing
+		e.sessionMutex.RLock()
+		currentSession, exists := e.activeSessions[session.ID]
+		e.sessionMutex.RUnlock()
+
+		if exists && currentSession.Status == SessionStatusRinging {
+			e.logger.Info("Hunt group session timed out",
+				logging.Field{Key: "session_id", Value: session.ID})
+
+			// Cancel the session
+			e.CancelSession(session.ID)
+		}
+	})
 }
 
+// startMemberTimeout gives up on a member that is still ringing after timeout seconds
 func (e *Engine) startMemberTimeout(session *CallSession, memberExtension string, timeout int) {
-	timer := time.NewTimer(time.Duration(timeout) * time.Second)
-	defer timer.Stop()
-
-	<-timer.C
-
-	// Check if member call is still ringing
-	e.sessionMutex.RLock()
-	currentSession, exists := e.activeSessions[session.ID]
-	e.sessionMutex.RUnlock()
-
-	if exists {
-		if memberCall, exists := currentSession.MemberCalls[memberExtension]; exists {
-			if memberCall.Status == MemberCallStatusRinging {
-				e.logger.Info("Hunt group member call timed out",
-					logging.Field{Key: "session_id", Value: session.ID},
-					logging.Field{Key: "member", Value: memberExtension})
-				
-				now := time.Now().UTC()
-				memberCall.Status = MemberCallStatusNoAnswer
-				memberCall.EndTime = &now
-				
-				// Check if session should be completed
-				e.checkSessionCompletion(currentSession)
+	e.clock.AfterFunc(time.Duration(timeout)*time.Second, func() {
+		// Check if member call is still ringing
+		e.sessionMutex.RLock()
+		currentSession, exists := e.activeSessions[session.ID]
+		e.sessionMutex.RUnlock()
+
+		if exists {
+			if memberCall, exists := currentSession.MemberCalls[memberExtension]; exists {
+				if memberCall.Status == MemberCallStatusRinging {
+					e.logger.Info("Hunt group member call timed out",
+						logging.Field{Key: "session_id", Value: session.ID},
+						logging.Field{Key: "member", Value: memberExtension})
+
+					now := e.clock.Now().UTC()
+					memberCall.Status = MemberCallStatusNoAnswer
+					memberCall.EndTime = &now
+
+					// Check if session should be completed
+					e.checkSessionCompletion(currentSession)
+				}
 			}
 		}
-	}
+	})
 }
 
-func (e *Engine) continueSequentialCalling(session *CallSession, group *HuntGroup, members []*HuntGroupMember, currentIndex int) {
-	// Wait for call waiting time
-	time.Sleep(time.Duration(e.callWaitingTime) * time.Second)
+// scheduleSequentialCalling checks on the member at currentIndex after the call waiting time
+func (e *Engine) scheduleSequentialCalling(session *CallSession, group *HuntGroup, members []*HuntGroupMember, currentIndex int) {
+	e.clock.AfterFunc(time.Duration(e.callWaitingTime)*time.Second, func() {
+		e.continueSequentialCalling(session, group, members, currentIndex)
+	})
+}
 
+func (e *Engine) continueSequentialCalling(session *CallSession, group *HuntGroup, members []*HuntGroupMember, currentIndex int) {
 	// Check if session is still active and no one has answered
 	e.sessionMutex.RLock()
 	currentSession, exists := e.activeSessions[session.ID]
@@ -500,7 +516,7 @@ func (e *Engine) continueSequentialCalling(session *CallSession, group *HuntGrou
 	
 	if memberCall.Status == MemberCallStatusRinging {
 		// Still ringing, wait more
-		go e.continueSequentialCalling(session, group, members, currentIndex)
+		e.scheduleSequentialCalling(session, group, members, currentIndex)
 		return
 	}
 
@@ -513,7 +529,7 @@ func (e *Engine) continueSequentialCalling(session *CallSession, group *HuntGrou
 				logging.Field{Key: "member", Value: members[nextIndex].Extension},
 				logging.Field{Key: "error", Value: err})
 		}
-		go e.continueSequentialCalling(session, group, members, nextIndex)
+		e.scheduleSequentialCalling(session, group, members, nextIndex)
 	} else {
 		// No more members to try
 		e.checkSessionCompletion(currentSession)
@@ -0,0 +1,220 @@
+package huntgroup
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/zurustar/xylitol2/internal/clock"
+	"github.com/zurustar/xylitol2/internal/database"
+	"github.com/zurustar/xylitol2/internal/parser"
+	"github.com/zurustar/xylitol2/internal/registrar"
+	"github.com/zurustar/xylitol2/internal/transaction"
+)
+
+// countingTransportManager counts the messages sent through it
+type countingTransportManager struct {
+	mockTransportManager
+	sent int
+}
+
+func (m *countingTransportManager) SendMessage(data []byte, protocol string, addr net.Addr) error {
+	m.sent++
+	return nil
+}
+
+// createTestEngine creates an engine on a fake clock with the extensions registered
+func createTestEngine(t *testing.T, extensions ...string) (*Engine, *clock.Fake, *countingTransportManager) {
+	t.Helper()
+	storage := database.NewRegistrationDB(database.NewMemoryManager())
+	reg := registrar.NewRegistrar(storage, &mockLogger{})
+	for _, extension := range extensions {
+		contact := &database.RegistrarContact{
+			AOR:     "sip:" + extension + "@test.local",
+			URI:     "sip:" + extension + "@192.168.1.10:5060",
+			CallID:  "register-" + extension,
+			CSeq:    1,
+			Expires: time.Now().UTC().Add(time.Hour),
+		}
+		if err := reg.Register(contact, 3600); err != nil {
+			t.Fatalf("Failed to register %s: %v", extension, err)
+		}
+	}
+
+	transportManager := &countingTransportManager{}
+	engine := NewEngine(NewDatabaseManager(NewMockDatabaseManager()), reg, transportManager,
+		&mockTransactionManager{}, &mockParser{}, &mockLogger{})
+	c := clock.NewFake(time.Now())
+	engine.SetClock(c)
+	return engine, c, transportManager
+}
+
+func createTestGroup(strategy HuntGroupStrategy, members ...*HuntGroupMember) *HuntGroup {
+	return &HuntGroup{
+		ID:          1,
+		Name:        "Sales",
+		Extension:   "600",
+		Strategy:    strategy,
+		RingTimeout: 30,
+		Enabled:     true,
+		Members:     members,
+	}
+}
+
+func isActiveSession(engine *Engine, sessionID string) bool {
+	engine.sessionMutex.RLock()
+	defer engine.sessionMutex.RUnlock()
+	_, exists := engine.activeSessions[sessionID]
+	return exists
+}
+
+func TestEngine_SimultaneousRingTimeout(t *testing.T) {
+	engine, c, _ := createTestEngine(t, "101", "102")
+	group := createTestGroup(StrategySimultaneous,
+		&HuntGroupMember{Extension: "101", Enabled: true, Timeout: 10},
+		&HuntGroupMember{Extension: "102", Enabled: true},
+	)
+
+	session, err := engine.ProcessIncomingCall(createTestInvite(), group)
+	if err != nil {
+		t.Fatalf("ProcessIncomingCall failed: %v", err)
+	}
+
+	// The member timeout overrides the group's ring timeout
+	c.Advance(10*time.Second - time.Millisecond)
+	if status := session.MemberCalls["101"].Status; status != MemberCallStatusRinging {
+		t.Fatalf("Expected 101 to ring until its timeout, got %v", status)
+	}
+	c.Advance(time.Millisecond)
+	if status := session.MemberCalls["101"].Status; status != MemberCallStatusNoAnswer {
+		t.Errorf("Expected 101 to time out after 10 seconds, got %v", status)
+	}
+	if session.Status != SessionStatusRinging {
+		t.Errorf("Expected session to ring while 102 rings, got %v", session.Status)
+	}
+
+	// The session fails once the last member times out at the ring timeout
+	c.Advance(20*time.Second - time.Millisecond)
+	if !isActiveSession(engine, session.ID) {
+		t.Fatal("Expected session to be active until the ring timeout")
+	}
+	c.Advance(time.Millisecond)
+	if status := session.MemberCalls["102"].Status; status != MemberCallStatusNoAnswer {
+		t.Errorf("Expected 102 to time out after 30 seconds, got %v", status)
+	}
+	if session.Status != SessionStatusFailed {
+		t.Errorf("Expected session to fail, got %v", session.Status)
+	}
+	if isActiveSession(engine, session.ID) {
+		t.Error("Expected failed session to be removed")
+	}
+}
+
+func TestEngine_AnswerStopsRingTimeout(t *testing.T) {
+	engine, c, _ := createTestEngine(t, "101", "102")
+	group := createTestGroup(StrategySimultaneous,
+		&HuntGroupMember{Extension: "101", Enabled: true},
+		&HuntGroupMember{Extension: "102", Enabled: true},
+	)
+
+	session, err := engine.ProcessIncomingCall(createTestInvite(), group)
+	if err != nil {
+		t.Fatalf("ProcessIncomingCall failed: %v", err)
+	}
+
+	c.Advance(5 * time.Second)
+	if err := engine.HandleMemberResponse(session.ID, "102", parser.NewResponseMessage(200, "OK")); err != nil {
+		t.Fatalf("HandleMemberResponse failed: %v", err)
+	}
+
+	c.Advance(time.Minute)
+	if session.Status != SessionStatusAnswered || session.AnsweredBy != "102" {
+		t.Errorf("Expected session answered by 102, got %v by %q", session.Status, session.AnsweredBy)
+	}
+	if !session.AnsweredAt.Equal(session.StartTime.Add(5 * time.Second)) {
+		t.Errorf("Expected answer 5 seconds after start, got %v", session.AnsweredAt.Sub(session.StartTime))
+	}
+	if status := session.MemberCalls["101"].Status; status != MemberCallStatusCancelled {
+		t.Errorf("Expected 101 to be cancelled, got %v", status)
+	}
+	if c.Pending() != 0 {
+		t.Errorf("Expected no timers left, got %d", c.Pending())
+	}
+}
+
+func TestEngine_SequentialCallWaiting(t *testing.T) {
+	engine, c, transportManager := createTestEngine(t, "101", "102")
+	engine.SetConfiguration(10, 30, 5)
+	group := createTestGroup(StrategySequential,
+		&HuntGroupMember{Extension: "101", Enabled: true, Timeout: 15},
+		&HuntGroupMember{Extension: "102", Enabled: true},
+	)
+
+	session, err := engine.ProcessIncomingCall(createTestInvite(), group)
+	if err != nil {
+		t.Fatalf("ProcessIncomingCall failed: %v", err)
+	}
+
+	// Only the first member rings, checked every call waiting time
+	for i := 0; i < 2; i++ {
+		c.Advance(5 * time.Second)
+		if transportManager.sent != 1 {
+			t.Fatalf("Expected only the first member to be called, sent %d", transportManager.sent)
+		}
+		if c.Pending() != 2 {
+			t.Fatalf("Expected the member timeout and the next check pending, got %d", c.Pending())
+		}
+	}
+
+	c.Advance(5*time.Second - time.Millisecond)
+	if status := session.MemberCalls["101"].Status; status != MemberCallStatusRinging {
+		t.Fatalf("Expected 101 to ring until its timeout, got %v", status)
+	}
+	c.Advance(time.Millisecond)
+	if status := session.MemberCalls["101"].Status; status != MemberCallStatusNoAnswer {
+		t.Errorf("Expected 101 to time out after 15 seconds, got %v", status)
+	}
+}
+
+func TestEngine_CancelCancelsSession(t *testing.T) {
+	engine, _, _ := createTestEngine(t, "101", "102")
+	var sent []*parser.SIPMessage
+	manager := transaction.NewManager(func(msg *parser.SIPMessage) error {
+		sent = append(sent, msg)
+		return nil
+	})
+	defer manager.Stop()
+	engine.transactionManager = manager
+
+	invite := createTestInvite()
+	invite.SetHeader(parser.HeaderVia, "SIP/2.0/UDP 192.168.1.100:5060;branch=z9hG4bKcaller")
+	manager.CreateTransaction(invite)
+
+	group := createTestGroup(StrategySimultaneous,
+		&HuntGroupMember{Extension: "101", Enabled: true},
+		&HuntGroupMember{Extension: "102", Enabled: true},
+	)
+	session, err := engine.ProcessIncomingCall(invite, group)
+	if err != nil {
+		t.Fatalf("ProcessIncomingCall failed: %v", err)
+	}
+
+	if _, err := manager.ProcessCancel(transaction.NewCancel(invite)); err != nil {
+		t.Fatalf("ProcessCancel failed: %v", err)
+	}
+
+	if session.Status != SessionStatusCancelled {
+		t.Errorf("Expected session to be cancelled, got %v", session.Status)
+	}
+	for extension, call := range session.MemberCalls {
+		if call.Status != MemberCallStatusCancelled {
+			t.Errorf("Expected %s to be cancelled, got %v", extension, call.Status)
+		}
+	}
+	if isActiveSession(engine, session.ID) {
+		t.Error("Expected cancelled session to be removed")
+	}
+	if len(sent) != 2 || sent[0].GetStatusCode() != parser.StatusOK || sent[1].GetStatusCode() != parser.StatusRequestTerminated {
+		t.Errorf("Expected 200 for CANCEL and 487 for INVITE, got %d responses", len(sent))
+	}
+}
@@ -2,6 +2,7 @@ package huntgroup
 
 import (
 	"net"
+	"strings"
 	"sync"
 	"time"
 
@@ -185,6 +186,7 @@ type CallLeg struct {
 	RouteSet      []string               `json:"route_set,omitempty"`     // Record-Route headers
 	Status        CallLegStatus          `json:"status"`
 	RemoteAddr    net.Addr               `json:"-"`
+	Transport     string                 `json:"transport,omitempty"`    // Transport RemoteAddr is reached over; UDP if empty
 	LocalSDP      string                 `json:"local_sdp,omitempty"`
 	RemoteSDP     string                 `json:"remote_sdp,omitempty"`
 	LastCSeq      uint32                 `json:"last_cseq"`
@@ -409,4 +411,11 @@ func (l *CallLeg) GetNextCSeq() uint32 {
 	defer l.mutex.Unlock()
 	l.LastCSeq++
 	return l.LastCSeq
-}
\ No newline at end of file
+}
+// transport returns the transport the leg's remote party is reached over
+func (l *CallLeg) transport() string {
+	if l.Transport == "" {
+		return "udp"
+	}
+	return strings.ToLower(l.Transport)
+}
@@ -152,9 +152,10 @@ func (m *MockDatabaseManager) UpdateUser(user *database.User) error
 func (m *MockDatabaseManager) DeleteUser(username, realm string) error                            { return nil }
 func (m *MockDatabaseManager) ListUsers() ([]*database.User, error)                               { return nil, nil }
 func (m *MockDatabaseManager) StoreContact(contact *database.Contact) error                       { return nil }
-func (m *MockDatabaseManager) RetrieveContacts(aor string) ([]*database.Contact, error)          { return nil, nil }
+func (m *MockDatabaseManager) RetrieveContacts(aor string, now time.Time) ([]*database.Contact, error)        { return nil, nil }
 func (m *MockDatabaseManager) DeleteContact(aor string, contactURI string) error                  { return nil }
-func (m *MockDatabaseManager) CleanupExpiredContacts() error                                       { return nil }
+func (m *MockDatabaseManager) DeleteFlowContacts(transport, source string) (int, error)        { return 0, nil }
+func (m *MockDatabaseManager) CleanupExpiredContacts(now time.Time) error                               { return nil }
 func (m *MockDatabaseManager) Exec(query string, args ...interface{}) error                       { return nil }
 func (m *MockDatabaseManager) ExecWithResult(query string, args ...interface{}) (database.Result, error) { return nil, nil }
 func (m *MockDatabaseManager) Query(query string, args ...interface{}) (database.Rows, error)    { return nil, nil }
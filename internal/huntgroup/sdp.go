@@ -2,11 +2,13 @@ package huntgroup
 
 import (
 	"fmt"
+	"net"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/zurustar/xylitol2/internal/logging"
+	"github.com/zurustar/xylitol2/internal/parser"
 )
 
 // SDPSession represents a complete SDP session description
@@ -264,15 +266,25 @@ func (sp *SDPProcessor) ModifySDPForB2BUA(originalSDP string, newAddress string,
 		return "", fmt.Errorf("failed to parse SDP: %w", err)
 	}
 
+	// SDP carries IPv6 addresses without brackets and labels them IP6 (RFC 4566 section 5.7)
+	newAddress = strings.Trim(newAddress, "[]")
+	addressType := sdpAddressType(newAddress)
+
 	// Update session-level connection if present
 	if session.Connection != nil {
 		session.Connection.Address = newAddress
+		if addressType != "" {
+			session.Connection.AddressType = addressType
+		}
 	}
 
 	// Update media-level connections and ports
 	for _, media := range session.MediaDescriptions {
 		if media.Connection != nil {
 			media.Connection.Address = newAddress
+			if addressType != "" {
+				media.Connection.AddressType = addressType
+			}
 		}
 		
 		// Update media port (for audio, typically)
@@ -284,6 +296,9 @@ func (sp *SDPProcessor) ModifySDPForB2BUA(originalSDP string, newAddress string,
 	// Update origin address
 	if session.Origin != nil {
 		session.Origin.Address = newAddress
+		if addressType != "" {
+			session.Origin.AddressType = addressType
+		}
 		// Increment session version for modification
 		if sessionVersion, err := strconv.ParseInt(session.Origin.SessionVersion, 10, 64); err == nil {
 			session.Origin.SessionVersion = strconv.FormatInt(sessionVersion+1, 10)
@@ -293,8 +308,9 @@ func (sp *SDPProcessor) ModifySDPForB2BUA(originalSDP string, newAddress string,
 	return sp.GenerateSDP(session)
 }
 
-// RelaySDPOffer processes and relays an SDP offer from caller to callee
-func (sp *SDPProcessor) RelaySDPOffer(callerSDP string, session *B2BUASession) (string, error) {
+// RelaySDPOffer processes and relays an SDP offer from caller to callee, with
+// host as its media address; an empty host stands for the server host
+func (sp *SDPProcessor) RelaySDPOffer(callerSDP string, session *B2BUASession, host string) (string, error) {
 	if callerSDP == "" {
 		return "", nil // No SDP to relay
 	}
@@ -305,7 +321,10 @@ func (sp *SDPProcessor) RelaySDPOffer(callerSDP string, session *B2BUASession) (
 
 	// For basic B2BUA operation, we can relay the SDP as-is
 	// In more advanced scenarios, we might need to modify media addresses
-	modifiedSDP, err := sp.ModifySDPForB2BUA(callerSDP, sp.serverHost, 0)
+	if host == "" {
+		host = sp.serverHost
+	}
+	modifiedSDP, err := sp.ModifySDPForB2BUA(callerSDP, host, 0)
 	if err != nil {
 		sp.logger.Warn("Failed to modify SDP, using original",
 			logging.Field{Key: "session_id", Value: session.SessionID},
@@ -320,8 +339,9 @@ func (sp *SDPProcessor) RelaySDPOffer(callerSDP string, session *B2BUASession) (
 	return modifiedSDP, nil
 }
 
-// RelaySDPAnswer processes and relays an SDP answer from callee to caller
-func (sp *SDPProcessor) RelaySDPAnswer(calleeSDP string, session *B2BUASession) (string, error) {
+// RelaySDPAnswer processes and relays an SDP answer from callee to caller, with
+// host as its media address; an empty host stands for the server host
+func (sp *SDPProcessor) RelaySDPAnswer(calleeSDP string, session *B2BUASession, host string) (string, error) {
 	if calleeSDP == "" {
 		return "", nil // No SDP to relay
 	}
@@ -337,7 +357,10 @@ func (sp *SDPProcessor) RelaySDPAnswer(calleeSDP string, session *B2BUASession)
 
 	// For basic B2BUA operation, we can relay the SDP as-is
 	// In more advanced scenarios, we might need to modify media addresses
-	modifiedSDP, err := sp.ModifySDPForB2BUA(calleeSDP, sp.serverHost, 0)
+	if host == "" {
+		host = sp.serverHost
+	}
+	modifiedSDP, err := sp.ModifySDPForB2BUA(calleeSDP, host, 0)
 	if err != nil {
 		sp.logger.Warn("Failed to modify SDP answer, using original",
 			logging.Field{Key: "session_id", Value: session.SessionID},
@@ -536,15 +559,82 @@ func (sp *SDPProcessor) CreateBasicSDP(address string, port int) string {
 	sessionID := strconv.FormatInt(time.Now().Unix(), 10)
 	sessionVersion := sessionID
 
+	address = strings.Trim(address, "[]")
+	addressType := sdpAddressType(address)
+	if addressType == "" {
+		addressType = "IP4"
+	}
+
 	sdp := fmt.Sprintf(`v=0
-o=- %s %s IN IP4 %s
+o=- %s %s IN %s %s
 s=SIP Call
-c=IN IP4 %s
+c=IN %s %s
 t=0 0
 m=audio %d RTP/AVP 0 8
 a=rtpmap:0 PCMU/8000
 a=rtpmap:8 PCMA/8000
-`, sessionID, sessionVersion, address, address, port)
+`, sessionID, sessionVersion, addressType, address, addressType, address, port)
 
 	return sdp
-}
\ No newline at end of file
+}
+
+// sdpAddressType returns the SDP addrtype of an IP literal, "IP4" or "IP6",
+// and an empty string for host names, whose type cannot be told from the name
+func sdpAddressType(address string) string {
+	ip := net.ParseIP(address)
+	switch {
+	case ip == nil:
+		return ""
+	case ip.To4() != nil:
+		return "IP4"
+	default:
+		return "IP6"
+	}
+}
+
+// ExtractSDP returns the SDP carried by a message. A multipart body is
+// searched for its application/sdp part; a body without Content-Type is
+// treated as SDP.
+func ExtractSDP(msg *parser.SIPMessage) string {
+	if len(msg.Body) == 0 {
+		return ""
+	}
+
+	if msg.IsMultipart() {
+		body, err := msg.GetMultipartBody()
+		if err != nil {
+			return ""
+		}
+		if part := body.FindPart(parser.ContentTypeSDP); part != nil {
+			return string(part.Body)
+		}
+		return ""
+	}
+
+	if contentType := msg.GetContentType(); contentType != "" && contentType != parser.ContentTypeSDP {
+		return ""
+	}
+	return string(msg.Body)
+}
+
+// ReplaceSDP replaces the SDP carried by a message, leaving the other parts
+// of a multipart body untouched
+func ReplaceSDP(msg *parser.SIPMessage, sdp string) error {
+	if !msg.IsMultipart() {
+		msg.Body = []byte(sdp)
+		msg.SetHeader(parser.HeaderContentLength, strconv.Itoa(len(msg.Body)))
+		return nil
+	}
+
+	body, err := msg.GetMultipartBody()
+	if err != nil {
+		return fmt.Errorf("failed to parse multipart body: %w", err)
+	}
+	part := body.FindPart(parser.ContentTypeSDP)
+	if part == nil {
+		return fmt.Errorf("multipart body has no %s part", parser.ContentTypeSDP)
+	}
+	part.Body = []byte(sdp)
+	msg.SetMultipartBody(body)
+	return nil
+}
@@ -2,9 +2,11 @@ package huntgroup
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 
 	"github.com/zurustar/xylitol2/internal/logging"
+	"github.com/zurustar/xylitol2/internal/parser"
 )
 
 // TestLogger is a simple logger for testing
@@ -112,6 +114,50 @@ a=rtpmap:0 PCMU/8000`
 	}
 }
 
+func TestSDPProcessor_ModifySDPForB2BUA_IPv6(t *testing.T) {
+	logger := &TestLogger{}
+	processor := NewSDPProcessor(logger, "[2001:db8::1]", 5060)
+
+	originalSDP := `v=0
+o=alice 2890844526 2890844527 IN IP4 192.0.2.10
+s=Session Description
+c=IN IP4 192.0.2.10
+t=0 0
+m=audio 49170 RTP/AVP 0
+c=IN IP4 192.0.2.10
+a=rtpmap:0 PCMU/8000`
+
+	modifiedSDP, err := processor.ModifySDPForB2BUA(originalSDP, "[2001:db8::1]", 0)
+	if err != nil {
+		t.Fatalf("Failed to modify SDP: %v", err)
+	}
+
+	session, err := processor.ParseSDP(modifiedSDP)
+	if err != nil {
+		t.Fatalf("Failed to parse modified SDP: %v", err)
+	}
+
+	// IPv6 addresses are written without brackets and typed IP6
+	if session.Connection.AddressType != "IP6" || session.Connection.Address != "2001:db8::1" {
+		t.Errorf("Expected connection 'IP6 2001:db8::1', got '%s %s'", session.Connection.AddressType, session.Connection.Address)
+	}
+	if session.Origin.AddressType != "IP6" || session.Origin.Address != "2001:db8::1" {
+		t.Errorf("Expected origin 'IP6 2001:db8::1', got '%s %s'", session.Origin.AddressType, session.Origin.Address)
+	}
+	if media := session.MediaDescriptions[0]; media.Connection == nil || media.Connection.AddressType != "IP6" {
+		t.Errorf("Expected media connection of type IP6, got %+v", media.Connection)
+	}
+
+	// Host names keep the address type of the original SDP
+	modifiedSDP, err = processor.ModifySDPForB2BUA(originalSDP, "media.example.com", 0)
+	if err != nil {
+		t.Fatalf("Failed to modify SDP: %v", err)
+	}
+	if !strings.Contains(modifiedSDP, "c=IN IP4 media.example.com") {
+		t.Errorf("Expected IP4 host name connection, got %s", modifiedSDP)
+	}
+}
+
 func TestSDPProcessor_ValidateSDP(t *testing.T) {
 	logger := &TestLogger{}
 	processor := NewSDPProcessor(logger, "192.168.1.1", 5060)
@@ -163,4 +209,75 @@ func TestSDPProcessor_CreateBasicSDP(t *testing.T) {
 	if len(session.MediaDescriptions) > 0 && session.MediaDescriptions[0].Port != 5004 {
 		t.Errorf("Expected port 5004, got %d", session.MediaDescriptions[0].Port)
 	}
-}
\ No newline at end of file
+
+	session, err = processor.ParseSDP(processor.CreateBasicSDP("2001:db8::100", 5004))
+	if err != nil {
+		t.Fatalf("Failed to parse created IPv6 SDP: %v", err)
+	}
+	if session.Connection.AddressType != "IP6" || session.Origin.AddressType != "IP6" {
+		t.Errorf("Expected IP6 address type, got connection %s origin %s", session.Connection.AddressType, session.Origin.AddressType)
+	}
+}
+
+func TestExtractAndReplaceSDP(t *testing.T) {
+	sdp := "v=0\r\no=- 1 1 IN IP4 192.0.2.1\r\ns=-\r\nc=IN IP4 192.0.2.1\r\nt=0 0\r\nm=audio 4000 RTP/AVP 0\r\n"
+	isup := []byte{0x01, 0x00, 0x49, 0x00, 0x00, 0x03}
+
+	t.Run("single SDP body", func(t *testing.T) {
+		msg := parser.NewRequestMessage(parser.MethodINVITE, "sip:bob@example.com")
+		msg.SetBody(parser.ContentTypeSDP, []byte(sdp))
+
+		if ExtractSDP(msg) != sdp {
+			t.Errorf("Expected SDP body, got %q", ExtractSDP(msg))
+		}
+		if err := ReplaceSDP(msg, "v=0\r\n"); err != nil {
+			t.Fatalf("Failed to replace SDP: %v", err)
+		}
+		if string(msg.Body) != "v=0\r\n" || msg.GetHeader(parser.HeaderContentLength) != "5" {
+			t.Errorf("Unexpected body after replace: %q, Content-Length %s", msg.Body, msg.GetHeader(parser.HeaderContentLength))
+		}
+	})
+
+	t.Run("non-SDP body", func(t *testing.T) {
+		msg := parser.NewRequestMessage(parser.MethodINFO, "sip:bob@example.com")
+		msg.SetBody("application/dtmf-relay", []byte("Signal=1"))
+
+		if ExtractSDP(msg) != "" {
+			t.Errorf("Expected no SDP, got %q", ExtractSDP(msg))
+		}
+	})
+
+	t.Run("multipart body", func(t *testing.T) {
+		body := parser.NewMultipartBody(parser.ContentTypeMultipartMixed)
+		body.AddPart(parser.NewBodyPart(parser.ContentTypeSDP, []byte(sdp)))
+		isupPart := parser.NewBodyPart("application/ISUP;version=nxv3", isup)
+		isupPart.SetHeader(parser.HeaderContentDisposition, "signal;handling=optional")
+		body.AddPart(isupPart)
+
+		msg := parser.NewRequestMessage(parser.MethodINVITE, "sip:bob@example.com")
+		msg.SetMultipartBody(body)
+
+		if ExtractSDP(msg) != sdp {
+			t.Errorf("Expected SDP part, got %q", ExtractSDP(msg))
+		}
+
+		modified := strings.Replace(sdp, "192.0.2.1", "198.51.100.1", -1)
+		if err := ReplaceSDP(msg, modified); err != nil {
+			t.Fatalf("Failed to replace SDP: %v", err)
+		}
+		if ExtractSDP(msg) != modified {
+			t.Errorf("SDP part was not replaced: %q", ExtractSDP(msg))
+		}
+
+		replaced, err := msg.GetMultipartBody()
+		if err != nil {
+			t.Fatalf("Failed to parse multipart body: %v", err)
+		}
+		if len(replaced.Parts) != 2 || !bytes.Equal(replaced.Parts[1].Body, isup) {
+			t.Error("Non-SDP part was not passed through untouched")
+		}
+		if replaced.Parts[1].GetHeader(parser.HeaderContentDisposition) != "signal;handling=optional" {
+			t.Error("Non-SDP part headers were not passed through untouched")
+		}
+	})
+}
@@ -33,7 +33,7 @@ server:
   udp_port: 0  # Use port 0 to get random available port
   tcp_port: 0
 database:
-  path: "%s"
+  driver: "memory"
 authentication:
   realm: "test.local"
   nonce_expiry: 300
@@ -47,7 +47,7 @@ web_admin:
 logging:
   level: "error"  # Reduce log noise in tests
   file: "%s"
-`, filepath.Join(tempDir, "test.db"), filepath.Join(tempDir, "test.log"))
+`, filepath.Join(tempDir, "test.log"))
 	
 	configFile := filepath.Join(tempDir, "config.yaml")
 	if err := os.WriteFile(configFile, []byte(configData), 0644); err != nil {
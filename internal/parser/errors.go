@@ -0,0 +1,33 @@
+package parser
+
+import "errors"
+
+// Errors returned by Parse and Validate. They are wrapped with details about
+// the offending line or value; use errors.Is to classify a failure.
+var (
+	// ErrInvalidStartLine is returned for a request or status line that is not
+	// made of exactly the required elements separated by single spaces
+	ErrInvalidStartLine = errors.New("invalid start line")
+	// ErrInvalidMethod is returned when the request method is not a token
+	ErrInvalidMethod = errors.New("invalid method")
+	// ErrInvalidRequestURI is returned for a malformed Request-URI
+	ErrInvalidRequestURI = errors.New("invalid Request-URI")
+	// ErrUnsupportedVersion is returned for a SIP version other than SIP/2.0
+	ErrUnsupportedVersion = errors.New("unsupported SIP version")
+	// ErrInvalidStatusCode is returned for a status code that is not three digits in 100-699
+	ErrInvalidStatusCode = errors.New("invalid status code")
+	// ErrInvalidHeader is returned for a header line that is not "name: value"
+	ErrInvalidHeader = errors.New("invalid header")
+	// ErrInvalidHeaderValue is returned for a header value that does not match its grammar
+	ErrInvalidHeaderValue = errors.New("invalid header value")
+	// ErrDuplicateHeader is returned when a header that allows a single value appears more than once
+	ErrDuplicateHeader = errors.New("duplicate header")
+	// ErrMissingHeader is returned when a mandatory header is absent
+	ErrMissingHeader = errors.New("missing header")
+	// ErrInvalidContentLength is returned for a Content-Length that is not a non-negative integer
+	ErrInvalidContentLength = errors.New("invalid Content-Length")
+	// ErrIncompleteBody is returned when the message ends before Content-Length bytes of body
+	ErrIncompleteBody = errors.New("incomplete body")
+	// ErrMethodMismatch is returned when the CSeq method differs from the request method
+	ErrMethodMismatch = errors.New("CSeq method does not match request method")
+)
@@ -0,0 +1,207 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// FastParser is a MessageParser for high message rates. It accepts and
+// rejects the same messages as Parser and produces equal messages, but:
+//
+//   - it scans the message in place instead of reading it line by line;
+//   - header values are substrings of a single copy of the header block;
+//   - known header names are interned, so looking them up does not allocate;
+//   - comma-separated values are only split when a header contains a comma;
+//   - the header lines Serialize needs to preserve wire order are derived
+//     from the header block only when the message is serialized.
+//
+// The body of a parsed message shares memory with the data passed to Parse,
+// so the caller must not modify data after a successful Parse.
+type FastParser struct {
+	Parser
+}
+
+// NewFastParser creates a new allocation-light SIP message parser
+func NewFastParser() *FastParser {
+	return &FastParser{}
+}
+
+// Parse parses a SIP message from raw bytes
+func (p *FastParser) Parse(data []byte) (*SIPMessage, error) {
+	if len(data) == 0 {
+		return nil, errors.New("empty message data")
+	}
+
+	startLineEnd := bytes.IndexByte(data, '\n')
+	if startLineEnd < 0 {
+		return nil, fmt.Errorf("failed to parse start line: failed to read start line: %w", io.EOF)
+	}
+	blockEnd, bodyStart := findHeaderBlockEnd(data, startLineEnd+1)
+
+	// Copy the start line and headers once; everything else refers into this copy
+	var head string
+	if blockEnd < 0 {
+		head = string(data[:startLineEnd])
+	} else {
+		head = string(data[:blockEnd])
+	}
+
+	startLine, err := parseStartLineText(strings.TrimRight(head[:startLineEnd], "\r"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse start line: %w", err)
+	}
+	if blockEnd < 0 {
+		return nil, fmt.Errorf("failed to parse headers: failed to read header line: %w", io.EOF)
+	}
+
+	msg := &SIPMessage{
+		StartLine:  startLine,
+		Headers:    make(map[string][]string, 16),
+		rawHeaders: head[startLineEnd+1:],
+	}
+
+	if err := p.parseHeaderBlock(msg.rawHeaders, msg.Headers); err != nil {
+		return nil, fmt.Errorf("failed to parse headers: %w", err)
+	}
+
+	body, err := sliceBody(data[bodyStart:], msg.Headers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse body: %w", err)
+	}
+	msg.Body = body
+
+	return msg, nil
+}
+
+// findHeaderBlockEnd returns the offset of the empty line that ends the
+// headers and the offset of the body following it, or -1 if the headers are
+// not terminated
+func findHeaderBlockEnd(data []byte, offset int) (int, int) {
+	for offset < len(data) {
+		lineEnd := bytes.IndexByte(data[offset:], '\n')
+		if lineEnd < 0 {
+			break
+		}
+		if len(bytes.TrimRight(data[offset:offset+lineEnd], "\r")) == 0 {
+			return offset, offset + lineEnd + 1
+		}
+		offset += lineEnd + 1
+	}
+	return -1, -1
+}
+
+// parseHeaderBlock adds the headers in block to headers. Values are kept in
+// one backing array; each header gets a capacity-limited window of it so that
+// appending to a header's values never overwrites another header.
+func (p *FastParser) parseHeaderBlock(block string, headers map[string][]string) error {
+	backing := make([]string, 0, 16)
+
+	return forEachHeaderField(block, func(name, wireName, value string) error {
+		start := len(backing)
+		if !p.isMultiValueHeader(name) {
+			if _, exists := headers[name]; exists && isSingleValueHeader(name) {
+				return fmt.Errorf("%w: %s", ErrDuplicateHeader, name)
+			}
+			backing = append(backing, value)
+		} else if strings.IndexByte(value, ',') < 0 {
+			if value != "" {
+				backing = append(backing, value)
+			}
+		} else {
+			backing = appendHeaderList(backing, value)
+		}
+		values := backing[start:len(backing):len(backing)]
+
+		if existing, exists := headers[name]; exists {
+			headers[name] = append(existing, values...)
+		} else {
+			headers[name] = values
+		}
+		return nil
+	})
+}
+
+// forEachHeaderField calls fn for every header field in block, the header
+// lines of a message without the terminating empty line. Continuation lines
+// are unfolded into the field they continue.
+func forEachHeaderField(block string, fn func(name, wireName, value string) error) error {
+	for len(block) > 0 {
+		lineEnd := strings.IndexByte(block, '\n')
+		if lineEnd < 0 {
+			lineEnd = len(block)
+		}
+		line := strings.TrimRight(block[:lineEnd], "\r")
+		block = block[min(lineEnd+1, len(block)):]
+
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			return fmt.Errorf("%w: continuation without previous header", ErrInvalidHeader)
+		}
+
+		// Unfolding only allocates when the field actually continues
+		for len(block) > 0 && (block[0] == ' ' || block[0] == '\t') {
+			lineEnd = strings.IndexByte(block, '\n')
+			if lineEnd < 0 {
+				lineEnd = len(block)
+			}
+			continuation := strings.TrimRight(block[:lineEnd], "\r")
+			block = block[min(lineEnd+1, len(block)):]
+			line = strings.TrimRight(line, " \t") + " " + strings.TrimSpace(continuation)
+		}
+
+		name, wireName, value, err := parseHeaderField(line)
+		if err != nil {
+			return err
+		}
+		if err := fn(name, wireName, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sliceBody returns the Content-Length bytes of rest that form the body,
+// without copying them
+func sliceBody(rest []byte, headers map[string][]string) ([]byte, error) {
+	values := headers[HeaderContentLength]
+	if len(values) == 0 || values[0] == "" {
+		// No Content-Length header, assume no body
+		return nil, nil
+	}
+
+	contentLength, err := parseContentLength(values[0])
+	if err != nil {
+		return nil, err
+	}
+	if contentLength == 0 {
+		return nil, nil
+	}
+	if len(rest) < contentLength {
+		return nil, fmt.Errorf("%w: expected %d bytes: %v", ErrIncompleteBody, contentLength, io.ErrUnexpectedEOF)
+	}
+	return rest[:contentLength:contentLength], nil
+}
+
+// headerLines returns the header lines of a parsed message in wire order,
+// deriving them from the raw header block when the parser did not record them
+func (m *SIPMessage) headerLines() []headerLine {
+	if m.lines != nil || m.rawHeaders == "" {
+		return m.lines
+	}
+
+	var lines []headerLine
+	parser := &Parser{}
+	forEachHeaderField(m.rawHeaders, func(name, wireName, value string) error {
+		var values []string
+		if parser.isMultiValueHeader(name) {
+			values = parser.parseMultiValueHeader(value)
+		} else {
+			values = []string{value}
+		}
+		lines = append(lines, headerLine{name: name, wireName: wireName, values: values})
+		return nil
+	})
+	return lines
+}
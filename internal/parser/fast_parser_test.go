@@ -0,0 +1,213 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const benchmarkREGISTER = "REGISTER sip:example.com SIP/2.0\r\n" +
+	"Via: SIP/2.0/UDP 192.0.2.10:5060;branch=z9hG4bK776asdhds;rport\r\n" +
+	"Max-Forwards: 70\r\n" +
+	"To: Alice <sip:alice@example.com>\r\n" +
+	"From: Alice <sip:alice@example.com>;tag=456248\r\n" +
+	"Call-ID: 843817637684230@998sdasdh09\r\n" +
+	"CSeq: 1826 REGISTER\r\n" +
+	"Contact: <sip:alice@192.0.2.10:5060>;expires=3600\r\n" +
+	"User-Agent: Example Phone 1.0\r\n" +
+	"Content-Length: 0\r\n" +
+	"\r\n"
+
+const benchmarkINVITE = "INVITE sip:bob@example.com SIP/2.0\r\n" +
+	"Via: SIP/2.0/UDP 192.0.2.20:5060;branch=z9hG4bKnashds8;received=192.0.2.20\r\n" +
+	"Via: SIP/2.0/TCP proxy.example.com;branch=z9hG4bK4b43c2ff8.1, SIP/2.0/UDP 192.0.2.1:5060;branch=z9hG4bK74bf9\r\n" +
+	"Max-Forwards: 69\r\n" +
+	"Record-Route: <sip:proxy.example.com;lr>\r\n" +
+	"To: Bob <sip:bob@example.com>\r\n" +
+	"From: Alice <sip:alice@example.com>;tag=1928301774\r\n" +
+	"Call-ID: a84b4c76e66710@pc33.example.com\r\n" +
+	"CSeq: 314159 INVITE\r\n" +
+	"Contact: <sip:alice@192.0.2.20:5060>\r\n" +
+	"Allow: INVITE, ACK, CANCEL, BYE, OPTIONS, UPDATE\r\n" +
+	"Supported: timer, 100rel\r\n" +
+	"Session-Expires: 1800;refresher=uac\r\n" +
+	"Content-Type: application/sdp\r\n" +
+	"Content-Length: 134\r\n" +
+	"\r\n" +
+	"v=0\r\n" +
+	"o=alice 2890844526 2890844526 IN IP4 192.0.2.20\r\n" +
+	"s=-\r\n" +
+	"c=IN IP4 192.0.2.20\r\n" +
+	"t=0 0\r\n" +
+	"m=audio 49170 RTP/AVP 0\r\n" +
+	"a=rtpmap:0 PCMU/8000\r\n"
+
+// parserSentinels are the errors compared when checking that both parsers reject a message the same way
+var parserSentinels = []error{
+	ErrInvalidStartLine, ErrInvalidMethod, ErrInvalidRequestURI, ErrUnsupportedVersion,
+	ErrInvalidStatusCode, ErrInvalidHeader, ErrInvalidHeaderValue, ErrDuplicateHeader,
+	ErrMissingHeader, ErrInvalidContentLength, ErrIncompleteBody, ErrMethodMismatch,
+}
+
+func sentinelOf(err error) error {
+	for _, sentinel := range parserSentinels {
+		if errors.Is(err, sentinel) {
+			return sentinel
+		}
+	}
+	return err
+}
+
+// assertSameResult checks that Parser and FastParser agree on a message
+func assertSameResult(t *testing.T, data []byte) {
+	t.Helper()
+	reference := NewParser()
+	fast := NewFastParser()
+
+	want, wantErr := reference.Parse(data)
+	if wantErr == nil {
+		wantErr = reference.Validate(want)
+	}
+	got, gotErr := fast.Parse(data)
+	if gotErr == nil {
+		gotErr = fast.Validate(got)
+	}
+
+	if (wantErr == nil) != (gotErr == nil) {
+		t.Fatalf("Parser error %v, FastParser error %v", wantErr, gotErr)
+	}
+	if wantErr != nil {
+		if sentinelOf(wantErr) != sentinelOf(gotErr) && wantErr.Error() != gotErr.Error() {
+			t.Errorf("Parser error %v, FastParser error %v", wantErr, gotErr)
+		}
+		return
+	}
+
+	if got.StartLine.String() != want.StartLine.String() {
+		t.Errorf("Start line %q, expected %q", got.StartLine.String(), want.StartLine.String())
+	}
+	if len(got.Headers) != len(want.Headers) {
+		t.Errorf("Got %d headers, expected %d", len(got.Headers), len(want.Headers))
+	}
+	for name, values := range want.Headers {
+		if !equalValues(got.Headers[name], values) {
+			t.Errorf("Header %s: got %q, expected %q", name, got.Headers[name], values)
+		}
+	}
+	if !bytes.Equal(got.Body, want.Body) {
+		t.Errorf("Body %q, expected %q", got.Body, want.Body)
+	}
+
+	wantBytes, _ := reference.Serialize(want)
+	gotBytes, _ := fast.Serialize(got)
+	if !bytes.Equal(gotBytes, wantBytes) {
+		t.Errorf("Serialized:\n%q\nexpected:\n%q", gotBytes, wantBytes)
+	}
+}
+
+func TestFastParser_MatchesParser(t *testing.T) {
+	messages := map[string]string{
+		"REGISTER":          benchmarkREGISTER,
+		"INVITE":            benchmarkINVITE,
+		"LF line endings":   strings.ReplaceAll(benchmarkREGISTER, "\r\n", "\n"),
+		"folded header":     strings.Replace(benchmarkINVITE, "Supported: timer, 100rel", "Supported: timer,\r\n 100rel", 1),
+		"empty multi-value": strings.Replace(benchmarkREGISTER, "User-Agent", "Supported:\r\nUser-Agent", 1),
+		"no headers":        "OPTIONS sip:example.com SIP/2.0\r\n\r\n",
+		"empty":             "",
+		"no line ending":    "OPTIONS sip:example.com SIP/2.0",
+		"unterminated":      "OPTIONS sip:example.com SIP/2.0\r\nVia: SIP/2.0/UDP host\r\n",
+		"leading fold":      "OPTIONS sip:example.com SIP/2.0\r\n continued\r\n\r\n",
+		"short body":        strings.Replace(benchmarkINVITE, "Content-Length: 134", "Content-Length: 500", 1),
+		"trailing data":     benchmarkREGISTER + "garbage",
+	}
+	for name, message := range messages {
+		t.Run(name, func(t *testing.T) {
+			assertSameResult(t, []byte(message))
+		})
+	}
+}
+
+func TestFastParser_MatchesParserOnRFC4475(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "rfc4475", "*", "*.sip"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("No torture test messages: %v", err)
+	}
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatalf("Failed to read %s: %v", file, err)
+			}
+			assertSameResult(t, data)
+		})
+	}
+}
+
+func TestFastParser_ModifiedHeaders(t *testing.T) {
+	p := NewFastParser()
+	msg, err := p.Parse([]byte(benchmarkINVITE))
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	// Appending to one header must not overwrite the values of the next one
+	msg.Headers[HeaderMaxForwards] = append(msg.Headers[HeaderMaxForwards], "68")
+	if msg.GetHeader(HeaderRecordRoute) != "<sip:proxy.example.com;lr>" {
+		t.Errorf("Append clobbered another header: %q", msg.GetHeader(HeaderRecordRoute))
+	}
+
+	msg.SetHeader(HeaderMaxForwards, "68")
+	data, err := p.Serialize(msg)
+	if err != nil {
+		t.Fatalf("Failed to serialize: %v", err)
+	}
+	expected := strings.Replace(benchmarkINVITE, "Max-Forwards: 69", "Max-Forwards: 68", 1)
+	if string(data) != expected {
+		t.Errorf("Serialized:\n%s\nexpected:\n%s", data, expected)
+	}
+}
+
+func benchmarkParse(b *testing.B, p MessageParser, message string) {
+	data := []byte(message)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.Parse(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParser_REGISTER(b *testing.B) {
+	benchmarkParse(b, NewParser(), benchmarkREGISTER)
+}
+
+func BenchmarkFastParser_REGISTER(b *testing.B) {
+	benchmarkParse(b, NewFastParser(), benchmarkREGISTER)
+}
+
+func BenchmarkParser_INVITE(b *testing.B) {
+	benchmarkParse(b, NewParser(), benchmarkINVITE)
+}
+
+func BenchmarkFastParser_INVITE(b *testing.B) {
+	benchmarkParse(b, NewFastParser(), benchmarkINVITE)
+}
+
+func BenchmarkFastParser_INVITEParallel(b *testing.B) {
+	p := NewFastParser()
+	data := []byte(benchmarkINVITE)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := p.Parse(data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,785 @@
+package parser
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Param is a single header parameter. Value is empty for flag parameters
+// such as rport and keeps any surrounding quotes as they appeared on the wire.
+type Param struct {
+	Name  string
+	Value string
+}
+
+// Params is an ordered parameter list that preserves the order and spelling
+// of the original header so it can be serialized back unchanged
+type Params []Param
+
+// Get returns the value of the named parameter, matching names case-insensitively
+func (p Params) Get(name string) (string, bool) {
+	for _, param := range p {
+		if strings.EqualFold(param.Name, name) {
+			return param.Value, true
+		}
+	}
+	return "", false
+}
+
+// Has checks if the named parameter is present
+func (p Params) Has(name string) bool {
+	_, exists := p.Get(name)
+	return exists
+}
+
+// Set replaces the value of the named parameter, appending it if absent
+func (p *Params) Set(name, value string) {
+	for i, param := range *p {
+		if strings.EqualFold(param.Name, name) {
+			(*p)[i].Value = value
+			return
+		}
+	}
+	*p = append(*p, Param{Name: name, Value: value})
+}
+
+// Remove removes the named parameter
+func (p *Params) Remove(name string) {
+	params := (*p)[:0]
+	for _, param := range *p {
+		if !strings.EqualFold(param.Name, name) {
+			params = append(params, param)
+		}
+	}
+	*p = params
+}
+
+// String returns the parameters in wire format, each prefixed with ';'
+func (p Params) String() string {
+	var builder strings.Builder
+	for _, param := range p {
+		builder.WriteByte(';')
+		builder.WriteString(param.Name)
+		if param.Value != "" {
+			builder.WriteByte('=')
+			builder.WriteString(param.Value)
+		}
+	}
+	return builder.String()
+}
+
+// parseParams parses a ';'-separated parameter list; a leading ';' is optional
+func parseParams(value string) (Params, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), ";")
+	if value == "" {
+		return nil, nil
+	}
+
+	var params Params
+	for _, part := range splitQuoted(value, ';') {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return nil, fmt.Errorf("empty parameter in %q", value)
+		}
+		name, paramValue, hasValue := strings.Cut(part, "=")
+		name = strings.TrimSpace(name)
+		paramValue = strings.TrimSpace(paramValue)
+		if name == "" || (hasValue && paramValue == "") {
+			return nil, fmt.Errorf("invalid parameter %q", part)
+		}
+		params = append(params, Param{Name: name, Value: paramValue})
+	}
+	return params, nil
+}
+
+// unquote removes surrounding double quotes and backslash escapes from a value
+func unquote(value string) string {
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return value
+	}
+	value = value[1 : len(value)-1]
+	if !strings.Contains(value, "\\") {
+		return value
+	}
+
+	var builder strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			i++
+		}
+		builder.WriteByte(value[i])
+	}
+	return builder.String()
+}
+
+// quote wraps a value in double quotes, escaping quotes and backslashes
+func quote(value string) string {
+	var builder strings.Builder
+	builder.WriteByte('"')
+	for i := 0; i < len(value); i++ {
+		if value[i] == '"' || value[i] == '\\' {
+			builder.WriteByte('\\')
+		}
+		builder.WriteByte(value[i])
+	}
+	builder.WriteByte('"')
+	return builder.String()
+}
+
+// splitQuoted splits value on sep, ignoring separators inside quoted strings
+// and angle brackets
+func splitQuoted(value string, sep byte) []string {
+	var parts []string
+	inQuotes := false
+	inAngleBrackets := false
+	start := 0
+
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; {
+		case inQuotes && c == '\\':
+			i++
+		case c == '"':
+			inQuotes = !inQuotes
+		case inQuotes:
+		case c == '<':
+			inAngleBrackets = true
+		case c == '>':
+			inAngleBrackets = false
+		case c == sep && !inAngleBrackets:
+			parts = append(parts, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, value[start:])
+}
+
+// splitHeaderList splits a comma-separated header value into its elements,
+// dropping empty elements
+func splitHeaderList(value string) []string {
+	return appendHeaderList(nil, value)
+}
+
+// appendHeaderList appends the elements of a comma-separated header value to
+// dst, dropping empty elements
+func appendHeaderList(dst []string, value string) []string {
+	for _, part := range splitQuoted(value, ',') {
+		if part = strings.TrimSpace(part); part != "" {
+			dst = append(dst, part)
+		}
+	}
+	return dst
+}
+
+// Via represents a single Via header field value
+type Via struct {
+	Protocol  string
+	Version   string
+	Transport string
+	Host      string
+	Port      int // 0 when the sent-by has no port
+	Params    Params
+}
+
+// ParseVia parses a single Via header field value such as
+// "SIP/2.0/UDP host:5060;branch=z9hG4bK776asdhds"
+func ParseVia(value string) (*Via, error) {
+	head, paramPart, _ := strings.Cut(strings.TrimSpace(value), ";")
+
+	// LWS is permitted around the '/' separators of the sent-protocol
+	fields := strings.Fields(head)
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("invalid Via header: %s", value)
+	}
+	sentBy := fields[len(fields)-1]
+	protocol := strings.Split(strings.Join(fields[:len(fields)-1], ""), "/")
+	if len(protocol) != 3 || protocol[0] == "" || protocol[1] == "" || protocol[2] == "" {
+		return nil, fmt.Errorf("invalid Via sent-protocol: %s", value)
+	}
+
+	host, port, err := splitHostPort(sentBy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Via sent-by: %w", err)
+	}
+
+	params, err := parseParams(paramPart)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Via parameters: %w", err)
+	}
+
+	return &Via{
+		Protocol:  protocol[0],
+		Version:   protocol[1],
+		Transport: protocol[2],
+		Host:      host,
+		Port:      port,
+		Params:    params,
+	}, nil
+}
+
+// ParseViaList parses a Via header value that may contain several comma-separated entries
+func ParseViaList(value string) ([]*Via, error) {
+	var vias []*Via
+	for _, part := range splitHeaderList(value) {
+		via, err := ParseVia(part)
+		if err != nil {
+			return nil, err
+		}
+		vias = append(vias, via)
+	}
+	return vias, nil
+}
+
+// SentBy returns the host and optional port in wire format
+func (v *Via) SentBy() string {
+	return joinHostPort(v.Host, v.Port)
+}
+
+// Branch returns the branch parameter
+func (v *Via) Branch() string {
+	branch, _ := v.Params.Get("branch")
+	return branch
+}
+
+// Received returns the received parameter
+func (v *Via) Received() string {
+	received, _ := v.Params.Get("received")
+	return received
+}
+
+// MAddr returns the maddr parameter
+func (v *Via) MAddr() string {
+	maddr, _ := v.Params.Get("maddr")
+	return maddr
+}
+
+// RPort returns the rport parameter value and whether the parameter is
+// present. A request carries rport without a value, so port is 0 then.
+func (v *Via) RPort() (int, bool) {
+	value, exists := v.Params.Get("rport")
+	if !exists || value == "" {
+		return 0, exists
+	}
+	port, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, true
+	}
+	return port, true
+}
+
+// SetReceived records the address a request arrived from (RFC 3261 section
+// 18.2.1, RFC 3581 section 4). received is added when host differs from the
+// sent-by or the client asked for rport, and rport is filled in with port.
+func (v *Via) SetReceived(host string, port int) {
+	if v.Params.Has("rport") {
+		v.Params.Set("received", host)
+		v.Params.Set("rport", strconv.Itoa(port))
+		return
+	}
+	sentBy := net.ParseIP(v.Host)
+	if sentBy == nil || !sentBy.Equal(net.ParseIP(host)) {
+		v.Params.Set("received", host)
+	}
+}
+
+// ResponseAddress returns the host and port a response is sent to, preferring
+// received and rport over the sent-by (RFC 3261 section 18.2.2, RFC 3581
+// section 4). port is 0 when neither rport nor the sent-by carries one.
+func (v *Via) ResponseAddress() (string, int) {
+	host := v.Host
+	if received := v.Received(); received != "" {
+		host = strings.Trim(received, "[]") // Some peers bracket IPv6 received values
+	}
+	port := v.Port
+	if rport, ok := v.RPort(); ok && rport != 0 {
+		port = rport
+	}
+	return host, port
+}
+
+// String returns the Via header field value in wire format
+func (v *Via) String() string {
+	return v.Protocol + "/" + v.Version + "/" + v.Transport + " " + v.SentBy() + v.Params.String()
+}
+
+// NameAddr represents a name-addr or addr-spec with header parameters, as
+// used by From, To, Contact, Route and Record-Route
+type NameAddr struct {
+	DisplayName string
+	URI         string
+	Params      Params
+
+	// quoted and bare record how the value was written so it serializes back unchanged
+	quoted bool
+	bare   bool
+}
+
+// ParseNameAddr parses a From, To, Route or Record-Route header field value
+func ParseNameAddr(value string) (*NameAddr, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, fmt.Errorf("empty address")
+	}
+
+	addr := &NameAddr{}
+	rest := value
+
+	if rest[0] == '"' {
+		end := closingQuote(rest)
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated display name: %s", value)
+		}
+		addr.DisplayName = unquote(rest[:end+1])
+		addr.quoted = true
+		rest = strings.TrimSpace(rest[end+1:])
+		if !strings.HasPrefix(rest, "<") {
+			return nil, fmt.Errorf("missing < after display name: %s", value)
+		}
+	}
+
+	if idx := strings.Index(rest, "<"); idx >= 0 {
+		if !addr.quoted {
+			// An unquoted display name is a sequence of tokens
+			addr.DisplayName = strings.TrimSpace(rest[:idx])
+			if !isTokenList(addr.DisplayName) {
+				return nil, fmt.Errorf("display name must be quoted: %s", value)
+			}
+		}
+		end := strings.Index(rest[idx:], ">")
+		if end < 0 {
+			return nil, fmt.Errorf("missing closing >: %s", value)
+		}
+		addr.URI = rest[idx+1 : idx+end]
+		if strings.ContainsAny(addr.URI, " \t") {
+			return nil, fmt.Errorf("whitespace in URI: %s", value)
+		}
+		rest = rest[idx+end+1:]
+	} else {
+		// In an addr-spec, everything after the first ';' is a header parameter.
+		// A URI containing '?' or ',' must be enclosed in <> (RFC 3261 §20).
+		addr.bare = true
+		uri, params, _ := strings.Cut(rest, ";")
+		addr.URI = strings.TrimSpace(uri)
+		if strings.ContainsAny(addr.URI, " \t?,") {
+			return nil, fmt.Errorf("invalid address: %s", value)
+		}
+		rest = ";" + params
+	}
+
+	if addr.URI == "" {
+		return nil, fmt.Errorf("empty URI: %s", value)
+	}
+
+	params, err := parseParams(rest)
+	if err != nil {
+		return nil, err
+	}
+	addr.Params = params
+	return addr, nil
+}
+
+// ParseNameAddrList parses a comma-separated list of addresses such as a Route header value
+func ParseNameAddrList(value string) ([]*NameAddr, error) {
+	var addrs []*NameAddr
+	for _, part := range splitHeaderList(value) {
+		addr, err := ParseNameAddr(part)
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
+// closingQuote returns the index of the quote that ends the quoted string at the start of value
+func closingQuote(value string) int {
+	for i := 1; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// Tag returns the tag parameter
+func (a *NameAddr) Tag() string {
+	tag, _ := a.Params.Get("tag")
+	return tag
+}
+
+// SetTag sets the tag parameter
+func (a *NameAddr) SetTag(tag string) {
+	a.Params.Set("tag", tag)
+}
+
+// String returns the address in wire format
+func (a *NameAddr) String() string {
+	if a.bare && a.DisplayName == "" && !strings.ContainsAny(a.URI, ";,?") {
+		return a.URI + a.Params.String()
+	}
+
+	var builder strings.Builder
+	if a.DisplayName != "" {
+		if a.quoted || !isTokenList(a.DisplayName) {
+			builder.WriteString(quote(a.DisplayName))
+		} else {
+			builder.WriteString(a.DisplayName)
+		}
+		builder.WriteByte(' ')
+	}
+	builder.WriteByte('<')
+	builder.WriteString(a.URI)
+	builder.WriteByte('>')
+	builder.WriteString(a.Params.String())
+	return builder.String()
+}
+
+// isTokenList checks if a display name can be written without quotes
+func isTokenList(value string) bool {
+	for _, word := range strings.Fields(value) {
+		if !IsToken(word) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsToken checks if value is a non-empty RFC 3261 token
+func IsToken(value string) bool {
+	if value == "" {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		if !isAlphanumeric(value[i]) && strings.IndexByte("-.!%*_+`'~", value[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Contact represents a single Contact header field value
+type Contact struct {
+	NameAddr
+	Wildcard bool // Contact: *
+}
+
+// ParseContact parses a single Contact header field value
+func ParseContact(value string) (*Contact, error) {
+	if strings.TrimSpace(value) == "*" {
+		return &Contact{Wildcard: true}, nil
+	}
+	addr, err := ParseNameAddr(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Contact header: %w", err)
+	}
+	return &Contact{NameAddr: *addr}, nil
+}
+
+// ParseContactList parses a Contact header value that may contain several comma-separated entries
+func ParseContactList(value string) ([]*Contact, error) {
+	var contacts []*Contact
+	for _, part := range splitHeaderList(value) {
+		contact, err := ParseContact(part)
+		if err != nil {
+			return nil, err
+		}
+		contacts = append(contacts, contact)
+	}
+	return contacts, nil
+}
+
+// Expires returns the expires parameter and whether it is present and valid
+func (c *Contact) Expires() (int, bool) {
+	value, exists := c.Params.Get("expires")
+	if !exists {
+		return 0, false
+	}
+	expires, err := strconv.Atoi(value)
+	if err != nil || expires < 0 {
+		return 0, false
+	}
+	return expires, true
+}
+
+// Q returns the q parameter and whether it is present and valid
+func (c *Contact) Q() (float64, bool) {
+	value, exists := c.Params.Get("q")
+	if !exists {
+		return 0, false
+	}
+	q, err := strconv.ParseFloat(value, 64)
+	if err != nil || q < 0 || q > 1 {
+		return 0, false
+	}
+	return q, true
+}
+
+// Instance returns the +sip.instance parameter with its quotes and angle
+// brackets removed, e.g. "urn:uuid:00000000-0000-1000-8000-AABBCCDDEEFF"
+func (c *Contact) Instance() string {
+	value, _ := c.Params.Get("+sip.instance")
+	value = unquote(value)
+	return strings.TrimSuffix(strings.TrimPrefix(value, "<"), ">")
+}
+
+// RegID returns the reg-id parameter of RFC 5626 and whether it is present and valid
+func (c *Contact) RegID() (int, bool) {
+	value, exists := c.Params.Get("reg-id")
+	if !exists {
+		return 0, false
+	}
+	regID, err := strconv.Atoi(value)
+	if err != nil || regID <= 0 {
+		return 0, false
+	}
+	return regID, true
+}
+
+// String returns the Contact header field value in wire format
+func (c *Contact) String() string {
+	if c.Wildcard {
+		return "*"
+	}
+	return c.NameAddr.String()
+}
+
+// CSeq represents the CSeq header field value
+type CSeq struct {
+	Seq    uint32
+	Method string
+}
+
+// ParseCSeq parses a CSeq header field value such as "314159 INVITE"
+func ParseCSeq(value string) (*CSeq, error) {
+	parts := strings.Fields(value)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid CSeq format: %s", value)
+	}
+	seq, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSeq number: %s", parts[0])
+	}
+	return &CSeq{Seq: uint32(seq), Method: parts[1]}, nil
+}
+
+// String returns the CSeq header field value in wire format
+func (c *CSeq) String() string {
+	return strconv.FormatUint(uint64(c.Seq), 10) + " " + c.Method
+}
+
+// splitHostPort splits a sent-by or host:port, accepting bracketed IPv6 references
+func splitHostPort(hostport string) (string, int, error) {
+	if hostport == "" {
+		return "", 0, fmt.Errorf("empty host")
+	}
+
+	host := hostport
+	portStr := ""
+	if strings.HasPrefix(hostport, "[") {
+		end := strings.Index(hostport, "]")
+		if end < 0 {
+			return "", 0, fmt.Errorf("missing ] in %s", hostport)
+		}
+		host = hostport[1:end]
+		rest := hostport[end+1:]
+		if rest != "" {
+			if !strings.HasPrefix(rest, ":") {
+				return "", 0, fmt.Errorf("invalid host %s", hostport)
+			}
+			portStr = rest[1:]
+		}
+	} else if idx := strings.LastIndex(hostport, ":"); idx >= 0 {
+		host = hostport[:idx]
+		portStr = hostport[idx+1:]
+	}
+
+	if host == "" {
+		return "", 0, fmt.Errorf("empty host in %s", hostport)
+	}
+	if portStr == "" {
+		return host, 0, nil
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return "", 0, fmt.Errorf("invalid port in %s", hostport)
+	}
+	return host, port, nil
+}
+
+// JoinHostPort formats host and port as a SIP hostport, bracketing IPv6
+// literals such as 2001:db8::1. A port of 0 is omitted, and a host that is
+// already bracketed is used as is.
+func JoinHostPort(host string, port int) string {
+	return joinHostPort(host, port)
+}
+
+// joinHostPort formats host and port, bracketing IPv6 addresses and omitting a zero port
+func joinHostPort(host string, port int) string {
+	if strings.Contains(host, ":") && !strings.HasPrefix(host, "[") {
+		host = "[" + host + "]"
+	}
+	if port == 0 {
+		return host
+	}
+	return host + ":" + strconv.Itoa(port)
+}
+
+// GetVias returns all Via header field values, topmost first
+func (m *SIPMessage) GetVias() ([]*Via, error) {
+	var vias []*Via
+	for _, value := range m.GetHeaders(HeaderVia) {
+		parsed, err := ParseViaList(value)
+		if err != nil {
+			return nil, err
+		}
+		vias = append(vias, parsed...)
+	}
+	return vias, nil
+}
+
+// GetTopVia returns the topmost Via header field value
+func (m *SIPMessage) GetTopVia() (*Via, error) {
+	value := m.GetHeader(HeaderVia)
+	if value == "" {
+		return nil, fmt.Errorf("required header missing: %s", HeaderVia)
+	}
+	vias, err := ParseViaList(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(vias) == 0 {
+		return nil, fmt.Errorf("required header missing: %s", HeaderVia)
+	}
+	return vias[0], nil
+}
+
+// SetViaReceived records source in the top Via of a received request, see
+// Via.SetReceived. The Via headers are left untouched when nothing changes.
+func (m *SIPMessage) SetViaReceived(source net.Addr) error {
+	host, portStr, err := net.SplitHostPort(source.String())
+	if err != nil {
+		return fmt.Errorf("invalid source address: %w", err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return fmt.Errorf("invalid source port: %s", portStr)
+	}
+
+	vias, err := m.GetVias()
+	if err != nil {
+		return err
+	}
+	if len(vias) == 0 {
+		return fmt.Errorf("required header missing: %s", HeaderVia)
+	}
+
+	before := vias[0].String()
+	vias[0].SetReceived(host, port)
+	if vias[0].String() != before {
+		m.SetVias(vias)
+	}
+	return nil
+}
+
+// SetVias replaces the Via headers, topmost first
+func (m *SIPMessage) SetVias(vias []*Via) {
+	m.RemoveHeader(HeaderVia)
+	for _, via := range vias {
+		m.AddHeader(HeaderVia, via.String())
+	}
+}
+
+// GetFrom returns the parsed From header
+func (m *SIPMessage) GetFrom() (*NameAddr, error) {
+	return m.getNameAddr(HeaderFrom)
+}
+
+// GetTo returns the parsed To header
+func (m *SIPMessage) GetTo() (*NameAddr, error) {
+	return m.getNameAddr(HeaderTo)
+}
+
+func (m *SIPMessage) getNameAddr(name string) (*NameAddr, error) {
+	value := m.GetHeader(name)
+	if value == "" {
+		return nil, fmt.Errorf("required header missing: %s", name)
+	}
+	addr, err := ParseNameAddr(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s header: %w", name, err)
+	}
+	return addr, nil
+}
+
+// GetCSeq returns the parsed CSeq header
+func (m *SIPMessage) GetCSeq() (*CSeq, error) {
+	value := m.GetHeader(HeaderCSeq)
+	if value == "" {
+		return nil, fmt.Errorf("required header missing: %s", HeaderCSeq)
+	}
+	return ParseCSeq(value)
+}
+
+// GetContacts returns all Contact header field values
+func (m *SIPMessage) GetContacts() ([]*Contact, error) {
+	var contacts []*Contact
+	for _, value := range m.GetHeaders(HeaderContact) {
+		parsed, err := ParseContactList(value)
+		if err != nil {
+			return nil, err
+		}
+		contacts = append(contacts, parsed...)
+	}
+	return contacts, nil
+}
+
+// SetContacts replaces the Contact headers
+func (m *SIPMessage) SetContacts(contacts []*Contact) {
+	m.RemoveHeader(HeaderContact)
+	for _, contact := range contacts {
+		m.AddHeader(HeaderContact, contact.String())
+	}
+}
+
+// GetRoutes returns the Route set in order
+func (m *SIPMessage) GetRoutes() ([]*NameAddr, error) {
+	return m.getNameAddrList(HeaderRoute)
+}
+
+// SetRoutes replaces the Route headers
+func (m *SIPMessage) SetRoutes(routes []*NameAddr) {
+	m.setNameAddrList(HeaderRoute, routes)
+}
+
+// GetRecordRoutes returns the Record-Route set in order
+func (m *SIPMessage) GetRecordRoutes() ([]*NameAddr, error) {
+	return m.getNameAddrList(HeaderRecordRoute)
+}
+
+// SetRecordRoutes replaces the Record-Route headers
+func (m *SIPMessage) SetRecordRoutes(routes []*NameAddr) {
+	m.setNameAddrList(HeaderRecordRoute, routes)
+}
+
+func (m *SIPMessage) getNameAddrList(name string) ([]*NameAddr, error) {
+	var addrs []*NameAddr
+	for _, value := range m.GetHeaders(name) {
+		parsed, err := ParseNameAddrList(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s header: %w", name, err)
+		}
+		addrs = append(addrs, parsed...)
+	}
+	return addrs, nil
+}
+
+func (m *SIPMessage) setNameAddrList(name string, addrs []*NameAddr) {
+	m.RemoveHeader(name)
+	for _, addr := range addrs {
+		m.AddHeader(name, addr.String())
+	}
+}
@@ -0,0 +1,458 @@
+package parser
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestParseVia(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     string
+		transport string
+		host      string
+		port      int
+		branch    string
+	}{
+		{"host and port", "SIP/2.0/UDP pc33.atlanta.com:5060;branch=z9hG4bK776asdhds", "UDP", "pc33.atlanta.com", 5060, "z9hG4bK776asdhds"},
+		{"no port", "SIP/2.0/TCP 192.168.1.1;branch=z9hG4bKabc", "TCP", "192.168.1.1", 0, "z9hG4bKabc"},
+		{"IPv6", "SIP/2.0/UDP [2001:db8::1]:5070;branch=z9hG4bK1", "UDP", "2001:db8::1", 5070, "z9hG4bK1"},
+		{"LWS in sent-protocol", "SIP / 2.0 / UDP host.example.com;branch=z9hG4bK2", "UDP", "host.example.com", 0, "z9hG4bK2"},
+		{"no branch", "SIP/2.0/UDP 10.0.0.1:5060", "UDP", "10.0.0.1", 5060, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			via, err := ParseVia(tt.value)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if via.Transport != tt.transport || via.Host != tt.host || via.Port != tt.port {
+				t.Errorf("Got transport=%s host=%s port=%d", via.Transport, via.Host, via.Port)
+			}
+			if via.Branch() != tt.branch {
+				t.Errorf("Expected branch %q, got %q", tt.branch, via.Branch())
+			}
+		})
+	}
+}
+
+func TestParseVia_Errors(t *testing.T) {
+	invalid := []string{
+		"",
+		"SIP/2.0/UDP",
+		"SIP/2.0 host.example.com",
+		"SIP/2.0/UDP host.example.com:port",
+		"SIP/2.0/UDP [2001:db8::1:5060",
+		"SIP/2.0/UDP host.example.com;branch=",
+	}
+	for _, value := range invalid {
+		if _, err := ParseVia(value); err == nil {
+			t.Errorf("Expected error for %q", value)
+		}
+	}
+}
+
+func TestVia_Params(t *testing.T) {
+	via, err := ParseVia("SIP/2.0/UDP 10.0.0.1:5060;rport;branch=z9hG4bK1;maddr=224.0.0.1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if port, present := via.RPort(); !present || port != 0 {
+		t.Errorf("Expected empty rport, got %d %v", port, present)
+	}
+	if via.MAddr() != "224.0.0.1" {
+		t.Errorf("Expected maddr 224.0.0.1, got %s", via.MAddr())
+	}
+
+	via.Params.Set("rport", "5070")
+	via.Params.Set("received", "192.0.2.1")
+	if port, present := via.RPort(); !present || port != 5070 {
+		t.Errorf("Expected rport 5070, got %d %v", port, present)
+	}
+	if via.Received() != "192.0.2.1" {
+		t.Errorf("Expected received 192.0.2.1, got %s", via.Received())
+	}
+
+	expected := "SIP/2.0/UDP 10.0.0.1:5060;rport=5070;branch=z9hG4bK1;maddr=224.0.0.1;received=192.0.2.1"
+	if via.String() != expected {
+		t.Errorf("Expected %s, got %s", expected, via.String())
+	}
+}
+
+func TestVia_SetReceived(t *testing.T) {
+	tests := []struct {
+		name     string
+		via      string
+		host     string
+		port     int
+		expected string
+		respHost string
+		respPort int
+	}{
+		{
+			name:     "rport filled behind NAT",
+			via:      "SIP/2.0/UDP 10.0.0.1:5060;rport;branch=z9hG4bK1",
+			host:     "192.0.2.1",
+			port:     9988,
+			expected: "SIP/2.0/UDP 10.0.0.1:5060;rport=9988;branch=z9hG4bK1;received=192.0.2.1",
+			respHost: "192.0.2.1",
+			respPort: 9988,
+		},
+		{
+			name:     "rport forces received",
+			via:      "SIP/2.0/UDP 192.0.2.1:5060;branch=z9hG4bK1;rport",
+			host:     "192.0.2.1",
+			port:     5060,
+			expected: "SIP/2.0/UDP 192.0.2.1:5060;branch=z9hG4bK1;rport=5060;received=192.0.2.1",
+			respHost: "192.0.2.1",
+			respPort: 5060,
+		},
+		{
+			name:     "received without rport",
+			via:      "SIP/2.0/UDP 10.0.0.1:5070;branch=z9hG4bK1",
+			host:     "192.0.2.1",
+			port:     9988,
+			expected: "SIP/2.0/UDP 10.0.0.1:5070;branch=z9hG4bK1;received=192.0.2.1",
+			respHost: "192.0.2.1",
+			respPort: 5070,
+		},
+		{
+			name:     "matching sent-by",
+			via:      "SIP/2.0/UDP 192.0.2.1:5070;branch=z9hG4bK1",
+			host:     "192.0.2.1",
+			port:     5070,
+			expected: "SIP/2.0/UDP 192.0.2.1:5070;branch=z9hG4bK1",
+			respHost: "192.0.2.1",
+			respPort: 5070,
+		},
+		{
+			name:     "IPv6",
+			via:      "SIP/2.0/UDP [2001:db8::1];rport;branch=z9hG4bK1",
+			host:     "2001:db8::9",
+			port:     9988,
+			expected: "SIP/2.0/UDP [2001:db8::1];rport=9988;branch=z9hG4bK1;received=2001:db8::9",
+			respHost: "2001:db8::9",
+			respPort: 9988,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			via, err := ParseVia(tt.via)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			via.SetReceived(tt.host, tt.port)
+			if via.String() != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, via.String())
+			}
+			host, port := via.ResponseAddress()
+			if host != tt.respHost || port != tt.respPort {
+				t.Errorf("Expected response address %s:%d, got %s:%d", tt.respHost, tt.respPort, host, port)
+			}
+		})
+	}
+}
+
+func TestSIPMessage_SetViaReceived(t *testing.T) {
+	msg := NewRequestMessage(MethodREGISTER, "sip:example.com")
+	msg.AddHeader(HeaderVia, "SIP/2.0/UDP 10.0.0.1:5060;rport;branch=z9hG4bK1, SIP/2.0/UDP 10.0.0.2;branch=z9hG4bK2")
+
+	if err := msg.SetViaReceived(&net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 9988}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	vias, err := msg.GetVias()
+	if err != nil || len(vias) != 2 {
+		t.Fatalf("Unexpected Vias: %+v, %v", vias, err)
+	}
+	if host, port := vias[0].ResponseAddress(); host != "192.0.2.1" || port != 9988 {
+		t.Errorf("Expected top Via response address 192.0.2.1:9988, got %s:%d", host, port)
+	}
+	if vias[1].Received() != "" {
+		t.Errorf("Expected second Via untouched, got received %s", vias[1].Received())
+	}
+
+	empty := NewRequestMessage(MethodREGISTER, "sip:example.com")
+	if err := empty.SetViaReceived(&net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 9988}); err == nil {
+		t.Error("Expected error for request without Via")
+	}
+}
+
+func TestParseNameAddr(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		displayName string
+		uri         string
+		tag         string
+	}{
+		{"quoted display name", `"Alice Smith" <sip:alice@atlanta.com>;tag=1928301774`, "Alice Smith", "sip:alice@atlanta.com", "1928301774"},
+		{"token display name", "Bob <sip:bob@biloxi.com>", "Bob", "sip:bob@biloxi.com", ""},
+		{"escaped quote", `"A \"B\" C" <sip:c@example.com>;tag=x`, `A "B" C`, "sip:c@example.com", "x"},
+		{"comma in display name", `"Smith, Alice" <sip:alice@example.com>`, "Smith, Alice", "sip:alice@example.com", ""},
+		{"addr-spec with tag", "sip:user@example.com;tag=simple", "", "sip:user@example.com", "simple"},
+		{"URI parameters inside brackets", "<sip:user@example.com;transport=tcp>;tag=abc", "", "sip:user@example.com;transport=tcp", "abc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, err := ParseNameAddr(tt.value)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if addr.DisplayName != tt.displayName {
+				t.Errorf("Expected display name %q, got %q", tt.displayName, addr.DisplayName)
+			}
+			if addr.URI != tt.uri {
+				t.Errorf("Expected URI %q, got %q", tt.uri, addr.URI)
+			}
+			if addr.Tag() != tt.tag {
+				t.Errorf("Expected tag %q, got %q", tt.tag, addr.Tag())
+			}
+			if addr.String() != tt.value {
+				t.Errorf("Round trip mismatch: %q became %q", tt.value, addr.String())
+			}
+		})
+	}
+}
+
+func TestParseNameAddr_Errors(t *testing.T) {
+	invalid := []string{
+		"",
+		"<sip:alice@example.com",
+		`"Alice <sip:alice@example.com>`,
+		`"Alice" sip:alice@example.com`,
+		"<>",
+		"sip:alice@example.com;tag=",
+	}
+	for _, value := range invalid {
+		if _, err := ParseNameAddr(value); err == nil {
+			t.Errorf("Expected error for %q", value)
+		}
+	}
+}
+
+func TestNameAddr_SetTag(t *testing.T) {
+	addr := &NameAddr{DisplayName: "Alice Smith", URI: "sip:alice@example.com"}
+	addr.SetTag("abc")
+
+	expected := "Alice Smith <sip:alice@example.com>;tag=abc"
+	if addr.String() != expected {
+		t.Errorf("Expected %s, got %s", expected, addr.String())
+	}
+
+	addr.DisplayName = "Smith, Alice"
+	if !strings.HasPrefix(addr.String(), `"Smith, Alice" <`) {
+		t.Errorf("Expected display name with special characters to be quoted, got %s", addr.String())
+	}
+}
+
+func TestParseContact(t *testing.T) {
+	contact, err := ParseContact(`<sip:alice@192.0.2.4:5060;ob>;q=0.7;expires=3600;+sip.instance="<urn:uuid:00000000-0000-1000-8000-AABBCCDDEEFF>";reg-id=2`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if contact.URI != "sip:alice@192.0.2.4:5060;ob" {
+		t.Errorf("Unexpected URI: %s", contact.URI)
+	}
+	if q, ok := contact.Q(); !ok || q != 0.7 {
+		t.Errorf("Expected q 0.7, got %v %v", q, ok)
+	}
+	if expires, ok := contact.Expires(); !ok || expires != 3600 {
+		t.Errorf("Expected expires 3600, got %v %v", expires, ok)
+	}
+	if contact.Instance() != "urn:uuid:00000000-0000-1000-8000-AABBCCDDEEFF" {
+		t.Errorf("Unexpected instance: %s", contact.Instance())
+	}
+	if regID, ok := contact.RegID(); !ok || regID != 2 {
+		t.Errorf("Expected reg-id 2, got %v %v", regID, ok)
+	}
+
+	wildcard, err := ParseContact("*")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !wildcard.Wildcard || wildcard.String() != "*" {
+		t.Errorf("Expected wildcard contact, got %+v", wildcard)
+	}
+
+	if _, ok := (&Contact{NameAddr: NameAddr{URI: "sip:a@b", Params: Params{{Name: "expires", Value: "soon"}}}}).Expires(); ok {
+		t.Error("Expected invalid expires to be reported as absent")
+	}
+	if _, ok := (&Contact{NameAddr: NameAddr{URI: "sip:a@b", Params: Params{{Name: "reg-id", Value: "0"}}}}).RegID(); ok {
+		t.Error("Expected reg-id 0 to be reported as absent")
+	}
+}
+
+func TestJoinHostPort(t *testing.T) {
+	tests := []struct {
+		host     string
+		port     int
+		expected string
+	}{
+		{"example.com", 5060, "example.com:5060"},
+		{"192.0.2.1", 0, "192.0.2.1"},
+		{"2001:db8::1", 5060, "[2001:db8::1]:5060"},
+		{"2001:db8::1", 0, "[2001:db8::1]"},
+		{"[2001:db8::1]", 5061, "[2001:db8::1]:5061"},
+	}
+	for _, tt := range tests {
+		if result := JoinHostPort(tt.host, tt.port); result != tt.expected {
+			t.Errorf("JoinHostPort(%q, %d) = %q, expected %q", tt.host, tt.port, result, tt.expected)
+		}
+	}
+
+	// IPv6 references in a Contact survive parsing and serialization
+	contact, err := ParseContact("<sip:alice@[2001:db8::10]:5070;transport=tcp>;expires=60")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	uri, err := ParseURI(contact.URI)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if uri.Host != "2001:db8::10" || uri.Port != 5070 || uri.HostPort() != "[2001:db8::10]:5070" {
+		t.Errorf("Unexpected host %q port %d hostport %q", uri.Host, uri.Port, uri.HostPort())
+	}
+}
+
+func TestParseContactList(t *testing.T) {
+	contacts, err := ParseContactList(`"Mr. Watson" <sip:watson@worcester.bell-telephone.com>;q=0.7, <mailto:watson@bell-telephone.com>;q=0.1`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(contacts) != 2 {
+		t.Fatalf("Expected 2 contacts, got %d", len(contacts))
+	}
+	if contacts[1].URI != "mailto:watson@bell-telephone.com" {
+		t.Errorf("Unexpected second contact URI: %s", contacts[1].URI)
+	}
+}
+
+func TestParseCSeq(t *testing.T) {
+	cseq, err := ParseCSeq("314159 INVITE")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cseq.Seq != 314159 || cseq.Method != MethodINVITE {
+		t.Errorf("Unexpected CSeq: %+v", cseq)
+	}
+	if cseq.String() != "314159 INVITE" {
+		t.Errorf("Unexpected string: %s", cseq.String())
+	}
+
+	for _, value := range []string{"", "INVITE", "abc INVITE", "1 INVITE extra", "4294967296 INVITE"} {
+		if _, err := ParseCSeq(value); err == nil {
+			t.Errorf("Expected error for %q", value)
+		}
+	}
+}
+
+func TestSIPMessage_TypedHeadersRoundTrip(t *testing.T) {
+	raw := "INVITE sip:bob@biloxi.com SIP/2.0\r\n" +
+		"Via: SIP/2.0/UDP proxy.example.com;branch=z9hG4bK1, SIP/2.0/UDP [2001:db8::9]:5060;branch=z9hG4bK2;received=2001:db8::10\r\n" +
+		"Max-Forwards: 70\r\n" +
+		"To: Bob <sip:bob@biloxi.com>\r\n" +
+		"From: \"Alice\" <sip:alice@atlanta.com>;tag=1928301774\r\n" +
+		"Call-ID: a84b4c76e66710@pc33.atlanta.com\r\n" +
+		"CSeq: 314159 INVITE\r\n" +
+		"Contact: <sip:alice@pc33.atlanta.com>;expires=60\r\n" +
+		"Route: <sip:p1.example.com;lr>, <sip:p2.example.com;lr>\r\n" +
+		"Record-Route: <sip:p3.example.com;lr>\r\n" +
+		"Content-Length: 0\r\n" +
+		"\r\n"
+
+	p := NewParser()
+	msg, err := p.Parse([]byte(raw))
+	if err != nil {
+		t.Fatalf("Failed to parse message: %v", err)
+	}
+
+	vias, err := msg.GetVias()
+	if err != nil {
+		t.Fatalf("Failed to get Vias: %v", err)
+	}
+	if len(vias) != 2 || vias[1].Host != "2001:db8::9" || vias[1].Received() != "2001:db8::10" {
+		t.Fatalf("Unexpected Vias: %+v", vias)
+	}
+	topVia, err := msg.GetTopVia()
+	if err != nil || topVia.Branch() != "z9hG4bK1" {
+		t.Errorf("Unexpected top Via: %+v, %v", topVia, err)
+	}
+
+	from, err := msg.GetFrom()
+	if err != nil || from.Tag() != "1928301774" {
+		t.Errorf("Unexpected From: %+v, %v", from, err)
+	}
+	to, err := msg.GetTo()
+	if err != nil || to.URI != "sip:bob@biloxi.com" || to.Tag() != "" {
+		t.Errorf("Unexpected To: %+v, %v", to, err)
+	}
+	cseq, err := msg.GetCSeq()
+	if err != nil || cseq.Seq != 314159 {
+		t.Errorf("Unexpected CSeq: %+v, %v", cseq, err)
+	}
+	contacts, err := msg.GetContacts()
+	if err != nil || len(contacts) != 1 {
+		t.Fatalf("Unexpected Contacts: %+v, %v", contacts, err)
+	}
+	routes, err := msg.GetRoutes()
+	if err != nil || len(routes) != 2 || routes[1].URI != "sip:p2.example.com;lr" {
+		t.Errorf("Unexpected Routes: %+v, %v", routes, err)
+	}
+	recordRoutes, err := msg.GetRecordRoutes()
+	if err != nil || len(recordRoutes) != 1 {
+		t.Errorf("Unexpected Record-Routes: %+v, %v", recordRoutes, err)
+	}
+
+	// Writing the typed values back must not change any header value
+	before := msg.Clone()
+	msg.SetVias(vias)
+	msg.SetHeader(HeaderFrom, from.String())
+	msg.SetHeader(HeaderTo, to.String())
+	msg.SetHeader(HeaderCSeq, cseq.String())
+	msg.SetContacts(contacts)
+	msg.SetRoutes(routes)
+	msg.SetRecordRoutes(recordRoutes)
+	for name, values := range before.Headers {
+		if strings.Join(msg.Headers[name], "\n") != strings.Join(values, "\n") {
+			t.Errorf("Typed round trip changed %s: %q became %q", name, values, msg.Headers[name])
+		}
+	}
+
+	after, err := p.Serialize(msg)
+	if err != nil {
+		t.Fatalf("Failed to serialize: %v", err)
+	}
+	reparsed, err := p.Parse(after)
+	if err != nil {
+		t.Fatalf("Failed to reparse: %v", err)
+	}
+	reparsedVias, err := reparsed.GetVias()
+	if err != nil || len(reparsedVias) != 2 || reparsedVias[1].String() != vias[1].String() {
+		t.Errorf("Vias did not survive reparse: %+v, %v", reparsedVias, err)
+	}
+}
+
+func TestSIPMessage_MissingTypedHeaders(t *testing.T) {
+	msg := NewRequestMessage(MethodOPTIONS, "sip:example.com")
+
+	if _, err := msg.GetTopVia(); err == nil {
+		t.Error("Expected error for missing Via")
+	}
+	if _, err := msg.GetFrom(); err == nil {
+		t.Error("Expected error for missing From")
+	}
+	if _, err := msg.GetCSeq(); err == nil {
+		t.Error("Expected error for missing CSeq")
+	}
+	if routes, err := msg.GetRoutes(); err != nil || len(routes) != 0 {
+		t.Errorf("Expected empty route set, got %v, %v", routes, err)
+	}
+}
@@ -127,6 +127,20 @@ type SIPMessage struct {
 	Transport   string
 	Source      net.Addr
 	Destination net.Addr
+
+	// lines records the header lines of a parsed message in wire order so
+	// that Serialize can reproduce headers the application did not change.
+	// FastParser leaves it empty and keeps the raw header block instead,
+	// from which the lines are derived only when needed.
+	lines      []headerLine
+	rawHeaders string
+}
+
+// headerLine records how a header line appeared on the wire
+type headerLine struct {
+	name     string   // canonical name used as the key in Headers
+	wireName string   // name as written, keeping compact form and casing
+	values   []string // values taken from this line
 }
 
 // StartLine interface for request and status lines
@@ -307,6 +321,10 @@ func (m *SIPMessage) Clone() *SIPMessage {
 	// Copy body
 	copy(clone.Body, m.Body)
 
+	// Header lines are never modified after parsing, so they can be shared
+	clone.lines = m.lines
+	clone.rawHeaders = m.rawHeaders
+
 	// Copy headers
 	for name, values := range m.Headers {
 		clone.Headers[name] = make([]string, len(values))
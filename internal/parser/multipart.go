@@ -0,0 +1,291 @@
+package parser
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// Common body content types
+const (
+	ContentTypeSDP            = "application/sdp"
+	ContentTypeMultipartMixed = "multipart/mixed"
+)
+
+// BodyPart is one part of a multipart message body. Headers keep the order
+// and spelling they had on the wire so unmodified parts are relayed unchanged.
+type BodyPart struct {
+	Headers []Header
+	Body    []byte
+}
+
+// NewBodyPart creates a body part with the given Content-Type
+func NewBodyPart(contentType string, body []byte) *BodyPart {
+	return &BodyPart{
+		Headers: []Header{{Name: HeaderContentType, Values: []string{contentType}}},
+		Body:    body,
+	}
+}
+
+// GetHeader returns the first value of a part header, matching names case-insensitively
+func (p *BodyPart) GetHeader(name string) string {
+	for _, header := range p.Headers {
+		if strings.EqualFold(header.Name, name) && len(header.Values) > 0 {
+			return header.Values[0]
+		}
+	}
+	return ""
+}
+
+// SetHeader sets a part header value, replacing any existing values
+func (p *BodyPart) SetHeader(name, value string) {
+	for i, header := range p.Headers {
+		if strings.EqualFold(header.Name, name) {
+			p.Headers[i].Values = []string{value}
+			return
+		}
+	}
+	p.Headers = append(p.Headers, Header{Name: name, Values: []string{value}})
+}
+
+// ContentType returns the lower-cased media type of the part without parameters.
+// A part without Content-Type is text/plain (RFC 2046 §5.1).
+func (p *BodyPart) ContentType() string {
+	value := p.GetHeader(HeaderContentType)
+	if value == "" {
+		return "text/plain"
+	}
+	return mediaType(value)
+}
+
+// ContentDisposition returns the lower-cased disposition type of the part,
+// e.g. "session" or "render", or "" if the part has no Content-Disposition
+func (p *BodyPart) ContentDisposition() string {
+	value := p.GetHeader(HeaderContentDisposition)
+	disposition, _, _ := strings.Cut(value, ";")
+	return strings.ToLower(strings.TrimSpace(disposition))
+}
+
+// MultipartBody is a parsed multipart MIME body (RFC 2046 §5.1)
+type MultipartBody struct {
+	MediaType string            // e.g. multipart/mixed
+	Params    map[string]string // Content-Type parameters other than boundary, e.g. type and start
+	Boundary  string
+	Parts     []*BodyPart
+}
+
+// NewMultipartBody creates an empty multipart body with a random boundary
+func NewMultipartBody(mediaType string) *MultipartBody {
+	buf := make([]byte, 12)
+	rand.Read(buf)
+	return &MultipartBody{
+		MediaType: mediaType,
+		Boundary:  "boundary-" + hex.EncodeToString(buf),
+	}
+}
+
+// ParseMultipartBody parses body according to its Content-Type header value
+func ParseMultipartBody(contentType string, body []byte) (*MultipartBody, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Content-Type %q: %w", contentType, err)
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		return nil, fmt.Errorf("not a multipart body: %s", mediaType)
+	}
+	boundary := params["boundary"]
+	if boundary == "" {
+		return nil, errors.New("multipart Content-Type without boundary")
+	}
+
+	delete(params, "boundary")
+
+	delimiter := []byte("--" + boundary)
+	multipart := &MultipartBody{MediaType: mediaType, Params: params, Boundary: boundary}
+
+	// Skip the preamble; the first delimiter may start the body or follow a line break
+	start := bytes.Index(body, delimiter)
+	if start < 0 || (start > 0 && body[start-1] != '\n') {
+		return nil, errors.New("multipart body has no opening boundary")
+	}
+	rest := body[start+len(delimiter):]
+
+	for {
+		if bytes.HasPrefix(rest, []byte("--")) {
+			// Close delimiter; anything after it is the epilogue
+			break
+		}
+		rest = skipLine(rest)
+
+		// The part ends at the CRLF preceding the next delimiter
+		end := bytes.Index(rest, append([]byte("\n"), delimiter...))
+		if end < 0 {
+			return nil, errors.New("multipart body has no closing boundary")
+		}
+		content := rest[:end]
+		content = bytes.TrimSuffix(content, []byte("\r"))
+
+		part, err := parseBodyPart(content)
+		if err != nil {
+			return nil, fmt.Errorf("invalid body part %d: %w", len(multipart.Parts)+1, err)
+		}
+		multipart.Parts = append(multipart.Parts, part)
+		rest = rest[end+1+len(delimiter):]
+	}
+
+	return multipart, nil
+}
+
+// skipLine drops the remainder of the delimiter line, including transport padding
+func skipLine(data []byte) []byte {
+	if idx := bytes.IndexByte(data, '\n'); idx >= 0 {
+		return data[idx+1:]
+	}
+	return nil
+}
+
+func parseBodyPart(content []byte) (*BodyPart, error) {
+	part := &BodyPart{}
+
+	for {
+		lineEnd := bytes.IndexByte(content, '\n')
+		var line []byte
+		if lineEnd < 0 {
+			line = content
+		} else {
+			line = content[:lineEnd]
+		}
+		line = bytes.TrimSuffix(line, []byte("\r"))
+
+		// A blank line separates the part headers from the part body
+		if len(line) == 0 {
+			if lineEnd >= 0 {
+				part.Body = content[lineEnd+1:]
+			}
+			return part, nil
+		}
+		if lineEnd < 0 {
+			return nil, errors.New("missing blank line after part headers")
+		}
+		content = content[lineEnd+1:]
+
+		if line[0] == ' ' || line[0] == '\t' {
+			if len(part.Headers) == 0 {
+				return nil, errors.New("header continuation without previous header")
+			}
+			last := &part.Headers[len(part.Headers)-1]
+			last.Values[0] += " " + strings.TrimSpace(string(line))
+			continue
+		}
+
+		name, value, found := strings.Cut(string(line), ":")
+		if !found || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid part header: %s", line)
+		}
+		part.Headers = append(part.Headers, Header{
+			Name:   strings.TrimSpace(name),
+			Values: []string{strings.TrimSpace(value)},
+		})
+	}
+}
+
+// ContentType returns the Content-Type header value for the body
+func (m *MultipartBody) ContentType() string {
+	params := make(map[string]string, len(m.Params)+1)
+	for name, value := range m.Params {
+		params[name] = value
+	}
+	params["boundary"] = m.Boundary
+	return mime.FormatMediaType(m.MediaType, params)
+}
+
+// FindPart returns the first part with the given media type, or nil
+func (m *MultipartBody) FindPart(contentType string) *BodyPart {
+	for _, part := range m.Parts {
+		if part.ContentType() == strings.ToLower(contentType) {
+			return part
+		}
+	}
+	return nil
+}
+
+// AddPart appends a part to the body
+func (m *MultipartBody) AddPart(part *BodyPart) {
+	m.Parts = append(m.Parts, part)
+}
+
+// Bytes returns the body in wire format
+func (m *MultipartBody) Bytes() []byte {
+	var buffer bytes.Buffer
+	for _, part := range m.Parts {
+		buffer.WriteString("--")
+		buffer.WriteString(m.Boundary)
+		buffer.WriteString("\r\n")
+		for _, header := range part.Headers {
+			buffer.WriteString(header.String())
+			buffer.WriteString("\r\n")
+		}
+		buffer.WriteString("\r\n")
+		buffer.Write(part.Body)
+		buffer.WriteString("\r\n")
+	}
+	buffer.WriteString("--")
+	buffer.WriteString(m.Boundary)
+	buffer.WriteString("--\r\n")
+	return buffer.Bytes()
+}
+
+// mediaType returns the lower-cased media type of a Content-Type value without parameters
+func mediaType(contentType string) string {
+	value, _, _ := strings.Cut(contentType, ";")
+	return strings.ToLower(strings.TrimSpace(value))
+}
+
+// GetContentType returns the lower-cased media type of the message body
+// without parameters, or "" if the message has no Content-Type
+func (m *SIPMessage) GetContentType() string {
+	return mediaType(m.GetHeader(HeaderContentType))
+}
+
+// IsMultipart checks if the message carries a multipart body
+func (m *SIPMessage) IsMultipart() bool {
+	return strings.HasPrefix(m.GetContentType(), "multipart/")
+}
+
+// GetMultipartBody parses the message body as a multipart body
+func (m *SIPMessage) GetMultipartBody() (*MultipartBody, error) {
+	return ParseMultipartBody(m.GetHeader(HeaderContentType), m.Body)
+}
+
+// SetMultipartBody replaces the message body, updating Content-Type and
+// Content-Length. Parameters of a Content-Type the message already has for the
+// same media type, such as type and start of multipart/related, are kept; only
+// the boundary is taken from body.
+func (m *SIPMessage) SetMultipartBody(body *MultipartBody) {
+	contentType := body.ContentType()
+	mediaType, params, err := mime.ParseMediaType(m.GetHeader(HeaderContentType))
+	if err == nil && mediaType == strings.ToLower(body.MediaType) {
+		for name, value := range body.Params {
+			params[name] = value
+		}
+		params["boundary"] = body.Boundary
+		contentType = mime.FormatMediaType(body.MediaType, params)
+	}
+	m.SetBody(contentType, body.Bytes())
+}
+
+// SetBody replaces the message body, updating Content-Type and Content-Length
+func (m *SIPMessage) SetBody(contentType string, body []byte) {
+	m.Body = body
+	if contentType != "" {
+		m.SetHeader(HeaderContentType, contentType)
+	} else {
+		m.RemoveHeader(HeaderContentType)
+	}
+	m.SetHeader(HeaderContentLength, strconv.Itoa(len(body)))
+}
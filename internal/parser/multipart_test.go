@@ -0,0 +1,220 @@
+package parser
+
+import (
+	"mime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const testSDP = "v=0\r\n" +
+	"o=alice 2890844526 2890844526 IN IP4 192.0.2.1\r\n" +
+	"s=-\r\n" +
+	"c=IN IP4 192.0.2.1\r\n" +
+	"t=0 0\r\n" +
+	"m=audio 49170 RTP/AVP 0\r\n"
+
+const testMultipartBody = "--unique-boundary-1\r\n" +
+	"Content-Type: application/sdp\r\n" +
+	"\r\n" +
+	testSDP +
+	"\r\n" +
+	"--unique-boundary-1\r\n" +
+	"Content-Type: application/ISUP;version=nxv3;base=etsi121\r\n" +
+	"Content-Disposition: signal;handling=optional\r\n" +
+	"\r\n" +
+	"\x01\x00\x49\x00\x00\x03\x02\x00\x07\x04\x10\x00\x33\x63\x21\r\n" +
+	"--unique-boundary-1--\r\n"
+
+func TestParseMultipartBody(t *testing.T) {
+	body, err := ParseMultipartBody(`multipart/mixed;boundary=unique-boundary-1`, []byte(testMultipartBody))
+	if err != nil {
+		t.Fatalf("Failed to parse multipart body: %v", err)
+	}
+
+	if body.MediaType != ContentTypeMultipartMixed {
+		t.Errorf("Expected media type multipart/mixed, got %s", body.MediaType)
+	}
+	if len(body.Parts) != 2 {
+		t.Fatalf("Expected 2 parts, got %d", len(body.Parts))
+	}
+
+	sdp := body.FindPart(ContentTypeSDP)
+	if sdp == nil {
+		t.Fatal("Expected SDP part")
+	}
+	if string(sdp.Body) != testSDP {
+		t.Errorf("Unexpected SDP part body: %q", sdp.Body)
+	}
+
+	isup := body.Parts[1]
+	if isup.ContentType() != "application/isup" {
+		t.Errorf("Expected application/isup, got %s", isup.ContentType())
+	}
+	if isup.ContentDisposition() != "signal" {
+		t.Errorf("Expected disposition signal, got %s", isup.ContentDisposition())
+	}
+	if len(isup.Body) != 15 || isup.Body[0] != 0x01 {
+		t.Errorf("Binary part was not preserved: %q", isup.Body)
+	}
+
+	// Serializing an unmodified body reproduces the input
+	if string(body.Bytes()) != testMultipartBody {
+		t.Errorf("Round trip changed body:\n%q\nvs\n%q", testMultipartBody, body.Bytes())
+	}
+}
+
+func TestParseMultipartBody_PreambleAndEpilogue(t *testing.T) {
+	raw := "This is the preamble.\r\n" +
+		"--b1\r\n" +
+		"\r\n" +
+		"plain text\r\n" +
+		"--b1--\r\n" +
+		"This is the epilogue.\r\n"
+
+	body, err := ParseMultipartBody(`multipart/mixed; boundary="b1"`, []byte(raw))
+	if err != nil {
+		t.Fatalf("Failed to parse multipart body: %v", err)
+	}
+	if len(body.Parts) != 1 {
+		t.Fatalf("Expected 1 part, got %d", len(body.Parts))
+	}
+	if body.Parts[0].ContentType() != "text/plain" {
+		t.Errorf("Expected default text/plain, got %s", body.Parts[0].ContentType())
+	}
+	if string(body.Parts[0].Body) != "plain text" {
+		t.Errorf("Unexpected part body: %q", body.Parts[0].Body)
+	}
+}
+
+func TestParseMultipartBody_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{"not multipart", "application/sdp", testSDP},
+		{"missing boundary", "multipart/mixed", testMultipartBody},
+		{"no opening boundary", "multipart/mixed;boundary=other", testMultipartBody},
+		{"no closing boundary", "multipart/mixed;boundary=b1", "--b1\r\nContent-Type: text/plain\r\n\r\ntext"},
+		{"bad part header", "multipart/mixed;boundary=b1", "--b1\r\nnot a header\r\n\r\ntext\r\n--b1--\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseMultipartBody(tt.contentType, []byte(tt.body)); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
+
+func TestMultipartBody_Build(t *testing.T) {
+	body := NewMultipartBody(ContentTypeMultipartMixed)
+	body.AddPart(NewBodyPart(ContentTypeSDP, []byte(testSDP)))
+	location := NewBodyPart("application/pidf+xml", []byte("<presence/>"))
+	location.SetHeader(HeaderContentDisposition, "render;handling=optional")
+	body.AddPart(location)
+
+	msg := NewRequestMessage(MethodINVITE, "sip:bob@example.com")
+	msg.SetMultipartBody(body)
+
+	if !msg.IsMultipart() {
+		t.Fatal("Expected message to be multipart")
+	}
+	if !strings.Contains(msg.GetHeader(HeaderContentType), "boundary="+body.Boundary) {
+		t.Errorf("Content-Type missing boundary: %s", msg.GetHeader(HeaderContentType))
+	}
+
+	parsed, err := msg.GetMultipartBody()
+	if err != nil {
+		t.Fatalf("Failed to parse built body: %v", err)
+	}
+	if len(parsed.Parts) != 2 {
+		t.Fatalf("Expected 2 parts, got %d", len(parsed.Parts))
+	}
+	if string(parsed.FindPart(ContentTypeSDP).Body) != testSDP {
+		t.Error("SDP part did not survive round trip")
+	}
+	if parsed.Parts[1].ContentDisposition() != "render" {
+		t.Errorf("Expected disposition render, got %s", parsed.Parts[1].ContentDisposition())
+	}
+}
+
+func TestSetMultipartBody_KeepsContentTypeParams(t *testing.T) {
+	body := NewMultipartBody("multipart/related")
+	body.AddPart(NewBodyPart(ContentTypeSDP, []byte(testSDP)))
+
+	msg := NewRequestMessage(MethodINVITE, "sip:bob@example.com")
+	msg.SetHeader(HeaderContentType, `multipart/related;type="application/sdp";start="<sdp@example.com>";boundary=old`)
+	msg.SetMultipartBody(body)
+
+	_, params, err := mime.ParseMediaType(msg.GetHeader(HeaderContentType))
+	if err != nil {
+		t.Fatalf("Invalid Content-Type %q: %v", msg.GetHeader(HeaderContentType), err)
+	}
+	if params["type"] != ContentTypeSDP {
+		t.Errorf("Expected type %s, got %q", ContentTypeSDP, params["type"])
+	}
+	if params["start"] != "<sdp@example.com>" {
+		t.Errorf("Expected start <sdp@example.com>, got %q", params["start"])
+	}
+	if params["boundary"] != body.Boundary {
+		t.Errorf("Expected boundary %s, got %q", body.Boundary, params["boundary"])
+	}
+
+	// A parsed body keeps its parameters when written back
+	parsed, err := msg.GetMultipartBody()
+	if err != nil {
+		t.Fatalf("Failed to parse built body: %v", err)
+	}
+	if parsed.Params["type"] != ContentTypeSDP || parsed.Params["start"] != "<sdp@example.com>" {
+		t.Errorf("Parsed body lost its parameters: %v", parsed.Params)
+	}
+	if !strings.Contains(parsed.ContentType(), "start=") {
+		t.Errorf("ContentType dropped start: %s", parsed.ContentType())
+	}
+}
+
+func TestParseMessageWithMultipartBody(t *testing.T) {
+	raw := "INVITE sip:bob@example.com SIP/2.0\r\n" +
+		"Via: SIP/2.0/UDP 192.0.2.1;branch=z9hG4bK1\r\n" +
+		"Max-Forwards: 70\r\n" +
+		"To: <sip:bob@example.com>\r\n" +
+		"From: <sip:alice@example.com>;tag=1\r\n" +
+		"Call-ID: multipart@example.com\r\n" +
+		"CSeq: 1 INVITE\r\n" +
+		"Content-Type: multipart/mixed;boundary=unique-boundary-1\r\n" +
+		"Content-Length: " + strconv.Itoa(len(testMultipartBody)) + "\r\n" +
+		"\r\n" +
+		testMultipartBody
+
+	parser := NewParser()
+	msg, err := parser.Parse([]byte(raw))
+	if err != nil {
+		t.Fatalf("Failed to parse message: %v", err)
+	}
+	if err := parser.Validate(msg); err != nil {
+		t.Fatalf("Message failed validation: %v", err)
+	}
+
+	body, err := msg.GetMultipartBody()
+	if err != nil {
+		t.Fatalf("Failed to parse multipart body: %v", err)
+	}
+
+	// Replace only the SDP part; the ISUP part must be relayed byte for byte
+	body.FindPart(ContentTypeSDP).Body = []byte(strings.Replace(testSDP, "192.0.2.1", "198.51.100.1", -1))
+	msg.SetMultipartBody(body)
+
+	if err := parser.Validate(msg); err != nil {
+		t.Fatalf("Modified message failed validation: %v", err)
+	}
+	isupStart := strings.Index(testMultipartBody, "--unique-boundary-1\r\nContent-Type: application/ISUP")
+	if !strings.Contains(string(msg.Body), testMultipartBody[isupStart:]) {
+		t.Error("Non-SDP part was modified")
+	}
+	if strings.Contains(string(msg.Body), "IN IP4 192.0.2.1") {
+		t.Error("SDP part was not replaced")
+	}
+}
@@ -5,8 +5,11 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Parser implements the MessageParser interface
@@ -32,7 +35,7 @@ func (p *Parser) Parse(data []byte) (*SIPMessage, error) {
 	}
 
 	// Parse headers
-	headers, err := p.parseHeaders(reader)
+	headers, lines, err := p.parseHeaders(reader)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse headers: %w", err)
 	}
@@ -47,32 +50,39 @@ func (p *Parser) Parse(data []byte) (*SIPMessage, error) {
 		StartLine: startLine,
 		Headers:   headers,
 		Body:      body,
+		lines:     lines,
 	}
 
 	return msg, nil
 }
 
-// parseStartLine parses the first line of a SIP message
+// parseStartLine parses the first line of a SIP message. Elements must be
+// separated by a single space (RFC 3261 §7.1, §7.2).
 func (p *Parser) parseStartLine(reader *bufio.Reader) (StartLine, error) {
 	line, err := p.readLine(reader)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read start line: %w", err)
 	}
+	return parseStartLineText(line)
+}
 
-	parts := strings.Fields(line)
-	if len(parts) < 3 {
-		return nil, fmt.Errorf("invalid start line format: %s", line)
-	}
-
+// parseStartLineText parses a start line without its line ending
+func parseStartLineText(line string) (StartLine, error) {
 	// Check if it's a request or response
-	if strings.HasPrefix(parts[0], "SIP/") {
-		// Response line: SIP/2.0 200 OK
-		version := parts[0]
-		statusCode, err := strconv.Atoi(parts[1])
-		if err != nil {
-			return nil, fmt.Errorf("invalid status code: %s", parts[1])
+	if strings.HasPrefix(line, "SIP/") {
+		// Response line: SIP/2.0 200 OK; the reason phrase may be empty
+		version, rest, found := strings.Cut(line, " ")
+		if !found {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidStartLine, line)
+		}
+		if version != SIPVersion {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedVersion, version)
+		}
+		code, reasonPhrase, _ := strings.Cut(rest, " ")
+		statusCode, err := strconv.Atoi(code)
+		if err != nil || len(code) != 3 || !IsValidStatusCode(statusCode) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidStatusCode, code)
 		}
-		reasonPhrase := strings.Join(parts[2:], " ")
 		
 		return &StatusLine{
 			Version:      version,
@@ -81,16 +91,24 @@ func (p *Parser) parseStartLine(reader *bufio.Reader) (StartLine, error) {
 		}, nil
 	} else {
 		// Request line: INVITE sip:user@example.com SIP/2.0
+		parts := strings.Split(line, " ")
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidStartLine, line)
+		}
 		method := parts[0]
 		requestURI := parts[1]
 		version := parts[2]
 		
-		if !IsValidMethod(method) {
-			return nil, fmt.Errorf("invalid method: %s", method)
+		if !IsToken(method) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMethod, method)
+		}
+		
+		if err := validateRequestURI(requestURI); err != nil {
+			return nil, err
 		}
 		
 		if version != SIPVersion {
-			return nil, fmt.Errorf("unsupported SIP version: %s", version)
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedVersion, version)
 		}
 		
 		return &RequestLine{
@@ -101,15 +119,41 @@ func (p *Parser) parseStartLine(reader *bufio.Reader) (StartLine, error) {
 	}
 }
 
-// parseHeaders parses SIP headers
-func (p *Parser) parseHeaders(reader *bufio.Reader) (map[string][]string, error) {
+// validateRequestURI checks that a Request-URI is an absolute URI without
+// enclosing angle brackets. SIP, SIPS and tel URIs must also be well formed,
+// and SIP URIs must not carry headers (RFC 3261 §19.1.1).
+func validateRequestURI(requestURI string) error {
+	scheme, _, found := strings.Cut(requestURI, ":")
+	if !found || scheme == "" || !isAlphanumeric(scheme[0]) || strings.ContainsAny(requestURI, "<>\"\t") {
+		return fmt.Errorf("%w: %s", ErrInvalidRequestURI, requestURI)
+	}
+
+	switch strings.ToLower(scheme) {
+	case SchemeSIP, SchemeSIPS, SchemeTel:
+		uri, err := ParseURI(requestURI)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRequestURI, err)
+		}
+		if len(uri.Headers) > 0 {
+			return fmt.Errorf("%w: headers are not allowed: %s", ErrInvalidRequestURI, requestURI)
+		}
+	}
+	return nil
+}
+
+// parseHeaders parses SIP headers, returning them keyed by canonical name
+// together with the header lines in wire order
+func (p *Parser) parseHeaders(reader *bufio.Reader) (map[string][]string, []headerLine, error) {
 	headers := make(map[string][]string)
-	var lastHeaderName string
+	var lines []headerLine
 	
+	// Unfold continuation lines (starting with space or tab) into the
+	// preceding header line first, so folded lists split correctly
+	var logicalLines []string
 	for {
 		line, err := p.readLine(reader)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read header line: %w", err)
+			return nil, nil, fmt.Errorf("failed to read header line: %w", err)
 		}
 		
 		// Empty line indicates end of headers
@@ -117,44 +161,62 @@ func (p *Parser) parseHeaders(reader *bufio.Reader) (map[string][]string, error)
 			break
 		}
 		
-		// Handle header folding (continuation lines start with space or tab)
 		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
-			if lastHeaderName == "" {
-				return nil, errors.New("header continuation without previous header")
+			if len(logicalLines) == 0 {
+				return nil, nil, fmt.Errorf("%w: continuation without previous header", ErrInvalidHeader)
 			}
-			// Append the continuation to the last header value
-			lastIndex := len(headers[lastHeaderName]) - 1
-			headers[lastHeaderName][lastIndex] += " " + strings.TrimSpace(line)
+			last := &logicalLines[len(logicalLines)-1]
+			*last = strings.TrimRight(*last, " \t") + " " + strings.TrimSpace(line)
 			continue
 		}
-		
-		// Parse header name and value
-		colonIndex := strings.Index(line, ":")
-		if colonIndex == -1 {
-			return nil, fmt.Errorf("invalid header format: %s", line)
-		}
-		
-		name := strings.TrimSpace(line[:colonIndex])
-		value := strings.TrimSpace(line[colonIndex+1:])
-		
-		if name == "" {
-			return nil, fmt.Errorf("empty header name: %s", line)
+		logicalLines = append(logicalLines, line)
+	}
+	
+	for _, line := range logicalLines {
+		name, wireName, value, err := parseHeaderField(line)
+		if err != nil {
+			return nil, nil, err
 		}
 		
-		// Handle compact header forms
-		name = p.expandCompactHeader(name)
-		lastHeaderName = name
-		
 		// Handle multi-value headers (comma-separated)
+		var values []string
 		if p.isMultiValueHeader(name) {
-			values := p.parseMultiValueHeader(value)
-			headers[name] = append(headers[name], values...)
+			values = p.parseMultiValueHeader(value)
 		} else {
-			headers[name] = append(headers[name], value)
+			if _, exists := headers[name]; exists && isSingleValueHeader(name) {
+				return nil, nil, fmt.Errorf("%w: %s", ErrDuplicateHeader, name)
+			}
+			values = []string{value}
 		}
+		headers[name] = append(headers[name], values...)
+		lines = append(lines, headerLine{
+			name:     name,
+			wireName: wireName,
+			values:   append([]string(nil), values...),
+		})
 	}
 	
-	return headers, nil
+	return headers, lines, nil
+}
+
+// parseHeaderField splits an unfolded header line into its canonical name,
+// the name as written and the trimmed value. Whitespace is allowed before
+// the colon.
+func parseHeaderField(line string) (name, wireName, value string, err error) {
+	colonIndex := strings.IndexByte(line, ':')
+	if colonIndex == -1 {
+		return "", "", "", fmt.Errorf("%w: %s", ErrInvalidHeader, line)
+	}
+	
+	wireName = strings.TrimRight(line[:colonIndex], " \t")
+	value = strings.TrimSpace(line[colonIndex+1:])
+	
+	if !IsToken(wireName) {
+		return "", "", "", fmt.Errorf("%w: invalid header name: %s", ErrInvalidHeader, line)
+	}
+	
+	// Handle compact header forms and case variations
+	return internHeaderName(wireName), wireName, value, nil
 }
 
 // parseBody parses the message body
@@ -170,39 +232,35 @@ func (p *Parser) parseBody(reader *bufio.Reader, headers map[string][]string) ([
 		return nil, nil
 	}
 	
-	contentLength, err := strconv.Atoi(contentLengthStr)
+	contentLength, err := parseContentLength(contentLengthStr)
 	if err != nil {
-		return nil, fmt.Errorf("invalid Content-Length: %s", contentLengthStr)
-	}
-	
-	if contentLength < 0 {
-		return nil, fmt.Errorf("negative Content-Length: %d", contentLength)
+		return nil, err
 	}
 	
 	if contentLength == 0 {
 		return nil, nil
 	}
 	
-	// Read the exact number of bytes specified by Content-Length
+	// Read the exact number of bytes specified by Content-Length; anything
+	// after them is not part of this message
 	body := make([]byte, contentLength)
-	totalRead := 0
-	
-	for totalRead < contentLength {
-		n, err := reader.Read(body[totalRead:])
-		if err != nil {
-			if n == 0 {
-				return nil, fmt.Errorf("failed to read body: %w", err)
-			}
-		}
-		totalRead += n
-		if totalRead >= contentLength {
-			break
-		}
+	if _, err := io.ReadFull(reader, body); err != nil {
+		return nil, fmt.Errorf("%w: expected %d bytes: %v", ErrIncompleteBody, contentLength, err)
 	}
 	
 	return body, nil
 }
 
+// parseContentLength parses a Content-Length value, which must be a
+// non-negative decimal integer
+func parseContentLength(value string) (int, error) {
+	contentLength, err := strconv.ParseUint(value, 10, 31)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrInvalidContentLength, value)
+	}
+	return int(contentLength), nil
+}
+
 // readLine reads a line from the reader, handling CRLF line endings
 func (p *Parser) readLine(reader *bufio.Reader) (string, error) {
 	line, err := reader.ReadString('\n')
@@ -215,30 +273,27 @@ func (p *Parser) readLine(reader *bufio.Reader) (string, error) {
 	return line, nil
 }
 
+// compactHeaders maps compact header forms (RFC 3261 §7.3.3) to full names
+var compactHeaders = map[string]string{
+	"i": HeaderCallID,
+	"m": HeaderContact,
+	"l": HeaderContentLength,
+	"c": HeaderContentType,
+	"f": HeaderFrom,
+	"s": HeaderSubject,
+	"k": HeaderSupported,
+	"t": HeaderTo,
+	"v": HeaderVia,
+}
+
 // expandCompactHeader expands compact header forms to full names
 func (p *Parser) expandCompactHeader(name string) string {
-	switch strings.ToLower(name) {
-	case "i":
-		return HeaderCallID
-	case "m":
-		return HeaderContact
-	case "l":
-		return HeaderContentLength
-	case "c":
-		return HeaderContentType
-	case "f":
-		return HeaderFrom
-	case "s":
-		return HeaderSubject
-	case "k":
-		return HeaderSupported
-	case "t":
-		return HeaderTo
-	case "v":
-		return HeaderVia
-	default:
-		return name
+	if len(name) == 1 {
+		if fullName, exists := compactHeaders[strings.ToLower(name)]; exists {
+			return fullName
+		}
 	}
+	return name
 }
 
 // isMultiValueHeader checks if a header can have multiple comma-separated values
@@ -256,45 +311,19 @@ func (p *Parser) isMultiValueHeader(name string) bool {
 
 // parseMultiValueHeader parses comma-separated header values
 func (p *Parser) parseMultiValueHeader(value string) []string {
-	var values []string
-	var current strings.Builder
-	inQuotes := false
-	inAngleBrackets := false
-	
-	for _, char := range value {
-		switch char {
-		case '"':
-			inQuotes = !inQuotes
-			current.WriteRune(char)
-		case '<':
-			inAngleBrackets = true
-			current.WriteRune(char)
-		case '>':
-			inAngleBrackets = false
-			current.WriteRune(char)
-		case ',':
-			if !inQuotes && !inAngleBrackets {
-				// End of current value
-				val := strings.TrimSpace(current.String())
-				if val != "" {
-					values = append(values, val)
-				}
-				current.Reset()
-			} else {
-				current.WriteRune(char)
-			}
-		default:
-			current.WriteRune(char)
-		}
-	}
-	
-	// Add the last value
-	val := strings.TrimSpace(current.String())
-	if val != "" {
-		values = append(values, val)
+	return splitHeaderList(value)
+}
+
+// isSingleValueHeader checks if a header must not appear more than once
+// in a message (RFC 3261 §7.3.1)
+func isSingleValueHeader(name string) bool {
+	switch name {
+	case HeaderCallID, HeaderCSeq, HeaderFrom, HeaderTo, HeaderMaxForwards,
+		HeaderContentLength, HeaderContentType, HeaderExpires:
+		return true
+	default:
+		return false
 	}
-	
-	return values
 }
 
 // Validate validates a SIP message according to RFC3261 rules
@@ -311,79 +340,124 @@ func (p *Parser) Validate(msg *SIPMessage) error {
 	requiredHeaders := []string{HeaderVia, HeaderFrom, HeaderTo, HeaderCallID, HeaderCSeq}
 	for _, header := range requiredHeaders {
 		if !msg.HasHeader(header) {
-			return fmt.Errorf("required header missing: %s", header)
+			return fmt.Errorf("%w: %s", ErrMissingHeader, header)
 		}
 	}
 	
 	// Validate Max-Forwards for requests
 	if msg.IsRequest() {
 		if !msg.HasHeader(HeaderMaxForwards) {
-			return fmt.Errorf("Max-Forwards header required for requests")
+			return fmt.Errorf("%w: %s", ErrMissingHeader, HeaderMaxForwards)
 		}
 		
+		// Max-Forwards is an integer in the range 0-255
 		maxForwardsStr := msg.GetHeader(HeaderMaxForwards)
-		maxForwards, err := strconv.Atoi(maxForwardsStr)
-		if err != nil {
-			return fmt.Errorf("invalid Max-Forwards value: %s", maxForwardsStr)
-		}
-		
-		if maxForwards < 0 || maxForwards > 255 {
-			return fmt.Errorf("Max-Forwards out of range: %d", maxForwards)
+		if _, err := strconv.ParseUint(maxForwardsStr, 10, 8); err != nil {
+			return fmt.Errorf("%w: invalid Max-Forwards value: %s", ErrInvalidHeaderValue, maxForwardsStr)
 		}
 	}
 	
 	// Validate Content-Length
 	if msg.HasHeader(HeaderContentLength) {
-		contentLengthStr := msg.GetHeader(HeaderContentLength)
-		contentLength, err := strconv.Atoi(contentLengthStr)
+		contentLength, err := parseContentLength(msg.GetHeader(HeaderContentLength))
 		if err != nil {
-			return fmt.Errorf("invalid Content-Length: %s", contentLengthStr)
-		}
-		
-		if contentLength < 0 {
-			return fmt.Errorf("negative Content-Length: %d", contentLength)
+			return err
 		}
 		
 		if len(msg.Body) != contentLength {
-			return fmt.Errorf("Content-Length mismatch: header says %d, body is %d bytes", 
+			return fmt.Errorf("%w: header says %d, body is %d bytes", ErrInvalidContentLength,
 				contentLength, len(msg.Body))
 		}
 	}
 	
-	// Validate CSeq header format
+	// Validate CSeq header format; the number must be below 2**31
 	cseqStr := msg.GetHeader(HeaderCSeq)
 	cseqParts := strings.Fields(cseqStr)
 	if len(cseqParts) != 2 {
-		return fmt.Errorf("invalid CSeq format: %s", cseqStr)
+		return fmt.Errorf("%w: invalid CSeq format: %s", ErrInvalidHeaderValue, cseqStr)
 	}
 	
-	cseqNum, err := strconv.ParseUint(cseqParts[0], 10, 32)
+	cseqNum, err := strconv.ParseUint(cseqParts[0], 10, 31)
 	if err != nil {
-		return fmt.Errorf("invalid CSeq number: %s", cseqParts[0])
+		return fmt.Errorf("%w: invalid CSeq number: %s", ErrInvalidHeaderValue, cseqParts[0])
 	}
 	
 	if cseqNum == 0 {
-		return fmt.Errorf("CSeq number cannot be zero")
+		return fmt.Errorf("%w: CSeq number cannot be zero", ErrInvalidHeaderValue)
 	}
 	
 	method := cseqParts[1]
-	if !IsValidMethod(method) {
-		return fmt.Errorf("invalid method in CSeq: %s", method)
+	if !IsToken(method) {
+		return fmt.Errorf("%w: invalid method in CSeq: %s", ErrInvalidHeaderValue, method)
 	}
 	
 	// For requests, CSeq method should match request method
 	if msg.IsRequest() {
 		requestMethod := msg.GetMethod()
 		if method != requestMethod {
-			return fmt.Errorf("CSeq method (%s) does not match request method (%s)", 
+			return fmt.Errorf("%w: CSeq method (%s), request method (%s)", ErrMethodMismatch,
 				method, requestMethod)
 		}
 	}
 	
+	// Validate the grammar of structured headers
+	if _, err := msg.GetVias(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidHeaderValue, err)
+	}
+	if _, err := msg.GetFrom(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidHeaderValue, err)
+	}
+	if _, err := msg.GetTo(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidHeaderValue, err)
+	}
+	if _, err := msg.GetContacts(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidHeaderValue, err)
+	}
+	if date := msg.GetHeader(HeaderDate); date != "" {
+		// SIP dates are always in GMT (RFC 3261 §20.17)
+		if _, err := time.Parse(sipDateFormat, date); err != nil {
+			return fmt.Errorf("%w: invalid Date: %s", ErrInvalidHeaderValue, date)
+		}
+	}
+	
 	return nil
 }
 
-// Serialize converts a SIP message back to wire format
+// sipDateFormat is the RFC 1123 date format required by the Date header
+const sipDateFormat = "Mon, 02 Jan 2006 15:04:05 GMT"
+
+// headerOrder is the order in which headers that did not come from a parsed
+// message are serialized
+var headerOrder = []string{
+	HeaderVia,
+	HeaderMaxForwards,
+	HeaderTo,
+	HeaderFrom,
+	HeaderCallID,
+	HeaderCSeq,
+	HeaderContact,
+	HeaderExpires,
+	HeaderSessionExpires,
+	HeaderMinSE,
+	HeaderAllow,
+	HeaderSupported,
+	HeaderRequire,
+	HeaderProxyRequire,
+	HeaderUnsupported,
+	HeaderWWWAuthenticate,
+	HeaderAuthorization,
+	HeaderProxyAuthenticate,
+	HeaderProxyAuthorization,
+	HeaderUserAgent,
+	HeaderServer,
+	HeaderContentType,
+	HeaderContentLength,
+}
+
+// Serialize converts a SIP message back to wire format. Headers of a parsed
+// message keep their original order, name spelling and line grouping unless
+// their values were changed; changed headers are written at the position
+// they first appeared, and added headers follow in a fixed order.
 func (p *Parser) Serialize(msg *SIPMessage) ([]byte, error) {
 	if msg == nil {
 		return nil, errors.New("message is nil")
@@ -399,56 +473,51 @@ func (p *Parser) Serialize(msg *SIPMessage) ([]byte, error) {
 	buffer.WriteString(msg.StartLine.String())
 	buffer.WriteString("\r\n")
 
-	// Write headers in proper order
-	headerOrder := []string{
-		HeaderVia,
-		HeaderMaxForwards,
-		HeaderTo,
-		HeaderFrom,
-		HeaderCallID,
-		HeaderCSeq,
-		HeaderContact,
-		HeaderExpires,
-		HeaderSessionExpires,
-		HeaderMinSE,
-		HeaderAllow,
-		HeaderSupported,
-		HeaderRequire,
-		HeaderProxyRequire,
-		HeaderUnsupported,
-		HeaderWWWAuthenticate,
-		HeaderAuthorization,
-		HeaderProxyAuthenticate,
-		HeaderProxyAuthorization,
-		HeaderUserAgent,
-		HeaderServer,
-		HeaderContentType,
-		HeaderContentLength,
-	}
-
-	// Write headers in preferred order
+	// Values each header had when the message was parsed
+	parsedValues := make(map[string][]string)
+	lines := msg.headerLines()
+	for _, line := range lines {
+		parsedValues[line.name] = append(parsedValues[line.name], line.values...)
+	}
+
+	// Write headers of the parsed message in wire order
 	writtenHeaders := make(map[string]bool)
+	for _, line := range lines {
+		values, exists := msg.Headers[line.name]
+		if !exists {
+			continue
+		}
+		if equalValues(values, parsedValues[line.name]) {
+			writeHeaderLine(&buffer, line.wireName, strings.Join(line.values, ", "))
+		} else if !writtenHeaders[line.name] {
+			for _, value := range values {
+				writeHeaderLine(&buffer, line.wireName, value)
+			}
+		}
+		writtenHeaders[line.name] = true
+	}
+
+	// Write added headers in preferred order
 	for _, headerName := range headerOrder {
-		if values, exists := msg.Headers[headerName]; exists {
+		if values, exists := msg.Headers[headerName]; exists && !writtenHeaders[headerName] {
 			for _, value := range values {
-				buffer.WriteString(headerName)
-				buffer.WriteString(": ")
-				buffer.WriteString(value)
-				buffer.WriteString("\r\n")
+				writeHeaderLine(&buffer, headerName, value)
 			}
 			writtenHeaders[headerName] = true
 		}
 	}
 
-	// Write remaining headers not in the preferred order
-	for headerName, values := range msg.Headers {
+	// Write remaining headers sorted by name so output is deterministic
+	var remaining []string
+	for headerName := range msg.Headers {
 		if !writtenHeaders[headerName] {
-			for _, value := range values {
-				buffer.WriteString(headerName)
-				buffer.WriteString(": ")
-				buffer.WriteString(value)
-				buffer.WriteString("\r\n")
-			}
+			remaining = append(remaining, headerName)
+		}
+	}
+	sort.Strings(remaining)
+	for _, headerName := range remaining {
+		for _, value := range msg.Headers[headerName] {
+			writeHeaderLine(&buffer, headerName, value)
 		}
 	}
 
@@ -463,6 +532,25 @@ func (p *Parser) Serialize(msg *SIPMessage) ([]byte, error) {
 	return buffer.Bytes(), nil
 }
 
+func writeHeaderLine(buffer *bytes.Buffer, name, value string) {
+	buffer.WriteString(name)
+	buffer.WriteString(": ")
+	buffer.WriteString(value)
+	buffer.WriteString("\r\n")
+}
+
+func equalValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
 // Additional header constants for parsing
 const (
 	HeaderSubject      = "Subject"
@@ -471,4 +559,59 @@ const (
 	HeaderAccept       = "Accept"
 	HeaderAcceptEncoding = "Accept-Encoding"
 	HeaderAcceptLanguage = "Accept-Language"
-)
\ No newline at end of file
+	HeaderContentDisposition = "Content-Disposition"
+	HeaderDate         = "Date"
+)
+
+// knownHeaders maps lower-cased header names to their canonical spelling
+var knownHeaders = func() map[string]string {
+	names := []string{
+		HeaderVia, HeaderFrom, HeaderTo, HeaderCallID, HeaderCSeq, HeaderMaxForwards,
+		HeaderContact, HeaderExpires, HeaderContentType, HeaderContentLength,
+		HeaderUserAgent, HeaderServer, HeaderAllow, HeaderSupported, HeaderRequire,
+		HeaderProxyRequire, HeaderUnsupported, HeaderWWWAuthenticate, HeaderAuthorization,
+		HeaderProxyAuthenticate, HeaderProxyAuthorization, HeaderSessionExpires, HeaderMinSE,
+		HeaderSubject, HeaderRoute, HeaderRecordRoute, HeaderAccept, HeaderAcceptEncoding,
+		HeaderAcceptLanguage, HeaderContentDisposition, HeaderDate,
+	}
+	known := make(map[string]string, len(names))
+	for _, name := range names {
+		known[strings.ToLower(name)] = name
+	}
+	return known
+}()
+
+// internedHeaderNames maps the usual spellings of known header names,
+// including compact forms, to their canonical name so that looking them up
+// does not allocate
+var internedHeaderNames = func() map[string]string {
+	interned := make(map[string]string)
+	for lower, name := range knownHeaders {
+		interned[name] = name
+		interned[lower] = name
+		interned[strings.ToUpper(name)] = name
+	}
+	for compact, name := range compactHeaders {
+		interned[compact] = name
+		interned[strings.ToUpper(compact)] = name
+	}
+	return interned
+}()
+
+// internHeaderName returns the canonical name for a header name as written
+// on the wire, expanding compact forms
+func internHeaderName(wireName string) string {
+	if name, exists := internedHeaderNames[wireName]; exists {
+		return name
+	}
+	return canonicalHeaderName(wireName)
+}
+
+// canonicalHeaderName returns the canonical spelling of a known header name,
+// or name unchanged for headers this package does not define
+func canonicalHeaderName(name string) string {
+	if canonical, exists := knownHeaders[strings.ToLower(name)]; exists {
+		return canonical
+	}
+	return name
+}
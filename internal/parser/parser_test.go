@@ -303,12 +303,12 @@ func TestParseErrors(t *testing.T) {
 		},
 		{
 			name: "Invalid method",
-			message: `INVALID sip:bob@example.com SIP/2.0
+			message: `INV@LID sip:bob@example.com SIP/2.0
 Via: SIP/2.0/UDP 192.168.1.1:5060
 From: Alice <sip:alice@example.com>
 To: Bob <sip:bob@example.com>
 Call-ID: test
-CSeq: 1 INVALID
+CSeq: 1 INV@LID
 Content-Length: 0
 
 `,
@@ -899,4 +899,125 @@ Content-Length: 0
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestSerializePreservesHeaderOrderAndCasing(t *testing.T) {
+	original := "INVITE sip:bob@example.com SIP/2.0\r\n" +
+		"v: SIP/2.0/UDP 192.168.1.1:5060;branch=z9hG4bK776asdhds, SIP/2.0/UDP 10.0.0.1;branch=z9hG4bK1\r\n" +
+		"X-Custom-B: second\r\n" +
+		"call-id: a84b4c76e66710@pc33.example.com\r\n" +
+		"f: Alice <sip:alice@example.com>;tag=1928301774\r\n" +
+		"t: Bob <sip:bob@example.com>\r\n" +
+		"Route: <sip:p1.example.com;lr>\r\n" +
+		"X-Custom-A: first\r\n" +
+		"Route: <sip:p2.example.com;lr>\r\n" +
+		"CSEQ: 314159 INVITE\r\n" +
+		"Max-Forwards: 70\r\n" +
+		"l: 0\r\n" +
+		"\r\n"
+
+	parser := NewParser()
+	msg, err := parser.Parse([]byte(original))
+	if err != nil {
+		t.Fatalf("Failed to parse message: %v", err)
+	}
+
+	// Header names are looked up by canonical name regardless of wire form
+	if msg.GetHeader(HeaderCallID) != "a84b4c76e66710@pc33.example.com" {
+		t.Errorf("Expected Call-ID to be found by canonical name, got %q", msg.GetHeader(HeaderCallID))
+	}
+	if msg.GetHeader(HeaderCSeq) != "314159 INVITE" {
+		t.Errorf("Expected CSeq to be found by canonical name, got %q", msg.GetHeader(HeaderCSeq))
+	}
+
+	serialized, err := parser.Serialize(msg)
+	if err != nil {
+		t.Fatalf("Failed to serialize message: %v", err)
+	}
+	if string(serialized) != original {
+		t.Errorf("Unmodified message changed on serialization:\n%s\nvs\n%s", original, serialized)
+	}
+
+	// The clone of a parsed message serializes the same way
+	cloned, err := parser.Serialize(msg.Clone())
+	if err != nil {
+		t.Fatalf("Failed to serialize clone: %v", err)
+	}
+	if string(cloned) != original {
+		t.Errorf("Cloned message changed on serialization:\n%s", cloned)
+	}
+}
+
+func TestSerializeOnlyChangesModifiedHeaders(t *testing.T) {
+	original := "INVITE sip:bob@example.com SIP/2.0\r\n" +
+		"Max-Forwards: 70\r\n" +
+		"v: SIP/2.0/UDP 192.168.1.1:5060;branch=z9hG4bK776asdhds\r\n" +
+		"To: Bob <sip:bob@example.com>\r\n" +
+		"From: Alice <sip:alice@example.com>;tag=1928301774\r\n" +
+		"Call-ID: a84b4c76e66710@pc33.example.com\r\n" +
+		"CSeq: 314159 INVITE\r\n" +
+		"Route: <sip:proxy.example.com;lr>\r\n" +
+		"Content-Length: 0\r\n" +
+		"\r\n"
+
+	parser := NewParser()
+	msg, err := parser.Parse([]byte(original))
+	if err != nil {
+		t.Fatalf("Failed to parse message: %v", err)
+	}
+
+	// Behave like a proxy: push a Via, decrement Max-Forwards, pop the Route
+	vias := msg.GetHeaders(HeaderVia)
+	msg.RemoveHeader(HeaderVia)
+	msg.AddHeader(HeaderVia, "SIP/2.0/UDP proxy.example.com;branch=z9hG4bKproxy")
+	for _, via := range vias {
+		msg.AddHeader(HeaderVia, via)
+	}
+	msg.SetHeader(HeaderMaxForwards, "69")
+	msg.RemoveHeader(HeaderRoute)
+	msg.AddHeader("Record-Route", "<sip:proxy.example.com;lr>")
+
+	expected := "INVITE sip:bob@example.com SIP/2.0\r\n" +
+		"Max-Forwards: 69\r\n" +
+		"v: SIP/2.0/UDP proxy.example.com;branch=z9hG4bKproxy\r\n" +
+		"v: SIP/2.0/UDP 192.168.1.1:5060;branch=z9hG4bK776asdhds\r\n" +
+		"To: Bob <sip:bob@example.com>\r\n" +
+		"From: Alice <sip:alice@example.com>;tag=1928301774\r\n" +
+		"Call-ID: a84b4c76e66710@pc33.example.com\r\n" +
+		"CSeq: 314159 INVITE\r\n" +
+		"Content-Length: 0\r\n" +
+		"Record-Route: <sip:proxy.example.com;lr>\r\n" +
+		"\r\n"
+
+	serialized, err := parser.Serialize(msg)
+	if err != nil {
+		t.Fatalf("Failed to serialize message: %v", err)
+	}
+	if string(serialized) != expected {
+		t.Errorf("Unexpected serialization:\n%s\nexpected:\n%s", serialized, expected)
+	}
+}
+
+func TestSerializeAddedHeadersDeterministic(t *testing.T) {
+	msg := NewRequestMessage(MethodOPTIONS, "sip:example.com")
+	msg.AddHeader("X-Zeta", "1")
+	msg.AddHeader("X-Alpha", "2")
+	msg.AddHeader(HeaderCallID, "abc")
+	msg.AddHeader("X-Mid", "3")
+
+	parser := NewParser()
+	serialized, err := parser.Serialize(msg)
+	if err != nil {
+		t.Fatalf("Failed to serialize message: %v", err)
+	}
+
+	expected := "OPTIONS sip:example.com SIP/2.0\r\n" +
+		"Call-ID: abc\r\n" +
+		"X-Alpha: 2\r\n" +
+		"X-Mid: 3\r\n" +
+		"X-Zeta: 1\r\n" +
+		"\r\n"
+	if string(serialized) != expected {
+		t.Errorf("Unexpected serialization:\n%s", serialized)
+	}
+}
@@ -0,0 +1,184 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// rfc4475Invalid maps each invalid message in testdata/rfc4475/invalid to the
+// error Parse or Validate must return for it. The messages are taken from
+// RFC 4475 §3.1.2, named as in the RFC.
+var rfc4475Invalid = map[string]error{
+	"badinv01":   ErrInvalidHeaderValue,   // extraneous header field separators
+	"clerr":      ErrIncompleteBody,       // Content-Length larger than message
+	"scalar02":   ErrInvalidContentLength, // negative Content-Length
+	"scalarlg":   ErrInvalidHeaderValue,   // request scalar fields with overlarge values
+	"scalar1s":   ErrInvalidHeaderValue,   // response scalar fields with overlarge values
+	"quotbal":    ErrInvalidHeaderValue,   // unterminated quoted string in display name
+	"ltgtruri":   ErrInvalidRequestURI,    // <> enclosing Request-URI
+	"lwsruri":    ErrInvalidStartLine,     // LWS within Request-URI
+	"lwsstart":   ErrInvalidStartLine,     // multiple SP separating Request-Line elements
+	"trws":       ErrInvalidStartLine,     // SP characters at end of Request-Line
+	"escruri":    ErrInvalidRequestURI,    // escaped headers in SIP Request-URI
+	"baddate":    ErrInvalidHeaderValue,   // invalid time zone in Date
+	"regbadct":   ErrInvalidHeaderValue,   // name-addr URI with '?' not enclosed in <>
+	"badaspec":   ErrInvalidHeaderValue,   // spaces within addr-spec
+	"baddn":      ErrInvalidHeaderValue,   // non-token characters in display name
+	"badvers":    ErrUnsupportedVersion,   // unknown protocol version
+	"mismatch01": ErrMethodMismatch,       // start line and CSeq method mismatch
+	"mismatch02": ErrMethodMismatch,       // unknown method with CSeq method mismatch
+	"bigcode":    ErrInvalidStatusCode,    // overlarge response code
+	"multi01":    ErrDuplicateHeader,      // multiple values in single value required fields
+	"mcl01":      ErrDuplicateHeader,      // multiple Content-Length values
+	"insuf":      ErrMissingHeader,        // missing required header fields
+}
+
+// repeatMarkup matches the <repeat count=N>text</repeat> markup RFC 4475 §2.3
+// uses to print long messages
+var repeatMarkup = regexp.MustCompile(`<repeat count=(\d+)>(.*?)</repeat>`)
+
+// expandRepeats replaces each repeat markup with count copies of its text
+func expandRepeats(data []byte) []byte {
+	return repeatMarkup.ReplaceAllFunc(data, func(markup []byte) []byte {
+		match := repeatMarkup.FindSubmatch(markup)
+		count, _ := strconv.Atoi(string(match[1]))
+		return bytes.Repeat(match[2], count)
+	})
+}
+
+// parseAndValidate runs a raw message through Parse and Validate
+func parseAndValidate(data []byte) (*SIPMessage, error) {
+	parser := NewParser()
+	msg, err := parser.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return msg, parser.Validate(msg)
+}
+
+// readTortureMessages reads the messages in testdata/rfc4475/dir. Messages the
+// RFC prints with repeat markup are kept as .markup files and expanded here.
+func readTortureMessages(t *testing.T, dir string) map[string][]byte {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join("testdata", "rfc4475", dir, "*.sip"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("No torture test messages in %s: %v", dir, err)
+	}
+	markups, err := filepath.Glob(filepath.Join("testdata", "rfc4475", dir, "*.markup"))
+	if err != nil {
+		t.Fatalf("Failed to list marked up messages in %s: %v", dir, err)
+	}
+	messages := make(map[string][]byte)
+	for _, file := range append(files, markups...) {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		if filepath.Ext(file) == ".markup" {
+			data = expandRepeats(data)
+		}
+		messages[strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))] = data
+	}
+	return messages
+}
+
+func TestRFC4475_ValidMessages(t *testing.T) {
+	messages := readTortureMessages(t, "valid")
+	for name, data := range messages {
+		t.Run(name, func(t *testing.T) {
+			if _, err := parseAndValidate(data); err != nil {
+				t.Errorf("Expected message to be accepted, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestRFC4475_InvalidMessages(t *testing.T) {
+	messages := readTortureMessages(t, "invalid")
+	for name := range rfc4475Invalid {
+		if _, exists := messages[name]; !exists {
+			t.Errorf("Missing message %s.sip", name)
+		}
+	}
+
+	for name, data := range messages {
+		t.Run(name, func(t *testing.T) {
+			want, exists := rfc4475Invalid[name]
+			if !exists {
+				t.Fatal("No expected error for message")
+			}
+			_, err := parseAndValidate(data)
+			if !errors.Is(err, want) {
+				t.Errorf("Expected %v, got: %v", want, err)
+			}
+		})
+	}
+}
+
+func TestRFC4475_ParsedValues(t *testing.T) {
+	messages := readTortureMessages(t, "valid")
+
+	// wsinv: folding, LWS around separators and odd header name casing
+	msg, err := parseAndValidate(messages["wsinv"])
+	if err != nil {
+		t.Fatalf("Failed to parse wsinv: %v", err)
+	}
+	if cseq, _ := msg.GetCSeq(); cseq.Seq != 9 || cseq.Method != MethodINVITE {
+		t.Errorf("Unexpected CSeq: %+v", cseq)
+	}
+	vias, _ := msg.GetVias()
+	if len(vias) != 3 || vias[1].Transport != "TCP" || vias[2].Branch() != "z9hG4bK30239" {
+		t.Errorf("Unexpected Vias: %d", len(vias))
+	}
+	if from, _ := msg.GetFrom(); from.DisplayName != `J Rosenberg \"` || from.Tag() != "98asjd8" {
+		t.Errorf("Unexpected From: %+v", from)
+	}
+	if msg.GetHeader("NewFangledHeader") != "newfangled value continued newfangled value" {
+		t.Errorf("Unexpected folded header: %q", msg.GetHeader("NewFangledHeader"))
+	}
+
+	// intmeth: extension method and token characters everywhere
+	msg, err = parseAndValidate(messages["intmeth"])
+	if err != nil {
+		t.Fatalf("Failed to parse intmeth: %v", err)
+	}
+	if msg.GetMethod() != "!interesting-Method0123456789_*+`.%indeed'~" {
+		t.Errorf("Unexpected method: %s", msg.GetMethod())
+	}
+
+	// noreason: empty reason phrase
+	msg, err = parseAndValidate(messages["noreason"])
+	if err != nil {
+		t.Fatalf("Failed to parse noreason: %v", err)
+	}
+	if msg.GetStatusCode() != 100 || msg.GetReasonPhrase() != "" {
+		t.Errorf("Unexpected status line: %s", msg.StartLine.String())
+	}
+
+	// longreq: long values generated from the RFC's repeat markup
+	msg, err = parseAndValidate(messages["longreq"])
+	if err != nil {
+		t.Fatalf("Failed to parse longreq: %v", err)
+	}
+	if from, _ := msg.GetFrom(); from.Tag() != "12"+strings.Repeat("982", 50)+"424" {
+		t.Errorf("Unexpected From tag: %s", from.Tag())
+	}
+	if vias, _ := msg.GetVias(); len(vias) != 34 {
+		t.Errorf("Expected 34 Vias, got %d", len(vias))
+	}
+
+	// dblreq: only the first of two messages in a datagram is parsed
+	msg, err = parseAndValidate(messages["dblreq"])
+	if err != nil {
+		t.Fatalf("Failed to parse dblreq: %v", err)
+	}
+	if msg.GetMethod() != MethodREGISTER || len(msg.Body) != 0 {
+		t.Errorf("Unexpected message: %s with %d byte body", msg.GetMethod(), len(msg.Body))
+	}
+}
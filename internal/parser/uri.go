@@ -0,0 +1,402 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// URI schemes
+const (
+	SchemeSIP  = "sip"
+	SchemeSIPS = "sips"
+	SchemeTel  = "tel"
+)
+
+// URI represents a SIP or SIPS URI (RFC 3261 §19.1) or a tel URI (RFC 3966).
+// User, Password, parameters and headers hold unescaped values; String
+// escapes them again as needed. For tel URIs, User holds the telephone
+// number and Host is empty.
+type URI struct {
+	Scheme   string
+	User     string
+	Password string
+	Host     string // IPv6 references are stored without brackets
+	Port     int    // 0 when the URI has no port
+	Params   Params
+	Headers  Params
+}
+
+// Character sets that may appear unescaped in each URI component, in
+// addition to alphanumerics
+const (
+	uriMark         = "-_.!~*'()"
+	userUnreserved  = uriMark + "&=+$,;?/"
+	passwordChars   = uriMark + "&=+$,"
+	paramUnreserved = uriMark + "[]/:&+$"
+	headerChars     = uriMark + "[]/?:+$"
+	telChars        = uriMark + "+#"
+)
+
+// telVisualSeparators are ignored when comparing telephone numbers
+const telVisualSeparators = "-.()"
+
+// ParseURI parses a sip:, sips: or tel: URI. Angle brackets around the URI are accepted.
+func ParseURI(value string) (*URI, error) {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "<") && strings.HasSuffix(value, ">") {
+		value = value[1 : len(value)-1]
+	}
+
+	colon := strings.Index(value, ":")
+	if colon <= 0 {
+		return nil, fmt.Errorf("missing URI scheme: %s", value)
+	}
+	scheme := strings.ToLower(value[:colon])
+	rest := value[colon+1:]
+
+	var uri *URI
+	var err error
+	switch scheme {
+	case SchemeSIP, SchemeSIPS:
+		uri, err = parseSIPURI(rest)
+	case SchemeTel:
+		uri, err = parseTelURI(rest)
+	default:
+		return nil, fmt.Errorf("unsupported URI scheme: %s", scheme)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s URI %s: %w", scheme, value, err)
+	}
+	uri.Scheme = scheme
+	return uri, nil
+}
+
+func parseSIPURI(value string) (*URI, error) {
+	uri := &URI{}
+
+	// The userinfo cannot contain an unescaped '@', so the first one ends it
+	if at := strings.Index(value, "@"); at >= 0 {
+		userinfo := value[:at]
+		value = value[at+1:]
+
+		user, password, hasPassword := strings.Cut(userinfo, ":")
+		if user == "" {
+			return nil, fmt.Errorf("empty user")
+		}
+		var err error
+		if uri.User, err = unescape(user); err != nil {
+			return nil, err
+		}
+		if hasPassword {
+			if uri.Password, err = unescape(password); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	hostport := value
+	var paramPart, headerPart string
+	if idx := strings.IndexAny(value, ";?"); idx >= 0 {
+		hostport = value[:idx]
+		paramPart = value[idx:]
+		if q := strings.Index(paramPart, "?"); q >= 0 {
+			headerPart = paramPart[q+1:]
+			paramPart = paramPart[:q]
+		}
+	}
+
+	host, port, err := splitHostPort(hostport)
+	if err != nil {
+		return nil, err
+	}
+	if strings.ContainsAny(host, " \t\"<>") || (strings.Contains(host, ":") && !strings.HasPrefix(hostport, "[")) {
+		return nil, fmt.Errorf("invalid host %s", hostport)
+	}
+	uri.Host = host
+	uri.Port = port
+
+	if uri.Params, err = parseURIParams(paramPart); err != nil {
+		return nil, err
+	}
+	if uri.Headers, err = parseURIHeaders(headerPart); err != nil {
+		return nil, err
+	}
+	return uri, nil
+}
+
+func parseTelURI(value string) (*URI, error) {
+	number, paramPart, _ := strings.Cut(value, ";")
+	number, err := unescape(number)
+	if err != nil {
+		return nil, err
+	}
+	if number == "" || number == "+" {
+		return nil, fmt.Errorf("empty telephone number")
+	}
+
+	uri := &URI{User: number}
+	if uri.Params, err = parseURIParams(paramPart); err != nil {
+		return nil, err
+	}
+
+	// A local number is only meaningful together with its phone-context
+	if !strings.HasPrefix(number, "+") && !uri.Params.Has("phone-context") {
+		return nil, fmt.Errorf("local number %s without phone-context", number)
+	}
+	return uri, nil
+}
+
+func parseURIParams(value string) (Params, error) {
+	value = strings.TrimPrefix(value, ";")
+	if value == "" {
+		return nil, nil
+	}
+
+	var params Params
+	for _, part := range strings.Split(value, ";") {
+		name, paramValue, hasValue := strings.Cut(part, "=")
+		if name == "" || (hasValue && paramValue == "") {
+			return nil, fmt.Errorf("invalid parameter %q", part)
+		}
+		name, err := unescape(name)
+		if err != nil {
+			return nil, err
+		}
+		if paramValue, err = unescape(paramValue); err != nil {
+			return nil, err
+		}
+		params = append(params, Param{Name: name, Value: paramValue})
+	}
+	return params, nil
+}
+
+func parseURIHeaders(value string) (Params, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var headers Params
+	for _, part := range strings.Split(value, "&") {
+		name, headerValue, _ := strings.Cut(part, "=")
+		if name == "" {
+			return nil, fmt.Errorf("invalid header %q", part)
+		}
+		name, err := unescape(name)
+		if err != nil {
+			return nil, err
+		}
+		if headerValue, err = unescape(headerValue); err != nil {
+			return nil, err
+		}
+		headers = append(headers, Param{Name: name, Value: headerValue})
+	}
+	return headers, nil
+}
+
+// unescape decodes %HH escapes
+func unescape(value string) (string, error) {
+	if !strings.Contains(value, "%") {
+		return value, nil
+	}
+
+	var builder strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '%' {
+			builder.WriteByte(value[i])
+			continue
+		}
+		if i+2 >= len(value) || !isHex(value[i+1]) || !isHex(value[i+2]) {
+			return "", fmt.Errorf("invalid escape sequence in %q", value)
+		}
+		builder.WriteByte(unhex(value[i+1])<<4 | unhex(value[i+2]))
+		i += 2
+	}
+	return builder.String(), nil
+}
+
+// escape encodes every byte that is not alphanumeric or in allowed as %HH
+func escape(value, allowed string) string {
+	const hexDigits = "0123456789ABCDEF"
+
+	var builder strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if isAlphanumeric(c) || strings.IndexByte(allowed, c) >= 0 {
+			builder.WriteByte(c)
+			continue
+		}
+		builder.WriteByte('%')
+		builder.WriteByte(hexDigits[c>>4])
+		builder.WriteByte(hexDigits[c&0x0f])
+	}
+	return builder.String()
+}
+
+func isAlphanumeric(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func unhex(c byte) byte {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0'
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
+// IsSecure returns true for sips: URIs
+func (u *URI) IsSecure() bool {
+	return u.Scheme == SchemeSIPS
+}
+
+// Transport returns the lower-cased transport parameter, or "" if absent
+func (u *URI) Transport() string {
+	transport, _ := u.Params.Get("transport")
+	return strings.ToLower(transport)
+}
+
+// HostPort returns the host and optional port in wire format
+func (u *URI) HostPort() string {
+	return joinHostPort(u.Host, u.Port)
+}
+
+// String returns the URI in wire format
+func (u *URI) String() string {
+	var builder strings.Builder
+	builder.WriteString(u.Scheme)
+	builder.WriteByte(':')
+
+	if u.Scheme == SchemeTel {
+		builder.WriteString(escape(u.User, telChars))
+		writeURIParams(&builder, u.Params)
+		return builder.String()
+	}
+
+	if u.User != "" {
+		builder.WriteString(escape(u.User, userUnreserved))
+		if u.Password != "" {
+			builder.WriteByte(':')
+			builder.WriteString(escape(u.Password, passwordChars))
+		}
+		builder.WriteByte('@')
+	}
+	builder.WriteString(u.HostPort())
+	writeURIParams(&builder, u.Params)
+
+	for i, header := range u.Headers {
+		if i == 0 {
+			builder.WriteByte('?')
+		} else {
+			builder.WriteByte('&')
+		}
+		builder.WriteString(escape(header.Name, headerChars))
+		builder.WriteByte('=')
+		builder.WriteString(escape(header.Value, headerChars))
+	}
+	return builder.String()
+}
+
+func writeURIParams(builder *strings.Builder, params Params) {
+	for _, param := range params {
+		builder.WriteByte(';')
+		builder.WriteString(escape(param.Name, paramUnreserved))
+		if param.Value != "" {
+			builder.WriteByte('=')
+			builder.WriteString(escape(param.Value, paramUnreserved))
+		}
+	}
+}
+
+// AOR returns the address-of-record form of the URI: scheme, user, host and
+// port only, with the scheme and host lower-cased. Two URIs that identify
+// the same registration produce the same AOR string.
+func (u *URI) AOR() string {
+	if u.Scheme == SchemeTel {
+		return SchemeTel + ":" + escape(normalizeTelNumber(u.User), telChars)
+	}
+
+	aor := &URI{
+		Scheme: strings.ToLower(u.Scheme),
+		User:   u.User,
+		Host:   strings.ToLower(u.Host),
+		Port:   u.Port,
+	}
+	return aor.String()
+}
+
+// normalizeTelNumber removes visual separators and lower-cases hex digits
+func normalizeTelNumber(number string) string {
+	var builder strings.Builder
+	for i := 0; i < len(number); i++ {
+		if strings.IndexByte(telVisualSeparators, number[i]) < 0 {
+			builder.WriteByte(number[i])
+		}
+	}
+	return strings.ToLower(builder.String())
+}
+
+// uriParamsRequiredToMatch are compared even when present in only one URI (RFC 3261 §19.1.4)
+var uriParamsRequiredToMatch = []string{"user", "ttl", "method", "maddr", "transport"}
+
+// Equal compares two URIs using the rules of RFC 3261 §19.1.4, or RFC 3966
+// §4 for tel URIs
+func (u *URI) Equal(other *URI) bool {
+	if u == nil || other == nil {
+		return u == other
+	}
+	if !strings.EqualFold(u.Scheme, other.Scheme) {
+		return false
+	}
+
+	if strings.EqualFold(u.Scheme, SchemeTel) {
+		return normalizeTelNumber(u.User) == normalizeTelNumber(other.User) &&
+			sameParams(u.Params, other.Params)
+	}
+
+	// User and password are case-sensitive; everything else is not
+	if u.User != other.User || u.Password != other.Password {
+		return false
+	}
+	if !strings.EqualFold(u.Host, other.Host) || u.Port != other.Port {
+		return false
+	}
+
+	for _, name := range uriParamsRequiredToMatch {
+		value, exists := u.Params.Get(name)
+		otherValue, otherExists := other.Params.Get(name)
+		if exists != otherExists || !strings.EqualFold(value, otherValue) {
+			return false
+		}
+	}
+
+	// Any other parameter only matters when both URIs carry it
+	for _, param := range u.Params {
+		if otherValue, exists := other.Params.Get(param.Name); exists && !strings.EqualFold(param.Value, otherValue) {
+			return false
+		}
+	}
+
+	// Headers must all be present in both and match
+	return sameParams(u.Headers, other.Headers)
+}
+
+// sameParams checks if two parameter lists hold the same names and values, ignoring order and case
+func sameParams(a, b Params) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, param := range a {
+		value, exists := b.Get(param.Name)
+		if !exists || !strings.EqualFold(param.Value, value) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,181 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestParseURI(t *testing.T) {
+	tests := []struct {
+		value    string
+		scheme   string
+		user     string
+		password string
+		host     string
+		port     int
+	}{
+		{"sip:alice@atlanta.com", "sip", "alice", "", "atlanta.com", 0},
+		{"sip:alice:secretword@atlanta.com;transport=tcp", "sip", "alice", "secretword", "atlanta.com", 0},
+		{"sips:alice@atlanta.com?subject=project%20x&priority=urgent", "sips", "alice", "", "atlanta.com", 0},
+		{"sip:+1-212-555-1212:1234@gateway.com;user=phone", "sip", "+1-212-555-1212", "1234", "gateway.com", 0},
+		{"sip:alice@192.0.2.4:5070", "sip", "alice", "", "192.0.2.4", 5070},
+		{"sip:atlanta.com;method=REGISTER?to=alice%40atlanta.com", "sip", "", "", "atlanta.com", 0},
+		{"sip:alice;day=tuesday@atlanta.com", "sip", "alice;day=tuesday", "", "atlanta.com", 0},
+		{"sip:user@[2001:db8::10]:5060", "sip", "user", "", "2001:db8::10", 5060},
+		{"SIP:%61lice@AtLanTa.CoM", "sip", "alice", "", "AtLanTa.CoM", 0},
+		{"<sip:bob@biloxi.com>", "sip", "bob", "", "biloxi.com", 0},
+		{"tel:+1-201-555-0123", "tel", "+1-201-555-0123", "", "", 0},
+		{"tel:7042;phone-context=example.com", "tel", "7042", "", "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			uri, err := ParseURI(tt.value)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if uri.Scheme != tt.scheme || uri.User != tt.user || uri.Password != tt.password ||
+				uri.Host != tt.host || uri.Port != tt.port {
+				t.Errorf("Unexpected URI: %+v", uri)
+			}
+		})
+	}
+}
+
+func TestParseURI_ParamsAndHeaders(t *testing.T) {
+	uri, err := ParseURI("sips:alice@atlanta.com;transport=TCP;lr?subject=project%20x&priority=urgent")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !uri.IsSecure() {
+		t.Error("Expected sips URI to be secure")
+	}
+	if uri.Transport() != "tcp" {
+		t.Errorf("Expected transport tcp, got %s", uri.Transport())
+	}
+	if !uri.Params.Has("lr") {
+		t.Error("Expected lr parameter")
+	}
+	if subject, _ := uri.Headers.Get("subject"); subject != "project x" {
+		t.Errorf("Expected unescaped subject header, got %q", subject)
+	}
+	if uri.String() != "sips:alice@atlanta.com;transport=TCP;lr?subject=project%20x&priority=urgent" {
+		t.Errorf("Unexpected string: %s", uri.String())
+	}
+}
+
+func TestParseURI_Errors(t *testing.T) {
+	invalid := []string{
+		"",
+		"alice@atlanta.com",
+		"http://example.com",
+		"sip:",
+		"sip:@atlanta.com",
+		"sip:alice@",
+		"sip:alice@atlanta.com:port",
+		"sip:alice@2001:db8::1",
+		"sip:alice@[2001:db8::1",
+		"sip:al%6ice@atlanta.com",
+		"sip:alice@atlanta.com;transport=",
+		"tel:",
+		"tel:7042",
+	}
+	for _, value := range invalid {
+		if _, err := ParseURI(value); err == nil {
+			t.Errorf("Expected error for %q", value)
+		}
+	}
+}
+
+func TestURI_String(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"sip:alice@atlanta.com", "sip:alice@atlanta.com"},
+		{"sip:%61lice@atlanta.com", "sip:alice@atlanta.com"},
+		{"sip:a%20b@atlanta.com", "sip:a%20b@atlanta.com"},
+		{"sip:alice;day=tuesday@atlanta.com", "sip:alice;day=tuesday@atlanta.com"},
+		{"sip:user@[2001:db8::10]:5060;maddr=239.255.255.1", "sip:user@[2001:db8::10]:5060;maddr=239.255.255.1"},
+		{"sip:atlanta.com;method=REGISTER?to=alice%40atlanta.com", "sip:atlanta.com;method=REGISTER?to=alice%40atlanta.com"},
+		{"tel:+1-201-555-0123;ext=1234", "tel:+1-201-555-0123;ext=1234"},
+	}
+
+	for _, tt := range tests {
+		uri, err := ParseURI(tt.value)
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", tt.value, err)
+			continue
+		}
+		if uri.String() != tt.expected {
+			t.Errorf("For %q expected %q, got %q", tt.value, tt.expected, uri.String())
+		}
+	}
+}
+
+func TestURI_Equal(t *testing.T) {
+	// Examples from RFC 3261 §19.1.4 and RFC 3966 §4
+	equal := [][2]string{
+		{"sip:%61lice@atlanta.com;transport=TCP", "sip:alice@AtLanTa.CoM;Transport=tcp"},
+		{"sip:carol@chicago.com", "sip:carol@chicago.com;newparam=5"},
+		{"sip:carol@chicago.com", "sip:carol@chicago.com;security=on"},
+		{"sip:carol@chicago.com;newparam=5", "sip:carol@chicago.com;security=on"},
+		{"sip:biloxi.com;transport=tcp;method=REGISTER?to=sip:bob%40biloxi.com", "sip:biloxi.com;method=REGISTER;transport=tcp?to=sip:bob%40biloxi.com"},
+		{"sip:alice@atlanta.com?subject=project%20x&priority=urgent", "sip:alice@atlanta.com?priority=urgent&subject=project%20x"},
+		{"tel:+1-201-555-0123", "tel:+1.201.555.0123"},
+		{"tel:7042;phone-context=example.com", "tel:7042;PHONE-CONTEXT=Example.com"},
+	}
+	for _, pair := range equal {
+		if !mustParseURI(t, pair[0]).Equal(mustParseURI(t, pair[1])) {
+			t.Errorf("Expected %s to equal %s", pair[0], pair[1])
+		}
+	}
+
+	notEqual := [][2]string{
+		{"SIP:ALICE@AtLanTa.CoM;Transport=udp", "sip:alice@AtLanTa.CoM;Transport=UDP"},
+		{"sip:bob@biloxi.com", "sip:bob@biloxi.com:5060"},
+		{"sip:bob@biloxi.com", "sip:bob@biloxi.com;transport=udp"},
+		{"sip:bob@biloxi.com", "sip:bob@biloxi.com:6000;transport=tcp"},
+		{"sip:carol@chicago.com", "sip:carol@chicago.com?Subject=next%20meeting"},
+		{"sip:bob@phone21.boxesbybob.com", "sip:bob@192.0.2.4"},
+		{"sip:alice@atlanta.com", "sips:alice@atlanta.com"},
+		{"sip:alice:a@atlanta.com", "sip:alice:b@atlanta.com"},
+		{"sip:carol@chicago.com;security=on", "sip:carol@chicago.com;security=off"},
+		{"tel:+1-201-555-0123", "tel:+1-201-555-0124"},
+		{"tel:+1-201-555-0123", "tel:+1-201-555-0123;ext=1"},
+	}
+	for _, pair := range notEqual {
+		if mustParseURI(t, pair[0]).Equal(mustParseURI(t, pair[1])) {
+			t.Errorf("Expected %s not to equal %s", pair[0], pair[1])
+		}
+	}
+}
+
+func TestURI_AOR(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"sip:alice@example.com", "sip:alice@example.com"},
+		{"<sip:alice@Example.COM;transport=tcp>", "sip:alice@example.com"},
+		{"SIP:%61lice:secret@example.com?subject=hi", "sip:alice@example.com"},
+		{"sips:alice@example.com:5061;lr", "sips:alice@example.com:5061"},
+		{"sip:user@[2001:DB8::10]", "sip:user@[2001:db8::10]"},
+		{"tel:+1-201-555-0123;ext=1", "tel:+12015550123"},
+	}
+
+	for _, tt := range tests {
+		if aor := mustParseURI(t, tt.value).AOR(); aor != tt.expected {
+			t.Errorf("For %q expected AOR %q, got %q", tt.value, tt.expected, aor)
+		}
+	}
+}
+
+func mustParseURI(t *testing.T, value string) *URI {
+	t.Helper()
+	uri, err := ParseURI(value)
+	if err != nil {
+		t.Fatalf("Failed to parse %q: %v", value, err)
+	}
+	return uri
+}
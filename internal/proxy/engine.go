@@ -1,6 +1,8 @@
 package proxy
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
 	"strconv"
@@ -11,6 +13,7 @@ import (
 	"github.com/zurustar/xylitol2/internal/huntgroup"
 	"github.com/zurustar/xylitol2/internal/parser"
 	"github.com/zurustar/xylitol2/internal/registrar"
+	"github.com/zurustar/xylitol2/internal/resolver"
 	"github.com/zurustar/xylitol2/internal/transaction"
 	"github.com/zurustar/xylitol2/internal/transport"
 )
@@ -25,7 +28,18 @@ type RequestForwardingEngine struct {
 	huntGroupEngine   huntgroup.HuntGroupEngine
 	serverHost        string
 	serverPort        int
+	tlsPort           int
 	maxForwards       int
+	resolver          resolver.SIPResolver
+}
+
+// resolveTimeout bounds the DNS lookups made to locate a target
+const resolveTimeout = 5 * time.Second
+
+// destination is a transport address a request can be sent to
+type destination struct {
+	addr      net.Addr
+	transport string
 }
 
 // NewRequestForwardingEngine creates a new request forwarding engine
@@ -48,10 +62,23 @@ func NewRequestForwardingEngine(
 		huntGroupEngine:    huntGroupEngine,
 		serverHost:         serverHost,
 		serverPort:         serverPort,
+		tlsPort:            transport.DefaultTLSPort,
 		maxForwards:        70, // RFC3261 default
+		resolver:           resolver.NewResolver(resolver.SystemDNS()),
 	}
 }
 
+// SetTLSPort sets the port advertised in Via headers of requests forwarded over TLS
+func (e *RequestForwardingEngine) SetTLSPort(port int) {
+	e.tlsPort = port
+}
+
+// SetResolver sets the resolver that locates targets which are not reached over
+// an existing flow
+func (e *RequestForwardingEngine) SetResolver(r resolver.SIPResolver) {
+	e.resolver = r
+}
+
 // ProcessRequest processes an incoming SIP request for proxy forwarding
 func (e *RequestForwardingEngine) ProcessRequest(req *parser.SIPMessage, transaction transaction.Transaction) error {
 	if req == nil || !req.IsRequest() {
@@ -92,6 +119,21 @@ func (e *RequestForwardingEngine) processProxyableRequest(req *parser.SIPMessage
 		return e.sendBadRequest(req, transaction, "Missing Request-URI")
 	}
 
+	// Requests on a route set go to its next hop without being retargeted
+	if uri, routed := e.nextHop(req); routed {
+		destinations, err := e.resolveURI(uri)
+		if err != nil {
+			return e.sendNotFound(req, transaction, "Next hop not found")
+		}
+		if err := e.forward(req, requestURI, destinations); err != nil {
+			if errors.Is(err, transport.ErrMessageTooLarge) {
+				return e.sendMessageTooLarge(req, transaction)
+			}
+			return err
+		}
+		return nil
+	}
+
 	// Resolve target using registrar database or hunt groups
 	targets, err := e.resolveTarget(requestURI)
 	if err != nil {
@@ -107,7 +149,13 @@ func (e *RequestForwardingEngine) processProxyableRequest(req *parser.SIPMessage
 	}
 
 	// Forward request to targets
-	return e.ForwardRequest(req, targets)
+	if err := e.ForwardRequest(req, targets); err != nil {
+		if errors.Is(err, transport.ErrMessageTooLarge) {
+			return e.sendMessageTooLarge(req, transaction)
+		}
+		return err
+	}
+	return nil
 }
 
 // processOptionsRequest processes OPTIONS requests
@@ -133,32 +181,63 @@ func (e *RequestForwardingEngine) ForwardRequest(req *parser.SIPMessage, targets
 	// TODO: Implement parallel forking in task 10.2
 	target := targets[0]
 
-	// Create a copy of the request for forwarding
-	forwardedReq := req.Clone()
-
-	// Add Via header for this proxy
-	viaHeader := e.createViaHeader(req.Transport)
-	e.addViaHeader(forwardedReq, viaHeader)
-
-	// Update Request-URI to target contact
-	if reqLine, ok := forwardedReq.StartLine.(*parser.RequestLine); ok {
-		reqLine.RequestURI = target.URI
-	}
-
-	// Parse target address
-	targetAddr, transport, err := e.parseTargetURI(target.URI)
+	// Locate the target; the destinations are tried in turn until one accepts the request
+	destinations, err := e.resolveDestinations(target)
 	if err != nil {
 		return fmt.Errorf("failed to parse target URI %s: %w", target.URI, err)
 	}
+	return e.forward(req, target.URI, destinations)
+}
 
-	// Serialize the message
-	data, err := e.parser.Serialize(forwardedReq)
-	if err != nil {
-		return fmt.Errorf("failed to serialize forwarded request: %w", err)
+// forward sends req with requestURI as its Request-URI to the first of
+// destinations that accepts it
+func (e *RequestForwardingEngine) forward(req *parser.SIPMessage, requestURI string, destinations []destination) error {
+	err := fmt.Errorf("no destinations for %s", requestURI)
+	for _, dest := range destinations {
+		// Create a copy of the request for forwarding
+		forwardedReq := req.Clone()
+
+		// Record where the request came from so that responses find their way back
+		e.addReceivedParams(forwardedReq)
+
+		// Add Via header for this proxy, naming the transport the request leaves on
+		viaHeader := e.createViaHeader(dest.transport, dest.addr)
+		e.addViaHeader(forwardedReq, viaHeader)
+		e.removeOwnRoutes(forwardedReq)
+		e.addRecordRoute(forwardedReq, dest)
+
+		// Update Request-URI to target contact
+		if reqLine, ok := forwardedReq.StartLine.(*parser.RequestLine); ok {
+			reqLine.RequestURI = requestURI
+		}
+
+		// Serialize the message
+		data, serializeErr := e.parser.Serialize(forwardedReq)
+		if serializeErr != nil {
+			return fmt.Errorf("failed to serialize forwarded request: %w", serializeErr)
+		}
+
+		// Send the request
+		if err = e.transportManager.SendMessage(data, dest.transport, dest.addr); err == nil {
+			e.forwardCancel(req, forwardedReq, dest)
+			return nil
+		}
 	}
+	return err
+}
 
-	// Send the request
-	return e.transportManager.SendMessage(data, transport, targetAddr)
+// forwardCancel arranges for a CANCEL of the INVITE req to be sent on to dest,
+// where forwardedReq went, once the transaction manager matches it
+func (e *RequestForwardingEngine) forwardCancel(req, forwardedReq *parser.SIPMessage, dest destination) {
+	cancelManager, ok := e.transactionManager.(transaction.CancelManager)
+	if !ok || req.GetMethod() != parser.MethodINVITE {
+		return
+	}
+	cancelManager.OnCancel(req, func(*parser.SIPMessage) {
+		if data, err := e.parser.Serialize(transaction.NewCancel(forwardedReq)); err == nil {
+			e.transportManager.SendMessage(data, dest.transport, dest.addr)
+		}
+	})
 }
 
 // ProcessResponse processes an incoming SIP response for proxy routing
@@ -227,123 +306,318 @@ func (e *RequestForwardingEngine) resolveTarget(requestURI string) ([]*database.
 		return nil, fmt.Errorf("failed to find contacts for AOR %s: %w", aor, err)
 	}
 
+	// A sips: Request-URI must only reach contacts over TLS end to end (RFC 3261 section 19.1.4)
+	secure := isSecureURI(requestURI)
+
 	// Filter out expired contacts
 	var validContacts []*database.RegistrarContact
 	now := time.Now().UTC()
 	for _, contact := range contacts {
-		if contact.Expires.After(now) {
+		if contact.Expires.After(now) && (!secure || isSecureURI(contact.URI)) {
 			validContacts = append(validContacts, contact)
 		}
 	}
 
-	return validContacts, nil
+	return selectOutboundFlows(validContacts), nil
+}
+
+// selectOutboundFlows keeps a single flow for each SIP Outbound instance, so a
+// UA registered over several flows is not rung once per flow (RFC 5626 section
+// 5.3). The most recently refreshed flow wins; dead flows are removed by the
+// registrar when the transport reports them.
+func selectOutboundFlows(contacts []*database.RegistrarContact) []*database.RegistrarContact {
+	chosen := make(map[string]int)
+	var selected []*database.RegistrarContact
+	for _, contact := range contacts {
+		if !registrar.IsOutbound(contact) {
+			selected = append(selected, contact)
+			continue
+		}
+		key := contact.AOR + " " + contact.InstanceID
+		if i, exists := chosen[key]; exists {
+			if contact.Expires.After(selected[i].Expires) {
+				selected[i] = contact
+			}
+			continue
+		}
+		chosen[key] = len(selected)
+		selected = append(selected, contact)
+	}
+	return selected
 }
 
 // extractAOR extracts the Address of Record from a URI
 func (e *RequestForwardingEngine) extractAOR(uri string) (string, error) {
-	// Simple AOR extraction - remove parameters and headers
-	uri = strings.TrimSpace(uri)
-	
-	// Remove angle brackets if present
-	if strings.HasPrefix(uri, "<") && strings.HasSuffix(uri, ">") {
-		uri = uri[1 : len(uri)-1]
+	parsed, err := parser.ParseURI(uri)
+	if err != nil {
+		return "", err
 	}
 	
-	// Remove parameters (everything after ';')
-	if idx := strings.Index(uri, ";"); idx >= 0 {
-		uri = uri[:idx]
+	return parsed.AOR(), nil
+}
+
+// parseTargetURI parses a target URI and returns the address and transport of
+// the first destination it resolves to
+func (e *RequestForwardingEngine) parseTargetURI(uri string) (net.Addr, string, error) {
+	destinations, err := e.resolveURI(uri)
+	if err != nil {
+		return nil, "", err
 	}
-	
-	// Remove headers (everything after '?')
-	if idx := strings.Index(uri, "?"); idx >= 0 {
-		uri = uri[:idx]
+	return destinations[0].addr, destinations[0].transport, nil
+}
+
+// resolveURI locates the servers for uri as described in RFC 3263 and returns
+// them in the order they should be tried
+func (e *RequestForwardingEngine) resolveURI(uri string) ([]destination, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+
+	targets, err := e.resolver.Resolve(ctx, uri)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve address: %w", err)
+	}
+	destinations := make([]destination, len(targets))
+	for i, target := range targets {
+		addr := target.Addr()
+		if target.Transport == "tls" {
+			// The server certificate is checked against the name the address was found by
+			addr = &transport.NamedAddr{TCPAddr: addr.(*net.TCPAddr), Name: target.Host}
+		}
+		destinations[i] = destination{addr: addr, transport: target.Transport}
 	}
-	
-	return uri, nil
+	return destinations, nil
 }
 
-// parseTargetURI parses a target URI and returns address and transport
-func (e *RequestForwardingEngine) parseTargetURI(uri string) (net.Addr, string, error) {
-	// Simple URI parsing for sip: and sips: URIs
-	uri = strings.TrimSpace(uri)
-	
-	// Remove angle brackets if present
-	if strings.HasPrefix(uri, "<") && strings.HasSuffix(uri, ">") {
-		uri = uri[1 : len(uri)-1]
+// resolveContact returns the address and transport for reaching a registered contact.
+// Contacts registered from behind NAT are reached at the flow their registration was
+// observed on, as are WebSocket clients, which cannot accept connections (RFC 7118
+// section 5.3), and SIP Outbound bindings, which exist only as their flow (RFC 5626).
+// Other contacts registered over TCP or TLS are reached over the connection they
+// registered on while it is open, and by connecting to the Contact once it has
+// closed (RFC 5923). The Request-URI keeps the registered Contact either way.
+func (e *RequestForwardingEngine) resolveContact(contact *database.RegistrarContact) (net.Addr, string, error) {
+	destinations, err := e.resolveDestinations(contact)
+	if err != nil {
+		return nil, "", err
 	}
-	
-	// Check scheme
-	var transport string
-	if strings.HasPrefix(uri, "sip:") {
-		transport = "udp" // Default for sip:
-		uri = uri[4:]
-	} else if strings.HasPrefix(uri, "sips:") {
-		transport = "tcp" // Default for sips: (should be TLS, but using TCP for now)
-		uri = uri[5:]
-	} else {
-		return nil, "", fmt.Errorf("unsupported URI scheme")
+	return destinations[0].addr, destinations[0].transport, nil
+}
+
+// resolveDestinations returns the destinations for reaching a registered contact
+// in the order they should be tried. A contact reached over its flow has just the
+// one; others have every server their URI resolves to (RFC 3263).
+func (e *RequestForwardingEngine) resolveDestinations(contact *database.RegistrarContact) ([]destination, error) {
+	parsed, err := parser.ParseURI(contact.URI)
+	webSocket := err == nil && isWebSocketTransport(parsed.Transport())
+	if !webSocket && !contact.BehindNAT && !registrar.IsOutbound(contact) && !e.hasOpenFlow(contact) {
+		return e.resolveURI(contact.URI)
 	}
-	
-	// Extract host and port
-	var host string
-	var port int = 5060 // Default SIP port
-	
-	// Remove user part if present
-	if idx := strings.Index(uri, "@"); idx >= 0 {
-		uri = uri[idx+1:]
+
+	if contact.Source == "" {
+		return nil, fmt.Errorf("no flow known for contact %s", contact.URI)
 	}
-	
-	// Remove parameters and headers
-	if idx := strings.Index(uri, ";"); idx >= 0 {
-		// Check for transport parameter
-		params := uri[idx+1:]
-		uri = uri[:idx]
-		
-		// Parse transport parameter
-		paramPairs := strings.Split(params, ";")
-		for _, param := range paramPairs {
-			if strings.HasPrefix(param, "transport=") {
-				transport = param[10:]
-				break
-			}
+
+	transport := strings.ToLower(contact.Transport)
+	if webSocket {
+		transport = "ws"
+		if parsed.IsSecure() {
+			transport = "wss"
 		}
 	}
-	
-	if idx := strings.Index(uri, "?"); idx >= 0 {
-		uri = uri[:idx]
+	addr, err := resolveAddr(transport, contact.Source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve flow %s for contact %s: %w", contact.Source, contact.URI, err)
 	}
-	
-	// Parse host:port
-	if strings.Contains(uri, ":") {
-		parts := strings.Split(uri, ":")
-		if len(parts) != 2 {
-			return nil, "", fmt.Errorf("invalid host:port format")
-		}
-		host = parts[0]
-		var err error
-		port, err = strconv.Atoi(parts[1])
-		if err != nil {
-			return nil, "", fmt.Errorf("invalid port number: %w", err)
-		}
-	} else {
-		host = uri
+	return []destination{{addr: addr, transport: transport}}, nil
+}
+
+// hasOpenFlow reports whether the connection a contact registered over is still open
+func (e *RequestForwardingEngine) hasOpenFlow(contact *database.RegistrarContact) bool {
+	transport := strings.ToLower(contact.Transport)
+	if contact.Source == "" || (transport != "tcp" && transport != "tls") || e.transportManager == nil {
+		return false
 	}
-	
-	// Resolve address
-	addr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", host, port))
+	addr, err := resolveAddr(transport, contact.Source)
+	return err == nil && e.transportManager.HasConnection(transport, addr)
+}
+
+// addReceivedParams records where a request came from in its top Via so that
+// responses find their way back through NAT (RFC 3581). WebSocket clients put an
+// unresolvable .invalid host in their Via (RFC 7118 section 5.2), so they always
+// get rport as responses can only be routed by source address.
+func (e *RequestForwardingEngine) addReceivedParams(req *parser.SIPMessage) {
+	if req.Source == nil {
+		return
+	}
+	host, portStr, err := net.SplitHostPort(req.Source.String())
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to resolve address: %w", err)
+		return
 	}
-	
-	return addr, transport, nil
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return
+	}
+
+	vias, err := req.GetVias()
+	if err != nil || len(vias) == 0 {
+		return
+	}
+	if isWebSocketTransport(req.Transport) && !vias[0].Params.Has("rport") {
+		vias[0].Params.Set("rport", "")
+	}
+	vias[0].SetReceived(host, port)
+	req.SetVias(vias)
+}
+
+// isWebSocketTransport reports whether transport is WS or WSS, ignoring case
+func isWebSocketTransport(transport string) bool {
+	return strings.EqualFold(transport, "ws") || strings.EqualFold(transport, "wss")
+}
+
+// isSecureURI reports whether uri is a sips: URI
+func isSecureURI(uri string) bool {
+	parsed, err := parser.ParseURI(uri)
+	return err == nil && parsed.IsSecure()
 }
 
-// createViaHeader creates a Via header for this proxy
-func (e *RequestForwardingEngine) createViaHeader(transport string) string {
+// defaultPort returns the port used when a URI or Via header does not specify one
+func defaultPort(protocol string) int {
+	if protocol == "tls" {
+		return transport.DefaultTLSPort
+	}
+	return 5060 // Default SIP port
+}
+
+// resolveAddr resolves address for sending over protocol; stream transports use TCP addresses
+func resolveAddr(protocol, address string) (net.Addr, error) {
+	if protocol == "tcp" || protocol == "tls" || isWebSocketTransport(protocol) {
+		return net.ResolveTCPAddr("tcp", address)
+	}
+	return net.ResolveUDPAddr("udp", address)
+}
+
+// createViaHeader creates a Via header for this proxy naming the address it is
+// known by on the listener the request leaves from to reach addr
+func (e *RequestForwardingEngine) createViaHeader(transport string, addr net.Addr) string {
 	// Generate a unique branch parameter
 	branch := e.generateBranch()
-	return fmt.Sprintf("SIP/2.0/%s %s:%d;branch=%s", 
-		strings.ToUpper(transport), e.serverHost, e.serverPort, branch)
+	host, port := e.advertisedAddress(transport, addr)
+	return fmt.Sprintf("SIP/2.0/%s %s;branch=%s", 
+		strings.ToUpper(transport), parser.JoinHostPort(host, port), branch)
+}
+
+// advertisedAddress returns the host and port this proxy is known by on the
+// listener a message to addr over transport leaves from
+func (e *RequestForwardingEngine) advertisedAddress(transport string, addr net.Addr) (string, int) {
+	host, port := e.transportManager.AdvertisedAddress(transport, addr)
+	if host == "" {
+		host = e.serverHost
+	}
+	if port == 0 {
+		port = e.serverPort
+		if strings.EqualFold(transport, "tls") {
+			port = e.tlsPort
+		}
+	}
+	return host, port
+}
+
+// addRecordRoute puts this proxy in the route set of the dialog an initial
+// INVITE creates, so that requests within the dialog pass through it too (RFC
+// 3261 section 16.6 step 4). The entry names the address this proxy is known by
+// on the listener the request leaves from. A request that arrived on a listener
+// known by another address, as across a NAT or between interfaces, gets a
+// second entry naming that one for the caller's side (RFC 5658).
+func (e *RequestForwardingEngine) addRecordRoute(req *parser.SIPMessage, dest destination) {
+	if req.GetMethod() != parser.MethodINVITE || hasToTag(req) {
+		return
+	}
+
+	entries := []string{e.recordRouteEntry(dest.transport, dest.addr)}
+	if req.Source != nil && req.Transport != "" {
+		if inbound := e.recordRouteEntry(req.Transport, req.Source); inbound != entries[0] {
+			entries = append(entries, inbound)
+		}
+	}
+
+	// Record-Route entries are added on top, like Via headers
+	existing := req.GetHeaders(parser.HeaderRecordRoute)
+	req.RemoveHeader(parser.HeaderRecordRoute)
+	for _, entry := range append(entries, existing...) {
+		req.AddHeader(parser.HeaderRecordRoute, entry)
+	}
+}
+
+// recordRouteEntry returns a Record-Route header value naming this proxy's
+// address for messages to addr over transport
+func (e *RequestForwardingEngine) recordRouteEntry(transport string, addr net.Addr) string {
+	host, port := e.advertisedAddress(transport, addr)
+	uri := &parser.URI{Scheme: parser.SchemeSIP, Host: strings.Trim(host, "[]"), Port: port}
+	if transport = strings.ToLower(transport); transport != "" && transport != "udp" {
+		uri.Params.Set("transport", transport)
+	}
+	uri.Params.Set("lr", "")
+	return "<" + uri.String() + ">"
+}
+
+// nextHop returns the URI a request on a route set is sent to without looking
+// up registrations: the first Route entry that does not name this proxy, or the
+// Request-URI of a request within a dialog this proxy record-routed (RFC 3261
+// sections 16.4 and 16.6)
+func (e *RequestForwardingEngine) nextHop(req *parser.SIPMessage) (string, bool) {
+	routes, err := req.GetRoutes()
+	if err != nil || len(routes) == 0 {
+		return "", false
+	}
+	for _, route := range routes {
+		if !e.isOwnRoute(route) {
+			return route.URI, true
+		}
+	}
+	return req.GetRequestURI(), hasToTag(req)
+}
+
+// removeOwnRoutes removes the Route entries naming this proxy from the top of
+// the route set of req; a double Record-Route leaves two of them
+func (e *RequestForwardingEngine) removeOwnRoutes(req *parser.SIPMessage) {
+	routes, err := req.GetRoutes()
+	if err != nil {
+		return
+	}
+	own := 0
+	for own < len(routes) && e.isOwnRoute(routes[own]) {
+		own++
+	}
+	if own > 0 {
+		req.SetRoutes(routes[own:])
+	}
+}
+
+// isOwnRoute reports whether a Route entry names this proxy, by the address a
+// listener advertises or by the server host
+func (e *RequestForwardingEngine) isOwnRoute(route *parser.NameAddr) bool {
+	uri, err := parser.ParseURI(route.URI)
+	if err != nil {
+		return false
+	}
+	if matcher, ok := e.transportManager.(transport.AddressMatcher); ok {
+		port := uri.Port
+		if port == 0 && uri.IsSecure() {
+			port = defaultPort("tls")
+		} else if port == 0 {
+			port = defaultPort(uri.Transport())
+		}
+		if matcher.IsAdvertisedAddress(uri.Host, port) {
+			return true
+		}
+	}
+	return e.isLocalURI(route.URI)
+}
+
+// hasToTag reports whether req is sent within a dialog
+func hasToTag(req *parser.SIPMessage) bool {
+	to, err := req.GetTo()
+	return err == nil && to.Params.Has("tag")
 }
 
 // addViaHeader adds a Via header to the top of the Via header list
@@ -365,52 +639,20 @@ func (e *RequestForwardingEngine) addViaHeader(msg *parser.SIPMessage, viaHeader
 
 // parseViaHeader parses a Via header and returns routing information
 func (e *RequestForwardingEngine) parseViaHeader(viaHeader string) (net.Addr, string, error) {
-	// Parse Via header format: SIP/2.0/transport host:port;parameters
-	parts := strings.Fields(viaHeader)
-	if len(parts) < 2 {
-		return nil, "", fmt.Errorf("invalid Via header format")
-	}
-	
-	// Parse protocol/version/transport
-	protocolPart := parts[0] // SIP/2.0/UDP or SIP/2.0/TCP
-	protocolParts := strings.Split(protocolPart, "/")
-	if len(protocolParts) != 3 {
-		return nil, "", fmt.Errorf("invalid protocol part in Via header")
+	via, err := parser.ParseVia(viaHeader)
+	if err != nil {
+		return nil, "", err
 	}
-	transport := strings.ToLower(protocolParts[2])
+	transport := strings.ToLower(via.Transport)
 	
-	// Parse host:port part (may include parameters)
-	hostPart := parts[1]
-	if idx := strings.Index(hostPart, ";"); idx >= 0 {
-		hostPart = hostPart[:idx]
-	}
-	
-	// Default port
-	port := 5060
-	host := hostPart
-	
-	// Parse host:port
-	if strings.Contains(hostPart, ":") {
-		hostPortParts := strings.Split(hostPart, ":")
-		if len(hostPortParts) == 2 {
-			host = hostPortParts[0]
-			var err error
-			port, err = strconv.Atoi(hostPortParts[1])
-			if err != nil {
-				return nil, "", fmt.Errorf("invalid port in Via header: %w", err)
-			}
-		}
+	// Prefer the address the request was actually received from (RFC 3261 section 18.2.2, RFC 3581)
+	host, port := via.ResponseAddress()
+	if port == 0 {
+		port = defaultPort(transport)
 	}
 	
 	// Resolve address based on transport
-	var addr net.Addr
-	var err error
-	if transport == "tcp" {
-		addr, err = net.ResolveTCPAddr("tcp", fmt.Sprintf("%s:%d", host, port))
-	} else {
-		addr, err = net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", host, port))
-	}
-	
+	addr, err := resolveAddr(transport, net.JoinHostPort(host, strconv.Itoa(port)))
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to resolve address from Via header: %w", err)
 	}
@@ -458,7 +700,10 @@ func (e *RequestForwardingEngine) decrementMaxForwards(req *parser.SIPMessage) {
 
 // isLocalURI checks if a URI is for this server
 func (e *RequestForwardingEngine) isLocalURI(uri string) bool {
-	// Simple check - see if the URI contains this server's host
+	// Compare hosts so that IPv6 literals match with or without brackets
+	if parsed, err := parser.ParseURI(uri); err == nil {
+		return strings.EqualFold(parsed.Host, strings.Trim(e.serverHost, "[]"))
+	}
 	return strings.Contains(uri, e.serverHost)
 }
 
@@ -482,6 +727,12 @@ func (e *RequestForwardingEngine) sendTooManyHops(req *parser.SIPMessage, transa
 	return transaction.SendResponse(response)
 }
 
+func (e *RequestForwardingEngine) sendMessageTooLarge(req *parser.SIPMessage, transaction transaction.Transaction) error {
+	response := parser.NewResponseMessage(parser.StatusMessageTooLarge, "Message Too Large")
+	e.copyRequiredHeaders(req, response)
+	return transaction.SendResponse(response)
+}
+
 func (e *RequestForwardingEngine) sendMethodNotAllowed(req *parser.SIPMessage, transaction transaction.Transaction) error {
 	response := parser.NewResponseMessage(parser.StatusMethodNotAllowed, "Method Not Allowed")
 	e.copyRequiredHeaders(req, response)
@@ -43,6 +43,8 @@ func (m *mockRegistrar) FindContacts(aor string) ([]*database.RegistrarContact,
 
 func (m *mockRegistrar) CleanupExpired() {}
 
+func (m *mockRegistrar) FlowFailed(transport string, addr net.Addr) {}
+
 func (m *mockRegistrar) addContact(aor, uri string) {
 	contact := &database.RegistrarContact{
 		AOR:     aor,
@@ -72,6 +74,10 @@ func newMockTransportManager() *mockTransportManager {
 
 func (m *mockTransportManager) StartUDP(port int) error { return nil }
 func (m *mockTransportManager) StartTCP(port int) error { return nil }
+func (m *mockTransportManager) StartTLS(port int, config transport.TLSConfig) error { return nil }
+func (m *mockTransportManager) ReloadTLSCertificates() error { return nil }
+func (m *mockTransportManager) StartWebSocket(port int) error { return nil }
+func (m *mockTransportManager) StartSecureWebSocket(port int, config transport.TLSConfig) error { return nil }
 func (m *mockTransportManager) RegisterHandler(handler transport.MessageHandler) {}
 func (m *mockTransportManager) Stop() error { return nil }
 
@@ -84,6 +90,20 @@ func (m *mockTransportManager) SendMessage(msg []byte, transport string, addr ne
 	return nil
 }
 
+func (m *mockTransportManager) HasConnection(transport string, addr net.Addr) bool {
+	return false
+}
+
+func (m *mockTransportManager) RegisterFlowHandler(handler transport.FlowHandler) {}
+
+func (m *mockTransportManager) SetProxyProtocol(enabled bool) {}
+
+func (m *mockTransportManager) StartListener(listener transport.Listener) error { return nil }
+
+func (m *mockTransportManager) AdvertisedAddress(transport string, addr net.Addr) (string, int) {
+	return "", 0
+}
+
 func (m *mockTransportManager) getLastSentMessage() *sentMessage {
 	if len(m.sentMessages) == 0 {
 		return nil
@@ -186,7 +206,7 @@ func TestNewRequestForwardingEngine(t *testing.T) {
 	mockTxnMgr := &mockTransactionManager{}
 	mockParser := &mockParser{}
 
-	engine := NewRequestForwardingEngine(mockReg, mockTM, mockTxnMgr, mockParser, "proxy.example.com", 5060)
+	engine := NewRequestForwardingEngine(mockReg, mockTM, mockTxnMgr, mockParser, nil, nil, "proxy.example.com", 5060)
 
 	if engine == nil {
 		t.Fatal("Expected non-nil engine")
@@ -212,7 +232,7 @@ func TestProcessRequest_INVITE(t *testing.T) {
 	mockParser := &mockParser{}
 	mockTxn := &mockTransaction{}
 
-	engine := NewRequestForwardingEngine(mockReg, mockTM, mockTxnMgr, mockParser, "proxy.example.com", 5060)
+	engine := NewRequestForwardingEngine(mockReg, mockTM, mockTxnMgr, mockParser, nil, nil, "proxy.example.com", 5060)
 
 	// Add a registered contact
 	mockReg.addContact("sip:alice@example.com", "sip:alice@127.0.0.1:5060")
@@ -242,7 +262,7 @@ func TestProcessRequest_UserNotRegistered(t *testing.T) {
 	mockParser := &mockParser{}
 	mockTxn := &mockTransaction{}
 
-	engine := NewRequestForwardingEngine(mockReg, mockTM, mockTxnMgr, mockParser, "proxy.example.com", 5060)
+	engine := NewRequestForwardingEngine(mockReg, mockTM, mockTxnMgr, mockParser, nil, nil, "proxy.example.com", 5060)
 
 	req := createTestInviteRequest()
 
@@ -269,7 +289,7 @@ func TestProcessRequest_MaxForwardsExceeded(t *testing.T) {
 	mockParser := &mockParser{}
 	mockTxn := &mockTransaction{}
 
-	engine := NewRequestForwardingEngine(mockReg, mockTM, mockTxnMgr, mockParser, "proxy.example.com", 5060)
+	engine := NewRequestForwardingEngine(mockReg, mockTM, mockTxnMgr, mockParser, nil, nil, "proxy.example.com", 5060)
 
 	req := createTestInviteRequest()
 	req.SetHeader(parser.HeaderMaxForwards, "0")
@@ -297,7 +317,7 @@ func TestProcessRequest_REGISTER(t *testing.T) {
 	mockParser := &mockParser{}
 	mockTxn := &mockTransaction{}
 
-	engine := NewRequestForwardingEngine(mockReg, mockTM, mockTxnMgr, mockParser, "proxy.example.com", 5060)
+	engine := NewRequestForwardingEngine(mockReg, mockTM, mockTxnMgr, mockParser, nil, nil, "proxy.example.com", 5060)
 
 	req := parser.NewRequestMessage(parser.MethodREGISTER, "sip:alice@example.com")
 
@@ -318,7 +338,7 @@ func TestProcessRequest_UnsupportedMethod(t *testing.T) {
 	mockParser := &mockParser{}
 	mockTxn := &mockTransaction{}
 
-	engine := NewRequestForwardingEngine(mockReg, mockTM, mockTxnMgr, mockParser, "proxy.example.com", 5060)
+	engine := NewRequestForwardingEngine(mockReg, mockTM, mockTxnMgr, mockParser, nil, nil, "proxy.example.com", 5060)
 
 	req := parser.NewRequestMessage("UNSUPPORTED", "sip:alice@example.com")
 
@@ -350,7 +370,7 @@ func TestForwardRequest(t *testing.T) {
 	mockTxnMgr := &mockTransactionManager{}
 	mockParser := &mockParser{}
 
-	engine := NewRequestForwardingEngine(mockReg, mockTM, mockTxnMgr, mockParser, "proxy.example.com", 5060)
+	engine := NewRequestForwardingEngine(mockReg, mockTM, mockTxnMgr, mockParser, nil, nil, "proxy.example.com", 5060)
 
 	req := createTestInviteRequest()
 	targets := []*database.RegistrarContact{
@@ -390,7 +410,7 @@ func TestForwardRequest_NoTargets(t *testing.T) {
 	mockTxnMgr := &mockTransactionManager{}
 	mockParser := &mockParser{}
 
-	engine := NewRequestForwardingEngine(mockReg, mockTM, mockTxnMgr, mockParser, "proxy.example.com", 5060)
+	engine := NewRequestForwardingEngine(mockReg, mockTM, mockTxnMgr, mockParser, nil, nil, "proxy.example.com", 5060)
 
 	req := createTestInviteRequest()
 	targets := []*database.RegistrarContact{}
@@ -412,7 +432,7 @@ func TestProcessResponse(t *testing.T) {
 	mockParser := &mockParser{}
 	mockTxn := &mockTransaction{}
 
-	engine := NewRequestForwardingEngine(mockReg, mockTM, mockTxnMgr, mockParser, "proxy.example.com", 5060)
+	engine := NewRequestForwardingEngine(mockReg, mockTM, mockTxnMgr, mockParser, nil, nil, "proxy.example.com", 5060)
 
 	resp := createTestResponse()
 
@@ -445,7 +465,7 @@ func TestProcessResponse_NoViaHeaders(t *testing.T) {
 	mockParser := &mockParser{}
 	mockTxn := &mockTransaction{}
 
-	engine := NewRequestForwardingEngine(mockReg, mockTM, mockTxnMgr, mockParser, "proxy.example.com", 5060)
+	engine := NewRequestForwardingEngine(mockReg, mockTM, mockTxnMgr, mockParser, nil, nil, "proxy.example.com", 5060)
 
 	resp := parser.NewResponseMessage(parser.StatusOK, "OK")
 
@@ -465,7 +485,7 @@ func TestExtractAOR(t *testing.T) {
 	mockTxnMgr := &mockTransactionManager{}
 	mockParser := &mockParser{}
 
-	engine := NewRequestForwardingEngine(mockReg, mockTM, mockTxnMgr, mockParser, "proxy.example.com", 5060)
+	engine := NewRequestForwardingEngine(mockReg, mockTM, mockTxnMgr, mockParser, nil, nil, "proxy.example.com", 5060)
 
 	tests := []struct {
 		input    string
@@ -497,7 +517,7 @@ func TestParseTargetURI(t *testing.T) {
 	mockTxnMgr := &mockTransactionManager{}
 	mockParser := &mockParser{}
 
-	engine := NewRequestForwardingEngine(mockReg, mockTM, mockTxnMgr, mockParser, "proxy.example.com", 5060)
+	engine := NewRequestForwardingEngine(mockReg, mockTM, mockTxnMgr, mockParser, nil, nil, "proxy.example.com", 5060)
 
 	tests := []struct {
 		input             string
@@ -508,7 +528,7 @@ func TestParseTargetURI(t *testing.T) {
 		{"sip:alice@127.0.0.1", "udp", "127.0.0.1", 5060},
 		{"sip:alice@127.0.0.1:5080", "udp", "127.0.0.1", 5080},
 		{"sip:alice@127.0.0.1;transport=tcp", "tcp", "127.0.0.1", 5060},
-		{"sips:alice@127.0.0.1", "tcp", "127.0.0.1", 5060},
+		{"sips:alice@127.0.0.1", "tls", "127.0.0.1", 5061},
 		{"<sip:alice@127.0.0.1:5080;transport=tcp>", "tcp", "127.0.0.1", 5080},
 	}
 
@@ -540,7 +560,7 @@ func TestCheckMaxForwards(t *testing.T) {
 	mockTxnMgr := &mockTransactionManager{}
 	mockParser := &mockParser{}
 
-	engine := NewRequestForwardingEngine(mockReg, mockTM, mockTxnMgr, mockParser, "proxy.example.com", 5060)
+	engine := NewRequestForwardingEngine(mockReg, mockTM, mockTxnMgr, mockParser, nil, nil, "proxy.example.com", 5060)
 
 	// Test with valid Max-Forwards
 	req := createTestInviteRequest()
@@ -578,7 +598,7 @@ func TestDecrementMaxForwards(t *testing.T) {
 	mockTxnMgr := &mockTransactionManager{}
 	mockParser := &mockParser{}
 
-	engine := NewRequestForwardingEngine(mockReg, mockTM, mockTxnMgr, mockParser, "proxy.example.com", 5060)
+	engine := NewRequestForwardingEngine(mockReg, mockTM, mockTxnMgr, mockParser, nil, nil, "proxy.example.com", 5060)
 
 	req := createTestInviteRequest()
 	req.SetHeader(parser.HeaderMaxForwards, "10")
@@ -597,9 +617,9 @@ func TestCreateViaHeader(t *testing.T) {
 	mockTxnMgr := &mockTransactionManager{}
 	mockParser := &mockParser{}
 
-	engine := NewRequestForwardingEngine(mockReg, mockTM, mockTxnMgr, mockParser, "proxy.example.com", 5060)
+	engine := NewRequestForwardingEngine(mockReg, mockTM, mockTxnMgr, mockParser, nil, nil, "proxy.example.com", 5060)
 
-	viaHeader := engine.createViaHeader("udp")
+	viaHeader := engine.createViaHeader("udp", nil)
 
 	expectedPrefix := "SIP/2.0/UDP proxy.example.com:5060;branch=z9hG4bK-"
 	if !strings.HasPrefix(viaHeader, expectedPrefix) {
@@ -612,13 +632,104 @@ func TestCreateViaHeader(t *testing.T) {
 	}
 }
 
+// advertisingTransportManager has an external listener known by a public
+// address reaching 10.0.0.0/8 and an internal one reaching everything else
+type advertisingTransportManager struct {
+	mockTransportManager
+}
+
+func (m *advertisingTransportManager) AdvertisedAddress(transport string, addr net.Addr) (string, int) {
+	if strings.HasPrefix(addr.String(), "10.") {
+		return "203.0.113.5", 5060
+	}
+	return "192.168.1.5", 5060
+}
+
+func (m *advertisingTransportManager) IsAdvertisedAddress(host string, port int) bool {
+	return (host == "203.0.113.5" || host == "192.168.1.5") && port == 5060
+}
+
+func TestRecordRoute(t *testing.T) {
+	mockReg := newMockRegistrar()
+	mockTM := &advertisingTransportManager{}
+	engine := NewRequestForwardingEngine(mockReg, mockTM, &mockTransactionManager{}, parser.NewParser(), nil, nil, "proxy.example.com", 5060)
+	mockReg.addContact("sip:alice@example.com", "sip:alice@10.0.0.7:5060")
+
+	lastSent := func() *parser.SIPMessage {
+		t.Helper()
+		sent := mockTM.getLastSentMessage()
+		if sent == nil {
+			t.Fatal("Expected message to be sent")
+		}
+		msg, err := parser.NewParser().Parse(sent.data)
+		if err != nil {
+			t.Fatalf("Failed to parse sent message: %v", err)
+		}
+		return msg
+	}
+
+	// An initial INVITE crossing from the internal to the external interface
+	// records both addresses, the outbound one on top
+	invite := createTestInviteRequest()
+	invite.Source = &net.UDPAddr{IP: net.IPv4(192, 168, 1, 20), Port: 5060}
+	invite.Transport = "UDP"
+	if err := engine.ProcessRequest(invite, &mockTransaction{}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	recordRoutes := lastSent().GetHeaders(parser.HeaderRecordRoute)
+	expected := []string{"<sip:203.0.113.5:5060;lr>", "<sip:192.168.1.5:5060;lr>"}
+	if strings.Join(recordRoutes, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected Record-Route %v, got %v", expected, recordRoutes)
+	}
+
+	// A BYE from the callee is routed to the caller's Contact, not retargeted,
+	// and leaves without the Route entries naming this proxy
+	bye := parser.NewRequestMessage(parser.MethodBYE, "sip:bob@192.168.1.20:5060")
+	bye.SetHeader(parser.HeaderVia, "SIP/2.0/UDP 10.0.0.7:5060;branch=z9hG4bK-bye")
+	bye.SetHeader(parser.HeaderFrom, "Alice <sip:alice@example.com>;tag=67890")
+	bye.SetHeader(parser.HeaderTo, "Bob <sip:bob@example.com>;tag=12345")
+	bye.SetHeader(parser.HeaderCallID, "test-call-id@example.com")
+	bye.SetHeader(parser.HeaderCSeq, "1 BYE")
+	bye.SetHeader(parser.HeaderMaxForwards, "70")
+	bye.AddHeader(parser.HeaderRoute, "<sip:203.0.113.5:5060;lr>")
+	bye.AddHeader(parser.HeaderRoute, "<sip:192.168.1.5:5060;lr>")
+	if err := engine.ProcessRequest(bye, &mockTransaction{}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	forwarded := lastSent()
+	if addr := mockTM.getLastSentMessage().addr.String(); addr != "192.168.1.20:5060" {
+		t.Errorf("Expected BYE to be sent to the caller's Contact, got %s", addr)
+	}
+	if forwarded.GetRequestURI() != "sip:bob@192.168.1.20:5060" || forwarded.HasHeader(parser.HeaderRoute) {
+		t.Errorf("Expected BYE to keep its Request-URI and lose its Route headers, got %s %v",
+			forwarded.GetRequestURI(), forwarded.GetHeaders(parser.HeaderRoute))
+	}
+	if forwarded.HasHeader(parser.HeaderRecordRoute) {
+		t.Error("Expected no Record-Route on a request within a dialog")
+	}
+
+	// A Route entry naming another proxy is the next hop
+	bye.SetHeader(parser.HeaderRoute, "<sip:192.168.1.5:5060;lr>")
+	bye.AddHeader(parser.HeaderRoute, "<sip:192.0.2.9:5070;lr>")
+	if err := engine.ProcessRequest(bye, &mockTransaction{}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	forwarded = lastSent()
+	if addr := mockTM.getLastSentMessage().addr.String(); addr != "192.0.2.9:5070" {
+		t.Errorf("Expected BYE to be sent to the next hop, got %s", addr)
+	}
+	if routes := forwarded.GetHeaders(parser.HeaderRoute); len(routes) != 1 || routes[0] != "<sip:192.0.2.9:5070;lr>" {
+		t.Errorf("Expected the next hop's Route entry to remain, got %v", routes)
+	}
+}
+
 func TestParseViaHeader(t *testing.T) {
 	mockReg := newMockRegistrar()
 	mockTM := newMockTransportManager()
 	mockTxnMgr := &mockTransactionManager{}
 	mockParser := &mockParser{}
 
-	engine := NewRequestForwardingEngine(mockReg, mockTM, mockTxnMgr, mockParser, "proxy.example.com", 5060)
+	engine := NewRequestForwardingEngine(mockReg, mockTM, mockTxnMgr, mockParser, nil, nil, "proxy.example.com", 5060)
 
 	tests := []struct {
 		input             string
@@ -1,6 +1,7 @@
 package proxy
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
@@ -9,6 +10,7 @@ import (
 	"github.com/zurustar/xylitol2/internal/database"
 	"github.com/zurustar/xylitol2/internal/parser"
 	"github.com/zurustar/xylitol2/internal/transaction"
+	"github.com/zurustar/xylitol2/internal/transport"
 )
 
 // ProxyState represents the state of a proxy transaction
@@ -34,6 +36,10 @@ type ClientTransaction struct {
 	Response    *parser.SIPMessage
 	State       ClientTransactionState
 	CreatedAt   time.Time
+
+	// destinations holds the destination the request was last sent to followed by
+	// those still to be tried should it fail (RFC 3263 section 4.3)
+	destinations []destination
 }
 
 // ClientTransactionState represents the state of a client transaction
@@ -90,9 +96,15 @@ func (e *StatefulProxyEngine) ProcessRequest(req *parser.SIPMessage, transaction
 	// Handle different request methods
 	switch method {
 	case parser.MethodINVITE:
+		if _, routed := e.nextHop(req); routed {
+			// Re-INVITEs and INVITEs routed on by a Route header are not forked
+			return e.processInDialogRequest(req, transaction)
+		}
 		return e.processInviteRequest(req, transaction)
 	case parser.MethodCANCEL:
-		return e.processCancelRequest(req, transaction)
+		// CANCEL requests are matched to their INVITE by the transaction manager,
+		// which runs the callback processInviteRequest registered
+		return fmt.Errorf("CANCEL requests should be handled by the transaction layer")
 	case parser.MethodACK:
 		return e.processAckRequest(req, transaction)
 	case parser.MethodBYE, parser.MethodINFO:
@@ -151,38 +163,29 @@ func (e *StatefulProxyEngine) processInviteRequest(req *parser.SIPMessage, serve
 	e.proxyStates[proxyState.ID] = proxyState
 	e.mutex.Unlock()
 
+	// A CANCEL matched to the INVITE server transaction cancels every branch
+	if cancelManager, ok := e.transactionManager.(transaction.CancelManager); ok {
+		cancelManager.OnCancel(req, func(*parser.SIPMessage) {
+			e.cancelProxyState(proxyState)
+		})
+	}
+
 	// Fork the request to all targets
 	return e.forkRequest(proxyState)
 }
 
-// processCancelRequest processes CANCEL requests
-func (e *StatefulProxyEngine) processCancelRequest(req *parser.SIPMessage, serverTxn transaction.Transaction) error {
-	// Find the corresponding INVITE transaction
-	inviteStateID := e.generateProxyStateID(req)
-	
-	e.mutex.RLock()
-	proxyState, exists := e.proxyStates[inviteStateID]
-	e.mutex.RUnlock()
-
-	if !exists {
-		// No matching INVITE transaction found
-		return e.sendCallTransactionDoesNotExist(req, serverTxn)
-	}
-
+// cancelProxyState sends a CANCEL to every pending branch of proxyState and
+// stops responses from being forwarded to the client, which gets a 487 for
+// its INVITE instead
+func (e *StatefulProxyEngine) cancelProxyState(proxyState *ProxyState) {
 	proxyState.mutex.Lock()
 	defer proxyState.mutex.Unlock()
 
-	// Send CANCEL to all active client transactions
-	for _, clientTxn := range proxyState.ClientTransactions {
-		if clientTxn.State == ClientStateTrying || clientTxn.State == ClientStateProceeding {
-			e.sendCancelToTarget(clientTxn, req)
-		}
+	if proxyState.FinalResponseSent {
+		return
 	}
-
-	// Send 200 OK to CANCEL request
-	response := parser.NewResponseMessage(parser.StatusOK, "OK")
-	e.copyRequiredHeaders(req, response)
-	return serverTxn.SendResponse(response)
+	e.cancelOtherClientTransactions(proxyState, "")
+	proxyState.FinalResponseSent = true
 }
 
 // processAckRequest processes ACK requests
@@ -226,6 +229,7 @@ func (e *StatefulProxyEngine) forkRequest(proxyState *ProxyState) error {
 	defer proxyState.mutex.Unlock()
 
 	// Create client transactions for each target
+	tooLarge := 0
 	for i, target := range proxyState.Targets {
 		clientTxn := &ClientTransaction{
 			ID:        fmt.Sprintf("%s-client-%d", proxyState.ID, i),
@@ -234,30 +238,23 @@ func (e *StatefulProxyEngine) forkRequest(proxyState *ProxyState) error {
 			CreatedAt: time.Now(),
 		}
 
-		// Create a copy of the request for this target
-		forwardedReq := proxyState.OriginalRequest.Clone()
-
-		// Add Via header for this proxy
-		viaHeader := e.createViaHeader(forwardedReq.Transport)
-		e.addViaHeader(forwardedReq, viaHeader)
-
-		// Update Request-URI to target contact
-		if reqLine, ok := forwardedReq.StartLine.(*parser.RequestLine); ok {
-			reqLine.RequestURI = target.URI
-		}
-
-		clientTxn.Request = forwardedReq
-
-		// Create client transaction
-		clientTxn.Transaction = e.transactionManager.CreateTransaction(forwardedReq)
-
 		// Store client transaction
 		proxyState.ClientTransactions[clientTxn.ID] = clientTxn
 
+		destinations, err := e.resolveDestinations(target)
+		if err != nil {
+			clientTxn.State = ClientStateTerminated
+			continue
+		}
+		clientTxn.destinations = destinations
+
 		// Send the request
-		if err := e.sendRequestToTarget(clientTxn); err != nil {
+		if err := e.sendToNextDestination(proxyState, clientTxn); err != nil {
 			// Mark this client transaction as failed
 			clientTxn.State = ClientStateTerminated
+			if errors.Is(err, transport.ErrMessageTooLarge) {
+				tooLarge++
+			}
 			continue
 		}
 	}
@@ -271,6 +268,13 @@ func (e *StatefulProxyEngine) forkRequest(proxyState *ProxyState) error {
 		}
 	}
 
+	if allFailed && tooLarge == len(proxyState.ClientTransactions) {
+		// No transport could carry the request to any target
+		response := parser.NewResponseMessage(parser.StatusMessageTooLarge, "Message Too Large")
+		e.copyRequiredHeaders(proxyState.OriginalRequest, response)
+		return proxyState.ServerTransaction.SendResponse(response)
+	}
+
 	if allFailed {
 		// Send 500 Server Internal Error
 		response := parser.NewResponseMessage(parser.StatusServerInternalError, "All targets failed")
@@ -310,6 +314,13 @@ func (e *StatefulProxyEngine) ProcessResponse(resp *parser.SIPMessage, transacti
 		return fmt.Errorf("no matching client transaction found for response")
 	}
 
+	return e.handleResponse(proxyState, clientTxn, resp)
+}
+
+// handleResponse handles a response to the request of clientTxn, received or
+// passed up by its client transaction on a timeout. The caller holds the lock
+// of proxyState.
+func (e *StatefulProxyEngine) handleResponse(proxyState *ProxyState, clientTxn *ClientTransaction, resp *parser.SIPMessage) error {
 	// Update client transaction state
 	clientTxn.Response = resp
 	statusCode := resp.GetStatusCode()
@@ -323,6 +334,13 @@ func (e *StatefulProxyEngine) ProcessResponse(resp *parser.SIPMessage, transacti
 		clientTxn.State = ClientStateCompleted
 		return e.handleSuccessResponse(proxyState, clientTxn, resp)
 	} else if statusCode >= 300 {
+		// A server that timed out or is unavailable is replaced by the next
+		// destination of the target, if there is one
+		if (statusCode == parser.StatusRequestTimeout || statusCode == parser.StatusServiceUnavailable) &&
+			!proxyState.FinalResponseSent && e.failOver(proxyState, clientTxn) {
+			return nil
+		}
+
 		// Error response
 		clientTxn.State = ClientStateCompleted
 		return e.handleErrorResponse(proxyState, clientTxn, resp)
@@ -435,37 +453,126 @@ func (e *StatefulProxyEngine) findProxyStateForResponse(resp *parser.SIPMessage)
 	return nil
 }
 
-func (e *StatefulProxyEngine) sendRequestToTarget(clientTxn *ClientTransaction) error {
-	// Parse target address
-	targetAddr, transport, err := e.parseTargetURI(clientTxn.Target.URI)
+// sendToNextDestination sends a copy of the original request to the first
+// destination of clientTxn with a new Via and client transaction, moving on to
+// the next destination for as long as sending fails
+func (e *StatefulProxyEngine) sendToNextDestination(proxyState *ProxyState, clientTxn *ClientTransaction) error {
+	err := fmt.Errorf("no destinations for target %s", clientTxn.Target.URI)
+	for len(clientTxn.destinations) > 0 {
+		dest := clientTxn.destinations[0]
+
+		// Create a copy of the request for this destination
+		forwardedReq := proxyState.OriginalRequest.Clone()
+		e.addReceivedParams(forwardedReq)
+
+		// Add Via header for this proxy, naming the transport the request leaves on
+		viaHeader := e.createViaHeader(dest.transport, dest.addr)
+		e.addViaHeader(forwardedReq, viaHeader)
+		e.removeOwnRoutes(forwardedReq)
+		e.addRecordRoute(forwardedReq, dest)
+
+		// Update Request-URI to target contact
+		if reqLine, ok := forwardedReq.StartLine.(*parser.RequestLine); ok {
+			reqLine.RequestURI = clientTxn.Target.URI
+		}
+
+		clientTxn.Request = forwardedReq
+		clientTxn.Response = nil
+		clientTxn.State = ClientStateTrying
+
+		if err = e.sendClientRequest(proxyState, clientTxn); err == nil {
+			return nil
+		}
+		clientTxn.destinations = clientTxn.destinations[1:]
+	}
+	return err
+}
+
+// sendClientRequest sends the request of clientTxn to its current destination.
+// A transaction manager that runs client transactions sends it in one, which
+// passes a 408 up to handleResponse when the destination does not answer in
+// time (RFC 3261 section 16.7).
+func (e *StatefulProxyEngine) sendClientRequest(proxyState *ProxyState, clientTxn *ClientTransaction) error {
+	clients, ok := e.transactionManager.(transaction.ClientManager)
+	if !ok {
+		clientTxn.Transaction = e.transactionManager.CreateTransaction(clientTxn.Request)
+		return e.sendRequestToTarget(clientTxn)
+	}
+
+	dest, err := e.currentDestination(clientTxn)
 	if err != nil {
 		return fmt.Errorf("failed to parse target URI %s: %w", clientTxn.Target.URI, err)
 	}
+	request := clientTxn.Request
+	send := func(msg *parser.SIPMessage) error {
+		return e.sendToDestination(dest, msg)
+	}
+	onTimeout := func(timeout *parser.SIPMessage) {
+		proxyState.mutex.Lock()
+		defer proxyState.mutex.Unlock()
+		// Ignore the timeout of a request that was cancelled or sent elsewhere since
+		if clientTxn.Request == request && clientTxn.State != ClientStateTerminated {
+			e.handleResponse(proxyState, clientTxn, timeout)
+		}
+	}
 
-	// Serialize the message
-	data, err := e.parser.Serialize(clientTxn.Request)
+	txn, err := clients.SendClientRequest(request, send, onTimeout)
 	if err != nil {
-		return fmt.Errorf("failed to serialize request: %w", err)
+		return err
 	}
+	clientTxn.Transaction = txn
+	return nil
+}
 
-	// Send the request
-	return e.transportManager.SendMessage(data, transport, targetAddr)
+// failOver retries the request of clientTxn at the next destination of its
+// target and reports whether one accepted it
+func (e *StatefulProxyEngine) failOver(proxyState *ProxyState, clientTxn *ClientTransaction) bool {
+	if len(clientTxn.destinations) < 2 {
+		return false
+	}
+	clientTxn.destinations = clientTxn.destinations[1:]
+	return e.sendToNextDestination(proxyState, clientTxn) == nil
 }
 
-func (e *StatefulProxyEngine) sendCancelToTarget(clientTxn *ClientTransaction, originalCancel *parser.SIPMessage) error {
-	// Create CANCEL request for this target
-	cancelReq := parser.NewRequestMessage(parser.MethodCANCEL, clientTxn.Target.URI)
+// currentDestination returns where the request of clientTxn was last sent
+func (e *StatefulProxyEngine) currentDestination(clientTxn *ClientTransaction) (destination, error) {
+	if len(clientTxn.destinations) > 0 {
+		return clientTxn.destinations[0], nil
+	}
+	addr, transport, err := e.resolveContact(clientTxn.Target)
+	return destination{addr: addr, transport: transport}, err
+}
 
-	// Copy required headers from original CANCEL
-	e.copyRequiredHeaders(originalCancel, cancelReq)
+func (e *StatefulProxyEngine) sendRequestToTarget(clientTxn *ClientTransaction) error {
+	// Parse target address
+	dest, err := e.currentDestination(clientTxn)
+	if err != nil {
+		return fmt.Errorf("failed to parse target URI %s: %w", clientTxn.Target.URI, err)
+	}
 
-	// Update Request-URI
-	if reqLine, ok := cancelReq.StartLine.(*parser.RequestLine); ok {
-		reqLine.RequestURI = clientTxn.Target.URI
+	return e.sendToDestination(dest, clientTxn.Request)
+}
+
+// sendToDestination serializes msg and sends it to dest
+func (e *StatefulProxyEngine) sendToDestination(dest destination, msg *parser.SIPMessage) error {
+	data, err := e.parser.Serialize(msg)
+	if err != nil {
+		return fmt.Errorf("failed to serialize %s: %w", msg.GetMethod(), err)
 	}
+	return e.transportManager.SendMessage(data, dest.transport, dest.addr)
+}
 
-	// Parse target address
-	targetAddr, transport, err := e.parseTargetURI(clientTxn.Target.URI)
+// sendCancelToTarget sends a CANCEL for the request of clientTxn where the
+// request went; the CANCEL carries the request's Via so that it matches the
+// INVITE transaction downstream
+func (e *StatefulProxyEngine) sendCancelToTarget(clientTxn *ClientTransaction) error {
+	if clientTxn.Request == nil {
+		return fmt.Errorf("no request sent to %s", clientTxn.Target.URI)
+	}
+	cancelReq := transaction.NewCancel(clientTxn.Request)
+
+	// CANCEL goes where the INVITE went
+	dest, err := e.currentDestination(clientTxn)
 	if err != nil {
 		return fmt.Errorf("failed to parse target URI for CANCEL: %w", err)
 	}
@@ -476,12 +583,13 @@ func (e *StatefulProxyEngine) sendCancelToTarget(clientTxn *ClientTransaction, o
 		return fmt.Errorf("failed to serialize CANCEL: %w", err)
 	}
 
-	return e.transportManager.SendMessage(data, transport, targetAddr)
+	return e.transportManager.SendMessage(data, dest.transport, dest.addr)
 }
 
 func (e *StatefulProxyEngine) forwardAckToTarget(clientTxn *ClientTransaction, ack *parser.SIPMessage) error {
 	// Create ACK request for this target
 	ackReq := ack.Clone()
+	e.removeOwnRoutes(ackReq)
 
 	// Update Request-URI
 	if reqLine, ok := ackReq.StartLine.(*parser.RequestLine); ok {
@@ -489,7 +597,7 @@ func (e *StatefulProxyEngine) forwardAckToTarget(clientTxn *ClientTransaction, a
 	}
 
 	// Parse target address
-	targetAddr, transport, err := e.parseTargetURI(clientTxn.Target.URI)
+	dest, err := e.currentDestination(clientTxn)
 	if err != nil {
 		return fmt.Errorf("failed to parse target URI for ACK: %w", err)
 	}
@@ -500,27 +608,14 @@ func (e *StatefulProxyEngine) forwardAckToTarget(clientTxn *ClientTransaction, a
 		return fmt.Errorf("failed to serialize ACK: %w", err)
 	}
 
-	return e.transportManager.SendMessage(data, transport, targetAddr)
+	return e.transportManager.SendMessage(data, dest.transport, dest.addr)
 }
 
 func (e *StatefulProxyEngine) cancelOtherClientTransactions(proxyState *ProxyState, excludeID string) {
 	for id, clientTxn := range proxyState.ClientTransactions {
 		if id != excludeID && (clientTxn.State == ClientStateTrying || clientTxn.State == ClientStateProceeding) {
-			// Send CANCEL to this target
-			cancelReq := parser.NewRequestMessage(parser.MethodCANCEL, clientTxn.Target.URI)
-			e.copyRequiredHeaders(proxyState.OriginalRequest, cancelReq)
-			
-			// Update Request-URI
-			if reqLine, ok := cancelReq.StartLine.(*parser.RequestLine); ok {
-				reqLine.RequestURI = clientTxn.Target.URI
-			}
-
-			// Send CANCEL (ignore errors for cleanup)
-			if targetAddr, transport, err := e.parseTargetURI(clientTxn.Target.URI); err == nil {
-				if data, err := e.parser.Serialize(cancelReq); err == nil {
-					e.transportManager.SendMessage(data, transport, targetAddr)
-				}
-			}
+			// Send CANCEL to this target (ignore errors for cleanup)
+			e.sendCancelToTarget(clientTxn)
 
 			clientTxn.State = ClientStateTerminated
 		}
@@ -560,14 +655,6 @@ func (e *StatefulProxyEngine) isBetterResponse(newCode, currentBestCode int) boo
 	return newCode < currentBestCode
 }
 
-// Error response methods
-
-func (e *StatefulProxyEngine) sendCallTransactionDoesNotExist(req *parser.SIPMessage, transaction transaction.Transaction) error {
-	response := parser.NewResponseMessage(parser.StatusCallTransactionDoesNotExist, "Call/Transaction Does Not Exist")
-	e.copyRequiredHeaders(req, response)
-	return transaction.SendResponse(response)
-}
-
 // CleanupExpiredStates removes expired proxy states
 func (e *StatefulProxyEngine) CleanupExpiredStates() {
 	e.mutex.Lock()
@@ -1,11 +1,15 @@
 package proxy
 
 import (
+	"context"
+	"net"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/zurustar/xylitol2/internal/clock"
 	"github.com/zurustar/xylitol2/internal/parser"
+	"github.com/zurustar/xylitol2/internal/resolver"
 	"github.com/zurustar/xylitol2/internal/transaction"
 )
 
@@ -17,7 +21,7 @@ func createTestStatefulEngine() *StatefulProxyEngine {
 	mockTxnMgr := &mockTransactionManager{}
 	mockParser := &mockParser{}
 
-	forwardingEngine := NewRequestForwardingEngine(mockReg, mockTM, mockTxnMgr, mockParser, "proxy.example.com", 5060)
+	forwardingEngine := NewRequestForwardingEngine(mockReg, mockTM, mockTxnMgr, mockParser, nil, nil, "proxy.example.com", 5060)
 	return NewStatefulProxyEngine(forwardingEngine)
 }
 
@@ -68,6 +72,24 @@ func createTestResponseWithCallID(statusCode int, callID string) *parser.SIPMess
 	return resp
 }
 
+// fakeDNS answers lookups from fixed tables
+type fakeDNS struct {
+	srv   map[string][]*net.SRV
+	hosts map[string][]net.IP
+}
+
+func (d *fakeDNS) LookupNAPTR(ctx context.Context, name string) ([]*resolver.NAPTR, error) {
+	return nil, nil
+}
+
+func (d *fakeDNS) LookupSRV(ctx context.Context, name string) ([]*net.SRV, error) {
+	return d.srv[name], nil
+}
+
+func (d *fakeDNS) LookupHost(ctx context.Context, host string) ([]net.IP, error) {
+	return d.hosts[host], nil
+}
+
 // Tests
 
 func TestNewStatefulProxyEngine(t *testing.T) {
@@ -183,62 +205,73 @@ func TestProcessRequest_INVITE_NoTargets(t *testing.T) {
 	}
 }
 
-func TestProcessRequest_CANCEL(t *testing.T) {
+func TestProcessRequest_ReINVITE(t *testing.T) {
 	engine := createTestStatefulEngine()
-	mockTxn := &mockTransaction{}
 
-	// First, create an INVITE transaction
-	engine.registrar.(*mockRegistrar).addContact("sip:alice@example.com", "sip:alice@127.0.0.1:5060")
-	inviteReq := createTestInviteWithCallID("test-call-id-4")
-	engine.ProcessRequest(inviteReq, &mockTransaction{})
+	// A re-INVITE routed here by this proxy's Record-Route goes to the remote
+	// target in its Request-URI instead of being forked to registrations
+	req := parser.NewRequestMessage(parser.MethodINVITE, "sip:alice@127.0.0.1:5070")
+	req.SetHeader(parser.HeaderVia, "SIP/2.0/UDP client.example.com:5060;branch=z9hG4bK-reinvite")
+	req.SetHeader(parser.HeaderFrom, "Bob <sip:bob@example.com>;tag=12345")
+	req.SetHeader(parser.HeaderTo, "Alice <sip:alice@example.com>;tag=67890")
+	req.SetHeader(parser.HeaderCallID, "test-call-id-reinvite")
+	req.SetHeader(parser.HeaderCSeq, "2 INVITE")
+	req.SetHeader(parser.HeaderMaxForwards, "70")
+	req.SetHeader(parser.HeaderRoute, "<sip:proxy.example.com;lr>")
 
-	// Now send CANCEL - need to use same Call-ID and CSeq number but CANCEL method
-	cancelReq := createTestCancelRequest("test-call-id-4")
-	// Fix the CSeq to match INVITE for proper transaction matching
-	cancelReq.SetHeader(parser.HeaderCSeq, "1 CANCEL")
-	
-	err := engine.ProcessRequest(cancelReq, mockTxn)
-	if err != nil {
+	if err := engine.ProcessRequest(req, &mockTransaction{}); err != nil {
 		t.Fatalf("Expected no error, got: %v", err)
 	}
 
-	// Check that 200 OK was sent for CANCEL
-	resp := mockTxn.getLastResponse()
-	if resp == nil {
-		t.Fatal("Expected response to CANCEL")
-	}
-
-	if resp.GetStatusCode() != parser.StatusOK {
-		t.Errorf("Expected status code %d for CANCEL, got %d", parser.StatusOK, resp.GetStatusCode())
+	sent := engine.transportManager.(*mockTransportManager).getLastSentMessage()
+	if sent == nil || sent.addr.String() != "127.0.0.1:5070" {
+		t.Fatalf("Expected the re-INVITE to be sent to the remote target, got %v", sent)
 	}
-
-	// Check that CANCEL was sent to targets
-	transportMgr := engine.transportManager.(*mockTransportManager)
-	// Should have INVITE + CANCEL messages
-	if len(transportMgr.sentMessages) < 2 {
-		t.Errorf("Expected at least 2 messages (INVITE + CANCEL), got %d", len(transportMgr.sentMessages))
+	if engine.GetProxyStateCount() != 0 {
+		t.Errorf("Expected the re-INVITE not to be forked, got %d proxy states", engine.GetProxyStateCount())
 	}
 }
 
-func TestProcessRequest_CANCEL_NoMatchingTransaction(t *testing.T) {
+func TestProcessRequest_CANCEL(t *testing.T) {
 	engine := createTestStatefulEngine()
-	mockTxn := &mockTransaction{}
+	engine.registrar.(*mockRegistrar).addContact("sip:alice@example.com", "sip:alice@127.0.0.1:5060")
 
-	// Send CANCEL without matching INVITE
-	cancelReq := createTestCancelRequest("nonexistent-call-id")
-	err := engine.ProcessRequest(cancelReq, mockTxn)
-	if err != nil {
+	// The transaction manager answers the CANCEL and the INVITE itself
+	var responses []*parser.SIPMessage
+	transactionManager := transaction.NewManager(func(msg *parser.SIPMessage) error {
+		responses = append(responses, msg)
+		return nil
+	})
+	defer transactionManager.Stop()
+	engine.transactionManager = transactionManager
+
+	inviteReq := createTestInviteWithCallID("test-call-id-4")
+	if err := engine.ProcessRequest(inviteReq, transactionManager.CreateTransaction(inviteReq)); err != nil {
 		t.Fatalf("Expected no error, got: %v", err)
 	}
 
-	// Check that 481 response was sent
-	resp := mockTxn.getLastResponse()
-	if resp == nil {
-		t.Fatal("Expected response to CANCEL")
+	cancelReq := createTestCancelRequest("test-call-id-4")
+	if _, err := transactionManager.ProcessCancel(cancelReq); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	var codes []int
+	for _, resp := range responses {
+		codes = append(codes, resp.GetStatusCode())
+	}
+	if len(codes) != 2 || codes[0] != parser.StatusOK || codes[1] != parser.StatusRequestTerminated {
+		t.Errorf("Expected 200 for CANCEL and 487 for INVITE, got %v", codes)
+	}
+
+	// The CANCEL goes on to the target the INVITE was forked to
+	transportMgr := engine.transportManager.(*mockTransportManager)
+	if len(transportMgr.sentMessages) != 2 || !strings.HasPrefix(string(transportMgr.sentMessages[1].data), parser.MethodCANCEL) {
+		t.Errorf("Expected INVITE and CANCEL to be sent to the target, got %d messages", len(transportMgr.sentMessages))
 	}
 
-	if resp.GetStatusCode() != parser.StatusCallTransactionDoesNotExist {
-		t.Errorf("Expected status code %d, got %d", parser.StatusCallTransactionDoesNotExist, resp.GetStatusCode())
+	// The proxy itself no longer takes CANCEL requests
+	if err := engine.ProcessRequest(cancelReq, &mockTransaction{}); err == nil {
+		t.Error("Expected an error for a CANCEL passed to the proxy")
 	}
 }
 
@@ -600,4 +633,79 @@ func TestStatefulProcessRequest_REGISTER(t *testing.T) {
 	if !strings.Contains(err.Error(), "REGISTER requests should be handled by registrar") {
 		t.Errorf("Expected specific error message, got: %v", err)
 	}
+}
+
+func TestProcessResponse_TimeoutFailsOver(t *testing.T) {
+	engine := createTestStatefulEngine()
+	serverTxn := &mockTransaction{}
+
+	// The partner domain publishes a primary and a backup server
+	engine.SetResolver(resolver.NewResolver(&fakeDNS{
+		srv: map[string][]*net.SRV{
+			"_sip._udp.partner.example": {
+				{Target: "primary.partner.example.", Port: 5060, Priority: 10, Weight: 10},
+				{Target: "backup.partner.example.", Port: 5060, Priority: 20, Weight: 10},
+			},
+		},
+		hosts: map[string][]net.IP{
+			"primary.partner.example": {net.ParseIP("192.0.2.1")},
+			"backup.partner.example":  {net.ParseIP("192.0.2.2")},
+		},
+	}))
+	engine.registrar.(*mockRegistrar).addContact("sip:alice@example.com", "sip:alice@partner.example")
+
+	// Client transactions run on a fake clock, so that Timer B fires on demand
+	transactionManager := transaction.NewManager(nil)
+	defer transactionManager.Stop()
+	fake := clock.NewFake(time.Now())
+	transactionManager.SetClock(fake)
+	engine.transactionManager = transactionManager
+
+	if err := engine.ProcessRequest(createTestInviteWithCallID("test-call-id-timeout"), serverTxn); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	transportMgr := engine.transportManager.(*mockTransportManager)
+	primary := &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 5060}
+	backup := &net.UDPAddr{IP: net.ParseIP("192.0.2.2"), Port: 5060}
+	sentTo := func(addr net.Addr) int {
+		count := 0
+		for _, sent := range transportMgr.sentMessages {
+			if sent.addr.String() == addr.String() {
+				count++
+			}
+		}
+		return count
+	}
+
+	// The primary server never answers; Timer A retransmits to it until Timer B fires
+	timers := transactionManager.Timers()
+	fake.Advance(timers.B - time.Millisecond)
+	if sentTo(backup) != 0 {
+		t.Fatal("Expected nothing sent to the backup server before Timer B")
+	}
+	if sentTo(primary) < 2 {
+		t.Errorf("Expected the INVITE to be retransmitted to the primary server, got %d sends", sentTo(primary))
+	}
+
+	fake.Advance(time.Millisecond)
+	if sentTo(backup) != 1 {
+		t.Fatalf("Expected the INVITE to fail over to the backup server, got %d sends", sentTo(backup))
+	}
+	if last := transportMgr.getLastSentMessage(); last.addr.String() != backup.String() {
+		t.Errorf("Expected the last message to go to %s, got %s", backup, last.addr)
+	}
+	if len(serverTxn.responses) != 0 {
+		t.Fatalf("Expected no response to the caller while the backup server is tried, got %d", serverTxn.getLastResponse().GetStatusCode())
+	}
+
+	// Once the backup server times out too, the caller gets the 408
+	fake.Advance(timers.B)
+	response := serverTxn.getLastResponse()
+	if response == nil || response.GetStatusCode() != parser.StatusRequestTimeout {
+		t.Fatalf("Expected 408 to be forwarded to the caller, got %v", response)
+	}
+	if vias := response.GetHeaders(parser.HeaderVia); len(vias) != 1 || !strings.Contains(vias[0], "client.example.com") {
+		t.Errorf("Expected only the caller's Via in the 408, got %v", vias)
+	}
 }
\ No newline at end of file
@@ -1,6 +1,8 @@
 package registrar
 
 import (
+	"net"
+
 	"github.com/zurustar/xylitol2/internal/database"
 )
 
@@ -10,4 +12,7 @@ type Registrar interface {
 	Unregister(aor string) error
 	FindContacts(aor string) ([]*database.RegistrarContact, error)
 	CleanupExpired()
-}
\ No newline at end of file
+	// FlowFailed drops the SIP Outbound bindings registered over a flow the
+	// transport layer reports as dead
+	FlowFailed(transport string, addr net.Addr)
+}
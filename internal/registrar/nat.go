@@ -0,0 +1,55 @@
+package registrar
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/zurustar/xylitol2/internal/database"
+	"github.com/zurustar/xylitol2/internal/parser"
+)
+
+// SetObservedFlow records the address and transport a REGISTER arrived from on
+// contact, and flags the binding as behind NAT when the Contact URI names an IP
+// address the REGISTER did not come from. The proxy then delivers requests to
+// the observed flow while keeping the registered Contact as Request-URI.
+func SetObservedFlow(contact *database.RegistrarContact, request *parser.SIPMessage) {
+	if request.Source == nil {
+		return
+	}
+	contact.Source = request.Source.String()
+	contact.Transport = strings.ToUpper(request.Transport)
+	contact.BehindNAT = behindNAT(contact.URI, contact.Source, contact.Transport)
+}
+
+// behindNAT reports whether a request from source could not have been sent from
+// the IP address in contactURI. Stream transports connect from ephemeral ports,
+// so only the host is compared for them. A hostname in contactURI is left to
+// DNS and never counts as behind NAT.
+func behindNAT(contactURI, source, transport string) bool {
+	uri, err := parser.ParseURI(contactURI)
+	if err != nil {
+		return false
+	}
+	host, port, err := net.SplitHostPort(source)
+	if err != nil {
+		return false
+	}
+
+	contactIP := net.ParseIP(uri.Host)
+	if contactIP == nil {
+		return false
+	}
+	if !contactIP.Equal(net.ParseIP(host)) {
+		return true
+	}
+	if transport != "UDP" {
+		return false
+	}
+
+	contactPort := uri.Port
+	if contactPort == 0 {
+		contactPort = 5060 // Default SIP port
+	}
+	return strconv.Itoa(contactPort) != port
+}
@@ -0,0 +1,60 @@
+package registrar
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/zurustar/xylitol2/internal/database"
+	"github.com/zurustar/xylitol2/internal/parser"
+)
+
+// OptionTagOutbound is the option tag of SIP Outbound (RFC 5626)
+const OptionTagOutbound = "outbound"
+
+// SetOutboundParams records the +sip.instance and reg-id of a Contact header on
+// contact. A binding only becomes an outbound flow when both are present and
+// the REGISTER came straight from the UA: without a Path header this registrar
+// is only the edge proxy for flows that end at itself (RFC 5626 section 6).
+func SetOutboundParams(contact *database.RegistrarContact, contactHeader string, request *parser.SIPMessage) {
+	parsed, err := parser.ParseContact(contactHeader)
+	if err != nil {
+		return
+	}
+	contact.InstanceID = parsed.Instance()
+	if contact.InstanceID == "" || len(request.GetHeaders(parser.HeaderVia)) != 1 {
+		return
+	}
+	if regID, ok := parsed.RegID(); ok {
+		contact.RegID = regID
+	}
+}
+
+// IsOutbound reports whether contact is bound to a SIP Outbound flow
+func IsOutbound(contact *database.RegistrarContact) bool {
+	return contact.RegID > 0
+}
+
+// outboundContactParams returns the Contact parameters that identify an
+// outbound binding in a REGISTER response, or "" for an ordinary binding
+func outboundContactParams(contact *database.RegistrarContact) string {
+	if contact.InstanceID == "" {
+		return ""
+	}
+	params := fmt.Sprintf(";+sip.instance=\"<%s>\"", contact.InstanceID)
+	if IsOutbound(contact) {
+		params += ";reg-id=" + strconv.Itoa(contact.RegID)
+	}
+	return params
+}
+
+// SetOutboundRequire adds "Require: outbound" to a successful REGISTER response
+// when one of the bindings it created uses SIP Outbound, telling the UA that
+// the registrar will deliver requests over its flows
+func SetOutboundRequire(response *parser.SIPMessage, contacts []*database.RegistrarContact) {
+	for _, contact := range contacts {
+		if IsOutbound(contact) {
+			response.SetHeader(parser.HeaderRequire, OptionTagOutbound)
+			return
+		}
+	}
+}
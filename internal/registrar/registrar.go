@@ -2,11 +2,13 @@ package registrar
 
 import (
 	"fmt"
+	"net"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/zurustar/xylitol2/internal/auth"
+	"github.com/zurustar/xylitol2/internal/clock"
 	"github.com/zurustar/xylitol2/internal/database"
 	"github.com/zurustar/xylitol2/internal/parser"
 )
@@ -20,6 +22,7 @@ type SIPRegistrar struct {
 	defaultExpires  int
 	maxExpires      int
 	minExpires      int
+	clock           clock.Clock
 }
 
 // NewSIPRegistrar creates a new SIP registrar instance
@@ -32,9 +35,15 @@ func NewSIPRegistrar(storage database.RegistrationDB, authenticator auth.Message
 		defaultExpires: 3600, // 1 hour default
 		maxExpires:     7200, // 2 hours max
 		minExpires:     60,   // 1 minute min
+		clock:          clock.Real(),
 	}
 }
 
+// SetClock sets the clock registrations expire by
+func (r *SIPRegistrar) SetClock(c clock.Clock) {
+	r.clock = c
+}
+
 // ProcessRegisterRequest processes a REGISTER request and returns a response
 func (r *SIPRegistrar) ProcessRegisterRequest(request *parser.SIPMessage) (*parser.SIPMessage, error) {
 	if request.GetMethod() != parser.MethodREGISTER {
@@ -100,7 +109,7 @@ func (r *SIPRegistrar) Register(contact *database.RegistrarContact, expires int)
 	}
 
 	// Set expiration time
-	contact.Expires = time.Now().UTC().Add(time.Duration(expires) * time.Second)
+	contact.Expires = r.clock.Now().UTC().Add(time.Duration(expires) * time.Second)
 
 	// Store the contact
 	return r.storage.Store(contact)
@@ -109,7 +118,7 @@ func (r *SIPRegistrar) Register(contact *database.RegistrarContact, expires int)
 // Unregister removes all contacts for an AOR (implements Registrar interface)
 func (r *SIPRegistrar) Unregister(aor string) error {
 	// Get all contacts for the AOR
-	contacts, err := r.storage.Retrieve(aor)
+	contacts, err := r.storage.Retrieve(aor, r.clock.Now())
 	if err != nil {
 		return fmt.Errorf("failed to retrieve contacts for unregistration: %w", err)
 	}
@@ -127,16 +136,23 @@ func (r *SIPRegistrar) Unregister(aor string) error {
 
 // FindContacts retrieves all registered contacts for an AOR (implements Registrar interface)
 func (r *SIPRegistrar) FindContacts(aor string) ([]*database.RegistrarContact, error) {
-	return r.storage.Retrieve(aor)
+	return r.storage.Retrieve(aor, r.clock.Now())
 }
 
 // CleanupExpired removes expired registrations (implements Registrar interface)
 func (r *SIPRegistrar) CleanupExpired() {
-	if err := r.storage.CleanupExpired(); err != nil {
+	if err := r.storage.CleanupExpired(r.clock.Now()); err != nil {
 		fmt.Printf("Warning: failed to cleanup expired contacts: %v\n", err)
 	}
 }
 
+// FlowFailed removes the SIP Outbound bindings registered over a dead flow (implements Registrar interface)
+func (r *SIPRegistrar) FlowFailed(transport string, addr net.Addr) {
+	if _, err := r.storage.DeleteFlow(strings.ToUpper(transport), addr.String()); err != nil {
+		fmt.Printf("Warning: failed to remove contacts of failed flow %s %s: %v\n", transport, addr, err)
+	}
+}
+
 // handleRegistrationQuery handles REGISTER requests without Contact headers (queries)
 func (r *SIPRegistrar) handleRegistrationQuery(request *parser.SIPMessage, aor string) (*parser.SIPMessage, error) {
 	// Get current contacts
@@ -150,11 +166,11 @@ func (r *SIPRegistrar) handleRegistrationQuery(request *parser.SIPMessage, aor s
 
 	// Add Contact headers for each registered contact
 	for _, contact := range contacts {
-		expires := int(time.Until(contact.Expires).Seconds())
+		expires := int(contact.Expires.Sub(r.clock.Now()).Seconds())
 		if expires < 0 {
 			expires = 0
 		}
-		contactValue := fmt.Sprintf("<%s>;expires=%d", contact.URI, expires)
+		contactValue := fmt.Sprintf("<%s>;expires=%d%s", contact.URI, expires, outboundContactParams(contact))
 		response.AddHeader(parser.HeaderContact, contactValue)
 	}
 
@@ -188,6 +204,7 @@ func (r *SIPRegistrar) handleRegistrationUpdate(request *parser.SIPMessage, aor
 
 	// Process each Contact header
 	var processedContacts []string
+	var registered []*database.RegistrarContact
 	for _, contactHeader := range contactHeaders {
 		contactURI, contactExpires, err := r.parseContactHeader(contactHeader, expires)
 		if err != nil {
@@ -214,6 +231,8 @@ func (r *SIPRegistrar) handleRegistrationUpdate(request *parser.SIPMessage, aor
 			CallID: callID,
 			CSeq:   cseq,
 		}
+		SetObservedFlow(contact, request)
+		SetOutboundParams(contact, contactHeader, request)
 
 		// Register or deregister the contact
 		if err := r.Register(contact, contactExpires); err != nil {
@@ -229,7 +248,8 @@ func (r *SIPRegistrar) handleRegistrationUpdate(request *parser.SIPMessage, aor
 			if contactExpires < r.minExpires {
 				contactExpires = r.minExpires
 			}
-			processedContacts = append(processedContacts, fmt.Sprintf("<%s>;expires=%d", contactURI, contactExpires))
+			processedContacts = append(processedContacts, fmt.Sprintf("<%s>;expires=%d%s", contactURI, contactExpires, outboundContactParams(contact)))
+			registered = append(registered, contact)
 		}
 	}
 
@@ -240,92 +260,53 @@ func (r *SIPRegistrar) handleRegistrationUpdate(request *parser.SIPMessage, aor
 	for _, contact := range processedContacts {
 		response.AddHeader(parser.HeaderContact, contact)
 	}
+	SetOutboundRequire(response, registered)
 
 	return response, nil
 }
 
 // extractAOR extracts the Address of Record from a To header
 func (r *SIPRegistrar) extractAOR(toHeader string) (string, error) {
-	// Simple extraction - look for URI between < and > or the entire value
-	toHeader = strings.TrimSpace(toHeader)
-	
-	// Remove display name and parameters
-	if idx := strings.Index(toHeader, "<"); idx >= 0 {
-		end := strings.Index(toHeader[idx:], ">")
-		if end < 0 {
-			return "", fmt.Errorf("malformed To header: missing closing >")
-		}
-		return toHeader[idx+1 : idx+end], nil
+	to, err := parser.ParseNameAddr(toHeader)
+	if err != nil {
+		return "", fmt.Errorf("malformed To header: %w", err)
 	}
 	
-	// No angle brackets, take everything before first semicolon (parameters)
-	if idx := strings.Index(toHeader, ";"); idx >= 0 {
-		return strings.TrimSpace(toHeader[:idx]), nil
+	uri, err := parser.ParseURI(to.URI)
+	if err != nil {
+		return "", fmt.Errorf("malformed To header: %w", err)
 	}
-	
-	return toHeader, nil
+	return uri.AOR(), nil
 }
 
 // parseContactHeader parses a Contact header and returns URI and expires value
 func (r *SIPRegistrar) parseContactHeader(contactHeader string, defaultExpires int) (string, int, error) {
-	contactHeader = strings.TrimSpace(contactHeader)
+	contact, err := parser.ParseContact(contactHeader)
+	if err != nil {
+		return "", 0, err
+	}
 	
 	// Handle wildcard
-	if contactHeader == "*" {
+	if contact.Wildcard {
 		return "*", 0, nil
 	}
 	
-	// Extract URI
-	var uri string
-	if idx := strings.Index(contactHeader, "<"); idx >= 0 {
-		end := strings.Index(contactHeader[idx:], ">")
-		if end < 0 {
-			return "", 0, fmt.Errorf("malformed Contact header: missing closing >")
-		}
-		uri = contactHeader[idx+1 : idx+end]
-		contactHeader = contactHeader[idx+end+1:] // Keep parameters part
-	} else {
-		// No angle brackets, split at first semicolon
-		parts := strings.SplitN(contactHeader, ";", 2)
-		uri = strings.TrimSpace(parts[0])
-		if len(parts) > 1 {
-			contactHeader = ";" + parts[1]
-		} else {
-			contactHeader = ""
-		}
-	}
-	
-	// Parse expires parameter
 	expires := defaultExpires
-	if strings.Contains(contactHeader, "expires=") {
-		// Simple parameter parsing
-		params := strings.Split(contactHeader, ";")
-		for _, param := range params {
-			param = strings.TrimSpace(param)
-			if strings.HasPrefix(param, "expires=") {
-				if val, err := strconv.Atoi(param[8:]); err == nil {
-					expires = val
-				}
-			}
-		}
+	if value, ok := contact.Expires(); ok {
+		expires = value
 	}
 	
-	return uri, expires, nil
+	return contact.URI, expires, nil
 }
 
 // parseCSeq parses a CSeq header and returns the sequence number
 func (r *SIPRegistrar) parseCSeq(cseqHeader string) (uint32, error) {
-	parts := strings.Fields(cseqHeader)
-	if len(parts) < 2 {
-		return 0, fmt.Errorf("invalid CSeq header format")
-	}
-	
-	cseq, err := strconv.ParseUint(parts[0], 10, 32)
+	cseq, err := parser.ParseCSeq(cseqHeader)
 	if err != nil {
-		return 0, fmt.Errorf("invalid CSeq number: %w", err)
+		return 0, err
 	}
 	
-	return uint32(cseq), nil
+	return cseq.Seq, nil
 }
 
 // createSuccessResponse creates a 200 OK response for a REGISTER request
@@ -339,7 +320,7 @@ func (r *SIPRegistrar) createSuccessResponse(request *parser.SIPMessage) *parser
 	response.SetHeader(parser.HeaderServer, "SIP-Server/1.0")
 	
 	// Add Date header
-	response.SetHeader("Date", time.Now().UTC().Format(time.RFC1123))
+	response.SetHeader("Date", r.clock.Now().UTC().Format(time.RFC1123))
 	
 	return response
 }
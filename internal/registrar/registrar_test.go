@@ -2,11 +2,13 @@ package registrar
 
 import (
 	"fmt"
+	"net"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/zurustar/xylitol2/internal/auth"
+	"github.com/zurustar/xylitol2/internal/clock"
 	"github.com/zurustar/xylitol2/internal/database"
 	"github.com/zurustar/xylitol2/internal/parser"
 )
@@ -39,12 +41,11 @@ func (m *mockRegistrationDB) Store(contact *database.RegistrarContact) error {
 	return nil
 }
 
-func (m *mockRegistrationDB) Retrieve(aor string) ([]*database.RegistrarContact, error) {
+func (m *mockRegistrationDB) Retrieve(aor string, now time.Time) ([]*database.RegistrarContact, error) {
 	contacts := m.contacts[aor]
 	var validContacts []*database.RegistrarContact
 	
 	// Filter out expired contacts
-	now := time.Now().UTC()
 	for _, contact := range contacts {
 		if contact.Expires.After(now) {
 			validContacts = append(validContacts, contact)
@@ -66,8 +67,23 @@ func (m *mockRegistrationDB) Delete(aor string, contactURI string) error {
 	return fmt.Errorf("contact not found")
 }
 
-func (m *mockRegistrationDB) CleanupExpired() error {
-	now := time.Now().UTC()
+func (m *mockRegistrationDB) DeleteFlow(transport, source string) (int, error) {
+	removed := 0
+	for aor, contacts := range m.contacts {
+		var remaining []*database.RegistrarContact
+		for _, contact := range contacts {
+			if contact.RegID > 0 && contact.Transport == transport && contact.Source == source {
+				removed++
+				continue
+			}
+			remaining = append(remaining, contact)
+		}
+		m.contacts[aor] = remaining
+	}
+	return removed, nil
+}
+
+func (m *mockRegistrationDB) CleanupExpired(now time.Time) error {
 	for aor, contacts := range m.contacts {
 		var validContacts []*database.RegistrarContact
 		for _, contact := range contacts {
@@ -191,6 +207,45 @@ func TestSIPRegistrar_ProcessRegisterRequest(t *testing.T) {
 		}
 	})
 	
+	t.Run("WebSocket Registration Records Source", func(t *testing.T) {
+		request := createTestRegisterRequest("sip:erin@example.com", "sip:erin@df7jal23ls0d.invalid;transport=ws", 3600)
+		request.Transport = "WS"
+		request.Source = &net.TCPAddr{IP: net.IPv4(192, 168, 1, 103), Port: 49152}
+		
+		if _, err := registrar.ProcessRegisterRequest(request); err != nil {
+			t.Fatalf("Failed to process REGISTER request: %v", err)
+		}
+		
+		contacts, err := registrar.FindContacts("sip:erin@example.com")
+		if err != nil || len(contacts) != 1 {
+			t.Fatalf("Expected 1 contact, got %d (%v)", len(contacts), err)
+		}
+		if contacts[0].Source != "192.168.1.103:49152" {
+			t.Errorf("Expected source 192.168.1.103:49152, got %s", contacts[0].Source)
+		}
+	})
+	
+	t.Run("NAT Registration Records Observed Flow", func(t *testing.T) {
+		request := createTestRegisterRequest("sip:frank@example.com", "sip:frank@10.0.0.7:5060", 3600)
+		request.Transport = "udp"
+		request.Source = &net.UDPAddr{IP: net.IPv4(203, 0, 113, 5), Port: 40000}
+		
+		if _, err := registrar.ProcessRegisterRequest(request); err != nil {
+			t.Fatalf("Failed to process REGISTER request: %v", err)
+		}
+		
+		contacts, err := registrar.FindContacts("sip:frank@example.com")
+		if err != nil || len(contacts) != 1 {
+			t.Fatalf("Expected 1 contact, got %d (%v)", len(contacts), err)
+		}
+		if contacts[0].URI != "sip:frank@10.0.0.7:5060" {
+			t.Errorf("Expected registered Contact to be kept, got %s", contacts[0].URI)
+		}
+		if !contacts[0].BehindNAT || contacts[0].Source != "203.0.113.5:40000" || contacts[0].Transport != "UDP" {
+			t.Errorf("Expected NAT flow UDP 203.0.113.5:40000, got %+v", contacts[0])
+		}
+	})
+	
 	t.Run("Registration Query", func(t *testing.T) {
 		// First register a contact
 		request1 := createTestRegisterRequest("sip:bob@example.com", "sip:bob@192.168.1.101:5060", 3600)
@@ -590,6 +645,46 @@ func TestSIPRegistrar_CleanupExpired(t *testing.T) {
 	}
 }
 
+func TestSIPRegistrar_ExpiryVirtualTime(t *testing.T) {
+	storage := newMockRegistrationDB()
+	registrar := NewSIPRegistrar(storage, newMockMessageAuthenticator(true, false), &mockUserManager{}, "example.com")
+	c := clock.NewFake(time.Now())
+	registrar.SetClock(c)
+
+	aor := "sip:alice@example.com"
+	contact := &database.RegistrarContact{AOR: aor, URI: "sip:alice@192.168.1.100:5060", CallID: "test-call-id", CSeq: 1}
+	if err := registrar.Register(contact, registrar.GetMinExpires()); err != nil {
+		t.Fatalf("Failed to register contact: %v", err)
+	}
+
+	// A query reports the time left on the binding
+	c.Advance(20 * time.Second)
+	request := parser.NewRequestMessage(parser.MethodREGISTER, "sip:example.com")
+	response, err := registrar.handleRegistrationQuery(request, aor)
+	if err != nil {
+		t.Fatalf("Failed to query registrations: %v", err)
+	}
+	if got := response.GetHeader(parser.HeaderContact); got != "<sip:alice@192.168.1.100:5060>;expires=40" {
+		t.Errorf("Expected 40 seconds left on the binding, got %q", got)
+	}
+
+	c.Advance(39 * time.Second)
+	registrar.CleanupExpired()
+	if contacts, _ := registrar.FindContacts(aor); len(contacts) != 1 {
+		t.Fatalf("Expected the binding to be registered until it expires, got %d contacts", len(contacts))
+	}
+
+	// The binding lapses after exactly min expires and cleanup removes it
+	c.Advance(time.Second)
+	if contacts, _ := registrar.FindContacts(aor); len(contacts) != 0 {
+		t.Errorf("Expected no contacts once the binding expired, got %d", len(contacts))
+	}
+	registrar.CleanupExpired()
+	if len(storage.contacts[aor]) != 0 {
+		t.Errorf("Expected cleanup to remove the expired binding, %d left", len(storage.contacts[aor]))
+	}
+}
+
 func TestSIPRegistrar_HeaderParsing(t *testing.T) {
 	storage := newMockRegistrationDB()
 	authenticator := newMockMessageAuthenticator(true, false)
@@ -641,6 +736,21 @@ func TestSIPRegistrar_HeaderParsing(t *testing.T) {
 		}
 	})
 	
+	t.Run("Parse Contact Header with IPv6 Reference", func(t *testing.T) {
+		uri, expires, err := registrar.parseContactHeader("sip:dave@[2001:db8::1]:5060;expires=900", 3600)
+		if err != nil {
+			t.Fatalf("Failed to parse contact header: %v", err)
+		}
+		
+		if uri != "sip:dave@[2001:db8::1]:5060" {
+			t.Errorf("Expected URI sip:dave@[2001:db8::1]:5060, got %s", uri)
+		}
+		
+		if expires != 900 {
+			t.Errorf("Expected expires 900, got %d", expires)
+		}
+	})
+	
 	t.Run("Parse Wildcard Contact", func(t *testing.T) {
 		uri, expires, err := registrar.parseContactHeader("*", 3600)
 		if err != nil {
@@ -655,4 +765,122 @@ func TestSIPRegistrar_HeaderParsing(t *testing.T) {
 			t.Errorf("Expected expires 0 for wildcard, got %d", expires)
 		}
 	})
-}
\ No newline at end of file
+}
+
+func TestSIPRegistrar_ExtractAOR(t *testing.T) {
+	storage := newMockRegistrationDB()
+	authenticator := newMockMessageAuthenticator(true, false)
+	userManager := &mockUserManager{}
+	registrar := NewSIPRegistrar(storage, authenticator, userManager, "example.com")
+	
+	tests := []struct {
+		toHeader string
+		expected string
+	}{
+		{"<sip:alice@example.com>", "sip:alice@example.com"},
+		{"\"Alice\" <sip:alice@Example.COM;transport=tcp>;tag=123", "sip:alice@example.com"},
+		{"sip:%61lice@example.com;tag=456", "sip:alice@example.com"},
+		{"<sip:alice@[2001:DB8::1]:5060>", "sip:alice@[2001:db8::1]:5060"},
+	}
+	
+	for _, tt := range tests {
+		aor, err := registrar.extractAOR(tt.toHeader)
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", tt.toHeader, err)
+			continue
+		}
+		if aor != tt.expected {
+			t.Errorf("For %q expected AOR %q, got %q", tt.toHeader, tt.expected, aor)
+		}
+	}
+	
+	for _, toHeader := range []string{"<sip:alice@example.com", "<http://example.com>"} {
+		if _, err := registrar.extractAOR(toHeader); err == nil {
+			t.Errorf("Expected error for %q", toHeader)
+		}
+	}
+}
+
+func TestBehindNAT(t *testing.T) {
+	tests := []struct {
+		name       string
+		contactURI string
+		source     string
+		transport  string
+		expected   bool
+	}{
+		{"same address", "sip:alice@192.0.2.10:5060", "192.0.2.10:5060", "UDP", false},
+		{"default port", "sip:alice@192.0.2.10", "192.0.2.10:5060", "UDP", false},
+		{"private host", "sip:alice@10.0.0.7:5060", "203.0.113.5:5060", "UDP", true},
+		{"remapped port", "sip:alice@192.0.2.10:5060", "192.0.2.10:40000", "UDP", true},
+		{"TCP ephemeral port", "sip:alice@192.0.2.10:5060;transport=tcp", "192.0.2.10:40000", "TCP", false},
+		{"hostname", "sip:alice@pc33.atlanta.com", "192.0.2.10:5060", "UDP", false},
+		{"hostname over TCP", "sip:alice@pc33.atlanta.com;transport=tcp", "192.0.2.10:40000", "TCP", false},
+		{"IPv6", "sip:alice@[2001:db8::1]:5060", "[2001:db8::1]:5060", "UDP", false},
+		{"invalid URI", "not a uri", "192.0.2.10:5060", "UDP", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := behindNAT(tt.contactURI, tt.source, tt.transport); got != tt.expected {
+				t.Errorf("behindNAT(%s, %s, %s) = %v, expected %v", tt.contactURI, tt.source, tt.transport, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSIPRegistrar_Outbound(t *testing.T) {
+	storage := database.NewRegistrationDB(database.NewMemoryManager())
+	registrar := NewSIPRegistrar(storage, newMockMessageAuthenticator(true, false), &mockUserManager{}, "example.com")
+	aor := "sip:grace@example.com"
+	instance := "urn:uuid:00000000-0000-1000-8000-AABBCCDDEEFF"
+
+	register := func(regID int, source *net.TCPAddr) *parser.SIPMessage {
+		request := createTestRegisterRequest(aor, "", -1)
+		request.SetHeader(parser.HeaderContact, fmt.Sprintf(`<sip:grace@10.0.0.7:5060;transport=tcp;ob>;+sip.instance="<%s>";reg-id=%d`, instance, regID))
+		request.Transport = "tcp"
+		request.Source = source
+		response, err := registrar.ProcessRegisterRequest(request)
+		if err != nil {
+			t.Fatalf("Failed to process REGISTER request: %v", err)
+		}
+		return response
+	}
+
+	first := &net.TCPAddr{IP: net.IPv4(203, 0, 113, 5), Port: 40000}
+	second := &net.TCPAddr{IP: net.IPv4(198, 51, 100, 7), Port: 50000}
+	response := register(1, first)
+	register(2, second)
+
+	if response.GetHeader(parser.HeaderRequire) != OptionTagOutbound {
+		t.Errorf("Expected Require: outbound, got %q", response.GetHeader(parser.HeaderRequire))
+	}
+	if contact := response.GetHeader(parser.HeaderContact); !strings.Contains(contact, "reg-id=1") {
+		t.Errorf("Expected reg-id in response Contact, got %s", contact)
+	}
+
+	contacts, err := registrar.FindContacts(aor)
+	if err != nil || len(contacts) != 2 {
+		t.Fatalf("Expected a binding per flow, got %d (%v)", len(contacts), err)
+	}
+	if contacts[0].InstanceID != instance || contacts[0].RegID != 1 || contacts[0].Source != first.String() {
+		t.Errorf("Unexpected first flow: %+v", contacts[0])
+	}
+
+	registrar.FlowFailed("TCP", first)
+	contacts, err = registrar.FindContacts(aor)
+	if err != nil || len(contacts) != 1 || contacts[0].RegID != 2 {
+		t.Fatalf("Expected only the second flow to remain, got %+v (%v)", contacts, err)
+	}
+
+	// Without an instance, reg-id means nothing and the binding is ordinary
+	request := createTestRegisterRequest(aor, "", -1)
+	request.SetHeader(parser.HeaderContact, "<sip:grace@192.0.2.10:5060>;reg-id=1")
+	response, err = registrar.ProcessRegisterRequest(request)
+	if err != nil {
+		t.Fatalf("Failed to process REGISTER request: %v", err)
+	}
+	if response.GetHeader(parser.HeaderRequire) != "" {
+		t.Errorf("Expected no Require header, got %q", response.GetHeader(parser.HeaderRequire))
+	}
+}
@@ -1,6 +1,10 @@
 package registrar
 
 import (
+	"net"
+	"strings"
+
+	"github.com/zurustar/xylitol2/internal/clock"
 	"github.com/zurustar/xylitol2/internal/database"
 	"github.com/zurustar/xylitol2/internal/logging"
 )
@@ -9,6 +13,7 @@ import (
 type SimpleRegistrar struct {
 	storage database.RegistrationDB
 	logger  logging.Logger
+	clock   clock.Clock
 }
 
 // NewRegistrar creates a new simple registrar
@@ -16,9 +21,15 @@ func NewRegistrar(storage database.RegistrationDB, logger logging.Logger) Regist
 	return &SimpleRegistrar{
 		storage: storage,
 		logger:  logger,
+		clock:   clock.Real(),
 	}
 }
 
+// SetClock sets the clock registrations expire by
+func (r *SimpleRegistrar) SetClock(c clock.Clock) {
+	r.clock = c
+}
+
 // Register registers a contact
 func (r *SimpleRegistrar) Register(contact *database.RegistrarContact, expires int) error {
 	return r.storage.Store(contact)
@@ -27,7 +38,7 @@ func (r *SimpleRegistrar) Register(contact *database.RegistrarContact, expires i
 // Unregister removes all contacts for an AOR
 func (r *SimpleRegistrar) Unregister(aor string) error {
 	// Get all contacts for the AOR
-	contacts, err := r.storage.Retrieve(aor)
+	contacts, err := r.storage.Retrieve(aor, r.clock.Now())
 	if err != nil {
 		return err
 	}
@@ -47,12 +58,30 @@ func (r *SimpleRegistrar) Unregister(aor string) error {
 
 // FindContacts retrieves all registered contacts for an AOR
 func (r *SimpleRegistrar) FindContacts(aor string) ([]*database.RegistrarContact, error) {
-	return r.storage.Retrieve(aor)
+	return r.storage.Retrieve(aor, r.clock.Now())
 }
 
 // CleanupExpired removes expired registrations
 func (r *SimpleRegistrar) CleanupExpired() {
-	if err := r.storage.CleanupExpired(); err != nil {
+	if err := r.storage.CleanupExpired(r.clock.Now()); err != nil {
 		r.logger.Error("Failed to cleanup expired contacts", logging.Field{Key: "error", Value: err})
 	}
-}
\ No newline at end of file
+}
+
+// FlowFailed removes the SIP Outbound bindings registered over a dead flow
+func (r *SimpleRegistrar) FlowFailed(transport string, addr net.Addr) {
+	removed, err := r.storage.DeleteFlow(strings.ToUpper(transport), addr.String())
+	if err != nil {
+		r.logger.Error("Failed to remove contacts of failed flow",
+			logging.Field{Key: "transport", Value: transport},
+			logging.Field{Key: "source", Value: addr.String()},
+			logging.Field{Key: "error", Value: err})
+		return
+	}
+	if removed > 0 {
+		r.logger.Info("Removed contacts of failed flow",
+			logging.Field{Key: "transport", Value: transport},
+			logging.Field{Key: "source", Value: addr.String()},
+			logging.Field{Key: "contacts", Value: removed})
+	}
+}
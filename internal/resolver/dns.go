@@ -0,0 +1,301 @@
+package resolver
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	resolvConfPath   = "/etc/resolv.conf"
+	dnsQueryTimeout  = 5 * time.Second
+	dnsTypeNAPTR     = 35
+	dnsClassINET     = 1
+	dnsRcodeNXDOMAIN = 3
+)
+
+// systemDNS looks SRV and address records up with the Go resolver. The standard
+// library has no NAPTR lookup, so NAPTR queries are sent to the name servers in
+// resolv.conf directly.
+type systemDNS struct {
+	resolver *net.Resolver
+	servers  []string
+}
+
+// SystemDNS returns a DNS that queries the system's name servers
+func SystemDNS() DNS {
+	return &systemDNS{
+		resolver: net.DefaultResolver,
+		servers:  readNameServers(resolvConfPath),
+	}
+}
+
+func (d *systemDNS) LookupSRV(ctx context.Context, name string) ([]*net.SRV, error) {
+	_, records, err := d.resolver.LookupSRV(ctx, "", "", name)
+	if isNotFound(err) {
+		return nil, nil
+	}
+	return records, err
+}
+
+func (d *systemDNS) LookupHost(ctx context.Context, host string) ([]net.IP, error) {
+	addrs, err := d.resolver.LookupIPAddr(ctx, host)
+	if isNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ips := make([]net.IP, len(addrs))
+	for i, addr := range addrs {
+		ips[i] = addr.IP
+	}
+	return ips, nil
+}
+
+// LookupNAPTR asks each name server in turn, over UDP and then over TCP if the
+// answer was truncated
+func (d *systemDNS) LookupNAPTR(ctx context.Context, name string) ([]*NAPTR, error) {
+	id := uint16(rand.Intn(1 << 16))
+	query, err := naptrQuery(id, name)
+	if err != nil {
+		return nil, err
+	}
+
+	err = fmt.Errorf("no name servers configured")
+	for _, server := range d.servers {
+		var records []*NAPTR
+		var truncated bool
+		records, truncated, err = d.exchange(ctx, "udp", server, id, query)
+		if err == nil && truncated {
+			records, _, err = d.exchange(ctx, "tcp", server, id, query)
+		}
+		if err == nil {
+			return records, nil
+		}
+	}
+	return nil, err
+}
+
+// exchange sends query to server over network and parses the answer
+func (d *systemDNS) exchange(ctx context.Context, network, server string, id uint16, query []byte) ([]*NAPTR, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, dnsQueryTimeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, server)
+	if err != nil {
+		return nil, false, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if network == "udp" {
+		if _, err := conn.Write(query); err != nil {
+			return nil, false, err
+		}
+		response := make([]byte, 4096)
+		n, err := conn.Read(response)
+		if err != nil {
+			return nil, false, err
+		}
+		return parseNAPTRResponse(id, response[:n])
+	}
+
+	// DNS over TCP prefixes each message with its length
+	framed := make([]byte, 2, 2+len(query))
+	binary.BigEndian.PutUint16(framed, uint16(len(query)))
+	if _, err := conn.Write(append(framed, query...)); err != nil {
+		return nil, false, err
+	}
+	length := make([]byte, 2)
+	if _, err := io.ReadFull(conn, length); err != nil {
+		return nil, false, err
+	}
+	response := make([]byte, binary.BigEndian.Uint16(length))
+	if _, err := io.ReadFull(conn, response); err != nil {
+		return nil, false, err
+	}
+	return parseNAPTRResponse(id, response)
+}
+
+// naptrQuery builds a recursive query for the NAPTR records of name
+func naptrQuery(id uint16, name string) ([]byte, error) {
+	query := make([]byte, 12, 512)
+	binary.BigEndian.PutUint16(query[0:2], id)
+	query[2] = 0x01 // Recursion desired
+	binary.BigEndian.PutUint16(query[4:6], 1)
+
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return nil, fmt.Errorf("invalid domain name: %q", name)
+		}
+		query = append(query, byte(len(label)))
+		query = append(query, label...)
+	}
+	query = append(query, 0)
+	query = binary.BigEndian.AppendUint16(query, dnsTypeNAPTR)
+	query = binary.BigEndian.AppendUint16(query, dnsClassINET)
+	return query, nil
+}
+
+// parseNAPTRResponse returns the NAPTR records answering the query with id and
+// whether the response was truncated. A name that does not exist has no records.
+func parseNAPTRResponse(id uint16, msg []byte) ([]*NAPTR, bool, error) {
+	if len(msg) < 12 {
+		return nil, false, fmt.Errorf("DNS response too short")
+	}
+	if binary.BigEndian.Uint16(msg[0:2]) != id || msg[2]&0x80 == 0 {
+		return nil, false, fmt.Errorf("DNS response does not match query")
+	}
+	truncated := msg[2]&0x02 != 0
+	switch rcode := msg[3] & 0x0F; rcode {
+	case 0:
+	case dnsRcodeNXDOMAIN:
+		return nil, truncated, nil
+	default:
+		return nil, truncated, fmt.Errorf("DNS server returned rcode %d", rcode)
+	}
+
+	offset := 12
+	for i := 0; i < int(binary.BigEndian.Uint16(msg[4:6])); i++ {
+		_, next, err := readName(msg, offset)
+		if err != nil {
+			return nil, truncated, err
+		}
+		offset = next + 4 // Type and class
+	}
+
+	var records []*NAPTR
+	for i := 0; i < int(binary.BigEndian.Uint16(msg[6:8])); i++ {
+		_, next, err := readName(msg, offset)
+		if err != nil {
+			return nil, truncated, err
+		}
+		if next+10 > len(msg) {
+			return nil, truncated, errTruncatedMessage
+		}
+		rrType := binary.BigEndian.Uint16(msg[next : next+2])
+		rdLength := int(binary.BigEndian.Uint16(msg[next+8 : next+10]))
+		rdata := next + 10
+		offset = rdata + rdLength
+		if offset > len(msg) {
+			return nil, truncated, errTruncatedMessage
+		}
+		if rrType != dnsTypeNAPTR {
+			continue // e.g. the CNAME the name is an alias through
+		}
+
+		record, err := parseNAPTR(msg, rdata, offset)
+		if err != nil {
+			return nil, truncated, err
+		}
+		records = append(records, record)
+	}
+	return records, truncated, nil
+}
+
+var errTruncatedMessage = errors.New("DNS message truncated")
+
+// parseNAPTR parses the NAPTR RDATA in msg[start:end] (RFC 3403 section 4.1)
+func parseNAPTR(msg []byte, start, end int) (*NAPTR, error) {
+	if start+4 > end {
+		return nil, errTruncatedMessage
+	}
+	record := &NAPTR{
+		Order:      binary.BigEndian.Uint16(msg[start : start+2]),
+		Preference: binary.BigEndian.Uint16(msg[start+2 : start+4]),
+	}
+
+	offset := start + 4
+	fields := make([]string, 3)
+	for i := range fields {
+		if offset >= end || offset+1+int(msg[offset]) > end {
+			return nil, errTruncatedMessage
+		}
+		length := int(msg[offset])
+		fields[i] = string(msg[offset+1 : offset+1+length])
+		offset += 1 + length
+	}
+	record.Flags, record.Service, record.Regexp = fields[0], fields[1], fields[2]
+
+	replacement, _, err := readName(msg[:end], offset)
+	if err != nil {
+		return nil, err
+	}
+	record.Replacement = replacement
+	return record, nil
+}
+
+// readName reads the possibly compressed domain name at offset and returns it
+// with the offset following it
+func readName(msg []byte, offset int) (string, int, error) {
+	var labels []string
+	next := -1
+	for jumps := 0; ; {
+		if offset >= len(msg) {
+			return "", 0, errTruncatedMessage
+		}
+		length := int(msg[offset])
+		switch {
+		case length == 0:
+			if next == -1 {
+				next = offset + 1
+			}
+			return strings.Join(labels, "."), next, nil
+		case length&0xC0 == 0xC0:
+			if offset+1 >= len(msg) {
+				return "", 0, errTruncatedMessage
+			}
+			if jumps++; jumps > 16 {
+				return "", 0, fmt.Errorf("DNS name compression loop")
+			}
+			if next == -1 {
+				next = offset + 2
+			}
+			offset = int(binary.BigEndian.Uint16(msg[offset:offset+2]) & 0x3FFF)
+		default:
+			if offset+1+length > len(msg) {
+				return "", 0, errTruncatedMessage
+			}
+			labels = append(labels, string(msg[offset+1:offset+1+length]))
+			offset += 1 + length
+		}
+	}
+}
+
+// readNameServers returns the name servers of a resolv.conf file as host:port,
+// or the local resolver if there are none
+func readNameServers(path string) []string {
+	var servers []string
+	if file, err := os.Open(path); err == nil {
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) >= 2 && fields[0] == "nameserver" {
+				servers = append(servers, net.JoinHostPort(fields[1], "53"))
+			}
+		}
+	}
+	if len(servers) == 0 {
+		servers = []string{"127.0.0.1:53"}
+	}
+	return servers
+}
+
+// isNotFound reports whether a lookup failed because the name has no records
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
@@ -0,0 +1,123 @@
+package resolver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// naptrResponse builds a response to query answering with a CNAME record and
+// then NAPTR records whose owner names point back at the question
+func naptrResponse(query []byte, rcode byte, records ...[]byte) []byte {
+	msg := append([]byte(nil), query...)
+	msg[2] |= 0x80 // Response
+	msg[3] |= rcode
+	binary.BigEndian.PutUint16(msg[6:8], uint16(len(records)+1))
+
+	// CNAME answer, which carries no NAPTR data and must be skipped
+	msg = append(msg, 0xC0, 12)
+	msg = binary.BigEndian.AppendUint16(msg, 5)
+	msg = binary.BigEndian.AppendUint16(msg, dnsClassINET)
+	msg = binary.BigEndian.AppendUint32(msg, 300)
+	msg = binary.BigEndian.AppendUint16(msg, 2)
+	msg = append(msg, 0xC0, 12)
+
+	for _, rdata := range records {
+		msg = append(msg, 0xC0, 12)
+		msg = binary.BigEndian.AppendUint16(msg, dnsTypeNAPTR)
+		msg = binary.BigEndian.AppendUint16(msg, dnsClassINET)
+		msg = binary.BigEndian.AppendUint32(msg, 300)
+		msg = binary.BigEndian.AppendUint16(msg, uint16(len(rdata)))
+		msg = append(msg, rdata...)
+	}
+	return msg
+}
+
+// naptrRData encodes NAPTR RDATA whose replacement is label followed by the
+// name of the question (compressed as a pointer to offset 12)
+func naptrRData(order, preference uint16, flags, service, label string) []byte {
+	rdata := binary.BigEndian.AppendUint16(nil, order)
+	rdata = binary.BigEndian.AppendUint16(rdata, preference)
+	for _, field := range []string{flags, service, ""} {
+		rdata = append(rdata, byte(len(field)))
+		rdata = append(rdata, field...)
+	}
+	rdata = append(rdata, byte(len(label)))
+	rdata = append(rdata, label...)
+	return append(rdata, 0xC0, 12)
+}
+
+func TestNAPTRQuery(t *testing.T) {
+	query, err := naptrQuery(0x1234, "example.com.")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []byte{
+		0x12, 0x34, 0x01, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0,
+		0x00, 35, 0x00, 0x01,
+	}
+	if !bytes.Equal(query, expected) {
+		t.Errorf("Expected query %x, got %x", expected, query)
+	}
+
+	if _, err := naptrQuery(1, "bad..example.com"); err == nil {
+		t.Error("Expected error for empty label")
+	}
+}
+
+func TestParseNAPTRResponse(t *testing.T) {
+	query, _ := naptrQuery(7, "example.com")
+
+	msg := naptrResponse(query, 0,
+		naptrRData(10, 20, "s", "SIP+D2T", "_sip._tcp"),
+		naptrRData(20, 10, "S", "SIPS+D2T", "_sips._tcp"),
+	)
+	records, truncated, err := parseNAPTRResponse(7, msg)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if truncated {
+		t.Error("Expected response not to be truncated")
+	}
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+	first := records[0]
+	if first.Order != 10 || first.Preference != 20 || first.Flags != "s" || first.Service != "SIP+D2T" ||
+		first.Replacement != "_sip._tcp.example.com" {
+		t.Errorf("Unexpected first record: %+v", first)
+	}
+	if records[1].Replacement != "_sips._tcp.example.com" {
+		t.Errorf("Unexpected second replacement: %s", records[1].Replacement)
+	}
+
+	// Truncated responses are reported so that the query is retried over TCP
+	truncatedMsg := naptrResponse(query, 0)
+	truncatedMsg[2] |= 0x02
+	if _, truncated, err := parseNAPTRResponse(7, truncatedMsg); err != nil || !truncated {
+		t.Errorf("Expected truncated response, got truncated=%v err=%v", truncated, err)
+	}
+
+	// A name that does not exist has no records
+	if records, _, err := parseNAPTRResponse(7, naptrResponse(query, dnsRcodeNXDOMAIN)); err != nil || len(records) != 0 {
+		t.Errorf("Expected no records for NXDOMAIN, got %v (%v)", records, err)
+	}
+
+	if _, _, err := parseNAPTRResponse(8, msg); err == nil {
+		t.Error("Expected error for mismatched ID")
+	}
+	if _, _, err := parseNAPTRResponse(7, naptrResponse(query, 2)); err == nil {
+		t.Error("Expected error for SERVFAIL")
+	}
+	if _, _, err := parseNAPTRResponse(7, msg[:len(msg)-3]); err == nil {
+		t.Error("Expected error for message cut short")
+	}
+}
+
+func TestReadName_CompressionLoop(t *testing.T) {
+	msg := []byte{0xC0, 0x02, 0xC0, 0x00}
+	if _, _, err := readName(msg, 0); err == nil {
+		t.Error("Expected error for compression loop")
+	}
+}
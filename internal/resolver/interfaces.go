@@ -0,0 +1,46 @@
+package resolver
+
+import (
+	"context"
+	"net"
+)
+
+// Target is a transport address a SIP URI resolves to
+type Target struct {
+	Transport string // "udp", "tcp" or "tls"
+	Host      string // Name the address was resolved from, such as the SRV target
+	IP        net.IP
+	Port      int
+}
+
+// Addr returns the address to send to; stream transports use TCP addresses
+func (t Target) Addr() net.Addr {
+	if t.Transport == "udp" {
+		return &net.UDPAddr{IP: t.IP, Port: t.Port}
+	}
+	return &net.TCPAddr{IP: t.IP, Port: t.Port}
+}
+
+// NAPTR is a Naming Authority Pointer record (RFC 3403)
+type NAPTR struct {
+	Order       uint16
+	Preference  uint16
+	Flags       string
+	Service     string
+	Regexp      string
+	Replacement string
+}
+
+// DNS performs the lookups RFC 3263 resolution needs. Names that do not exist
+// yield no records and no error. SystemDNS queries the configured name servers;
+// tests plug in a fixed table.
+type DNS interface {
+	LookupNAPTR(ctx context.Context, name string) ([]*NAPTR, error)
+	LookupSRV(ctx context.Context, name string) ([]*net.SRV, error)
+	LookupHost(ctx context.Context, host string) ([]net.IP, error)
+}
+
+// SIPResolver resolves a SIP or SIPS URI to the ordered list of targets to try
+type SIPResolver interface {
+	Resolve(ctx context.Context, uri string) ([]Target, error)
+}
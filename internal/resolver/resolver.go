@@ -0,0 +1,272 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/zurustar/xylitol2/internal/parser"
+)
+
+// Default ports of SIP (RFC 3261 section 19.1.2)
+const (
+	defaultPort    = 5060
+	defaultTLSPort = 5061
+)
+
+// NAPTR services of SIP transports (RFC 3263 section 4.1)
+var naptrServices = map[string]string{
+	"SIP+D2U":  "udp",
+	"SIP+D2T":  "tcp",
+	"SIPS+D2T": "tls",
+}
+
+// Resolver locates SIP servers as described in RFC 3263. A URI naming an IP
+// address or a port is used as is; otherwise NAPTR records choose the transport
+// and SRV records the servers, falling back to SRV records of each transport
+// and then to the A and AAAA records of the host. The targets are returned in
+// the order they should be tried, so that a client can fail over to the next
+// on a transport error, timeout or 503.
+type Resolver struct {
+	dns  DNS
+	intn func(n int) int
+}
+
+// NewResolver creates a resolver that looks records up in dns
+func NewResolver(dns DNS) *Resolver {
+	return &Resolver{
+		dns:  dns,
+		intn: rand.Intn,
+	}
+}
+
+// Resolve returns the targets for uri in the order they should be tried
+func (r *Resolver) Resolve(ctx context.Context, uri string) ([]Target, error) {
+	parsed, err := parser.ParseURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Scheme == parser.SchemeTel {
+		return nil, fmt.Errorf("unsupported URI scheme: %s", parsed.Scheme)
+	}
+
+	host := parsed.Host
+	if maddr, ok := parsed.Params.Get("maddr"); ok && maddr != "" {
+		host = maddr
+	}
+	secure := parsed.IsSecure()
+
+	transport := parsed.Transport()
+	switch {
+	case secure:
+		transport = "tls" // sips: is always TLS, whatever the transport parameter says
+	case transport == "", transport == "udp", transport == "tcp", transport == "tls":
+	default:
+		return nil, fmt.Errorf("unsupported transport: %s", transport)
+	}
+
+	// A numeric host or an explicit port is contacted directly (section 4.2)
+	ip := net.ParseIP(host)
+	if ip != nil || parsed.Port != 0 {
+		if transport == "" {
+			transport = "udp"
+		}
+		port := parsed.Port
+		if port == 0 {
+			port = portFor(transport)
+		}
+		if ip != nil {
+			return []Target{{Transport: transport, Host: host, IP: ip, Port: port}}, nil
+		}
+		return r.found(r.lookupHost(ctx, transport, host, port))
+	}
+
+	// A transport parameter leaves only the servers to be found
+	if transport != "" {
+		targets, err := r.lookupSRV(ctx, transport, srvName(transport, host))
+		if len(targets) > 0 {
+			return targets, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return r.found(r.lookupHost(ctx, transport, host, portFor(transport)))
+	}
+
+	targets, err := r.lookupNAPTR(ctx, host, secure)
+	if len(targets) > 0 {
+		return targets, nil
+	}
+
+	// Without usable NAPTR records, try the SRV records of each transport in turn
+	transports := []string{"udp", "tcp", "tls"}
+	if secure {
+		transports = []string{"tls"}
+	}
+	for _, transport := range transports {
+		found, srvErr := r.lookupSRV(ctx, transport, srvName(transport, host))
+		targets = append(targets, found...)
+		if srvErr != nil {
+			err = srvErr
+		}
+	}
+	if len(targets) > 0 {
+		return targets, nil
+	}
+
+	transport = "udp"
+	if secure {
+		transport = "tls"
+	}
+	found, hostErr := r.lookupHost(ctx, transport, host, portFor(transport))
+	if len(found) == 0 && hostErr == nil {
+		hostErr = err
+	}
+	return r.found(found, hostErr)
+}
+
+// found turns an empty lookup result into an error
+func (r *Resolver) found(targets []Target, err error) ([]Target, error) {
+	if len(targets) > 0 {
+		return targets, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("no addresses found")
+}
+
+// lookupNAPTR follows the NAPTR records of host that name a SIP transport, in
+// order and preference, to their SRV records (RFC 3263 section 4.1). Only
+// SIPS+D2T is usable for a sips: URI.
+func (r *Resolver) lookupNAPTR(ctx context.Context, host string, secure bool) ([]Target, error) {
+	records, err := r.dns.LookupNAPTR(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("NAPTR lookup of %s failed: %w", host, err)
+	}
+
+	var usable []*NAPTR
+	for _, record := range records {
+		transport, ok := naptrServices[strings.ToUpper(record.Service)]
+		if !ok || !strings.EqualFold(record.Flags, "s") || (secure && transport != "tls") {
+			continue
+		}
+		usable = append(usable, record)
+	}
+	sort.SliceStable(usable, func(i, j int) bool {
+		if usable[i].Order != usable[j].Order {
+			return usable[i].Order < usable[j].Order
+		}
+		return usable[i].Preference < usable[j].Preference
+	})
+
+	var targets []Target
+	for _, record := range usable {
+		found, srvErr := r.lookupSRV(ctx, naptrServices[strings.ToUpper(record.Service)], record.Replacement)
+		targets = append(targets, found...)
+		if srvErr != nil {
+			err = srvErr
+		}
+	}
+	return targets, err
+}
+
+// lookupSRV returns the addresses of the servers in the SRV records of name, by
+// priority and then weighted at random (RFC 2782)
+func (r *Resolver) lookupSRV(ctx context.Context, transport, name string) ([]Target, error) {
+	records, err := r.dns.LookupSRV(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("SRV lookup of %s failed: %w", name, err)
+	}
+
+	var targets []Target
+	for _, record := range r.orderSRV(records) {
+		host := strings.TrimSuffix(record.Target, ".")
+		if host == "" {
+			continue // A target of "." means the service is not available
+		}
+		found, hostErr := r.lookupHost(ctx, transport, host, int(record.Port))
+		targets = append(targets, found...)
+		if hostErr != nil {
+			err = hostErr
+		}
+	}
+	return targets, err
+}
+
+// orderSRV sorts records by priority and orders those of equal priority by a
+// weighted random selection
+func (r *Resolver) orderSRV(records []*net.SRV) []*net.SRV {
+	remaining := append([]*net.SRV(nil), records...)
+	sort.SliceStable(remaining, func(i, j int) bool {
+		return remaining[i].Priority < remaining[j].Priority
+	})
+
+	ordered := make([]*net.SRV, 0, len(remaining))
+	for len(remaining) > 0 {
+		end := 1
+		for end < len(remaining) && remaining[end].Priority == remaining[0].Priority {
+			end++
+		}
+		group := remaining[:end]
+		remaining = remaining[end:]
+
+		// Records of weight 0 go first so that they are only chosen when picked
+		sort.SliceStable(group, func(i, j int) bool {
+			return group[i].Weight == 0 && group[j].Weight != 0
+		})
+		for len(group) > 0 {
+			total := 0
+			for _, record := range group {
+				total += int(record.Weight)
+			}
+			pick := r.intn(total + 1)
+			chosen := 0
+			for sum := 0; chosen < len(group)-1; chosen++ {
+				sum += int(group[chosen].Weight)
+				if sum >= pick {
+					break
+				}
+			}
+			ordered = append(ordered, group[chosen])
+			group = append(group[:chosen:chosen], group[chosen+1:]...)
+		}
+	}
+	return ordered
+}
+
+// lookupHost returns a target for each address of host
+func (r *Resolver) lookupHost(ctx context.Context, transport, host string, port int) ([]Target, error) {
+	ips, err := r.dns.LookupHost(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("address lookup of %s failed: %w", host, err)
+	}
+	targets := make([]Target, 0, len(ips))
+	for _, ip := range ips {
+		targets = append(targets, Target{Transport: transport, Host: host, IP: ip, Port: port})
+	}
+	return targets, nil
+}
+
+// srvName returns the SRV owner name of SIP over transport at domain
+func srvName(transport, domain string) string {
+	switch transport {
+	case "tls":
+		return "_sips._tcp." + domain
+	case "tcp":
+		return "_sip._tcp." + domain
+	default:
+		return "_sip._udp." + domain
+	}
+}
+
+// portFor returns the default port of transport
+func portFor(transport string) int {
+	if transport == "tls" {
+		return defaultTLSPort
+	}
+	return defaultPort
+}
@@ -0,0 +1,198 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+// fakeDNS answers lookups from fixed tables
+type fakeDNS struct {
+	naptr map[string][]*NAPTR
+	srv   map[string][]*net.SRV
+	hosts map[string][]net.IP
+	fail  map[string]bool
+}
+
+func (d *fakeDNS) LookupNAPTR(ctx context.Context, name string) ([]*NAPTR, error) {
+	if d.fail[name] {
+		return nil, errors.New("SERVFAIL")
+	}
+	return d.naptr[name], nil
+}
+
+func (d *fakeDNS) LookupSRV(ctx context.Context, name string) ([]*net.SRV, error) {
+	if d.fail[name] {
+		return nil, errors.New("SERVFAIL")
+	}
+	return d.srv[name], nil
+}
+
+func (d *fakeDNS) LookupHost(ctx context.Context, host string) ([]net.IP, error) {
+	if d.fail[host] {
+		return nil, errors.New("SERVFAIL")
+	}
+	return d.hosts[host], nil
+}
+
+// partnerDNS publishes the records of a partner domain with NAPTR and SRV records
+func partnerDNS() *fakeDNS {
+	return &fakeDNS{
+		naptr: map[string][]*NAPTR{
+			"partner.example": {
+				{Order: 20, Preference: 10, Flags: "s", Service: "SIP+D2U", Replacement: "_sip._udp.partner.example"},
+				{Order: 10, Preference: 10, Flags: "s", Service: "SIP+D2T", Replacement: "_sip._tcp.partner.example"},
+				{Order: 10, Preference: 5, Flags: "s", Service: "SIPS+D2T", Replacement: "_sips._tcp.partner.example"},
+				{Order: 5, Preference: 10, Flags: "s", Service: "E2U+sip", Replacement: "ignored.partner.example"},
+			},
+		},
+		srv: map[string][]*net.SRV{
+			"_sips._tcp.partner.example": {{Target: "tls.partner.example.", Port: 5061, Priority: 10, Weight: 10}},
+			"_sip._tcp.partner.example":  {{Target: "tcp.partner.example.", Port: 5060, Priority: 10, Weight: 10}},
+			"_sip._udp.partner.example": {
+				{Target: "backup.partner.example.", Port: 5080, Priority: 20, Weight: 10},
+				{Target: "udp.partner.example.", Port: 5060, Priority: 10, Weight: 10},
+			},
+		},
+		hosts: map[string][]net.IP{
+			"tls.partner.example":    {net.ParseIP("192.0.2.1")},
+			"tcp.partner.example":    {net.ParseIP("192.0.2.2")},
+			"udp.partner.example":    {net.ParseIP("192.0.2.3")},
+			"backup.partner.example": {net.ParseIP("192.0.2.4"), net.ParseIP("2001:db8::4")},
+		},
+	}
+}
+
+func TestResolver_Resolve(t *testing.T) {
+	dns := partnerDNS()
+	dns.srv["_sip._udp.srvonly.example"] = []*net.SRV{{Target: "udp.partner.example.", Port: 5070, Priority: 1}}
+	dns.srv["_sip._tcp.srvonly.example"] = []*net.SRV{{Target: "tcp.partner.example.", Port: 5070, Priority: 1}}
+	dns.srv["_sip._udp.unavailable.example"] = []*net.SRV{{Target: ".", Port: 0}}
+	dns.hosts["plain.example"] = []net.IP{net.ParseIP("198.51.100.1")}
+	resolver := NewResolver(dns)
+
+	tests := []struct {
+		name     string
+		uri      string
+		expected []string // transport and address of each target in order
+	}{
+		{"numeric host", "sip:bob@203.0.113.1", []string{"udp 203.0.113.1:5060"}},
+		{"numeric IPv6 host with transport", "sip:bob@[2001:db8::1]:5070;transport=tcp", []string{"tcp [2001:db8::1]:5070"}},
+		{"sips numeric host", "sips:bob@203.0.113.1", []string{"tls 203.0.113.1:5061"}},
+		{"explicit port skips SRV", "sip:bob@plain.example:5090", []string{"udp 198.51.100.1:5090"}},
+		{"maddr", "sip:bob@partner.example;maddr=203.0.113.9", []string{"udp 203.0.113.9:5060"}},
+		{"NAPTR order and preference", "sip:bob@partner.example", []string{
+			"tls 192.0.2.1:5061", "tcp 192.0.2.2:5060",
+			"udp 192.0.2.3:5060", "udp 192.0.2.4:5080", "udp [2001:db8::4]:5080",
+		}},
+		{"sips uses only SIPS+D2T", "sips:bob@partner.example", []string{"tls 192.0.2.1:5061"}},
+		{"transport parameter selects SRV", "sip:bob@partner.example;transport=udp", []string{
+			"udp 192.0.2.3:5060", "udp 192.0.2.4:5080", "udp [2001:db8::4]:5080",
+		}},
+		{"SRV of each transport without NAPTR", "sip:bob@srvonly.example", []string{"udp 192.0.2.3:5070", "tcp 192.0.2.2:5070"}},
+		{"address records without SRV", "sip:bob@plain.example", []string{"udp 198.51.100.1:5060"}},
+		{"address records with transport", "sip:bob@plain.example;transport=tcp", []string{"tcp 198.51.100.1:5060"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targets, err := resolver.Resolve(context.Background(), tt.uri)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			var got []string
+			for _, target := range targets {
+				got = append(got, target.Transport+" "+target.Addr().String())
+			}
+			if len(got) != len(tt.expected) {
+				t.Fatalf("Expected %v, got %v", tt.expected, got)
+			}
+			for i := range got {
+				if got[i] != tt.expected[i] {
+					t.Errorf("Expected %v, got %v", tt.expected, got)
+					break
+				}
+			}
+		})
+	}
+
+	for _, uri := range []string{"sip:bob@unavailable.example", "sip:bob@nowhere.example", "tel:+15551234567", "sip:bob@partner.example;transport=sctp"} {
+		if targets, err := resolver.Resolve(context.Background(), uri); err == nil {
+			t.Errorf("Expected error resolving %s, got %v", uri, targets)
+		}
+	}
+}
+
+func TestResolver_TargetAddrType(t *testing.T) {
+	if _, ok := (Target{Transport: "udp", IP: net.ParseIP("192.0.2.1"), Port: 5060}).Addr().(*net.UDPAddr); !ok {
+		t.Error("Expected UDP address for udp target")
+	}
+	if _, ok := (Target{Transport: "tls", IP: net.ParseIP("192.0.2.1"), Port: 5061}).Addr().(*net.TCPAddr); !ok {
+		t.Error("Expected TCP address for tls target")
+	}
+}
+
+func TestResolver_LookupFailure(t *testing.T) {
+	dns := partnerDNS()
+	dns.fail = map[string]bool{"partner.example": true, "udp.partner.example": true}
+	resolver := NewResolver(dns)
+
+	// A failed NAPTR lookup falls back to SRV, and a failed address lookup
+	// skips to the next server
+	targets, err := resolver.Resolve(context.Background(), "sip:bob@partner.example;transport=udp")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(targets) != 2 || targets[0].Host != "backup.partner.example" {
+		t.Errorf("Expected only the backup server, got %+v", targets)
+	}
+
+	targets, err = resolver.Resolve(context.Background(), "sips:bob@partner.example")
+	if err != nil || len(targets) != 1 || targets[0].Host != "tls.partner.example" {
+		t.Errorf("Expected SRV fallback after NAPTR failure, got %+v (%v)", targets, err)
+	}
+}
+
+func TestResolver_OrderSRV(t *testing.T) {
+	records := []*net.SRV{
+		{Target: "c", Priority: 20, Weight: 0},
+		{Target: "a", Priority: 10, Weight: 0},
+		{Target: "b", Priority: 10, Weight: 60},
+		{Target: "d", Priority: 10, Weight: 40},
+	}
+	resolver := NewResolver(&fakeDNS{})
+
+	tests := []struct {
+		name     string
+		picks    []int
+		expected string
+	}{
+		// Running sums within priority 10 are a=0, b=60, d=100
+		{"zero pick chooses zero weight first", []int{0, 0, 0, 0}, "abdc"},
+		{"pick within second weight", []int{30, 0, 0, 0}, "badc"},
+		{"pick within third weight", []int{80, 50, 0, 0}, "dbac"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			picks := tt.picks
+			resolver.intn = func(n int) int {
+				pick := picks[0]
+				picks = picks[1:]
+				if pick >= n {
+					t.Fatalf("Pick %d out of range %d", pick, n)
+				}
+				return pick
+			}
+
+			var got string
+			for _, record := range resolver.orderSRV(records) {
+				got += record.Target
+			}
+			if got != tt.expected {
+				t.Errorf("Expected order %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
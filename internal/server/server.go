@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -23,6 +24,9 @@ import (
 	"github.com/zurustar/xylitol2/internal/webadmin"
 )
 
+// transactionHistorySize is the number of recent transactions the web admin shows
+const transactionHistorySize = 200
+
 // SIPServerImpl implements the Server interface
 type SIPServerImpl struct {
 	config             *config.Config
@@ -30,6 +34,7 @@ type SIPServerImpl struct {
 	transportManager   transport.TransportManager
 	messageParser      parser.MessageParser
 	transactionManager transaction.TransactionManager
+	transactionHistory *transaction.History
 	databaseManager    database.DatabaseManager
 	userManager        database.UserManager
 	registrar          registrar.Registrar
@@ -124,6 +129,9 @@ func (s *SIPServerImpl) Start() error {
 	s.logger.Info("SIP Server started successfully",
 		logging.Field{Key: "udp_port", Value: s.config.Server.UDPPort},
 		logging.Field{Key: "tcp_port", Value: s.config.Server.TCPPort},
+		logging.Field{Key: "listeners", Value: len(s.config.Server.Listeners)},
+		logging.Field{Key: "tls_enabled", Value: s.config.TLS.Enabled},
+		logging.Field{Key: "websocket_enabled", Value: s.config.WebSocket.Enabled},
 	)
 	
 	return nil
@@ -196,25 +204,45 @@ func (s *SIPServerImpl) initializeComponents() error {
 	}
 	s.logger.Info("Logger initialized")
 	
-	// 2. Initialize database
-	s.databaseManager = database.NewSQLiteManager(s.config.Database.Path)
+	if err := s.validateTLSSettings(); err != nil {
+		return err
+	}
 	
-	if err := s.databaseManager.Initialize(); err != nil {
+	// 2. Initialize database
+	if err := s.initializeDatabase(); err != nil {
 		return fmt.Errorf("failed to initialize database: %w", err)
 	}
-	s.logger.Info("Database initialized", logging.Field{Key: "path", Value: s.config.Database.Path})
 	
 	// 3. Initialize user manager
 	s.userManager = database.NewSIPUserManager(s.databaseManager)
 	s.logger.Info("User manager initialized")
 	
 	// 4. Initialize message parser
-	s.messageParser = parser.NewParser()
+	s.messageParser = parser.NewFastParser()
 	s.logger.Info("Message parser initialized")
 	
 	// 5. Initialize transaction manager
-	s.transactionManager = transaction.NewManager(nil) // Will set send function later
-	s.logger.Info("Transaction manager initialized")
+	transactionManager := transaction.NewManager(s.sendTransactionMessage)
+	transactionManager.SetTimers(transaction.Timers{
+		T1: time.Duration(s.config.Timers.T1) * time.Millisecond,
+		T2: time.Duration(s.config.Timers.T2) * time.Millisecond,
+		T4: time.Duration(s.config.Timers.T4) * time.Millisecond,
+		B:  time.Duration(s.config.Timers.TimerB) * time.Millisecond,
+		F:  time.Duration(s.config.Timers.TimerF) * time.Millisecond,
+		H:  time.Duration(s.config.Timers.TimerH) * time.Millisecond,
+	})
+	s.transactionManager = transactionManager
+
+	// Log transaction events and keep the recent transactions for the web admin
+	s.transactionHistory = transaction.NewHistory(transactionHistorySize)
+	transactionManager.AddObserver(transaction.NewLogObserver(s.logger))
+	transactionManager.AddObserver(s.transactionHistory)
+
+	timers := transactionManager.Timers()
+	s.logger.Info("Transaction manager initialized",
+		logging.Field{Key: "t1", Value: timers.T1.String()},
+		logging.Field{Key: "t2", Value: timers.T2.String()},
+		logging.Field{Key: "timer_b", Value: timers.B.String()})
 	
 	// 6. Initialize authentication processor
 	s.authProcessor = auth.NewAuthenticatedMessageProcessor(s.userManager, s.config.Authentication.Realm)
@@ -234,8 +262,12 @@ func (s *SIPServerImpl) initializeComponents() error {
 	)
 	s.logger.Info("Session timer manager initialized")
 	
-	// 9. Initialize proxy engine
-	s.proxyEngine = proxy.NewRequestForwardingEngine(
+	// 9. Initialize transport manager before the proxy engine, which sends through it
+	s.transportManager = transport.NewManager()
+	s.logger.Info("Transport manager initialized")
+	
+	// 10. Initialize proxy engine
+	proxyEngine := proxy.NewRequestForwardingEngine(
 		s.registrar,
 		s.transportManager,
 		s.transactionManager,
@@ -245,10 +277,10 @@ func (s *SIPServerImpl) initializeComponents() error {
 		"localhost",
 		s.config.Server.UDPPort,
 	)
-	
-	// 10. Initialize transport manager first
-	s.transportManager = transport.NewManager()
-	s.logger.Info("Transport manager initialized")
+	if s.config.TLS.Enabled {
+		proxyEngine.SetTLSPort(s.config.TLS.Port)
+	}
+	s.proxyEngine = proxyEngine
 	
 	// 11. Initialize validated handler manager with validation chain
 	validatedManager := handlers.NewValidatedManager()
@@ -276,18 +308,76 @@ func (s *SIPServerImpl) initializeComponents() error {
 	
 	// Register the transport adapter as the message handler
 	s.transportManager.RegisterHandler(s.handlerManager)
-	
+
+	// Drop SIP Outbound registrations when the flow they were made over fails
+	s.transportManager.RegisterFlowHandler(s.registrar)
+
 	s.logger.Info("Transport manager initialized")
 	
 	// 12. Initialize web admin server
-	s.webAdminServer = webadmin.NewServer(s.userManager, nil, nil, s.logger)
+	webAdminServer := webadmin.NewServer(s.userManager, nil, nil, s.logger)
+	if sqliteManager, ok := s.databaseManager.(*database.SQLiteManager); ok {
+		webAdminServer.SetBackupProvider(sqliteManager)
+	}
+	webAdminServer.SetTransactionHistory(s.transactionHistory)
+	s.webAdminServer = webAdminServer
 	s.logger.Info("Web admin server initialized")
 	
 	return nil
 }
 
+// sendTransactionMessage sends messages handed down by the transaction layer,
+// such as responses and their retransmissions, through the transport adapter
+func (s *SIPServerImpl) sendTransactionMessage(msg *parser.SIPMessage) error {
+	adapter, ok := s.handlerManager.(*handlers.TransportAdapter)
+	if !ok {
+		return fmt.Errorf("transport adapter not initialized")
+	}
+	return adapter.SendMessage(msg)
+}
+
 // startTransports starts UDP and TCP transport listeners
 func (s *SIPServerImpl) startTransports() error {
+	s.transportManager.SetProxyProtocol(s.config.Server.ProxyProtocol)
+	
+	// Start the configured listeners, or a UDP and TCP transport on all interfaces
+	if len(s.config.Server.Listeners) > 0 {
+		if err := s.startListeners(); err != nil {
+			return err
+		}
+	} else if err := s.startDefaultTransports(); err != nil {
+		return err
+	}
+
+	// Start TLS transport
+	if s.config.TLS.Enabled {
+		if err := s.transportManager.StartTLS(s.config.TLS.Port, s.tlsConfig()); err != nil {
+			return fmt.Errorf("failed to start TLS transport: %w", err)
+		}
+		s.logger.Info("TLS transport started", logging.Field{Key: "port", Value: s.config.TLS.Port})
+	}
+	
+	// Start WebSocket transports
+	if s.config.WebSocket.Enabled {
+		if s.config.WebSocket.Port > 0 {
+			if err := s.transportManager.StartWebSocket(s.config.WebSocket.Port); err != nil {
+				return fmt.Errorf("failed to start WS transport: %w", err)
+			}
+			s.logger.Info("WS transport started", logging.Field{Key: "port", Value: s.config.WebSocket.Port})
+		}
+		if s.config.WebSocket.SecurePort > 0 {
+			if err := s.transportManager.StartSecureWebSocket(s.config.WebSocket.SecurePort, s.tlsConfig()); err != nil {
+				return fmt.Errorf("failed to start WSS transport: %w", err)
+			}
+			s.logger.Info("WSS transport started", logging.Field{Key: "port", Value: s.config.WebSocket.SecurePort})
+		}
+	}
+	
+	return nil
+}
+
+// startDefaultTransports starts the UDP and TCP transports on all interfaces
+func (s *SIPServerImpl) startDefaultTransports() error {
 	// Start UDP transport
 	if err := s.transportManager.StartUDP(s.config.Server.UDPPort); err != nil {
 		return fmt.Errorf("failed to start UDP transport: %w", err)
@@ -303,6 +393,85 @@ func (s *SIPServerImpl) startTransports() error {
 	return nil
 }
 
+// startListeners starts the listeners bound to individual interfaces
+func (s *SIPServerImpl) startListeners() error {
+	for _, listener := range s.config.Server.Listeners {
+		err := s.transportManager.StartListener(transport.Listener{
+			Address:        listener.Address,
+			Port:           listener.Port,
+			Transport:      listener.Transport,
+			AdvertisedHost: listener.AdvertisedHost,
+			AdvertisedPort: listener.AdvertisedPort,
+			TLS:            s.tlsConfig(),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to start listener on %s:%d: %w", listener.Address, listener.Port, err)
+		}
+		s.logger.Info("SIP listener started",
+			logging.Field{Key: "address", Value: listener.Address},
+			logging.Field{Key: "port", Value: listener.Port},
+			logging.Field{Key: "transport", Value: listener.Transport},
+			logging.Field{Key: "advertised_host", Value: listener.AdvertisedHost},
+		)
+	}
+	return nil
+}
+
+// tlsConfig creates the TLS transport configuration from server config
+func (s *SIPServerImpl) tlsConfig() transport.TLSConfig {
+	return transport.TLSConfig{
+		CertFile:     s.config.TLS.CertFile,
+		KeyFile:      s.config.TLS.KeyFile,
+		CAFile:       s.config.TLS.CAFile,
+		MinVersion:   s.config.TLS.MinVersion,
+		CipherSuites: s.config.TLS.CipherSuites,
+	}
+}
+
+// validateTLSSettings checks the configured TLS version and cipher suites
+// before any listener serves the certificate
+func (s *SIPServerImpl) validateTLSSettings() error {
+	if !s.usesCertificates() {
+		return nil
+	}
+	if _, err := transport.ParseTLSVersion(s.config.TLS.MinVersion); err != nil {
+		return fmt.Errorf("invalid TLS min version: %w", err)
+	}
+	if _, err := transport.ParseCipherSuites(s.config.TLS.CipherSuites); err != nil {
+		return fmt.Errorf("invalid TLS cipher suites: %w", err)
+	}
+	return nil
+}
+
+// usesCertificates reports whether a TLS or WSS listener serves the configured certificate
+func (s *SIPServerImpl) usesCertificates() bool {
+	for _, listener := range s.config.Server.Listeners {
+		if strings.EqualFold(listener.Transport, "tls") || strings.EqualFold(listener.Transport, "wss") {
+			return true
+		}
+	}
+	return s.config.TLS.Enabled || (s.config.WebSocket.Enabled && s.config.WebSocket.SecurePort > 0)
+}
+
+// ReloadCertificates re-reads the TLS certificate files. Established TLS
+// connections keep running; new connections use the new certificate.
+func (s *SIPServerImpl) ReloadCertificates() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	if !s.started {
+		return fmt.Errorf("server is not running")
+	}
+	if !s.usesCertificates() {
+		return fmt.Errorf("TLS is not enabled")
+	}
+	if err := s.transportManager.ReloadTLSCertificates(); err != nil {
+		return fmt.Errorf("failed to reload TLS certificates: %w", err)
+	}
+	s.logger.Info("TLS certificates reloaded", logging.Field{Key: "cert_file", Value: s.config.TLS.CertFile})
+	return nil
+}
+
 // startBackgroundTasks starts background cleanup and maintenance tasks
 func (s *SIPServerImpl) startBackgroundTasks() {
 	// Start transaction cleanup routine
@@ -404,6 +573,43 @@ func (s *SIPServerImpl) setupMethodHandlers(manager *handlers.Manager) {
 	manager.RegisterHandler(auxHandler)
 }
 
+// initializeDatabase opens the configured storage backend. For SQLite, pending
+// schema migrations are applied before any other component touches the database.
+func (s *SIPServerImpl) initializeDatabase() error {
+	if s.config.Database.Driver == config.DatabaseDriverMemory {
+		memoryManager := database.NewMemoryManager()
+		if err := memoryManager.Initialize(); err != nil {
+			return err
+		}
+		s.databaseManager = memoryManager
+		s.logger.Info("Database initialized", logging.Field{Key: "driver", Value: config.DatabaseDriverMemory})
+		return nil
+	}
+
+	sqliteManager := database.NewSQLiteManager(s.config.Database.Path)
+	if err := sqliteManager.Open(); err != nil {
+		return err
+	}
+	s.databaseManager = sqliteManager
+
+	applied, err := sqliteManager.Migrate()
+	if err != nil {
+		return err
+	}
+	for _, migration := range applied {
+		s.logger.Info("Applied database migration",
+			logging.Field{Key: "version", Value: migration.Version},
+			logging.Field{Key: "description", Value: migration.Description},
+		)
+	}
+	s.logger.Info("Database initialized",
+		logging.Field{Key: "driver", Value: config.DatabaseDriverSQLite},
+		logging.Field{Key: "path", Value: s.config.Database.Path},
+		logging.Field{Key: "schema_version", Value: database.LatestSchemaVersion()},
+	)
+	return nil
+}
+
 // cleanup performs resource cleanup
 func (s *SIPServerImpl) cleanup() {
 	if s.databaseManager != nil {
@@ -424,10 +630,18 @@ func (s *SIPServerImpl) RunWithSignalHandling() error {
 	
 	// Set up signal handling
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 	
-	// Wait for shutdown signal
+	// Reload certificates on SIGHUP until a shutdown signal arrives
 	sig := <-sigChan
+	for sig == syscall.SIGHUP {
+		if s.usesCertificates() {
+			if err := s.ReloadCertificates(); err != nil {
+				s.logger.Error("Failed to reload TLS certificates", logging.Field{Key: "error", Value: err})
+			}
+		}
+		sig = <-sigChan
+	}
 	s.logger.Info("Received shutdown signal", logging.Field{Key: "signal", Value: sig.String()})
 	
 	// Graceful shutdown
@@ -3,6 +3,7 @@ package server
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -517,4 +518,136 @@ logging:
 	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
 		t.Error("Database file should persist after shutdown")
 	}
-}
\ No newline at end of file
+}
+
+func TestSIPServerImpl_MemoryDatabase(t *testing.T) {
+	tmpDir := t.TempDir()
+	
+	configData := `
+server:
+  udp_port: 0
+  tcp_port: 0
+database:
+  driver: "memory"
+authentication:
+  realm: "test.local"
+  nonce_expiry: 300
+session_timer:
+  default_expires: 1800
+  min_se: 90
+  max_se: 7200
+web_admin:
+  port: 0
+  enabled: false
+logging:
+  level: "error"
+  file: "` + filepath.Join(tmpDir, "test.log") + `"
+`
+	
+	configFile := filepath.Join(tmpDir, "config.yaml")
+	if err := os.WriteFile(configFile, []byte(configData), 0644); err != nil {
+		t.Fatalf("Failed to create test config file: %v", err)
+	}
+
+	server := NewSIPServer()
+	if err := server.LoadConfig(configFile); err != nil {
+		t.Fatalf("Failed to load configuration: %v", err)
+	}
+	
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	impl := server.(*SIPServerImpl)
+	if err := impl.userManager.CreateUser("alice", "test.local", "secret"); err != nil {
+		t.Fatalf("Failed to create user in memory database: %v", err)
+	}
+	if !impl.userManager.AuthenticateUser("alice", "test.local", "secret") {
+		t.Error("Expected user to authenticate against memory database")
+	}
+
+	// Nothing should be written to disk besides the log file
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to read temp dir: %v", err)
+	}
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) == ".db" {
+			t.Errorf("Unexpected database file created: %s", entry.Name())
+		}
+	}
+}
+
+func TestSIPServerImpl_InvalidTLSSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		tls      string
+		errorMsg string
+	}{
+		{
+			name: "invalid TLS min version",
+			tls: `
+  min_version: "1.0"
+`,
+			errorMsg: "invalid TLS min version",
+		},
+		{
+			name: "unknown TLS cipher suite",
+			tls: `
+  cipher_suites: ["TLS_RSA_WITH_RC4_128_SHA"]
+`,
+			errorMsg: "invalid TLS cipher suites",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+
+			configData := `
+server:
+  udp_port: 0
+  tcp_port: 0
+database:
+  driver: "memory"
+authentication:
+  realm: "test.local"
+  nonce_expiry: 300
+session_timer:
+  default_expires: 1800
+  min_se: 90
+  max_se: 7200
+web_admin:
+  port: 0
+  enabled: false
+logging:
+  level: "error"
+  file: "` + filepath.Join(tmpDir, "test.log") + `"
+tls:
+  enabled: true
+  port: 0
+  cert_file: "` + filepath.Join(tmpDir, "server.crt") + `"
+  key_file: "` + filepath.Join(tmpDir, "server.key") + `"` + tt.tls
+
+			configFile := filepath.Join(tmpDir, "config.yaml")
+			if err := os.WriteFile(configFile, []byte(configData), 0644); err != nil {
+				t.Fatalf("Failed to create test config file: %v", err)
+			}
+
+			server := NewSIPServer()
+			if err := server.LoadConfig(configFile); err != nil {
+				t.Fatalf("Failed to load configuration: %v", err)
+			}
+
+			err := server.Start()
+			if err == nil {
+				server.Stop()
+				t.Fatal("Expected error but got none")
+			}
+			if !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Errorf("Expected error containing %q, got %v", tt.errorMsg, err)
+			}
+		})
+	}
+}
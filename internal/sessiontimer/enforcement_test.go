@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/zurustar/xylitol2/internal/clock"
 	"github.com/zurustar/xylitol2/internal/parser"
 )
 
@@ -154,6 +155,8 @@ func TestCleanupTimer(t *testing.T) {
 func TestSessionRefreshPreventsTermination(t *testing.T) {
 	logger := &MockLogger{}
 	manager := NewManager(1800, 90, 7200, logger)
+	c := clock.NewFake(time.Now())
+	manager.SetClock(c)
 
 	// Track terminated sessions
 	terminatedSessions := make([]string, 0)
@@ -165,14 +168,15 @@ func TestSessionRefreshPreventsTermination(t *testing.T) {
 	callID := "refresh-test-session"
 	manager.CreateSession(callID, 1800)
 
-	// Wait a bit and then refresh
-	time.Sleep(10 * time.Millisecond)
+	// Refresh before it expires
+	c.Advance(1500 * time.Second)
 	err := manager.RefreshSession(callID)
 	if err != nil {
 		t.Fatalf("Failed to refresh session: %v", err)
 	}
 
-	// Run cleanup - session should not be terminated since it was refreshed
+	// Run cleanup past the original expiry - session should not be terminated since it was refreshed
+	c.Advance(600 * time.Second)
 	manager.cleanupExpiredSessionsWithCallback()
 
 	// Check that no sessions were terminated
@@ -312,4 +316,50 @@ func TestStopCleanupTimer(t *testing.T) {
 	// This test mainly ensures no panic occurs and cleanup can be stopped
 	// In a real scenario, we'd need to test that the goroutine actually stops
 	// but that's difficult to test reliably in a unit test
-}
\ No newline at end of file
+}
+
+func TestCleanupTimerVirtualTime(t *testing.T) {
+	logger := &MockLogger{}
+	manager := NewManager(1800, 90, 7200, logger)
+	c := clock.NewFake(time.Now())
+	manager.SetClock(c)
+
+	terminated := make(chan string, 1)
+	manager.SetSessionTerminationCallback(func(callID string) {
+		terminated <- callID
+	})
+
+	manager.StartCleanupTimer()
+	manager.CreateSession("short-session", 90)
+	manager.CreateSession("long-session", 3600)
+
+	// Cleanup runs every 30 seconds; the session expires after 90 and is
+	// removed by the first run after that
+	c.Advance(119 * time.Second)
+	if manager.GetSession("short-session") == nil {
+		t.Fatal("Session removed before the cleanup run after its expiry")
+	}
+
+	c.Advance(time.Second)
+	if manager.GetSession("short-session") != nil {
+		t.Error("Expired session should have been removed at 120 seconds")
+	}
+	select {
+	case callID := <-terminated:
+		if callID != "short-session" {
+			t.Errorf("Expected short-session to be terminated, got %s", callID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Termination callback was not called")
+	}
+
+	// No cleanup runs once the timer is stopped
+	manager.StopCleanupTimer()
+	if c.Pending() != 0 {
+		t.Errorf("Expected no pending timers after stop, got %d", c.Pending())
+	}
+	c.Advance(time.Hour)
+	if manager.GetSession("long-session") == nil {
+		t.Error("Session removed after the cleanup timer was stopped")
+	}
+}
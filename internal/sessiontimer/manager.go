@@ -5,10 +5,14 @@ import (
 	"sync"
 	"time"
 
+	"github.com/zurustar/xylitol2/internal/clock"
 	"github.com/zurustar/xylitol2/internal/logging"
 	"github.com/zurustar/xylitol2/internal/parser"
 )
 
+// cleanupInterval is how often the cleanup timer removes expired sessions
+const cleanupInterval = 30 * time.Second
+
 // Manager implements the SessionTimerManager interface
 type Manager struct {
 	sessions              map[string]*Session
@@ -17,8 +21,8 @@ type Manager struct {
 	maxSE                 int
 	logger                logging.Logger
 	mu                    sync.RWMutex
-	cleanupTicker         *time.Ticker
-	stopCleanup           chan bool
+	clock                 clock.Clock
+	cleanupTimer          clock.Timer
 	terminationCallback   func(callID string)
 }
 
@@ -30,10 +34,17 @@ func NewManager(defaultExpires, minSE, maxSE int, logger logging.Logger) *Manage
 		minSE:          minSE,
 		maxSE:          maxSE,
 		logger:         logger,
-		stopCleanup:    make(chan bool),
+		clock:          clock.Real(),
 	}
 }
 
+// SetClock sets the clock session expiry and the cleanup timer follow
+func (m *Manager) SetClock(c clock.Clock) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.clock = c
+}
+
 // CreateSession creates a new session with timer information
 func (m *Manager) CreateSession(callID string, sessionExpires int) *Session {
 	m.mu.Lock()
@@ -49,7 +60,7 @@ func (m *Manager) CreateSession(callID string, sessionExpires int) *Session {
 
 	session := &Session{
 		CallID:         callID,
-		SessionExpires: time.Now().Add(time.Duration(sessionExpires) * time.Second),
+		SessionExpires: m.clock.Now().Add(time.Duration(sessionExpires) * time.Second),
 		Refresher:      "uac", // Default to user agent client
 		MinSE:          m.minSE,
 	}
@@ -71,7 +82,7 @@ func (m *Manager) RefreshSession(callID string) error {
 	}
 
 	// Refresh the session timer
-	session.SessionExpires = time.Now().Add(time.Duration(m.defaultExpires) * time.Second)
+	session.SessionExpires = m.clock.Now().Add(time.Duration(m.defaultExpires) * time.Second)
 	m.logger.Info("Session refreshed", logging.Field{Key: "call_id", Value: callID})
 
 	return nil
@@ -137,28 +148,41 @@ func (m *Manager) GetMaxSE() int {
 
 // StartCleanupTimer starts the background cleanup timer
 func (m *Manager) StartCleanupTimer() {
-	// Run cleanup every 30 seconds
-	m.cleanupTicker = time.NewTicker(30 * time.Second)
-	
-	go func() {
-		for {
-			select {
-			case <-m.cleanupTicker.C:
-				m.cleanupExpiredSessionsWithCallback()
-			case <-m.stopCleanup:
-				return
-			}
-		}
-	}()
-	
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.cleanupTimer != nil {
+		m.cleanupTimer.Stop()
+	}
+	m.scheduleCleanup()
+
 	m.logger.Info("Session timer cleanup started")
 }
 
+// scheduleCleanup arms the cleanup timer, which re-arms itself until stopped.
+// The caller must hold m.mu.
+func (m *Manager) scheduleCleanup() {
+	var timer clock.Timer
+	timer = m.clock.AfterFunc(cleanupInterval, func() {
+		m.cleanupExpiredSessionsWithCallback()
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if m.cleanupTimer == timer {
+			m.scheduleCleanup()
+		}
+	})
+	m.cleanupTimer = timer
+}
+
 // StopCleanupTimer stops the background cleanup timer
 func (m *Manager) StopCleanupTimer() {
-	if m.cleanupTicker != nil {
-		m.cleanupTicker.Stop()
-		m.stopCleanup <- true
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.cleanupTimer != nil {
+		m.cleanupTimer.Stop()
+		m.cleanupTimer = nil
 		m.logger.Info("Session timer cleanup stopped")
 	}
 }
@@ -173,7 +197,7 @@ func (m *Manager) cleanupExpiredSessionsWithCallback() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	now := time.Now()
+	now := m.clock.Now()
 	expiredSessions := make([]string, 0)
 
 	for callID, session := range m.sessions {
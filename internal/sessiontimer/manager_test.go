@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/zurustar/xylitol2/internal/clock"
 	"github.com/zurustar/xylitol2/internal/logging"
 	"github.com/zurustar/xylitol2/internal/parser"
 )
@@ -158,6 +159,8 @@ func TestCreateSessionWithLimits(t *testing.T) {
 func TestRefreshSession(t *testing.T) {
 	logger := &MockLogger{}
 	manager := NewManager(1800, 90, 7200, logger)
+	c := clock.NewFake(time.Now())
+	manager.SetClock(c)
 
 	callID := "test-refresh-call-id"
 	sessionExpires := 1800
@@ -166,8 +169,7 @@ func TestRefreshSession(t *testing.T) {
 	session := manager.CreateSession(callID, sessionExpires)
 	originalExpiry := session.SessionExpires
 
-	// Wait a small amount to ensure time difference
-	time.Sleep(10 * time.Millisecond)
+	c.Advance(10 * time.Second)
 
 	// Refresh the session
 	err := manager.RefreshSession(callID)
@@ -182,8 +184,8 @@ func TestRefreshSession(t *testing.T) {
 	}
 
 	// Check that expiry time was updated
-	if !refreshedSession.SessionExpires.After(originalExpiry) {
-		t.Error("Session expiry time was not updated after refresh")
+	if !refreshedSession.SessionExpires.Equal(originalExpiry.Add(10 * time.Second)) {
+		t.Errorf("Expected expiry %v after refresh, got %v", originalExpiry.Add(10*time.Second), refreshedSession.SessionExpires)
 	}
 }
 
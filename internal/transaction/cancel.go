@@ -0,0 +1,54 @@
+package transaction
+
+import (
+	"fmt"
+
+	"github.com/zurustar/xylitol2/internal/parser"
+)
+
+// CancelFunc is called with the CANCEL request that cancels a pending INVITE
+type CancelFunc func(cancel *parser.SIPMessage)
+
+// NewCancel builds a CANCEL for request as RFC 3261 section 9.1 requires: the
+// same Request-URI, Call-ID, From, To and CSeq number as request and only its
+// top Via, so that the CANCEL matches the INVITE transaction downstream
+func NewCancel(request *parser.SIPMessage) *parser.SIPMessage {
+	cancel := parser.NewRequestMessage(parser.MethodCANCEL, request.GetRequestURI())
+
+	if via, err := request.GetTopVia(); err == nil {
+		cancel.SetHeader(parser.HeaderVia, via.String())
+	}
+	for _, route := range request.GetHeaders(parser.HeaderRoute) {
+		cancel.AddHeader(parser.HeaderRoute, route)
+	}
+	cancel.SetHeader(parser.HeaderMaxForwards, "70")
+	cancel.SetHeader(parser.HeaderFrom, request.GetHeader(parser.HeaderFrom))
+	cancel.SetHeader(parser.HeaderTo, request.GetHeader(parser.HeaderTo))
+	cancel.SetHeader(parser.HeaderCallID, request.GetHeader(parser.HeaderCallID))
+	cancel.SetHeader(parser.HeaderCSeq, fmt.Sprintf("%d %s", extractCSeq(request.GetHeader(parser.HeaderCSeq)), parser.MethodCANCEL))
+	cancel.SetHeader(parser.HeaderContentLength, "0")
+
+	cancel.Transport = request.Transport
+	cancel.Destination = request.Destination
+	return cancel
+}
+
+// newResponse builds a response to request whose To header carries toTag
+// unless the request already had one
+func newResponse(request *parser.SIPMessage, statusCode int, toTag string) *parser.SIPMessage {
+	response := parser.NewResponseMessage(statusCode, parser.GetReasonPhraseForCode(statusCode))
+
+	for _, via := range request.GetHeaders(parser.HeaderVia) {
+		response.AddHeader(parser.HeaderVia, via)
+	}
+	to := request.GetHeader(parser.HeaderTo)
+	if extractTag(to) == "" && toTag != "" {
+		to += ";tag=" + toTag
+	}
+	response.SetHeader(parser.HeaderFrom, request.GetHeader(parser.HeaderFrom))
+	response.SetHeader(parser.HeaderTo, to)
+	response.SetHeader(parser.HeaderCallID, request.GetHeader(parser.HeaderCallID))
+	response.SetHeader(parser.HeaderCSeq, request.GetHeader(parser.HeaderCSeq))
+	response.SetHeader(parser.HeaderContentLength, "0")
+	return response
+}
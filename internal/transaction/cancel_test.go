@@ -0,0 +1,300 @@
+package transaction
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zurustar/xylitol2/internal/clock"
+	"github.com/zurustar/xylitol2/internal/parser"
+)
+
+// createTestCancel creates a CANCEL for the test INVITE with the given top Via
+func createTestCancel(via string) *parser.SIPMessage {
+	return createTestMessage(parser.MethodCANCEL, map[string]string{
+		parser.HeaderVia:    via,
+		parser.HeaderCallID: "test-call-id",
+		parser.HeaderCSeq:   "1 CANCEL",
+	})
+}
+
+// responsesTo returns the status codes of the responses sent for method
+func responsesTo(sent []*parser.SIPMessage, method string) []int {
+	var codes []int
+	for _, msg := range sent {
+		if msg.IsResponse() && msg.GetHeader(parser.HeaderCSeq) == "1 "+method {
+			codes = append(codes, msg.GetStatusCode())
+		}
+	}
+	return codes
+}
+
+func TestManagerProcessCancel(t *testing.T) {
+	var sent []*parser.SIPMessage
+	manager := NewManager(func(msg *parser.SIPMessage) error {
+		sent = append(sent, msg)
+		return nil
+	})
+	defer manager.Stop()
+
+	invite := createTestMessage(parser.MethodINVITE, map[string]string{
+		parser.HeaderVia:    "SIP/2.0/UDP 192.168.1.1:5060;branch=z9hG4bKtest123",
+		parser.HeaderCallID: "test-call-id",
+	})
+	inviteTxn := manager.CreateTransaction(invite)
+
+	ringing := newResponse(invite, parser.StatusRinging, "totag")
+	if err := inviteTxn.SendResponse(ringing); err != nil {
+		t.Fatalf("Failed to send 180: %v", err)
+	}
+
+	var cancelled *parser.SIPMessage
+	if !manager.OnCancel(invite, func(cancel *parser.SIPMessage) { cancelled = cancel }) {
+		t.Fatal("OnCancel should find the INVITE transaction")
+	}
+
+	cancel := createTestCancel("SIP/2.0/UDP 192.168.1.1:5060;branch=z9hG4bKtest123")
+	if err := manager.ProcessMessage(cancel); err != nil {
+		t.Fatalf("ProcessMessage failed: %v", err)
+	}
+
+	if cancelled == nil {
+		t.Error("Cancel callback should have been called")
+	}
+	if codes := responsesTo(sent, parser.MethodCANCEL); len(codes) != 1 || codes[0] != parser.StatusOK {
+		t.Errorf("Expected 200 for CANCEL, got %v", codes)
+	}
+	if codes := responsesTo(sent, parser.MethodINVITE); len(codes) != 2 || codes[1] != parser.StatusRequestTerminated {
+		t.Errorf("Expected 180 and 487 for INVITE, got %v", codes)
+	}
+	if inviteTxn.GetState() != StateCompleted {
+		t.Errorf("Expected INVITE transaction in Completed state, got %v", inviteTxn.GetState())
+	}
+	for _, msg := range sent[1:] {
+		if tag := extractTag(msg.GetHeader(parser.HeaderTo)); tag != "totag" {
+			t.Errorf("Expected To tag of the 180 on %d, got %q", msg.GetStatusCode(), tag)
+		}
+	}
+
+	// A retransmitted CANCEL gets the 200 again and does not cancel twice
+	cancelled = nil
+	if err := manager.ProcessMessage(cancel); err != nil {
+		t.Fatalf("ProcessMessage failed: %v", err)
+	}
+	if cancelled != nil {
+		t.Error("Retransmitted CANCEL should not run the callbacks again")
+	}
+	if codes := responsesTo(sent, parser.MethodCANCEL); len(codes) != 2 || codes[1] != parser.StatusOK {
+		t.Errorf("Expected 200 retransmission, got %v", codes)
+	}
+	if codes := responsesTo(sent, parser.MethodINVITE); len(codes) != 2 {
+		t.Errorf("Expected no further INVITE response, got %v", codes)
+	}
+}
+
+func TestManagerProcessCancelNoMatch(t *testing.T) {
+	var sent []*parser.SIPMessage
+	manager := NewManager(func(msg *parser.SIPMessage) error {
+		sent = append(sent, msg)
+		return nil
+	})
+	defer manager.Stop()
+
+	invite := createTestMessage(parser.MethodINVITE, map[string]string{
+		parser.HeaderVia:    "SIP/2.0/UDP 192.168.1.1:5060;branch=z9hG4bKtest123",
+		parser.HeaderCallID: "test-call-id",
+	})
+	manager.CreateTransaction(invite)
+
+	// A different branch does not match the INVITE
+	if _, err := manager.ProcessCancel(createTestCancel("SIP/2.0/UDP 192.168.1.1:5060;branch=z9hG4bKother")); err != nil {
+		t.Fatalf("ProcessCancel failed: %v", err)
+	}
+	if codes := responsesTo(sent, parser.MethodCANCEL); len(codes) != 1 || codes[0] != parser.StatusCallTransactionDoesNotExist {
+		t.Errorf("Expected 481 for CANCEL, got %v", codes)
+	}
+	if codes := responsesTo(sent, parser.MethodINVITE); len(codes) != 0 {
+		t.Errorf("Expected no INVITE response, got %v", codes)
+	}
+}
+
+func TestManagerProcessCancelAfterFinalResponse(t *testing.T) {
+	var sent []*parser.SIPMessage
+	manager := NewManager(func(msg *parser.SIPMessage) error {
+		sent = append(sent, msg)
+		return nil
+	})
+	defer manager.Stop()
+
+	invite := createTestMessage(parser.MethodINVITE, map[string]string{
+		parser.HeaderVia:    "SIP/2.0/UDP 192.168.1.1:5060;branch=z9hG4bKtest123",
+		parser.HeaderCallID: "test-call-id",
+	})
+	inviteTxn := manager.CreateTransaction(invite)
+	if err := inviteTxn.SendResponse(newResponse(invite, parser.StatusBusyHere, "totag")); err != nil {
+		t.Fatalf("Failed to send 486: %v", err)
+	}
+	manager.OnCancel(invite, func(*parser.SIPMessage) {
+		t.Error("Cancel callback should not run after the final response")
+	})
+
+	if _, err := manager.ProcessCancel(createTestCancel("SIP/2.0/UDP 192.168.1.1:5060;branch=z9hG4bKtest123")); err != nil {
+		t.Fatalf("ProcessCancel failed: %v", err)
+	}
+
+	// The CANCEL still gets a 200 but the INVITE keeps its final response
+	if codes := responsesTo(sent, parser.MethodCANCEL); len(codes) != 1 || codes[0] != parser.StatusOK {
+		t.Errorf("Expected 200 for CANCEL, got %v", codes)
+	}
+	if codes := responsesTo(sent, parser.MethodINVITE); len(codes) != 1 || codes[0] != parser.StatusBusyHere {
+		t.Errorf("Expected only 486 for INVITE, got %v", codes)
+	}
+}
+
+func TestServerTransactionCancelCallbackResponds(t *testing.T) {
+	var sent []*parser.SIPMessage
+	invite := createTestMessage(parser.MethodINVITE, map[string]string{
+		parser.HeaderVia:    "SIP/2.0/UDP 192.168.1.1:5060;branch=z9hG4bKtest123",
+		parser.HeaderCallID: "test-call-id",
+	})
+	st := NewServerTransaction(invite, func(msg *parser.SIPMessage) error {
+		sent = append(sent, msg)
+		return nil
+	})
+
+	// A callback that sends a final response itself suppresses the 487
+	st.OnCancel(func(*parser.SIPMessage) {
+		st.SendResponse(newResponse(invite, parser.StatusServiceUnavailable, "totag"))
+	})
+
+	if !st.Cancel(createTestCancel("SIP/2.0/UDP 192.168.1.1:5060;branch=z9hG4bKtest123")) {
+		t.Fatal("Cancel should cancel a pending INVITE")
+	}
+	if codes := responsesTo(sent, parser.MethodINVITE); len(codes) != 1 || codes[0] != parser.StatusServiceUnavailable {
+		t.Errorf("Expected only 503 for INVITE, got %v", codes)
+	}
+	if st.Cancel(createTestCancel("SIP/2.0/UDP 192.168.1.1:5060;branch=z9hG4bKtest123")) {
+		t.Error("Cancel should not cancel a completed INVITE")
+	}
+}
+
+func TestManagerProcessCancelAfterLongRinging(t *testing.T) {
+	var sent []*parser.SIPMessage
+	manager := NewManager(func(msg *parser.SIPMessage) error {
+		sent = append(sent, msg)
+		return nil
+	})
+	defer manager.Stop()
+	c := clock.NewFake(time.Now())
+	manager.SetClock(c)
+
+	invite := createTestMessage(parser.MethodINVITE, map[string]string{
+		parser.HeaderVia:    "SIP/2.0/UDP 192.168.1.1:5060;branch=z9hG4bKtest123",
+		parser.HeaderCallID: "test-call-id",
+	})
+	inviteTxn := manager.CreateTransaction(invite)
+	if err := inviteTxn.SendResponse(newResponse(invite, parser.StatusRinging, "totag")); err != nil {
+		t.Fatalf("Failed to send 180: %v", err)
+	}
+	cancelled := false
+	manager.OnCancel(invite, func(*parser.SIPMessage) { cancelled = true })
+
+	// The call rings for longer than 64*T1 while the cleanup runs
+	c.Advance(64*TimerT1 + 8*time.Second)
+	manager.CleanupExpired()
+	if manager.FindTransaction(invite) == nil {
+		t.Fatal("Expected the ringing INVITE transaction to survive the cleanup")
+	}
+
+	if _, err := manager.ProcessCancel(createTestCancel("SIP/2.0/UDP 192.168.1.1:5060;branch=z9hG4bKtest123")); err != nil {
+		t.Fatalf("ProcessCancel failed: %v", err)
+	}
+	if !cancelled {
+		t.Error("Cancel callback should have been called")
+	}
+	if codes := responsesTo(sent, parser.MethodCANCEL); len(codes) != 1 || codes[0] != parser.StatusOK {
+		t.Errorf("Expected 200 for CANCEL, got %v", codes)
+	}
+	if codes := responsesTo(sent, parser.MethodINVITE); len(codes) != 2 || codes[1] != parser.StatusRequestTerminated {
+		t.Errorf("Expected 180 and 487 for INVITE, got %v", codes)
+	}
+}
+
+func TestServerTransactionCancelRacesFinalResponse(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		var mutex sync.Mutex
+		var sent []*parser.SIPMessage
+		invite := createTestMessage(parser.MethodINVITE, map[string]string{
+			parser.HeaderVia:    "SIP/2.0/UDP 192.168.1.1:5060;branch=z9hG4bKtest123",
+			parser.HeaderCallID: "test-call-id",
+		})
+		st := NewServerTransaction(invite, func(msg *parser.SIPMessage) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+			sent = append(sent, msg)
+			return nil
+		})
+
+		// The TU answers while the CANCEL arrives; the INVITE gets one final response
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			st.SendResponse(newResponse(invite, parser.StatusOK, "totag"))
+		}()
+		go func() {
+			defer wg.Done()
+			st.Cancel(createTestCancel("SIP/2.0/UDP 192.168.1.1:5060;branch=z9hG4bKtest123"))
+		}()
+		wg.Wait()
+		st.CancelAllTimers()
+
+		if codes := responsesTo(sent, parser.MethodINVITE); len(codes) != 1 {
+			t.Fatalf("Expected one final response for INVITE, got %v", codes)
+		}
+	}
+}
+
+func TestInviteTransactionIDFallback(t *testing.T) {
+	invite := createTestMessage(parser.MethodINVITE, map[string]string{
+		parser.HeaderVia:    "SIP/2.0/UDP 192.168.1.1:5060;branch=oldbranch",
+		parser.HeaderCallID: "test-call-id",
+		parser.HeaderCSeq:   "7 INVITE",
+	})
+	cancel := createTestMessage(parser.MethodCANCEL, map[string]string{
+		parser.HeaderVia:    "SIP/2.0/UDP 192.168.1.1:5060;branch=oldbranch",
+		parser.HeaderCallID: "test-call-id",
+		parser.HeaderCSeq:   "7 CANCEL",
+	})
+
+	if got, want := inviteTransactionID(cancel), generateTransactionID(invite); got != want {
+		t.Errorf("inviteTransactionID() = %v, want %v", got, want)
+	}
+}
+
+func TestNewCancel(t *testing.T) {
+	invite := createTestMessage(parser.MethodINVITE, map[string]string{
+		parser.HeaderVia:    "SIP/2.0/UDP proxy.example.com:5060;branch=z9hG4bKproxy, SIP/2.0/UDP 192.168.1.1:5060;branch=z9hG4bKtest123",
+		parser.HeaderCallID: "test-call-id",
+		parser.HeaderCSeq:   "3 INVITE",
+	})
+	invite.AddHeader(parser.HeaderRoute, "<sip:edge.example.com;lr>")
+
+	cancel := NewCancel(invite)
+
+	if cancel.GetMethod() != parser.MethodCANCEL || cancel.GetRequestURI() != invite.GetRequestURI() {
+		t.Errorf("Expected CANCEL to %s, got %s %s", invite.GetRequestURI(), cancel.GetMethod(), cancel.GetRequestURI())
+	}
+	vias, err := cancel.GetVias()
+	if err != nil || len(vias) != 1 || vias[0].Branch() != "z9hG4bKproxy" {
+		t.Errorf("Expected only the top Via of the INVITE, got %v", cancel.GetHeaders(parser.HeaderVia))
+	}
+	if cseq := cancel.GetHeader(parser.HeaderCSeq); cseq != "3 CANCEL" {
+		t.Errorf("Expected CSeq 3 CANCEL, got %q", cseq)
+	}
+	for _, header := range []string{parser.HeaderFrom, parser.HeaderTo, parser.HeaderCallID, parser.HeaderRoute} {
+		if cancel.GetHeader(header) != invite.GetHeader(header) {
+			t.Errorf("Expected %s %q, got %q", header, invite.GetHeader(header), cancel.GetHeader(header))
+		}
+	}
+}
@@ -5,31 +5,44 @@ import (
 	"strings"
 	"time"
 
+	"github.com/zurustar/xylitol2/internal/clock"
 	"github.com/zurustar/xylitol2/internal/parser"
 )
 
 // ClientTransaction represents a client transaction
 type ClientTransaction struct {
 	*BaseTransaction
-	retransmitCount int
-	sendMessage     func(*parser.SIPMessage) error
+	retransmitCount  int
+	sendMessage      func(*parser.SIPMessage) error
+	timeoutCallbacks []ResponseFunc
 }
 
-// NewClientTransaction creates a new client transaction
+// ResponseFunc is called with a response a client transaction passes up to its
+// transaction user
+type ResponseFunc func(response *parser.SIPMessage)
+
+// NewClientTransaction creates a new client transaction using the RFC3261 timer values
 func NewClientTransaction(msg *parser.SIPMessage, sendFunc func(*parser.SIPMessage) error) *ClientTransaction {
+	return newClientTransaction(msg, sendFunc, defaultWheel(), DefaultTimers(), nil)
+}
+
+// newClientTransaction creates a new client transaction whose timers run on clk
+// and whose events go to observer, if any
+func newClientTransaction(msg *parser.SIPMessage, sendFunc func(*parser.SIPMessage) error, clk clock.Clock, timers Timers, observer Observer) *ClientTransaction {
 	ct := &ClientTransaction{
-		BaseTransaction: NewBaseTransaction(msg, true),
+		BaseTransaction: newBaseTransaction(msg, true, clk, timers, observer),
 		sendMessage:     sendFunc,
 	}
 
 	// Set initial state based on method
 	if msg.GetMethod() == parser.MethodINVITE {
-		ct.setState(StateCalling)
+		ct.state = StateCalling
 		ct.startInviteClientTimers()
 	} else {
-		ct.setState(StateTrying)
+		ct.state = StateTrying
 		ct.startNonInviteClientTimers()
 	}
+	ct.notify(Event{Type: EventCreated, State: ct.state})
 
 	return ct
 }
@@ -71,9 +84,7 @@ func (ct *ClientTransaction) processInviteResponse(statusCode int, msg *parser.S
 			// Send ACK for error response
 			if ct.lastRequest != nil {
 				ack := ct.createACK(msg)
-				if ct.sendMessage != nil {
-					ct.sendMessage(ack)
-				}
+				ct.send(ack)
 			}
 		}
 
@@ -90,9 +101,7 @@ func (ct *ClientTransaction) processInviteResponse(statusCode int, msg *parser.S
 			// Send ACK for error response
 			if ct.lastRequest != nil {
 				ack := ct.createACK(msg)
-				if ct.sendMessage != nil {
-					ct.sendMessage(ack)
-				}
+				ct.send(ack)
 			}
 		}
 		// Ignore additional 1xx responses
@@ -101,9 +110,7 @@ func (ct *ClientTransaction) processInviteResponse(statusCode int, msg *parser.S
 		// Retransmit ACK for any response received
 		if ct.lastRequest != nil {
 			ack := ct.createACK(msg)
-			if ct.sendMessage != nil {
-				ct.sendMessage(ack)
-			}
+			ct.send(ack)
 		}
 
 	case StateTerminated:
@@ -118,9 +125,7 @@ func (ct *ClientTransaction) processNonInviteResponse(statusCode int, msg *parse
 	switch ct.GetState() {
 	case StateTrying:
 		if statusCode >= 100 && statusCode < 200 {
-			// Provisional response
-			ct.CancelTimer(TimerE)
-			ct.CancelTimer(TimerF)
+			// Provisional response; Timers E and F keep running
 			ct.setState(StateProceeding)
 		} else if statusCode >= 200 {
 			// Final response
@@ -171,25 +176,13 @@ func (ct *ClientTransaction) IsClient() bool {
 // startInviteClientTimers starts timers for INVITE client transactions
 func (ct *ClientTransaction) startInviteClientTimers() {
 	// Timer A: INVITE retransmission
-	ct.SetTimer(TimerA, TimerT1, func() {
-		if ct.GetState() == StateCalling {
-			ct.retransmitRequest()
-			// Double the interval for next retransmission, up to T2
-			interval := TimerT1 * time.Duration(1<<ct.retransmitCount)
-			if interval > TimerT2 {
-				interval = TimerT2
-			}
-			ct.retransmitCount++
-			ct.SetTimer(TimerA, interval, func() {
-				ct.retransmitRequest()
-			})
-		}
-	})
+	ct.startTimerA(ct.timerValues.T1)
 
 	// Timer B: INVITE transaction timeout
-	ct.SetTimer(TimerB, 64*TimerT1, func() {
+	ct.SetTimer(TimerB, ct.timerValues.B, func() {
 		if ct.GetState() == StateCalling || ct.GetState() == StateProceeding {
 			ct.setState(StateTerminated)
+			ct.timedOut()
 		}
 	})
 }
@@ -197,32 +190,80 @@ func (ct *ClientTransaction) startInviteClientTimers() {
 // startNonInviteClientTimers starts timers for non-INVITE client transactions
 func (ct *ClientTransaction) startNonInviteClientTimers() {
 	// Timer E: Non-INVITE request retransmission
-	ct.SetTimer(TimerE, TimerT1, func() {
-		if ct.GetState() == StateTrying {
-			ct.retransmitRequest()
-			// Double the interval for next retransmission, up to T2
-			interval := TimerT1 * time.Duration(1<<ct.retransmitCount)
-			if interval > TimerT2 {
-				interval = TimerT2
-			}
-			ct.retransmitCount++
-			ct.SetTimer(TimerE, interval, func() {
-				ct.retransmitRequest()
-			})
-		}
-	})
+	ct.startTimerE(ct.timerValues.T1)
 
 	// Timer F: Non-INVITE transaction timeout
-	ct.SetTimer(TimerF, 64*TimerT1, func() {
+	ct.SetTimer(TimerF, ct.timerValues.F, func() {
 		if ct.GetState() == StateTrying || ct.GetState() == StateProceeding {
 			ct.setState(StateTerminated)
+			ct.timedOut()
 		}
 	})
 }
 
+// OnTimeout registers callback to run with the 408 Request Timeout the
+// transaction passes up when Timer B or F fires before a final response
+func (ct *ClientTransaction) OnTimeout(callback ResponseFunc) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+	ct.timeoutCallbacks = append(ct.timeoutCallbacks, callback)
+}
+
+// timedOut informs the transaction user of a timeout with a 408 Request
+// Timeout built from the request (RFC 3261 sections 17.1.1.2 and 17.1.2.2)
+func (ct *ClientTransaction) timedOut() {
+	ct.mutex.Lock()
+	callbacks := ct.timeoutCallbacks
+	ct.timeoutCallbacks = nil
+	request := ct.lastRequest
+	ct.mutex.Unlock()
+
+	if request == nil {
+		return
+	}
+	response := newResponse(request, parser.StatusRequestTimeout, "")
+	for _, callback := range callbacks {
+		callback(response)
+	}
+}
+
+// startTimerA retransmits the INVITE after interval for as long as the
+// transaction is Calling, doubling the interval each time
+func (ct *ClientTransaction) startTimerA(interval time.Duration) {
+	ct.SetTimer(TimerA, interval, func() {
+		if ct.GetState() != StateCalling {
+			return
+		}
+		ct.retransmitRequest()
+		ct.retransmitCount++
+		ct.notify(Event{Type: EventRetransmitted, State: StateCalling, Timer: TimerA})
+		ct.startTimerA(interval * 2)
+	})
+}
+
+// startTimerE retransmits the request after interval for as long as the
+// transaction is Trying or Proceeding. The interval doubles each time up to T2
+// in Trying and is T2 in Proceeding.
+func (ct *ClientTransaction) startTimerE(interval time.Duration) {
+	ct.SetTimer(TimerE, interval, func() {
+		state := ct.GetState()
+		if state != StateTrying && state != StateProceeding {
+			return
+		}
+		ct.retransmitRequest()
+		ct.retransmitCount++
+		ct.notify(Event{Type: EventRetransmitted, State: state, Timer: TimerE})
+		next := interval * 2
+		if next > ct.timerValues.T2 || state == StateProceeding {
+			next = ct.timerValues.T2
+		}
+		ct.startTimerE(next)
+	})
+}
+
 // startTimerD starts Timer D for INVITE client transactions
 func (ct *ClientTransaction) startTimerD() {
-	duration := TimerT4
+	duration := ct.timerValues.T4
 	if ct.transport == "UDP" {
 		duration = 32 * time.Second
 	}
@@ -234,9 +275,9 @@ func (ct *ClientTransaction) startTimerD() {
 
 // startTimerK starts Timer K for non-INVITE client transactions
 func (ct *ClientTransaction) startTimerK() {
-	duration := TimerT4
+	duration := ct.timerValues.T4
 	if ct.transport == "UDP" {
-		duration = TimerT4
+		duration = ct.timerValues.T4
 	} else {
 		duration = 0 // Immediate transition for reliable transports
 	}
@@ -252,9 +293,21 @@ func (ct *ClientTransaction) startTimerK() {
 
 // retransmitRequest retransmits the original request
 func (ct *ClientTransaction) retransmitRequest() {
-	if ct.lastRequest != nil && ct.sendMessage != nil {
-		ct.sendMessage(ct.lastRequest)
+	if ct.lastRequest != nil {
+		ct.send(ct.lastRequest)
+	}
+}
+
+// send sends msg, telling the observer if that fails
+func (ct *ClientTransaction) send(msg *parser.SIPMessage) error {
+	if ct.sendMessage == nil {
+		return nil
+	}
+	err := ct.sendMessage(msg)
+	if err != nil {
+		ct.notify(Event{Type: EventTransportError, State: ct.GetState(), Err: err})
 	}
+	return err
 }
 
 // createACK creates an ACK request for INVITE transactions
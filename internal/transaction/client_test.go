@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/zurustar/xylitol2/internal/clock"
 	"github.com/zurustar/xylitol2/internal/parser"
 )
 
@@ -148,11 +149,7 @@ func TestClientTransactionNonINVITE(t *testing.T) {
 }
 
 func TestClientTransactionRetransmission(t *testing.T) {
-	sentMessages := []*parser.SIPMessage{}
-	sendFunc := func(msg *parser.SIPMessage) error {
-		sentMessages = append(sentMessages, msg)
-		return nil
-	}
+	r := newSendRecorder()
 
 	// Create INVITE request
 	invite := createTestMessage(parser.MethodINVITE, map[string]string{
@@ -160,18 +157,18 @@ func TestClientTransactionRetransmission(t *testing.T) {
 		parser.HeaderCallID: "test-call-id",
 	})
 
-	ct := NewClientTransaction(invite, sendFunc)
+	ct := newClientTransaction(invite, r.send, r.clock, DefaultTimers(), nil)
 
 	// Should start in Calling state
 	if ct.GetState() != StateCalling {
 		t.Errorf("Expected state Calling, got %v", ct.GetState())
 	}
 
-	// Wait for Timer A to fire (should retransmit)
-	time.Sleep(TimerT1 + 50*time.Millisecond)
+	// Let Timer A fire (should retransmit)
+	r.clock.Advance(TimerT1)
 
 	// Should have retransmitted the request
-	if len(sentMessages) == 0 {
+	if len(r.messages) != 1 || r.messages[0].GetMethod() != parser.MethodINVITE {
 		t.Error("Expected request retransmission")
 	}
 }
@@ -187,17 +184,11 @@ func TestClientTransactionTimeout(t *testing.T) {
 		parser.HeaderCallID: "test-call-id-2",
 	})
 
-	ct := NewClientTransaction(register, sendFunc)
+	c := clock.NewFake(time.Now())
+	ct := newClientTransaction(register, sendFunc, c, DefaultTimers(), nil)
 
-	// Simulate timeout by setting a very short Timer F
-	ct.SetTimer(TimerF, 10*time.Millisecond, func() {
-		if ct.GetState() == StateTrying || ct.GetState() == StateProceeding {
-			ct.setState(StateTerminated)
-		}
-	})
-
-	// Wait for timeout
-	time.Sleep(20 * time.Millisecond)
+	// Let Timer F fire
+	c.Advance(64 * TimerT1)
 
 	// Should be terminated
 	if ct.GetState() != StateTerminated {
@@ -205,6 +196,53 @@ func TestClientTransactionTimeout(t *testing.T) {
 	}
 }
 
+func TestClientTransactionTimeoutResponse(t *testing.T) {
+	for _, method := range []string{parser.MethodINVITE, parser.MethodREGISTER} {
+		t.Run(method, func(t *testing.T) {
+			r := newSendRecorder()
+			request := createTestMessage(method, nil)
+			ct := newClientTransaction(request, r.send, r.clock, DefaultTimers(), nil)
+
+			var timeouts []*parser.SIPMessage
+			ct.OnTimeout(func(response *parser.SIPMessage) {
+				timeouts = append(timeouts, response)
+			})
+
+			// Timer B and F both fire after 64*T1
+			r.clock.Advance(64*TimerT1 - time.Millisecond)
+			if len(timeouts) != 0 {
+				t.Fatalf("Expected no timeout before Timer B/F, got %d", len(timeouts))
+			}
+			r.clock.Advance(time.Millisecond)
+			expectState(t, ct, StateTerminated)
+
+			if len(timeouts) != 1 {
+				t.Fatalf("Expected one timeout response, got %d", len(timeouts))
+			}
+			response := timeouts[0]
+			if response.GetStatusCode() != parser.StatusRequestTimeout {
+				t.Errorf("Expected 408, got %d", response.GetStatusCode())
+			}
+			if response.GetHeader(parser.HeaderCallID) != request.GetHeader(parser.HeaderCallID) ||
+				response.GetHeader(parser.HeaderCSeq) != request.GetHeader(parser.HeaderCSeq) ||
+				response.GetHeader(parser.HeaderVia) != request.GetHeader(parser.HeaderVia) {
+				t.Errorf("Expected the 408 to match the request, got headers %v", response.Headers)
+			}
+		})
+	}
+
+	t.Run("final response", func(t *testing.T) {
+		r := newSendRecorder()
+		ct := newClientTransaction(createTestMessage(parser.MethodINVITE, nil), r.send, r.clock, DefaultTimers(), nil)
+		ct.OnTimeout(func(response *parser.SIPMessage) {
+			t.Errorf("Expected no timeout after a final response, got %d", response.GetStatusCode())
+		})
+
+		ct.ProcessMessage(parser.NewResponseMessage(486, "Busy Here"))
+		r.clock.Advance(time.Minute)
+	})
+}
+
 func TestClientTransactionSendResponse(t *testing.T) {
 	sendFunc := func(msg *parser.SIPMessage) error {
 		return nil
@@ -278,4 +316,165 @@ func TestCreateACK(t *testing.T) {
 	if ack.GetHeader(parser.HeaderTo) != "Test <sip:test@example.com>;tag=totag" {
 		t.Errorf("Expected To header with tag, got %v", ack.GetHeader(parser.HeaderTo))
 	}
-}
\ No newline at end of file
+}
+
+// retransmissionTimes is the RFC3261 Timer E and G retransmission schedule with
+// the default timers: the interval doubles from T1 up to T2 until Timer F or H
+// fires
+var retransmissionTimes = []time.Duration{
+	500 * time.Millisecond, 1500 * time.Millisecond, 3500 * time.Millisecond,
+	7500 * time.Millisecond, 11500 * time.Millisecond, 15500 * time.Millisecond,
+	19500 * time.Millisecond, 23500 * time.Millisecond, 27500 * time.Millisecond,
+	31500 * time.Millisecond,
+}
+
+// inviteRetransmissionTimes is the RFC3261 Timer A retransmission schedule with
+// the default timers: the interval doubles from T1 without limit until Timer B
+// fires
+var inviteRetransmissionTimes = []time.Duration{
+	500 * time.Millisecond, 1500 * time.Millisecond, 3500 * time.Millisecond,
+	7500 * time.Millisecond, 15500 * time.Millisecond, 31500 * time.Millisecond,
+}
+
+// sendRecorder records when a transaction on a fake clock sends messages
+type sendRecorder struct {
+	clock    *clock.Fake
+	start    time.Time
+	times    []time.Duration
+	messages []*parser.SIPMessage
+}
+
+func newSendRecorder() *sendRecorder {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	return &sendRecorder{clock: clock.NewFake(start), start: start}
+}
+
+func (r *sendRecorder) send(msg *parser.SIPMessage) error {
+	r.times = append(r.times, r.clock.Now().Sub(r.start))
+	r.messages = append(r.messages, msg)
+	return nil
+}
+
+func (r *sendRecorder) expectTimes(t *testing.T, expected []time.Duration) {
+	t.Helper()
+	if len(r.times) != len(expected) {
+		t.Fatalf("Expected sends at %v, got %v", expected, r.times)
+	}
+	for i := range expected {
+		if r.times[i] != expected[i] {
+			t.Fatalf("Expected sends at %v, got %v", expected, r.times)
+		}
+	}
+}
+
+func expectState(t *testing.T, txn Transaction, expected TransactionState) {
+	t.Helper()
+	if state := txn.GetState(); state != expected {
+		t.Fatalf("Expected state %v, got %v", expected, state)
+	}
+}
+
+func TestClientTransactionTimersVirtualTime(t *testing.T) {
+	timers := DefaultTimers()
+
+	t.Run("Timer A retransmits until Timer B", func(t *testing.T) {
+		r := newSendRecorder()
+		invite := createTestMessage(parser.MethodINVITE, nil)
+		ct := newClientTransaction(invite, r.send, r.clock, timers, nil)
+
+		r.clock.Advance(timers.B - time.Millisecond)
+		r.expectTimes(t, inviteRetransmissionTimes)
+		expectState(t, ct, StateCalling)
+
+		r.clock.Advance(time.Millisecond)
+		expectState(t, ct, StateTerminated)
+
+		r.clock.Advance(time.Minute)
+		r.expectTimes(t, inviteRetransmissionTimes)
+		if r.clock.Pending() != 0 {
+			t.Errorf("Expected no timers left, got %d", r.clock.Pending())
+		}
+	})
+
+	t.Run("Timer E retransmits until Timer F", func(t *testing.T) {
+		r := newSendRecorder()
+		register := createTestMessage(parser.MethodREGISTER, nil)
+		ct := newClientTransaction(register, r.send, r.clock, timers, nil)
+
+		r.clock.Advance(timers.F - time.Millisecond)
+		r.expectTimes(t, retransmissionTimes)
+		expectState(t, ct, StateTrying)
+
+		r.clock.Advance(time.Millisecond)
+		expectState(t, ct, StateTerminated)
+
+		r.clock.Advance(time.Minute)
+		r.expectTimes(t, retransmissionTimes)
+	})
+
+	t.Run("provisional response stops Timer A", func(t *testing.T) {
+		r := newSendRecorder()
+		invite := createTestMessage(parser.MethodINVITE, nil)
+		ct := newClientTransaction(invite, r.send, r.clock, timers, nil)
+
+		r.clock.Advance(time.Second)
+		ct.ProcessMessage(parser.NewResponseMessage(180, "Ringing"))
+		r.clock.Advance(time.Minute)
+		r.expectTimes(t, inviteRetransmissionTimes[:1])
+		expectState(t, ct, StateProceeding)
+	})
+
+	t.Run("Timer E continues at T2 in Proceeding", func(t *testing.T) {
+		r := newSendRecorder()
+		register := createTestMessage(parser.MethodREGISTER, nil)
+		ct := newClientTransaction(register, r.send, r.clock, timers, nil)
+
+		r.clock.Advance(time.Second)
+		ct.ProcessMessage(parser.NewResponseMessage(100, "Trying"))
+		expectState(t, ct, StateProceeding)
+
+		r.clock.Advance(timers.F - time.Second - time.Millisecond)
+		r.expectTimes(t, []time.Duration{
+			500 * time.Millisecond, 1500 * time.Millisecond, 5500 * time.Millisecond,
+			9500 * time.Millisecond, 13500 * time.Millisecond, 17500 * time.Millisecond,
+			21500 * time.Millisecond, 25500 * time.Millisecond, 29500 * time.Millisecond,
+		})
+		expectState(t, ct, StateProceeding)
+
+		r.clock.Advance(time.Millisecond)
+		expectState(t, ct, StateTerminated)
+	})
+
+	t.Run("Timer D", func(t *testing.T) {
+		r := newSendRecorder()
+		invite := createTestMessage(parser.MethodINVITE, nil)
+		invite.Transport = "UDP"
+		ct := newClientTransaction(invite, r.send, r.clock, timers, nil)
+
+		ct.ProcessMessage(parser.NewResponseMessage(486, "Busy Here"))
+		expectState(t, ct, StateCompleted)
+		r.expectTimes(t, []time.Duration{0}) // ACK
+
+		r.clock.Advance(32*time.Second - time.Millisecond)
+		expectState(t, ct, StateCompleted)
+		r.clock.Advance(time.Millisecond)
+		expectState(t, ct, StateTerminated)
+		r.expectTimes(t, []time.Duration{0})
+	})
+
+	t.Run("Timer K", func(t *testing.T) {
+		r := newSendRecorder()
+		register := createTestMessage(parser.MethodREGISTER, nil)
+		register.Transport = "UDP"
+		ct := newClientTransaction(register, r.send, r.clock, timers, nil)
+
+		ct.ProcessMessage(parser.NewResponseMessage(200, "OK"))
+		expectState(t, ct, StateCompleted)
+
+		r.clock.Advance(timers.T4 - time.Millisecond)
+		expectState(t, ct, StateCompleted)
+		r.clock.Advance(time.Millisecond)
+		expectState(t, ct, StateTerminated)
+		r.expectTimes(t, nil)
+	})
+}
@@ -0,0 +1,104 @@
+package transaction
+
+import (
+	"sync"
+	"time"
+)
+
+// TransactionRecord summarizes a transaction for display
+type TransactionRecord struct {
+	ID              string    `json:"id"`
+	Method          string    `json:"method"`
+	Client          bool      `json:"client"`
+	State           string    `json:"state"`
+	Created         time.Time `json:"created"`
+	Updated         time.Time `json:"updated"`
+	Terminated      bool      `json:"terminated"`
+	Retransmissions int       `json:"retransmissions"`
+	TimedOut        bool      `json:"timed_out"`
+	LastTimer       string    `json:"last_timer,omitempty"`
+	LastError       string    `json:"last_error,omitempty"`
+}
+
+// History is an observer that keeps a record of the most recently created
+// transactions, replacing the oldest once it holds its capacity
+type History struct {
+	records []*TransactionRecord
+	byID    map[string]*TransactionRecord
+	next    int
+	mutex   sync.Mutex
+}
+
+// NewHistory creates a history of at most capacity transactions
+func NewHistory(capacity int) *History {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &History{
+		records: make([]*TransactionRecord, 0, capacity),
+		byID:    make(map[string]*TransactionRecord),
+	}
+}
+
+// OnTransactionEvent records event
+func (h *History) OnTransactionEvent(event Event) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	record, exists := h.byID[event.TransactionID]
+	if !exists {
+		if event.Type != EventCreated {
+			// Created before the history was added, or already replaced
+			return
+		}
+		record = &TransactionRecord{
+			ID:      event.TransactionID,
+			Method:  event.Method,
+			Client:  event.Client,
+			Created: event.Time,
+		}
+		h.add(record)
+	}
+
+	record.State = event.State.String()
+	record.Updated = event.Time
+
+	switch event.Type {
+	case EventRetransmitted:
+		record.Retransmissions++
+	case EventTimerFired:
+		record.LastTimer = event.Timer.String()
+		if isTimeout(event.Timer) {
+			record.TimedOut = true
+		}
+	case EventTransportError:
+		record.LastError = event.Err.Error()
+	case EventTerminated:
+		record.Terminated = true
+	}
+}
+
+// add stores record in place of the oldest one once the history is full
+func (h *History) add(record *TransactionRecord) {
+	if len(h.records) < cap(h.records) {
+		h.records = append(h.records, record)
+	} else {
+		delete(h.byID, h.records[h.next].ID)
+		h.records[h.next] = record
+	}
+	h.next = (h.next + 1) % cap(h.records)
+	h.byID[record.ID] = record
+}
+
+// Recent returns copies of the recorded transactions, newest first
+func (h *History) Recent() []TransactionRecord {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	recent := make([]TransactionRecord, 0, len(h.records))
+	for i := 1; i <= len(h.records); i++ {
+		index := (h.next - i + cap(h.records)) % cap(h.records)
+		recent = append(recent, *h.records[index])
+	}
+	return recent
+}
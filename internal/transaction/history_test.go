@@ -0,0 +1,74 @@
+package transaction
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestHistory(t *testing.T) {
+	history := NewHistory(2)
+	start := time.Now()
+
+	history.OnTransactionEvent(Event{Type: EventCreated, TransactionID: "t1", Method: "INVITE", Client: true, State: StateCalling, Time: start})
+	history.OnTransactionEvent(Event{Type: EventTimerFired, TransactionID: "t1", State: StateCalling, Timer: TimerA, Time: start.Add(time.Second)})
+	history.OnTransactionEvent(Event{Type: EventRetransmitted, TransactionID: "t1", State: StateCalling, Timer: TimerA, Time: start.Add(time.Second)})
+	history.OnTransactionEvent(Event{Type: EventTimerFired, TransactionID: "t1", State: StateCalling, Timer: TimerB, Time: start.Add(32 * time.Second)})
+	history.OnTransactionEvent(Event{Type: EventTerminated, TransactionID: "t1", State: StateTerminated, Time: start.Add(32 * time.Second)})
+
+	history.OnTransactionEvent(Event{Type: EventCreated, TransactionID: "t2", Method: "BYE", State: StateTrying, Time: start.Add(time.Minute)})
+	history.OnTransactionEvent(Event{Type: EventTransportError, TransactionID: "t2", State: StateTrying, Err: errors.New("unreachable"), Time: start.Add(time.Minute)})
+
+	// Events of transactions created before the history are ignored
+	history.OnTransactionEvent(Event{Type: EventStateChanged, TransactionID: "t0", State: StateCompleted})
+
+	recent := history.Recent()
+	if len(recent) != 2 || recent[0].ID != "t2" || recent[1].ID != "t1" {
+		t.Fatalf("Expected t2 and t1, got %+v", recent)
+	}
+
+	expected := TransactionRecord{
+		ID:              "t1",
+		Method:          "INVITE",
+		Client:          true,
+		State:           "Terminated",
+		Created:         start,
+		Updated:         start.Add(32 * time.Second),
+		Terminated:      true,
+		Retransmissions: 1,
+		TimedOut:        true,
+		LastTimer:       "B",
+	}
+	if recent[1] != expected {
+		t.Errorf("Expected %+v, got %+v", expected, recent[1])
+	}
+	if recent[0].LastError != "unreachable" || recent[0].Terminated {
+		t.Errorf("Expected t2 to be pending with its transport error, got %+v", recent[0])
+	}
+
+	// The oldest transaction makes room for new ones
+	history.OnTransactionEvent(Event{Type: EventCreated, TransactionID: "t3", Method: "OPTIONS", State: StateTrying})
+	history.OnTransactionEvent(Event{Type: EventRetransmitted, TransactionID: "t1", State: StateCalling, Timer: TimerA})
+	recent = history.Recent()
+	if len(recent) != 2 || recent[0].ID != "t3" || recent[1].ID != "t2" {
+		t.Errorf("Expected t3 and t2, got %+v", recent)
+	}
+}
+
+func TestHistoryManyTransactions(t *testing.T) {
+	history := NewHistory(10)
+	for i := 0; i < 25; i++ {
+		history.OnTransactionEvent(Event{Type: EventCreated, TransactionID: fmt.Sprintf("t%d", i)})
+	}
+
+	recent := history.Recent()
+	if len(recent) != 10 {
+		t.Fatalf("Expected 10 records, got %d", len(recent))
+	}
+	for i, record := range recent {
+		if expected := fmt.Sprintf("t%d", 24-i); record.ID != expected {
+			t.Errorf("Expected %s at %d, got %s", expected, i, record.ID)
+		}
+	}
+}
@@ -50,4 +50,25 @@ type TransactionManager interface {
 	CreateTransaction(msg *parser.SIPMessage) Transaction
 	FindTransaction(msg *parser.SIPMessage) Transaction
 	CleanupExpired()
-}
\ No newline at end of file
+}
+
+// CancelManager binds CANCEL requests to the INVITE server transactions they
+// cancel, so that the proxy, the hunt group engine and the B2BUA can stop
+// processing an INVITE when its CANCEL arrives
+type CancelManager interface {
+	// OnCancel registers callback on the INVITE server transaction of invite
+	// and reports whether there is one
+	OnCancel(invite *parser.SIPMessage, callback CancelFunc) bool
+	// ProcessCancel answers a CANCEL request with 200, or 481 if it matches no
+	// INVITE server transaction, and cancels the INVITE it matches
+	ProcessCancel(cancel *parser.SIPMessage) (Transaction, error)
+}
+
+// ClientManager runs client transactions for transaction users that pick the
+// destination of their requests themselves, such as the stateful proxy
+type ClientManager interface {
+	// SendClientRequest sends request, and later its retransmissions, with send
+	// in a new client transaction. onTimeout gets the 408 Request Timeout the
+	// transaction passes up when no final response arrives in time.
+	SendClientRequest(request *parser.SIPMessage, send func(*parser.SIPMessage) error, onTimeout ResponseFunc) (Transaction, error)
+}
@@ -0,0 +1,51 @@
+package transaction
+
+import (
+	"github.com/zurustar/xylitol2/internal/logging"
+)
+
+// LogObserver writes transaction events to a logger. Timeouts and transport
+// errors, which explain why a call failed, are logged as warnings and
+// everything else at debug level.
+type LogObserver struct {
+	logger logging.Logger
+}
+
+// NewLogObserver creates an observer that logs to logger
+func NewLogObserver(logger logging.Logger) *LogObserver {
+	return &LogObserver{logger: logger}
+}
+
+// OnTransactionEvent logs event
+func (o *LogObserver) OnTransactionEvent(event Event) {
+	fields := []logging.Field{
+		{Key: "transaction_id", Value: event.TransactionID},
+		{Key: "method", Value: event.Method},
+		{Key: "client", Value: event.Client},
+		{Key: "state", Value: event.State.String()},
+	}
+
+	switch event.Type {
+	case EventCreated:
+		o.logger.Debug("Transaction created", fields...)
+	case EventStateChanged:
+		fields = append(fields, logging.Field{Key: "previous_state", Value: event.PreviousState.String()})
+		o.logger.Debug("Transaction state changed", fields...)
+	case EventRetransmitted:
+		fields = append(fields, logging.Field{Key: "timer", Value: event.Timer.String()})
+		o.logger.Debug("Transaction retransmitted", fields...)
+	case EventTimerFired:
+		fields = append(fields, logging.Field{Key: "timer", Value: event.Timer.String()})
+		if isTimeout(event.Timer) {
+			o.logger.Warn("Transaction timed out", fields...)
+		} else {
+			o.logger.Debug("Transaction timer fired", fields...)
+		}
+	case EventTransportError:
+		fields = append(fields, logging.Field{Key: "error", Value: event.Err.Error()})
+		o.logger.Warn("Transaction transport error", fields...)
+	case EventTerminated:
+		fields = append(fields, logging.Field{Key: "previous_state", Value: event.PreviousState.String()})
+		o.logger.Debug("Transaction terminated", fields...)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/zurustar/xylitol2/internal/clock"
 	"github.com/zurustar/xylitol2/internal/parser"
 )
 
@@ -14,15 +15,23 @@ type Manager struct {
 	sendMessage  func(*parser.SIPMessage) error
 	cleanupTicker *time.Ticker
 	stopCleanup   chan bool
+	timers        Timers
+	wheel         *timerWheel
+	clock         clock.Clock
+	observer      Observer
 }
 
-// NewManager creates a new transaction manager
+// NewManager creates a new transaction manager. The timers of its transactions
+// all run on one timer wheel owned by the manager.
 func NewManager(sendFunc func(*parser.SIPMessage) error) *Manager {
 	m := &Manager{
 		transactions: make(map[string]Transaction),
 		sendMessage:  sendFunc,
 		stopCleanup:  make(chan bool),
+		timers:       DefaultTimers(),
+		wheel:        newTimerWheel(wheelTick),
 	}
+	m.clock = m.wheel
 
 	// Start cleanup goroutine
 	m.startCleanupRoutine()
@@ -30,6 +39,45 @@ func NewManager(sendFunc func(*parser.SIPMessage) error) *Manager {
 	return m
 }
 
+// SetTimers sets the timer values of transactions created from now on; zero
+// values take the RFC3261 defaults
+func (m *Manager) SetTimers(timers Timers) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.timers = timers.withDefaults()
+}
+
+// SetClock sets the clock the timers of transactions created from now on run
+// on in place of the manager's timer wheel, so that tests can drive them
+func (m *Manager) SetClock(c clock.Clock) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.clock = c
+}
+
+// AddObserver adds an observer that transactions created from now on report
+// their events to
+func (m *Manager) AddObserver(observer Observer) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	switch current := m.observer.(type) {
+	case nil:
+		m.observer = observer
+	case observers:
+		// Transactions created so far keep the observers they were created with
+		m.observer = append(append(observers{}, current...), observer)
+	default:
+		m.observer = observers{current, observer}
+	}
+}
+
+// Timers returns the timer values transactions are created with
+func (m *Manager) Timers() Timers {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.timers
+}
+
 // CreateTransaction creates a new transaction based on the message
 func (m *Manager) CreateTransaction(msg *parser.SIPMessage) Transaction {
 	m.mutex.Lock()
@@ -39,7 +87,7 @@ func (m *Manager) CreateTransaction(msg *parser.SIPMessage) Transaction {
 
 	if msg.IsRequest() {
 		// Create server transaction
-		transaction = NewServerTransaction(msg, m.sendMessage)
+		transaction = newServerTransaction(msg, m.sendMessage, m.clock, m.timers, m.observer)
 	} else {
 		// This shouldn't happen in normal operation
 		// Client transactions are created when sending requests
@@ -62,7 +110,7 @@ func (m *Manager) CreateClientTransaction(msg *parser.SIPMessage) Transaction {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	transaction := NewClientTransaction(msg, m.sendMessage)
+	transaction := newClientTransaction(msg, m.sendMessage, m.clock, m.timers, m.observer)
 	id := transaction.GetID()
 	m.transactions[id] = transaction
 
@@ -105,7 +153,6 @@ func (m *Manager) RemoveTransaction(id string) {
 // CleanupExpired removes expired transactions
 func (m *Manager) CleanupExpired() {
 	m.mutex.Lock()
-	defer m.mutex.Unlock()
 
 	expiredIDs := make([]string, 0)
 
@@ -124,17 +171,32 @@ func (m *Manager) CleanupExpired() {
 	}
 
 	// Remove expired transactions
+	var abandoned []*BaseTransaction
 	for _, id := range expiredIDs {
 		if transaction, exists := m.transactions[id]; exists {
 			// Cancel all timers before removing
-			if bt, ok := transaction.(*ClientTransaction); ok {
-				bt.CancelAllTimers()
-			} else if bt, ok := transaction.(*ServerTransaction); ok {
+			var bt *BaseTransaction
+			if ct, ok := transaction.(*ClientTransaction); ok {
+				bt = ct.BaseTransaction
+			} else if st, ok := transaction.(*ServerTransaction); ok {
+				bt = st.BaseTransaction
+			}
+			if bt != nil {
 				bt.CancelAllTimers()
+				if bt.GetState() != StateTerminated {
+					abandoned = append(abandoned, bt)
+				}
 			}
 			delete(m.transactions, id)
 		}
 	}
+	m.mutex.Unlock()
+
+	// Transactions that expired without terminating end here
+	for _, bt := range abandoned {
+		state := bt.GetState()
+		bt.notify(Event{Type: EventTerminated, State: StateTerminated, PreviousState: state})
+	}
 }
 
 // GetTransactionCount returns the number of active transactions
@@ -158,8 +220,59 @@ func (m *Manager) GetTransactions() map[string]Transaction {
 	return transactions
 }
 
+// FindInviteTransaction returns the INVITE server transaction a CANCEL request
+// matches (RFC 3261 section 9.2), or nil if there is none
+func (m *Manager) FindInviteTransaction(cancel *parser.SIPMessage) *ServerTransaction {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	st, _ := m.transactions[inviteTransactionID(cancel)].(*ServerTransaction)
+	return st
+}
+
+// OnCancel registers callback on the INVITE server transaction of invite and
+// reports whether there is one
+func (m *Manager) OnCancel(invite *parser.SIPMessage, callback CancelFunc) bool {
+	m.mutex.RLock()
+	st, ok := m.transactions[generateTransactionID(invite)].(*ServerTransaction)
+	m.mutex.RUnlock()
+	if !ok || st.method != parser.MethodINVITE {
+		return false
+	}
+	st.OnCancel(callback)
+	return true
+}
+
+// ProcessCancel handles a CANCEL request in a server transaction of its own.
+// A CANCEL matching no INVITE server transaction is answered with 481; any
+// other gets a 200 carrying the To tag of the INVITE's responses, after which
+// the INVITE is cancelled if it has not had a final response yet.
+// Retransmissions of the CANCEL get the same response again.
+func (m *Manager) ProcessCancel(cancel *parser.SIPMessage) (Transaction, error) {
+	if transaction := m.FindTransaction(cancel); transaction != nil {
+		return transaction, transaction.ProcessMessage(cancel)
+	}
+	transaction := m.CreateTransaction(cancel)
+
+	invite := m.FindInviteTransaction(cancel)
+	if invite == nil {
+		response := newResponse(cancel, parser.StatusCallTransactionDoesNotExist, generateRandomString(16))
+		return transaction, transaction.SendResponse(response)
+	}
+
+	if err := transaction.SendResponse(newResponse(cancel, parser.StatusOK, invite.responseTag())); err != nil {
+		return transaction, err
+	}
+	invite.Cancel(cancel)
+	return transaction, nil
+}
+
 // ProcessMessage processes an incoming message and routes it to the appropriate transaction
 func (m *Manager) ProcessMessage(msg *parser.SIPMessage) error {
+	if msg.IsRequest() && msg.GetMethod() == parser.MethodCANCEL {
+		_, err := m.ProcessCancel(msg)
+		return err
+	}
+
 	// Find existing transaction
 	transaction := m.FindTransaction(msg)
 
@@ -201,10 +314,9 @@ func (m *Manager) SendRequest(msg *parser.SIPMessage) (Transaction, error) {
 		return nil, nil
 	}
 
-	// Send the message
-	if m.sendMessage != nil {
-		err := m.sendMessage(msg)
-		if err != nil {
+	// Send the message through the transaction, which reports a failure to its observer
+	if ct, ok := transaction.(*ClientTransaction); ok {
+		if err := ct.send(msg); err != nil {
 			// Remove the transaction if sending failed
 			m.RemoveTransaction(transaction.GetID())
 			return nil, err
@@ -214,6 +326,29 @@ func (m *Manager) SendRequest(msg *parser.SIPMessage) (Transaction, error) {
 	return transaction, nil
 }
 
+// SendClientRequest sends request in a new client transaction whose messages go
+// through send rather than the manager's send function. onTimeout, if not nil,
+// gets the 408 the transaction passes up when Timer B or F fires.
+func (m *Manager) SendClientRequest(request *parser.SIPMessage, send func(*parser.SIPMessage) error, onTimeout ResponseFunc) (Transaction, error) {
+	if !request.IsRequest() {
+		return nil, nil
+	}
+
+	m.mutex.Lock()
+	transaction := newClientTransaction(request, send, m.clock, m.timers, m.observer)
+	if onTimeout != nil {
+		transaction.OnTimeout(onTimeout)
+	}
+	m.transactions[transaction.GetID()] = transaction
+	m.mutex.Unlock()
+
+	if err := transaction.send(request); err != nil {
+		m.RemoveTransaction(transaction.GetID())
+		return nil, err
+	}
+	return transaction, nil
+}
+
 // SendResponse sends a response through an existing server transaction
 func (m *Manager) SendResponse(msg *parser.SIPMessage, transactionID string) error {
 	if !msg.IsResponse() {
@@ -269,4 +404,7 @@ func (m *Manager) Stop() {
 		}
 		delete(m.transactions, id)
 	}
+
+	// Stop the timer wheel
+	m.wheel.Close()
 }
\ No newline at end of file
@@ -1,8 +1,11 @@
 package transaction
 
 import (
+	"errors"
 	"testing"
+	"time"
 
+	"github.com/zurustar/xylitol2/internal/clock"
 	"github.com/zurustar/xylitol2/internal/parser"
 )
 
@@ -165,19 +168,19 @@ func TestManagerTransactionMatching(t *testing.T) {
 		t.Error("ACK transaction ID should match INVITE transaction ID")
 	}
 
-	// Create CANCEL with same branch - should match INVITE transaction
+	// Create CANCEL with same branch - has its own transaction but matches the INVITE
 	cancel := createTestMessage(parser.MethodCANCEL, map[string]string{
 		parser.HeaderVia:    "SIP/2.0/UDP 192.168.1.1:5060;branch=z9hG4bKtest123",
 		parser.HeaderCallID: "test-call-id",
 	})
 
-	foundCancel := manager.FindTransaction(cancel)
-	if foundCancel == nil {
-		t.Error("CANCEL should match INVITE transaction")
+	if foundCancel := manager.FindTransaction(cancel); foundCancel != nil {
+		t.Error("CANCEL should not match INVITE transaction")
 	}
 
-	if foundCancel.GetID() != transaction.GetID() {
-		t.Error("CANCEL transaction ID should match INVITE transaction ID")
+	foundInvite := manager.FindInviteTransaction(cancel)
+	if foundInvite == nil || foundInvite.GetID() != transaction.GetID() {
+		t.Error("CANCEL should be matched to INVITE transaction")
 	}
 
 	// Create different method with same branch - should NOT match
@@ -282,6 +285,64 @@ func TestManagerSendRequest(t *testing.T) {
 	}
 }
 
+func TestManagerSendClientRequest(t *testing.T) {
+	manager := NewManager(func(msg *parser.SIPMessage) error {
+		t.Errorf("Expected messages to go through the request's send function, got %s", msg.GetMethod())
+		return nil
+	})
+	defer manager.Stop()
+	c := clock.NewFake(time.Now())
+	manager.SetClock(c)
+
+	var sent []*parser.SIPMessage
+	send := func(msg *parser.SIPMessage) error {
+		sent = append(sent, msg)
+		return nil
+	}
+	var timeouts []*parser.SIPMessage
+	onTimeout := func(response *parser.SIPMessage) {
+		timeouts = append(timeouts, response)
+	}
+
+	invite := createTestMessage(parser.MethodINVITE, map[string]string{
+		parser.HeaderVia:    "SIP/2.0/UDP 192.168.1.1:5060;branch=z9hG4bKclient1",
+		parser.HeaderCallID: "client-call-id",
+	})
+	transaction, err := manager.SendClientRequest(invite, send, onTimeout)
+	if err != nil {
+		t.Fatalf("SendClientRequest failed: %v", err)
+	}
+	if !transaction.IsClient() || manager.FindTransaction(invite) != transaction {
+		t.Fatal("Expected a client transaction the manager finds")
+	}
+	if len(sent) != 1 {
+		t.Fatalf("Expected the request to be sent once, got %d", len(sent))
+	}
+
+	c.Advance(manager.Timers().B)
+	if len(sent) != 7 {
+		t.Errorf("Expected 6 retransmissions through send, got %d", len(sent)-1)
+	}
+	if len(timeouts) != 1 || timeouts[0].GetStatusCode() != parser.StatusRequestTimeout {
+		t.Fatalf("Expected a 408 on Timer B, got %v", timeouts)
+	}
+
+	// A request that cannot be sent leaves no transaction behind
+	failing := createTestMessage(parser.MethodINVITE, map[string]string{
+		parser.HeaderVia:    "SIP/2.0/UDP 192.168.1.1:5060;branch=z9hG4bKclient2",
+		parser.HeaderCallID: "client-call-id",
+	})
+	_, err = manager.SendClientRequest(failing, func(*parser.SIPMessage) error {
+		return errors.New("unreachable")
+	}, onTimeout)
+	if err == nil {
+		t.Error("Expected the send error")
+	}
+	if manager.FindTransaction(failing) != nil {
+		t.Error("Expected the failed transaction to be removed")
+	}
+}
+
 func TestManagerSendResponse(t *testing.T) {
 	sentMessages := []*parser.SIPMessage{}
 	sendFunc := func(msg *parser.SIPMessage) error {
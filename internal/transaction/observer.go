@@ -0,0 +1,108 @@
+package transaction
+
+import (
+	"time"
+)
+
+// EventType identifies what happened to a transaction
+type EventType int
+
+const (
+	EventCreated        EventType = iota // The transaction was created
+	EventStateChanged                    // The transaction moved to another state
+	EventRetransmitted                   // A retransmission timer resent a request or response
+	EventTimerFired                      // A transaction timer expired
+	EventTransportError                  // Sending a message of the transaction failed
+	EventTerminated                      // The transaction terminated or expired
+)
+
+// String returns the string representation of the event type
+func (et EventType) String() string {
+	switch et {
+	case EventCreated:
+		return "Created"
+	case EventStateChanged:
+		return "StateChanged"
+	case EventRetransmitted:
+		return "Retransmitted"
+	case EventTimerFired:
+		return "TimerFired"
+	case EventTransportError:
+		return "TransportError"
+	case EventTerminated:
+		return "Terminated"
+	default:
+		return "Unknown"
+	}
+}
+
+// String returns the RFC3261 name of the timer
+func (tt TimerType) String() string {
+	switch tt {
+	case TimerA:
+		return "A"
+	case TimerB:
+		return "B"
+	case TimerD:
+		return "D"
+	case TimerE:
+		return "E"
+	case TimerF:
+		return "F"
+	case TimerG:
+		return "G"
+	case TimerH:
+		return "H"
+	case TimerI:
+		return "I"
+	case TimerJ:
+		return "J"
+	case TimerK:
+		return "K"
+	default:
+		return "Unknown"
+	}
+}
+
+// Event describes something that happened to a transaction
+type Event struct {
+	Type          EventType
+	TransactionID string
+	Method        string
+	Client        bool
+	State         TransactionState // State after the event
+	PreviousState TransactionState // State before a state change
+	Timer         TimerType        // Timer that fired or retransmitted, if any
+	Err           error            // Error of a transport error
+	Time          time.Time
+}
+
+// Observer is notified of transaction events. It is called synchronously by
+// the transaction layer, possibly from timer goroutines, so it must be safe for
+// concurrent use and must not block.
+type Observer interface {
+	OnTransactionEvent(event Event)
+}
+
+// ObserverFunc adapts a function to the Observer interface
+type ObserverFunc func(event Event)
+
+// OnTransactionEvent calls f
+func (f ObserverFunc) OnTransactionEvent(event Event) {
+	f(event)
+}
+
+// observers notifies several observers in turn
+type observers []Observer
+
+func (o observers) OnTransactionEvent(event Event) {
+	for _, observer := range o {
+		observer.OnTransactionEvent(event)
+	}
+}
+
+// isTimeout reports whether the expiry of timer means the peer never answered:
+// Timer B and F wait for a final response, Timer H for the ACK
+func isTimeout(timer TimerType) bool {
+	return timer == TimerB || timer == TimerF || timer == TimerH
+}
@@ -0,0 +1,199 @@
+package transaction
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zurustar/xylitol2/internal/clock"
+	"github.com/zurustar/xylitol2/internal/logging"
+	"github.com/zurustar/xylitol2/internal/parser"
+)
+
+// eventRecorder records the events it observes
+type eventRecorder struct {
+	mutex  sync.Mutex
+	events []Event
+}
+
+func (r *eventRecorder) OnTransactionEvent(event Event) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.events = append(r.events, event)
+}
+
+// summary describes each event by its type, state and timer
+func (r *eventRecorder) summary() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var summary []string
+	for _, event := range r.events {
+		s := event.Type.String() + " " + event.State.String()
+		if event.Type == EventRetransmitted || event.Type == EventTimerFired {
+			s += " " + event.Timer.String()
+		}
+		summary = append(summary, s)
+	}
+	return summary
+}
+
+func TestManagerObserverClientTimeout(t *testing.T) {
+	manager := NewManager(func(*parser.SIPMessage) error { return nil })
+	defer manager.Stop()
+	c := clock.NewFake(time.Now())
+	manager.SetClock(c)
+	recorder := &eventRecorder{}
+	manager.AddObserver(recorder)
+
+	register := createTestMessage(parser.MethodREGISTER, map[string]string{
+		parser.HeaderVia:    "SIP/2.0/UDP 192.168.1.1:5060;branch=z9hG4bKtest456",
+		parser.HeaderCallID: "test-call-id",
+	})
+	if _, err := manager.SendRequest(register); err != nil {
+		t.Fatalf("SendRequest failed: %v", err)
+	}
+
+	// Timer E retransmits at 0.5, 1.5 and 3.5 seconds until Timer F gives up
+	c.Advance(4 * time.Second)
+	c.Advance(64*TimerT1 - 4*time.Second)
+
+	expected := []string{
+		"Created Trying",
+		"TimerFired Trying E", "Retransmitted Trying E",
+		"TimerFired Trying E", "Retransmitted Trying E",
+		"TimerFired Trying E", "Retransmitted Trying E",
+	}
+	summary := recorder.summary()
+	if len(summary) < len(expected) || !reflect.DeepEqual(summary[:len(expected)], expected) {
+		t.Fatalf("Expected events to start with %v, got %v", expected, summary)
+	}
+	end := []string{"TimerFired Trying F", "StateChanged Terminated", "Terminated Terminated"}
+	if !reflect.DeepEqual(summary[len(summary)-3:], end) {
+		t.Errorf("Expected events to end with %v, got %v", end, summary)
+	}
+
+	for _, event := range recorder.events {
+		if event.TransactionID != generateTransactionID(register) || event.Method != parser.MethodREGISTER || !event.Client {
+			t.Errorf("Unexpected transaction details in %+v", event)
+		}
+	}
+	if last := recorder.events[len(recorder.events)-1]; !last.Time.Equal(c.Now()) {
+		t.Errorf("Expected event time %v, got %v", c.Now(), last.Time)
+	}
+}
+
+func TestManagerObserverServerTransaction(t *testing.T) {
+	sendErr := errors.New("connection refused")
+	manager := NewManager(func(*parser.SIPMessage) error { return sendErr })
+	defer manager.Stop()
+	c := clock.NewFake(time.Now())
+	manager.SetClock(c)
+	recorder := &eventRecorder{}
+	manager.AddObserver(recorder)
+
+	invite := createTestMessage(parser.MethodINVITE, map[string]string{
+		parser.HeaderVia:    "SIP/2.0/UDP 192.168.1.1:5060;branch=z9hG4bKtest123",
+		parser.HeaderCallID: "test-call-id",
+	})
+	st := manager.CreateTransaction(invite)
+
+	if err := st.SendResponse(newResponse(invite, parser.StatusBusyHere, "totag")); !errors.Is(err, sendErr) {
+		t.Fatalf("Expected the transport error, got %v", err)
+	}
+
+	expected := []string{"Created Proceeding", "TransportError Proceeding"}
+	if summary := recorder.summary(); !reflect.DeepEqual(summary, expected) {
+		t.Errorf("Expected %v, got %v", expected, summary)
+	}
+	if err := recorder.events[1].Err; !errors.Is(err, sendErr) {
+		t.Errorf("Expected the transport error in the event, got %v", err)
+	}
+}
+
+func TestManagerObserverExpired(t *testing.T) {
+	manager := NewManager(nil)
+	defer manager.Stop()
+	c := clock.NewFake(time.Now())
+	manager.SetClock(c)
+	recorder := &eventRecorder{}
+	manager.AddObserver(recorder)
+
+	// An OPTIONS that never gets a response is terminated when it expires
+	options := createTestMessage(parser.MethodOPTIONS, map[string]string{
+		parser.HeaderVia:    "SIP/2.0/UDP 192.168.1.1:5060;branch=z9hG4bKtest123",
+		parser.HeaderCallID: "test-call-id",
+	})
+	manager.CreateTransaction(options)
+
+	c.Advance(64*TimerT1 + time.Second)
+	manager.CleanupExpired()
+
+	expected := []string{"Created Trying", "Terminated Terminated"}
+	if summary := recorder.summary(); !reflect.DeepEqual(summary, expected) {
+		t.Fatalf("Expected %v, got %v", expected, summary)
+	}
+	if previous := recorder.events[1].PreviousState; previous != StateTrying {
+		t.Errorf("Expected previous state Trying, got %v", previous)
+	}
+}
+
+func TestManagerAddObserver(t *testing.T) {
+	manager := NewManager(nil)
+	defer manager.Stop()
+
+	first := &eventRecorder{}
+	manager.AddObserver(first)
+	manager.CreateTransaction(createTestMessage(parser.MethodOPTIONS, nil))
+
+	// Observers added later see only the transactions created after them
+	second := &eventRecorder{}
+	manager.AddObserver(second)
+	manager.CreateTransaction(createTestMessage(parser.MethodINFO, nil))
+
+	if len(first.events) != 2 {
+		t.Errorf("Expected the first observer to see both transactions, got %d events", len(first.events))
+	}
+	if len(second.events) != 1 || second.events[0].Method != parser.MethodINFO {
+		t.Errorf("Expected the second observer to see the INFO transaction only, got %v", second.events)
+	}
+}
+
+// recordingLogger records the messages logged at each level
+type recordingLogger struct {
+	mutex    sync.Mutex
+	messages []string
+}
+
+func (l *recordingLogger) log(level, msg string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.messages = append(l.messages, level+": "+msg)
+}
+
+func (l *recordingLogger) Debug(msg string, fields ...logging.Field) { l.log("DEBUG", msg) }
+func (l *recordingLogger) Info(msg string, fields ...logging.Field)  { l.log("INFO", msg) }
+func (l *recordingLogger) Warn(msg string, fields ...logging.Field)  { l.log("WARN", msg) }
+func (l *recordingLogger) Error(msg string, fields ...logging.Field) { l.log("ERROR", msg) }
+
+func TestLogObserver(t *testing.T) {
+	logger := &recordingLogger{}
+	observer := NewLogObserver(logger)
+
+	observer.OnTransactionEvent(Event{Type: EventCreated, State: StateCalling})
+	observer.OnTransactionEvent(Event{Type: EventTimerFired, State: StateCalling, Timer: TimerA})
+	observer.OnTransactionEvent(Event{Type: EventTimerFired, State: StateCalling, Timer: TimerB})
+	observer.OnTransactionEvent(Event{Type: EventTransportError, State: StateCalling, Err: errors.New("unreachable")})
+
+	expected := []string{
+		"DEBUG: Transaction created",
+		"DEBUG: Transaction timer fired",
+		"WARN: Transaction timed out",
+		"WARN: Transaction transport error",
+	}
+	if !reflect.DeepEqual(logger.messages, expected) {
+		t.Errorf("Expected %s, got %s", strings.Join(expected, ", "), strings.Join(logger.messages, ", "))
+	}
+}
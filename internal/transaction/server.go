@@ -2,29 +2,42 @@ package transaction
 
 import (
 	"fmt"
+	"time"
 
+	"github.com/zurustar/xylitol2/internal/clock"
 	"github.com/zurustar/xylitol2/internal/parser"
 )
 
 // ServerTransaction represents a server transaction
 type ServerTransaction struct {
 	*BaseTransaction
-	sendMessage func(*parser.SIPMessage) error
+	sendMessage     func(*parser.SIPMessage) error
+	cancelCallbacks []CancelFunc
+	cancelled       bool
+	answered        bool // An INVITE final response was sent or is being sent
+	localTag        string
 }
 
-// NewServerTransaction creates a new server transaction
+// NewServerTransaction creates a new server transaction using the RFC3261 timer values
 func NewServerTransaction(msg *parser.SIPMessage, sendFunc func(*parser.SIPMessage) error) *ServerTransaction {
+	return newServerTransaction(msg, sendFunc, defaultWheel(), DefaultTimers(), nil)
+}
+
+// newServerTransaction creates a new server transaction whose timers run on clk
+// and whose events go to observer, if any
+func newServerTransaction(msg *parser.SIPMessage, sendFunc func(*parser.SIPMessage) error, clk clock.Clock, timers Timers, observer Observer) *ServerTransaction {
 	st := &ServerTransaction{
-		BaseTransaction: NewBaseTransaction(msg, false),
+		BaseTransaction: newBaseTransaction(msg, false, clk, timers, observer),
 		sendMessage:     sendFunc,
 	}
 
 	// Set initial state based on method
 	if msg.GetMethod() == parser.MethodINVITE {
-		st.setState(StateProceeding)
+		st.state = StateProceeding
 	} else {
-		st.setState(StateTrying)
+		st.state = StateTrying
 	}
+	st.notify(Event{Type: EventCreated, State: st.state})
 
 	return st
 }
@@ -48,14 +61,14 @@ func (st *ServerTransaction) processInviteRequest(msg *parser.SIPMessage) error
 	switch st.GetState() {
 	case StateProceeding:
 		// Retransmit last response if we have one
-		if st.lastResponse != nil && st.sendMessage != nil {
-			st.sendMessage(st.lastResponse)
+		if response := st.sentResponse(); response != nil {
+			st.send(response)
 		}
 
 	case StateCompleted:
 		// Retransmit final response
-		if st.lastResponse != nil && st.sendMessage != nil {
-			st.sendMessage(st.lastResponse)
+		if response := st.sentResponse(); response != nil {
+			st.send(response)
 		}
 
 	case StateConfirmed:
@@ -73,20 +86,20 @@ func (st *ServerTransaction) processNonInviteRequest(msg *parser.SIPMessage) err
 	switch st.GetState() {
 	case StateTrying:
 		// Retransmit last response if we have one
-		if st.lastResponse != nil && st.sendMessage != nil {
-			st.sendMessage(st.lastResponse)
+		if response := st.sentResponse(); response != nil {
+			st.send(response)
 		}
 
 	case StateProceeding:
 		// Retransmit last response if we have one
-		if st.lastResponse != nil && st.sendMessage != nil {
-			st.sendMessage(st.lastResponse)
+		if response := st.sentResponse(); response != nil {
+			st.send(response)
 		}
 
 	case StateCompleted:
 		// Retransmit final response
-		if st.lastResponse != nil && st.sendMessage != nil {
-			st.sendMessage(st.lastResponse)
+		if response := st.sentResponse(); response != nil {
+			st.send(response)
 		}
 
 	case StateTerminated:
@@ -103,7 +116,24 @@ func (st *ServerTransaction) SendResponse(response *parser.SIPMessage) error {
 	}
 
 	statusCode := response.GetStatusCode()
+
+	st.mutex.Lock()
+	// Responses go back the way the request came unless the caller routed them already
+	if response.Destination == nil && st.lastRequest != nil {
+		response.Transport = st.lastRequest.Transport
+		response.Destination = st.lastRequest.Source
+	}
+	if st.method == parser.MethodINVITE && statusCode >= 200 {
+		// Only one final response ends the INVITE, be it the TU's or the 487 of
+		// Cancel; the same one may be sent again
+		if st.answered && st.lastResponse.GetStatusCode() != statusCode {
+			st.mutex.Unlock()
+			return fmt.Errorf("final response %d already sent", st.lastResponse.GetStatusCode())
+		}
+		st.answered = true
+	}
 	st.lastResponse = response.Clone()
+	st.mutex.Unlock()
 
 	if st.method == parser.MethodINVITE {
 		return st.sendInviteResponse(statusCode, response)
@@ -115,10 +145,8 @@ func (st *ServerTransaction) SendResponse(response *parser.SIPMessage) error {
 // sendInviteResponse sends a response for INVITE server transactions
 func (st *ServerTransaction) sendInviteResponse(statusCode int, response *parser.SIPMessage) error {
 	// Send the response
-	if st.sendMessage != nil {
-		if err := st.sendMessage(response); err != nil {
-			return err
-		}
+	if err := st.send(response); err != nil {
+		return err
 	}
 
 	switch st.GetState() {
@@ -150,10 +178,8 @@ func (st *ServerTransaction) sendInviteResponse(statusCode int, response *parser
 // sendNonInviteResponse sends a response for non-INVITE server transactions
 func (st *ServerTransaction) sendNonInviteResponse(statusCode int, response *parser.SIPMessage) error {
 	// Send the response
-	if st.sendMessage != nil {
-		if err := st.sendMessage(response); err != nil {
-			return err
-		}
+	if err := st.send(response); err != nil {
+		return err
 	}
 
 	switch st.GetState() {
@@ -181,29 +207,41 @@ func (st *ServerTransaction) sendNonInviteResponse(statusCode int, response *par
 
 // startTimerG starts Timer G for INVITE server transactions (response retransmission)
 func (st *ServerTransaction) startTimerG(response *parser.SIPMessage) {
-	st.SetTimer(TimerG, TimerT1, func() {
-		if st.GetState() == StateCompleted {
-			// Retransmit response
-			if st.sendMessage != nil {
-				st.sendMessage(response)
-			}
-			// Set next retransmission with doubled interval, up to T2
-			interval := TimerT1 * 2
-			if interval > TimerT2 {
-				interval = TimerT2
-			}
-			st.SetTimer(TimerG, interval, func() {
-				if st.GetState() == StateCompleted && st.sendMessage != nil {
-					st.sendMessage(response)
-				}
-			})
+	st.retransmitResponse(response, st.timerValues.T1)
+}
+
+// retransmitResponse retransmits response after interval until an ACK arrives,
+// doubling the interval each time up to T2
+func (st *ServerTransaction) retransmitResponse(response *parser.SIPMessage, interval time.Duration) {
+	st.SetTimer(TimerG, interval, func() {
+		if st.GetState() != StateCompleted {
+			return
+		}
+		st.send(response)
+		st.notify(Event{Type: EventRetransmitted, State: StateCompleted, Timer: TimerG})
+		next := interval * 2
+		if next > st.timerValues.T2 {
+			next = st.timerValues.T2
 		}
+		st.retransmitResponse(response, next)
 	})
 }
 
+// send sends msg, telling the observer if that fails
+func (st *ServerTransaction) send(msg *parser.SIPMessage) error {
+	if st.sendMessage == nil {
+		return nil
+	}
+	err := st.sendMessage(msg)
+	if err != nil {
+		st.notify(Event{Type: EventTransportError, State: st.GetState(), Err: err})
+	}
+	return err
+}
+
 // startTimerH starts Timer H for INVITE server transactions (wait for ACK)
 func (st *ServerTransaction) startTimerH() {
-	st.SetTimer(TimerH, 64*TimerT1, func() {
+	st.SetTimer(TimerH, st.timerValues.H, func() {
 		if st.GetState() == StateCompleted {
 			st.setState(StateTerminated)
 		}
@@ -212,9 +250,9 @@ func (st *ServerTransaction) startTimerH() {
 
 // startTimerI starts Timer I for INVITE server transactions (wait for ACK retransmissions)
 func (st *ServerTransaction) startTimerI() {
-	duration := TimerT4
+	duration := st.timerValues.T4
 	if st.transport == "UDP" {
-		duration = TimerT4
+		duration = st.timerValues.T4
 	} else {
 		duration = 0 // Immediate transition for reliable transports
 	}
@@ -230,9 +268,9 @@ func (st *ServerTransaction) startTimerI() {
 
 // startTimerJ starts Timer J for non-INVITE server transactions
 func (st *ServerTransaction) startTimerJ() {
-	duration := TimerT4
+	duration := st.timerValues.T4
 	if st.transport == "UDP" {
-		duration = 64 * TimerT1
+		duration = 64 * st.timerValues.T1
 	} else {
 		duration = 0 // Immediate transition for reliable transports
 	}
@@ -269,6 +307,64 @@ func (st *ServerTransaction) HandleACK(ack *parser.SIPMessage) error {
 	return nil
 }
 
+// OnCancel registers callback to run when a CANCEL request cancels the INVITE
+// of the transaction
+func (st *ServerTransaction) OnCancel(callback CancelFunc) {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	st.cancelCallbacks = append(st.cancelCallbacks, callback)
+}
+
+// Cancel cancels the INVITE of the transaction if it has not had a final
+// response yet (RFC 3261 section 9.2): the callbacks registered with OnCancel
+// run and, unless one of them sent a final response, the INVITE is answered
+// with 487 Request Terminated. It reports whether the INVITE was cancelled.
+func (st *ServerTransaction) Cancel(cancel *parser.SIPMessage) bool {
+	st.mutex.Lock()
+	if st.method != parser.MethodINVITE || st.state != StateProceeding || st.answered || st.cancelled {
+		st.mutex.Unlock()
+		return false
+	}
+	st.cancelled = true
+	callbacks := st.cancelCallbacks
+	st.cancelCallbacks = nil
+	request := st.lastRequest
+	st.mutex.Unlock()
+
+	for _, callback := range callbacks {
+		callback(cancel)
+	}
+
+	// SendResponse refuses the 487 if a final response was sent in the meantime
+	if request != nil {
+		st.SendResponse(newResponse(request, parser.StatusRequestTerminated, st.responseTag()))
+	}
+	return true
+}
+
+// sentResponse returns the last response sent, for retransmission
+func (st *ServerTransaction) sentResponse() *parser.SIPMessage {
+	st.mutex.RLock()
+	defer st.mutex.RUnlock()
+	return st.lastResponse
+}
+
+// responseTag returns the To tag of the responses of the transaction: the one
+// of a response already sent or else one generated once
+func (st *ServerTransaction) responseTag() string {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	if st.lastResponse != nil {
+		if tag := extractTag(st.lastResponse.GetHeader(parser.HeaderTo)); tag != "" {
+			return tag
+		}
+	}
+	if st.localTag == "" {
+		st.localTag = generateRandomString(16)
+	}
+	return st.localTag
+}
+
 // GetState returns the current transaction state
 func (st *ServerTransaction) GetState() TransactionState {
 	return st.BaseTransaction.GetState()
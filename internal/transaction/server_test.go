@@ -1,9 +1,11 @@
 package transaction
 
 import (
+	"net"
 	"testing"
 	"time"
 
+	"github.com/zurustar/xylitol2/internal/clock"
 	"github.com/zurustar/xylitol2/internal/parser"
 )
 
@@ -57,6 +59,28 @@ func TestServerTransactionINVITE(t *testing.T) {
 	}
 }
 
+func TestServerTransactionResponseDestination(t *testing.T) {
+	var sent *parser.SIPMessage
+	sendFunc := func(msg *parser.SIPMessage) error {
+		sent = msg
+		return nil
+	}
+
+	options := createTestMessage(parser.MethodOPTIONS, nil)
+	options.Transport = "UDP"
+	options.Source = &net.UDPAddr{IP: net.ParseIP("203.0.113.5"), Port: 40000}
+
+	st := NewServerTransaction(options, sendFunc)
+	if err := st.SendResponse(parser.NewResponseMessage(200, "OK")); err != nil {
+		t.Fatalf("SendResponse failed: %v", err)
+	}
+
+	// The response should go back to where the request came from
+	if sent == nil || sent.Destination != options.Source || sent.Transport != "UDP" {
+		t.Errorf("Expected response routed to %v over UDP, got %+v", options.Source, sent)
+	}
+}
+
 func TestServerTransactionINVITEError(t *testing.T) {
 	sentMessages := []*parser.SIPMessage{}
 	sendFunc := func(msg *parser.SIPMessage) error {
@@ -193,7 +217,8 @@ func TestServerTransactionTimers(t *testing.T) {
 		parser.HeaderCallID: "test-call-id",
 	})
 
-	st := NewServerTransaction(invite, sendFunc)
+	c := clock.NewFake(time.Now())
+	st := newServerTransaction(invite, sendFunc, c, DefaultTimers(), nil)
 
 	// Send error response to start Timer H
 	busy := parser.NewResponseMessage(486, "Busy Here")
@@ -207,15 +232,8 @@ func TestServerTransactionTimers(t *testing.T) {
 		t.Errorf("Expected state Completed, got %v", st.GetState())
 	}
 
-	// Simulate Timer H timeout
-	st.SetTimer(TimerH, 10*time.Millisecond, func() {
-		if st.GetState() == StateCompleted {
-			st.setState(StateTerminated)
-		}
-	})
-
-	// Wait for timeout
-	time.Sleep(20 * time.Millisecond)
+	// Let Timer H fire
+	c.Advance(64 * TimerT1)
 
 	// Should be terminated
 	if st.GetState() != StateTerminated {
@@ -329,4 +347,64 @@ func TestServerTransactionTimerTransitions(t *testing.T) {
 	if st2.GetState() != StateTerminated {
 		t.Errorf("Expected state Terminated after Timer J, got %v", st2.GetState())
 	}
-}
\ No newline at end of file
+}
+
+func TestServerTransactionTimersVirtualTime(t *testing.T) {
+	timers := DefaultTimers()
+
+	t.Run("Timer G retransmits until Timer H", func(t *testing.T) {
+		r := newSendRecorder()
+		invite := createTestMessage(parser.MethodINVITE, nil)
+		invite.Transport = "UDP"
+		st := newServerTransaction(invite, r.send, r.clock, timers, nil)
+
+		st.SendResponse(parser.NewResponseMessage(486, "Busy Here"))
+		expectState(t, st, StateCompleted)
+
+		expected := append([]time.Duration{0}, retransmissionTimes...)
+		r.clock.Advance(timers.H - time.Millisecond)
+		r.expectTimes(t, expected)
+		expectState(t, st, StateCompleted)
+
+		r.clock.Advance(time.Millisecond)
+		expectState(t, st, StateTerminated)
+
+		r.clock.Advance(time.Minute)
+		r.expectTimes(t, expected)
+	})
+
+	t.Run("ACK stops Timer G and starts Timer I", func(t *testing.T) {
+		r := newSendRecorder()
+		invite := createTestMessage(parser.MethodINVITE, nil)
+		invite.Transport = "UDP"
+		st := newServerTransaction(invite, r.send, r.clock, timers, nil)
+
+		st.SendResponse(parser.NewResponseMessage(486, "Busy Here"))
+		r.clock.Advance(time.Second)
+		if err := st.HandleACK(createTestMessage(parser.MethodACK, nil)); err != nil {
+			t.Fatalf("HandleACK failed: %v", err)
+		}
+		expectState(t, st, StateConfirmed)
+
+		r.clock.Advance(timers.T4 - time.Millisecond)
+		expectState(t, st, StateConfirmed)
+		r.clock.Advance(time.Millisecond)
+		expectState(t, st, StateTerminated)
+		r.expectTimes(t, []time.Duration{0, 500 * time.Millisecond})
+	})
+
+	t.Run("Timer J", func(t *testing.T) {
+		r := newSendRecorder()
+		register := createTestMessage(parser.MethodREGISTER, nil)
+		register.Transport = "UDP"
+		st := newServerTransaction(register, r.send, r.clock, timers, nil)
+
+		st.SendResponse(parser.NewResponseMessage(200, "OK"))
+		expectState(t, st, StateCompleted)
+
+		r.clock.Advance(64*timers.T1 - time.Millisecond)
+		expectState(t, st, StateCompleted)
+		r.clock.Advance(time.Millisecond)
+		expectState(t, st, StateTerminated)
+	})
+}
@@ -8,6 +8,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/zurustar/xylitol2/internal/clock"
 	"github.com/zurustar/xylitol2/internal/parser"
 )
 
@@ -18,6 +19,46 @@ const (
 	TimerT4 = 5 * time.Second         // Maximum duration a message will remain in the network
 )
 
+// Timers holds the timer values transactions use. Zero values take the RFC3261
+// defaults; links with long round trips, such as satellite links, need a larger
+// T1 and T2.
+type Timers struct {
+	T1 time.Duration // RTT estimate
+	T2 time.Duration // Maximum retransmit interval
+	T4 time.Duration // Maximum duration a message will remain in the network
+	B  time.Duration // INVITE transaction timeout; 64*T1 if zero
+	F  time.Duration // Non-INVITE transaction timeout; 64*T1 if zero
+	H  time.Duration // Wait time for ACK receipt; 64*T1 if zero
+}
+
+// DefaultTimers returns the timer values of RFC3261
+func DefaultTimers() Timers {
+	return Timers{}.withDefaults()
+}
+
+// withDefaults returns the timers with zero values replaced by their defaults
+func (t Timers) withDefaults() Timers {
+	if t.T1 <= 0 {
+		t.T1 = TimerT1
+	}
+	if t.T2 <= 0 {
+		t.T2 = TimerT2
+	}
+	if t.T4 <= 0 {
+		t.T4 = TimerT4
+	}
+	if t.B <= 0 {
+		t.B = 64 * t.T1
+	}
+	if t.F <= 0 {
+		t.F = 64 * t.T1
+	}
+	if t.H <= 0 {
+		t.H = 64 * t.T1
+	}
+	return t
+}
+
 // Transaction timer types
 type TimerType int
 
@@ -38,8 +79,22 @@ const (
 type TransactionTimer struct {
 	Type     TimerType
 	Duration time.Duration
-	Timer    *time.Timer
 	Callback func()
+	entry    clock.Timer
+}
+
+// The timer wheel of transactions created outside a Manager
+var (
+	sharedWheel     *timerWheel
+	sharedWheelOnce sync.Once
+)
+
+// defaultWheel returns the timer wheel shared by transactions created outside a Manager
+func defaultWheel() *timerWheel {
+	sharedWheelOnce.Do(func() {
+		sharedWheel = newTimerWheel(wheelTick)
+	})
+	return sharedWheel
 }
 
 // BaseTransaction provides common functionality for all transactions
@@ -54,21 +109,35 @@ type BaseTransaction struct {
 	toTag        string
 	cseq        uint32
 	timers      map[TimerType]*TransactionTimer
+	clock       clock.Clock
+	timerValues Timers
+	observer    Observer
 	mutex       sync.RWMutex
 	lastRequest *parser.SIPMessage
 	lastResponse *parser.SIPMessage
 	transport   string
 	created     time.Time
+	changed     time.Time // When the transaction entered its current state
 }
 
-// NewBaseTransaction creates a new base transaction
+// NewBaseTransaction creates a new base transaction using the RFC3261 timer values
 func NewBaseTransaction(msg *parser.SIPMessage, isClient bool) *BaseTransaction {
+	return newBaseTransaction(msg, isClient, defaultWheel(), DefaultTimers(), nil)
+}
+
+// newBaseTransaction creates a new base transaction whose timers run on clk and
+// whose events go to observer, if any
+func newBaseTransaction(msg *parser.SIPMessage, isClient bool, clk clock.Clock, timers Timers, observer Observer) *BaseTransaction {
 	bt := &BaseTransaction{
-		id:       generateTransactionID(msg),
-		isClient: isClient,
-		timers:   make(map[TimerType]*TransactionTimer),
-		created:  time.Now(),
+		id:          generateTransactionID(msg),
+		isClient:    isClient,
+		timers:      make(map[TimerType]*TransactionTimer),
+		clock:       clk,
+		timerValues: timers,
+		observer:    observer,
+		created:     clk.Now(),
 	}
+	bt.changed = bt.created
 
 	if msg.IsRequest() {
 		bt.method = msg.GetMethod()
@@ -107,18 +176,42 @@ func (bt *BaseTransaction) IsClient() bool {
 // setState sets the transaction state
 func (bt *BaseTransaction) setState(state TransactionState) {
 	bt.mutex.Lock()
-	defer bt.mutex.Unlock()
+	previous := bt.state
 	bt.state = state
+	if state != previous {
+		bt.changed = bt.clock.Now()
+	}
+	bt.mutex.Unlock()
+
+	if state == previous {
+		return
+	}
+	bt.notify(Event{Type: EventStateChanged, State: state, PreviousState: previous})
+	if state == StateTerminated {
+		bt.notify(Event{Type: EventTerminated, State: state, PreviousState: previous})
+	}
+}
+
+// notify tells the observer about event, filling in the transaction details
+func (bt *BaseTransaction) notify(event Event) {
+	if bt.observer == nil {
+		return
+	}
+	event.TransactionID = bt.id
+	event.Method = bt.method
+	event.Client = bt.isClient
+	event.Time = bt.clock.Now()
+	bt.observer.OnTransactionEvent(event)
 }
 
-// SetTimer sets a timer for the transaction
+// SetTimer sets a timer for the transaction, replacing any timer of the same type
 func (bt *BaseTransaction) SetTimer(timerType TimerType, duration time.Duration, callback func()) {
 	bt.mutex.Lock()
 	defer bt.mutex.Unlock()
 
 	// Cancel existing timer if it exists
 	if existingTimer, exists := bt.timers[timerType]; exists {
-		existingTimer.Timer.Stop()
+		existingTimer.entry.Stop()
 	}
 
 	// Create new timer
@@ -128,10 +221,17 @@ func (bt *BaseTransaction) SetTimer(timerType TimerType, duration time.Duration,
 		Callback: callback,
 	}
 
-	timer.Timer = time.AfterFunc(duration, func() {
+	timer.entry = bt.clock.AfterFunc(duration, func() {
 		bt.mutex.Lock()
+		if bt.timers[timerType] != timer {
+			// Cancelled or replaced after it expired
+			bt.mutex.Unlock()
+			return
+		}
 		delete(bt.timers, timerType)
+		state := bt.state
 		bt.mutex.Unlock()
+		bt.notify(Event{Type: EventTimerFired, State: state, Timer: timerType})
 		callback()
 	})
 
@@ -144,7 +244,7 @@ func (bt *BaseTransaction) CancelTimer(timerType TimerType) {
 	defer bt.mutex.Unlock()
 
 	if timer, exists := bt.timers[timerType]; exists {
-		timer.Timer.Stop()
+		timer.entry.Stop()
 		delete(bt.timers, timerType)
 	}
 }
@@ -155,25 +255,36 @@ func (bt *BaseTransaction) CancelAllTimers() {
 	defer bt.mutex.Unlock()
 
 	for _, timer := range bt.timers {
-		timer.Timer.Stop()
+		timer.entry.Stop()
 	}
 	bt.timers = make(map[TimerType]*TransactionTimer)
 }
 
-// IsExpired checks if the transaction has expired
+// IsExpired checks if the transaction has outlived the timer that ends its
+// current state, which only happens if that timer was lost. An INVITE in
+// Proceeding never expires: it waits for a final response however long the
+// call rings, and a CANCEL must still find it.
 func (bt *BaseTransaction) IsExpired() bool {
 	bt.mutex.RLock()
 	defer bt.mutex.RUnlock()
-	
-	// Transaction expires after 32 seconds for INVITE, 64*T1 for non-INVITE
-	var expireTime time.Duration
-	if bt.method == parser.MethodINVITE {
-		expireTime = 32 * time.Second
-	} else {
-		expireTime = 64 * TimerT1
+
+	now := bt.clock.Now()
+	switch bt.state {
+	case StateProceeding, StateCalling, StateTrying:
+		if bt.method == parser.MethodINVITE {
+			// Timer B ends Calling; a server INVITE only leaves Proceeding
+			// with a final response
+			return bt.isClient && bt.state != StateProceeding && now.Sub(bt.created) > bt.timerValues.B
+		}
+		// Timer F ends a non-INVITE transaction waiting for its final response
+		return now.Sub(bt.created) > bt.timerValues.F
+	case StateCompleted, StateConfirmed:
+		// Timers D, H, I, J and K run for at most 32s, 64*T1 or Timer H
+		limit := max(bt.timerValues.H, 64*bt.timerValues.T1, 32*time.Second)
+		return now.Sub(bt.changed) > limit
+	default:
+		return true
 	}
-	
-	return time.Since(bt.created) > expireTime
 }
 
 // generateTransactionID generates a unique transaction ID
@@ -185,8 +296,9 @@ func generateTransactionID(msg *parser.SIPMessage) string {
 	
 	if branch != "" && strings.HasPrefix(branch, "z9hG4bK") {
 		// RFC3261 compliant branch parameter
-		if method == parser.MethodACK || method == parser.MethodCANCEL {
-			// ACK and CANCEL use the same transaction as the original INVITE
+		if method == parser.MethodACK {
+			// ACK uses the same transaction as the original INVITE; CANCEL
+			// has a transaction of its own, see inviteTransactionID
 			return fmt.Sprintf("%s-%s-%s", branch, parser.MethodINVITE, callID)
 		}
 		return fmt.Sprintf("%s-%s-%s", branch, method, callID)
@@ -198,22 +310,31 @@ func generateTransactionID(msg *parser.SIPMessage) string {
 	return fmt.Sprintf("%s-%s-%s-%s", callID, fromTag, cseq, method)
 }
 
+// inviteTransactionID returns the ID of the INVITE transaction a CANCEL request
+// matches (RFC 3261 section 9.2): the one with the same top Via branch and
+// Call-ID or, for a non-compliant branch, the same Call-ID, From tag and CSeq
+// number
+func inviteTransactionID(cancel *parser.SIPMessage) string {
+	branch := extractBranch(cancel.GetHeader(parser.HeaderVia))
+	callID := cancel.GetHeader(parser.HeaderCallID)
+
+	if branch != "" && strings.HasPrefix(branch, "z9hG4bK") {
+		return fmt.Sprintf("%s-%s-%s", branch, parser.MethodINVITE, callID)
+	}
+
+	fromTag := extractTag(cancel.GetHeader(parser.HeaderFrom))
+	cseq := fmt.Sprintf("%d %s", extractCSeq(cancel.GetHeader(parser.HeaderCSeq)), parser.MethodINVITE)
+	return fmt.Sprintf("%s-%s-%s-%s", callID, fromTag, cseq, parser.MethodINVITE)
+}
+
 // extractBranch extracts the branch parameter from Via header
 func extractBranch(via string) string {
-	if via == "" {
+	vias, err := parser.ParseViaList(via)
+	if err != nil || len(vias) == 0 {
 		return ""
 	}
 	
-	// Look for branch parameter
-	parts := strings.Split(via, ";")
-	for _, part := range parts {
-		part = strings.TrimSpace(part)
-		if strings.HasPrefix(part, "branch=") {
-			return strings.TrimPrefix(part, "branch=")
-		}
-	}
-	
-	return ""
+	return vias[0].Branch()
 }
 
 // extractTag extracts the tag parameter from From/To header
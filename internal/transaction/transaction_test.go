@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/zurustar/xylitol2/internal/clock"
 	"github.com/zurustar/xylitol2/internal/parser"
 )
 
@@ -35,7 +36,7 @@ func TestTransactionID(t *testing.T) {
 				parser.HeaderVia:    "SIP/2.0/UDP 192.168.1.1:5060;branch=z9hG4bKtest123",
 				parser.HeaderCallID: "test-call-id",
 			}),
-			expected: "z9hG4bKtest123-INVITE-test-call-id",
+			expected: "z9hG4bKtest123-CANCEL-test-call-id",
 		},
 		{
 			name: "REGISTER with RFC3261 branch",
@@ -226,17 +227,22 @@ func TestBaseTransactionExpiration(t *testing.T) {
 		parser.HeaderCallID: "test-call-id",
 	})
 
-	bt := NewBaseTransaction(msg, true)
+	c := clock.NewFake(time.Now())
+	bt := newBaseTransaction(msg, true, c, DefaultTimers(), nil)
 
 	// Should not be expired immediately
 	if bt.IsExpired() {
 		t.Error("Transaction should not be expired immediately")
 	}
 
-	// Test with old creation time
-	bt.created = time.Now().Add(-35 * time.Second)
+	// Not expired until Timer B has passed
+	c.Advance(64 * TimerT1)
+	if bt.IsExpired() {
+		t.Error("INVITE transaction should not be expired at 64*T1")
+	}
+	c.Advance(time.Millisecond)
 	if !bt.IsExpired() {
-		t.Error("INVITE transaction should be expired after 35 seconds")
+		t.Error("INVITE transaction should be expired after 64*T1")
 	}
 
 	// Test non-INVITE transaction
@@ -245,8 +251,8 @@ func TestBaseTransactionExpiration(t *testing.T) {
 		parser.HeaderCallID: "test-call-id-2",
 	})
 
-	bt2 := NewBaseTransaction(msg2, true)
-	bt2.created = time.Now().Add(-65 * TimerT1)
+	bt2 := newBaseTransaction(msg2, true, c, DefaultTimers(), nil)
+	c.Advance(65 * TimerT1)
 	if !bt2.IsExpired() {
 		t.Error("Non-INVITE transaction should be expired after 64*T1")
 	}
@@ -0,0 +1,269 @@
+package transaction
+
+import (
+	"sync"
+	"time"
+
+	"github.com/zurustar/xylitol2/internal/clock"
+)
+
+// Timer wheel geometry: 256 slots of one tick each at the first level and 64
+// slots at each of the higher levels, each slot spanning a whole turn of the
+// level below. With a 1ms tick the levels cover 256ms, 16s, 17m and 18h;
+// timers further out are parked at the top level and parked again each time
+// they cascade, until they fall within reach of the wheel.
+const (
+	wheelTick       = time.Millisecond
+	wheelRootBits   = 8
+	wheelLevelBits  = 6
+	wheelLevels     = 4
+	wheelRootSize   = 1 << wheelRootBits
+	wheelLevelSize  = 1 << wheelLevelBits
+	wheelRootMask   = wheelRootSize - 1
+	wheelLevelMask  = wheelLevelSize - 1
+	wheelMaxTimeout = 1<<(wheelRootBits+(wheelLevels-1)*wheelLevelBits) - 1
+)
+
+// wheelTimer is a callback scheduled on a timer wheel. Timers of a slot form a
+// doubly linked list so that they can be stopped in constant time.
+type wheelTimer struct {
+	wheel      *timerWheel
+	expires    uint64 // Tick the timer fires at
+	callback   func()
+	prev, next *wheelTimer
+	slot       *wheelTimer // Sentinel of the slot list the timer is in; nil once fired or stopped
+}
+
+// timerWheel runs the timers of many transactions on a single goroutine. A
+// hierarchical wheel makes starting and stopping a timer constant time however
+// many are pending, where a heap of runtime timers grows with the load
+// (Varghese and Lauck, "Hashed and Hierarchical Timing Wheels"). The goroutine
+// sleeps while no timers are pending. A wheel is the clock.Clock of the
+// transactions of a Manager.
+type timerWheel struct {
+	mutex   sync.Mutex
+	start   time.Time
+	tick    time.Duration
+	current uint64 // Last tick processed
+	slots   [wheelLevels][]wheelTimer
+	count   int
+	wake    chan struct{}
+	stop    chan struct{}
+	stopped sync.Once
+}
+
+// newTimerWheel creates a timer wheel and starts its goroutine
+func newTimerWheel(tick time.Duration) *timerWheel {
+	w := &timerWheel{
+		start: time.Now(),
+		tick:  tick,
+		wake:  make(chan struct{}, 1),
+		stop:  make(chan struct{}),
+	}
+	for level := range w.slots {
+		size := wheelLevelSize
+		if level == 0 {
+			size = wheelRootSize
+		}
+		w.slots[level] = make([]wheelTimer, size)
+		for i := range w.slots[level] {
+			sentinel := &w.slots[level][i]
+			sentinel.prev, sentinel.next = sentinel, sentinel
+		}
+	}
+	go w.run()
+	return w
+}
+
+// Schedule calls callback after duration, rounded up to the next tick.
+// Callbacks run outside the wheel's lock and may schedule further timers.
+func (w *timerWheel) Schedule(duration time.Duration, callback func()) *wheelTimer {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	elapsed := time.Since(w.start)
+	if w.count == 0 {
+		w.current = uint64(elapsed / w.tick) // Catch up on the ticks slept through
+	}
+
+	ticks := uint64(0)
+	if deadline := elapsed + duration; deadline > 0 {
+		ticks = uint64((deadline + w.tick - 1) / w.tick)
+	}
+	if ticks <= w.current {
+		ticks = w.current + 1 // Overdue timers fire on the next tick
+	}
+	timer := &wheelTimer{wheel: w, expires: ticks, callback: callback}
+	w.add(timer)
+
+	w.count++
+	if w.count == 1 {
+		select {
+		case w.wake <- struct{}{}:
+		default:
+		}
+	}
+	return timer
+}
+
+// Now returns the system time; the wheel follows it tick by tick
+func (w *timerWheel) Now() time.Time {
+	return time.Now()
+}
+
+// AfterFunc schedules f on the wheel
+func (w *timerWheel) AfterFunc(d time.Duration, f func()) clock.Timer {
+	return w.Schedule(d, f)
+}
+
+// Stop prevents the timer from firing and reports whether it was still pending
+func (t *wheelTimer) Stop() bool {
+	return t.wheel.Stop(t)
+}
+
+// Stop prevents timer from firing and reports whether it was still pending
+func (w *timerWheel) Stop(timer *wheelTimer) bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if timer.slot == nil {
+		return false
+	}
+	w.unlink(timer)
+	w.count--
+	return true
+}
+
+// Len returns the number of pending timers
+func (w *timerWheel) Len() int {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.count
+}
+
+// Close stops the wheel's goroutine; pending timers never fire
+func (w *timerWheel) Close() {
+	w.stopped.Do(func() {
+		close(w.stop)
+	})
+}
+
+// add links timer into the slot of the level its expiry falls in. Timers
+// cascading down may expire on the current tick, whose slot is processed next.
+// A timer beyond the wheel keeps its expiry and goes into the top level slot
+// that cascades before it is due.
+func (w *timerWheel) add(timer *wheelTimer) {
+	at := timer.expires
+	delta := at - w.current
+	if delta > wheelMaxTimeout {
+		at = w.current + wheelMaxTimeout
+		delta = wheelMaxTimeout
+	}
+
+	var sentinel *wheelTimer
+	switch {
+	case delta < wheelRootSize:
+		sentinel = &w.slots[0][at&wheelRootMask]
+	default:
+		level := 1
+		for delta >= 1<<(wheelRootBits+level*wheelLevelBits) {
+			level++
+		}
+		shift := wheelRootBits + (level-1)*wheelLevelBits
+		sentinel = &w.slots[level][(at>>shift)&wheelLevelMask]
+	}
+
+	timer.slot = sentinel
+	timer.prev = sentinel.prev
+	timer.next = sentinel
+	sentinel.prev.next = timer
+	sentinel.prev = timer
+}
+
+// unlink removes timer from its slot
+func (w *timerWheel) unlink(timer *wheelTimer) {
+	timer.prev.next = timer.next
+	timer.next.prev = timer.prev
+	timer.prev, timer.next, timer.slot = nil, nil, nil
+}
+
+// detach empties a slot and returns its timers
+func (w *timerWheel) detach(sentinel *wheelTimer) []*wheelTimer {
+	var timers []*wheelTimer
+	for timer := sentinel.next; timer != sentinel; {
+		next := timer.next
+		timer.prev, timer.next, timer.slot = nil, nil, nil
+		timers = append(timers, timer)
+		timer = next
+	}
+	sentinel.prev, sentinel.next = sentinel, sentinel
+	return timers
+}
+
+// cascade moves the timers of a slot at level down to the levels below and
+// returns the slot index, which is zero when the level has completed a turn
+func (w *timerWheel) cascade(level int) uint64 {
+	shift := wheelRootBits + (level-1)*wheelLevelBits
+	index := (w.current >> shift) & wheelLevelMask
+	for _, timer := range w.detach(&w.slots[level][index]) {
+		w.add(timer)
+	}
+	return index
+}
+
+// advance processes the ticks up to target and returns the expired timers
+func (w *timerWheel) advance(target uint64) []*wheelTimer {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.count == 0 {
+		w.current = target // Nothing can expire in between
+		return nil
+	}
+
+	var expired []*wheelTimer
+	for w.current < target {
+		w.current++
+		if w.current&wheelRootMask == 0 {
+			for level := 1; level < wheelLevels && w.cascade(level) == 0; level++ {
+			}
+		}
+		expired = append(expired, w.detach(&w.slots[0][w.current&wheelRootMask])...)
+	}
+	w.count -= len(expired)
+	return expired
+}
+
+// run advances the wheel every tick while timers are pending
+func (w *timerWheel) run() {
+	ticker := time.NewTicker(w.tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+		}
+
+		expired := w.advance(uint64(time.Since(w.start) / w.tick))
+		if len(expired) > 0 {
+			// Callbacks send retransmissions, so a slow one must not hold up the wheel
+			go func() {
+				for _, timer := range expired {
+					timer.callback()
+				}
+			}()
+		}
+
+		if w.Len() == 0 {
+			ticker.Stop()
+			select {
+			case <-w.stop:
+				return
+			case <-w.wake:
+			}
+			ticker.Reset(w.tick)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package transaction
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zurustar/xylitol2/internal/clock"
+	"github.com/zurustar/xylitol2/internal/parser"
+)
+
+// scheduleAt adds a timer firing on tick to a wheel driven by hand
+func scheduleAt(w *timerWheel, tick uint64, callback func()) *wheelTimer {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	timer := &wheelTimer{wheel: w, expires: tick, callback: callback}
+	w.add(timer)
+	w.count++
+	return timer
+}
+
+func TestTimerWheel_Cascade(t *testing.T) {
+	// An hour-long tick keeps the wheel's goroutine out of the way
+	w := newTimerWheel(time.Hour)
+	defer w.Close()
+
+	var fired []uint64
+	ticks := []uint64{1, 255, 256, 300, 1 << 14, 70000, 1<<20 + 5, 3 << 20}
+	for _, tick := range ticks {
+		tick := tick
+		scheduleAt(w, tick, func() { fired = append(fired, tick) })
+	}
+	stopped := scheduleAt(w, 500, func() { t.Error("Stopped timer fired") })
+	if !w.Stop(stopped) {
+		t.Error("Expected pending timer to stop")
+	}
+	if w.Stop(stopped) {
+		t.Error("Expected second stop to report the timer was not pending")
+	}
+
+	// Advance in uneven steps; no timer may expire before its tick
+	var current uint64
+	for _, step := range []uint64{1, 254, 1, 44, 16000, 100000, 1 << 20, 2 << 20} {
+		current += step
+		for _, timer := range w.advance(current) {
+			if timer.expires > current {
+				t.Errorf("Timer for tick %d expired at %d", timer.expires, current)
+			}
+			timer.callback()
+		}
+		for _, tick := range fired {
+			if tick > current {
+				t.Errorf("Timer for tick %d fired early at %d", tick, current)
+			}
+		}
+	}
+
+	if len(fired) != len(ticks) {
+		t.Fatalf("Expected %d timers to fire, got %v", len(ticks), fired)
+	}
+	for i, tick := range ticks {
+		if fired[i] != tick {
+			t.Errorf("Expected timers to fire in order %v, got %v", ticks, fired)
+			break
+		}
+	}
+	if w.Len() != 0 {
+		t.Errorf("Expected no pending timers, got %d", w.Len())
+	}
+}
+
+func TestTimerWheel_ExactTick(t *testing.T) {
+	w := newTimerWheel(time.Hour)
+	defer w.Close()
+
+	// Check each tick of a range spanning two levels
+	expiredAt := make(map[uint64]uint64)
+	for tick := uint64(1); tick <= 1<<15; tick += 97 {
+		scheduleAt(w, tick, nil)
+	}
+	for current := uint64(1); current <= 1<<15; current++ {
+		for _, timer := range w.advance(current) {
+			expiredAt[timer.expires] = current
+		}
+	}
+	for tick, current := range expiredAt {
+		if tick != current {
+			t.Errorf("Timer for tick %d expired at %d", tick, current)
+		}
+	}
+	if len(expiredAt) != (1<<15-1)/97+1 {
+		t.Errorf("Expected %d timers to expire, got %d", (1<<15-1)/97+1, len(expiredAt))
+	}
+}
+
+func TestTimerWheel_BeyondMaxTimeout(t *testing.T) {
+	w := newTimerWheel(time.Hour)
+	defer w.Close()
+
+	// Timers past the reach of the wheel must not fire early
+	ticks := []uint64{wheelMaxTimeout + 1, 2*wheelMaxTimeout + 7}
+	for _, tick := range ticks {
+		scheduleAt(w, tick, nil)
+	}
+	var current uint64
+	for _, tick := range ticks {
+		if expired := w.advance(tick - 1); len(expired) != 0 {
+			t.Errorf("Timer for tick %d expired at %d", expired[0].expires, tick-1)
+		}
+		expired := w.advance(tick)
+		if len(expired) != 1 || expired[0].expires != tick {
+			t.Errorf("Expected the timer for tick %d to expire on time, got %d timers", tick, len(expired))
+		}
+		current = tick
+	}
+	if w.Len() != 0 {
+		t.Errorf("Expected no pending timers at tick %d, got %d", current, w.Len())
+	}
+}
+
+func TestTimerWheel_Schedule(t *testing.T) {
+	w := newTimerWheel(wheelTick)
+	defer w.Close()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	start := time.Now()
+	var elapsed time.Duration
+	w.Schedule(20*time.Millisecond, func() {
+		elapsed = time.Since(start)
+		wg.Done()
+	})
+	// Callbacks may schedule further timers, which wakes a sleeping wheel
+	w.Schedule(time.Millisecond, func() {
+		w.Schedule(time.Millisecond, wg.Done)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Timers did not fire")
+	}
+	if elapsed < 20*time.Millisecond {
+		t.Errorf("Timer fired early after %v", elapsed)
+	}
+
+	// The wheel sleeps while idle and wakes for new timers
+	time.Sleep(10 * time.Millisecond)
+	fired := make(chan struct{})
+	w.Schedule(5*time.Millisecond, func() { close(fired) })
+	select {
+	case <-fired:
+	case <-time.After(time.Second):
+		t.Fatal("Timer scheduled on idle wheel did not fire")
+	}
+}
+
+func TestManager_SetTimers(t *testing.T) {
+	sent := 0
+	sendFunc := func(msg *parser.SIPMessage) error {
+		sent++
+		return nil
+	}
+
+	manager := NewManager(sendFunc)
+	defer manager.Stop()
+
+	if timers := manager.Timers(); timers != DefaultTimers() {
+		t.Errorf("Expected default timers, got %+v", timers)
+	}
+	if timers := DefaultTimers(); timers.T1 != TimerT1 || timers.B != 64*TimerT1 || timers.H != 32*time.Second {
+		t.Errorf("Unexpected default timers: %+v", timers)
+	}
+
+	manager.SetTimers(Timers{T1: 10 * time.Millisecond, T2: 20 * time.Millisecond, F: time.Second})
+	timers := manager.Timers()
+	if timers.B != 640*time.Millisecond || timers.H != 640*time.Millisecond || timers.F != time.Second || timers.T4 != TimerT4 {
+		t.Errorf("Unexpected derived timers: %+v", timers)
+	}
+
+	c := clock.NewFake(time.Now())
+	manager.SetClock(c)
+
+	invite := createTestMessage(parser.MethodINVITE, map[string]string{
+		parser.HeaderVia:    "SIP/2.0/UDP 192.168.1.1:5060;branch=z9hG4bKshortT1",
+		parser.HeaderCallID: "short-t1",
+	})
+	ct := manager.CreateClientTransaction(invite)
+
+	// Timer A follows the configured T1 rather than the default 500ms
+	c.Advance(10 * time.Millisecond)
+	if sent != 1 {
+		t.Errorf("Expected INVITE to be retransmitted after the configured T1, sent %d", sent)
+	}
+
+	// Timer B times the transaction out after 64*T1
+	c.Advance(630*time.Millisecond - time.Millisecond)
+	if ct.GetState() != StateCalling {
+		t.Errorf("Expected Calling before Timer B, state is %v", ct.GetState())
+	}
+	c.Advance(time.Millisecond)
+	if ct.GetState() != StateTerminated {
+		t.Errorf("Expected Timer B to terminate the transaction, state is %v", ct.GetState())
+	}
+}
+
+// The benchmarks compare starting and stopping timers with 100k transactions
+// pending, as a busy server has, on the wheel and on runtime timers
+
+const benchmarkPendingTimers = 100000
+
+func BenchmarkTimerWheel_100kPending(b *testing.B) {
+	w := newTimerWheel(wheelTick)
+	defer w.Close()
+	for i := 0; i < benchmarkPendingTimers; i++ {
+		w.Schedule(time.Duration(i%64+1)*time.Second, func() {})
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w.Stop(w.Schedule(TimerT1, func() {}))
+	}
+}
+
+func BenchmarkRuntimeTimers_100kPending(b *testing.B) {
+	pending := make([]*time.Timer, benchmarkPendingTimers)
+	for i := range pending {
+		pending[i] = time.AfterFunc(time.Duration(i%64+1)*time.Second, func() {})
+	}
+	defer func() {
+		for _, timer := range pending {
+			timer.Stop()
+		}
+	}()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		time.AfterFunc(TimerT1, func() {}).Stop()
+	}
+}
+
+// BenchmarkManager_100kTransactions creates 100k concurrent INVITE client
+// transactions, each running Timers A and B, and then stops the manager
+func BenchmarkManager_100kTransactions(b *testing.B) {
+	invites := make([]*parser.SIPMessage, benchmarkPendingTimers)
+	for i := range invites {
+		invites[i] = createTestMessage(parser.MethodINVITE, map[string]string{
+			parser.HeaderVia:    fmt.Sprintf("SIP/2.0/UDP 192.168.1.1:5060;branch=z9hG4bKbench%d", i),
+			parser.HeaderCallID: fmt.Sprintf("bench-%d", i),
+		})
+	}
+	sendFunc := func(msg *parser.SIPMessage) error { return nil }
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		manager := NewManager(sendFunc)
+		for _, invite := range invites {
+			manager.CreateClientTransaction(invite)
+		}
+		manager.Stop()
+	}
+}
@@ -69,6 +69,10 @@ type EnhancedTCPConfig struct {
 	DetailedErrorLogging bool
 	ErrorStatistics      bool
 	
+	// PROXY protocol: accepted connections must start with a header naming the
+	// real client, which is then reported as the source of their messages
+	ProxyProtocol bool
+	
 	// Logging
 	Logger Logger
 }
@@ -136,7 +140,7 @@ func (t *EnhancedTCPTransport) Start(port int) error {
 		return fmt.Errorf("enhanced TCP transport already running")
 	}
 	
-	listener, err := net.Listen("tcp4", fmt.Sprintf(":%d", port))
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
 	if err != nil {
 		t.logger.Error("Failed to start TCP listener", "port", port, "error", err)
 		return fmt.Errorf("failed to listen on TCP port %d: %w", port, err)
@@ -302,8 +306,16 @@ func (t *EnhancedTCPTransport) sendMessageWithTimeoutAndRecovery(data []byte, ad
 		}
 	}
 	
+	// Reuse the connection the peer opened to us, if any (RFC 5923)
+	if tcpConn, ok := t.connectionManager.GetConnectionByFlow(addr); ok {
+		if err := tcpConn.Write(data, writeTimeout); err == nil {
+			return nil
+		}
+		t.connectionManager.RemoveConnection(tcpConn.GetID())
+	}
+	
 	// Create connection with timeout
-	conn, err := net.DialTimeout("tcp4", addr.String(), writeTimeout)
+	conn, err := net.DialTimeout("tcp", addr.String(), writeTimeout)
 	if err != nil {
 		if t.config.DetailedErrorLogging {
 			t.logger.Debug("Failed to establish TCP connection", 
@@ -517,15 +529,32 @@ func (t *EnhancedTCPTransport) acceptConnections() {
 		
 		t.logger.Debug("Accepted TCP connection", "remote_addr", conn.RemoteAddr())
 		
-		// Add connection to manager
-		tcpConn := t.connectionManager.AddConnection(conn)
-		
 		// Handle the connection in a separate goroutine
 		t.wg.Add(1)
-		go t.handleConnection(tcpConn)
+		go t.handleAccepted(conn)
 	}
 }
 
+// handleAccepted reads the PROXY protocol header of an accepted connection if
+// one is expected, then adds the connection to the manager and handles it
+func (t *EnhancedTCPTransport) handleAccepted(conn net.Conn) {
+	if t.config.ProxyProtocol {
+		proxied, err := readProxyHeader(conn)
+		if err != nil {
+			t.logger.Warn("Rejecting connection without valid PROXY protocol header",
+				"remote_addr", conn.RemoteAddr(), "error", err)
+			conn.Close()
+			t.wg.Done()
+			return
+		}
+		conn = proxied
+	}
+	
+	// Add connection to manager
+	tcpConn := t.connectionManager.AddConnection(conn)
+	t.handleConnection(tcpConn)
+}
+
 // handleConnection handles a single TCP connection with enhanced timeout and error recovery
 func (t *EnhancedTCPTransport) handleConnection(tcpConn *TCPConnection) {
 	defer t.wg.Done()
@@ -694,6 +723,16 @@ func (t *EnhancedTCPTransport) handleConnection(tcpConn *TCPConnection) {
 			consecutiveErrors = 0
 		}
 		
+		if isCRLFPing(message) {
+			// Answer keepalives here; they are not SIP messages
+			tcpConn.UpdateActivity()
+			if err := tcpConn.Write([]byte(crlfPong), t.config.WriteTimeout); err != nil {
+				t.recordWriteError()
+				return
+			}
+			continue
+		}
+		
 		if len(message) > 0 {
 			// Update connection activity
 			tcpConn.UpdateActivity()
@@ -9,11 +9,33 @@ type MessageHandler interface {
 	HandleMessage(data []byte, transport string, addr net.Addr) error
 }
 
-// TransportManager defines the interface for managing UDP and TCP transport
+// FlowHandler is told when a flow to a peer fails: a connection closes, or a UDP
+// peer stops sending keepalives. Bindings that can only be reached over that
+// flow, such as SIP Outbound registrations, are then unusable.
+type FlowHandler interface {
+	FlowFailed(transport string, addr net.Addr)
+}
+
+// TransportManager defines the interface for managing UDP, TCP, TLS and WebSocket transport
 type TransportManager interface {
 	StartUDP(port int) error
 	StartTCP(port int) error
+	StartTLS(port int, config TLSConfig) error
+	StartWebSocket(port int) error
+	StartSecureWebSocket(port int, config TLSConfig) error
+	StartListener(listener Listener) error
+	ReloadTLSCertificates() error
 	SendMessage(msg []byte, transport string, addr net.Addr) error
+	HasConnection(transport string, addr net.Addr) bool
+	AdvertisedAddress(transport string, addr net.Addr) (string, int)
 	RegisterHandler(handler MessageHandler)
+	RegisterFlowHandler(handler FlowHandler)
+	SetProxyProtocol(enabled bool)
 	Stop() error
+}
+
+// AddressMatcher recognizes the addresses this server's listeners are known by,
+// so that a proxy can tell the Route entries its own Record-Route headers became
+type AddressMatcher interface {
+	IsAdvertisedAddress(host string, port int) bool
 }
\ No newline at end of file
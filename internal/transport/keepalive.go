@@ -0,0 +1,136 @@
+package transport
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+	"time"
+)
+
+// Keepalives of SIP Outbound (RFC 5626 section 3.5). On connection-oriented
+// transports a UA sends a double CRLF "ping" and expects a single CRLF "pong";
+// on UDP it sends STUN Binding Requests (RFC 5389) and expects a Binding
+// Success response.
+const (
+	crlfPing = "\r\n\r\n"
+	crlfPong = "\r\n"
+)
+
+// isCRLFPing reports whether a message read from a stream is a keepalive ping
+func isCRLFPing(message []byte) bool {
+	return string(message) == crlfPing
+}
+
+const (
+	stunHeaderSize           = 20
+	stunMagicCookie          = 0x2112A442
+	stunBindingRequest       = 0x0001
+	stunBindingSuccess       = 0x0101
+	stunAttrXORMappedAddress = 0x0020
+)
+
+// isSTUNBindingRequest reports whether a datagram is a STUN Binding Request.
+// STUN messages start with two zero bits and carry a fixed magic cookie, so
+// they cannot be mistaken for SIP, which starts with a method or "SIP/2.0".
+func isSTUNBindingRequest(data []byte) bool {
+	if len(data) < stunHeaderSize || data[0]&0xC0 != 0 {
+		return false
+	}
+	return binary.BigEndian.Uint16(data[0:2]) == stunBindingRequest &&
+		int(binary.BigEndian.Uint16(data[2:4]))+stunHeaderSize == len(data) &&
+		binary.BigEndian.Uint32(data[4:8]) == stunMagicCookie
+}
+
+// stunBindingResponse builds the Binding Success response to request, telling
+// the client the address its keepalive arrived from in an XOR-MAPPED-ADDRESS
+func stunBindingResponse(request []byte, addr *net.UDPAddr) []byte {
+	family := byte(0x01)
+	ip := addr.IP.To4()
+	if ip == nil {
+		family = 0x02
+		ip = addr.IP.To16()
+	}
+
+	// The address is XORed with the magic cookie followed by the transaction ID
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint32(key[0:4], stunMagicCookie)
+	copy(key[4:], request[8:stunHeaderSize])
+
+	value := make([]byte, 4+len(ip))
+	value[1] = family
+	binary.BigEndian.PutUint16(value[2:4], uint16(addr.Port)^uint16(stunMagicCookie>>16))
+	for i := range ip {
+		value[4+i] = ip[i] ^ key[i]
+	}
+
+	response := make([]byte, stunHeaderSize+4+len(value))
+	binary.BigEndian.PutUint16(response[0:2], stunBindingSuccess)
+	binary.BigEndian.PutUint16(response[2:4], uint16(4+len(value)))
+	copy(response[4:stunHeaderSize], request[4:stunHeaderSize])
+	binary.BigEndian.PutUint16(response[20:22], stunAttrXORMappedAddress)
+	binary.BigEndian.PutUint16(response[22:24], uint16(len(value)))
+	copy(response[24:], value)
+	return response
+}
+
+// udpFlowTracker remembers when each UDP flow last sent a STUN keepalive. UDP has
+// no connection to close, so a flow whose keepalives stop is taken to have failed.
+// Only peers that have sent a keepalive are tracked, as other UAs never promised
+// to keep their flow alive.
+type udpFlowTracker struct {
+	timeout   time.Duration
+	lastSeen  map[string]time.Time
+	addrs     map[string]net.Addr
+	lastSweep time.Time
+	mu        sync.Mutex
+}
+
+// udpFlowSweepInterval bounds how often the tracked flows are scanned for expiry
+const udpFlowSweepInterval = time.Second
+
+func newUDPFlowTracker(timeout time.Duration) *udpFlowTracker {
+	return &udpFlowTracker{
+		timeout:  timeout,
+		lastSeen: make(map[string]time.Time),
+		addrs:    make(map[string]net.Addr),
+	}
+}
+
+// keepalive records a keepalive from addr
+func (f *udpFlowTracker) keepalive(addr net.Addr, now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lastSeen[addr.String()] = now
+	f.addrs[addr.String()] = addr
+}
+
+// seen refreshes a tracked flow that carried SIP traffic, which keeps its
+// NAT binding alive just as a keepalive does
+func (f *udpFlowTracker) seen(addr net.Addr, now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, tracked := f.lastSeen[addr.String()]; tracked {
+		f.lastSeen[addr.String()] = now
+	}
+}
+
+// expire forgets and returns the flows that have been silent for longer than the timeout
+func (f *udpFlowTracker) expire(now time.Time) []net.Addr {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if now.Sub(f.lastSweep) < udpFlowSweepInterval {
+		return nil
+	}
+	f.lastSweep = now
+
+	var expired []net.Addr
+	for key, seen := range f.lastSeen {
+		if now.Sub(seen) > f.timeout {
+			expired = append(expired, f.addrs[key])
+			delete(f.lastSeen, key)
+			delete(f.addrs, key)
+		}
+	}
+	return expired
+}
@@ -0,0 +1,417 @@
+package transport
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	routeCacheTTL  = time.Minute // How long the route to a destination is remembered
+	routeCacheSize = 4096        // Remembered routes; the cache starts over when full
+)
+
+// Listener describes a socket the server listens on. Servers behind 1:1 NAT, or
+// with separate internal and external interfaces, bind one listener per
+// interface and give each the address it is known by from the other side, which
+// is then used in the Via of requests sent from it.
+type Listener struct {
+	// Address is the local IP address to bind; empty binds all interfaces
+	Address string
+	Port    int
+	// Transport is "UDP", "TCP", "TLS", "WS" or "WSS"; empty listens on UDP and TCP
+	Transport string
+	// AdvertisedHost and AdvertisedPort default to Address and Port
+	AdvertisedHost string
+	AdvertisedPort int
+	// TLS holds the certificate of a TLS or WSS listener
+	TLS TLSConfig
+}
+
+// listener is a started Listener and its transports
+type listener struct {
+	Listener
+	udp *UDPTransport
+	tcp *TCPTransport
+	tls *TLSTransport
+	ws  *WebSocketTransport // WS or WSS
+}
+
+// StartListener starts the transports of a listener. Once listeners are started,
+// messages are sent from the listener whose address the destination is routed
+// through rather than from the transports started by StartUDP, StartTCP, StartTLS
+// and StartWebSocket.
+func (m *Manager) StartListener(config Listener) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l := &listener{Listener: config}
+	l.Transport = strings.ToUpper(config.Transport)
+	switch l.Transport {
+	case "", "UDP", "TCP":
+		if err := m.startUDPAndTCP(l); err != nil {
+			return err
+		}
+	case "TLS":
+		l.tls = NewTLSTransport(config.TLS)
+		if m.handler != nil {
+			l.tls.RegisterHandler(m.handler)
+		}
+		if m.flowHandler != nil {
+			l.tls.RegisterFlowHandler(m.flowHandler)
+		}
+		l.tls.SetProxyProtocol(m.proxyProto)
+		if err := l.tls.StartOn(l.Address, l.Port); err != nil {
+			return fmt.Errorf("failed to start TLS listener: %w", err)
+		}
+	case "WS", "WSS":
+		l.ws = NewWebSocketTransport()
+		if l.Transport == "WSS" {
+			l.ws = NewSecureWebSocketTransport(config.TLS)
+		}
+		if err := m.startWebSocket(l.ws, l.Address, l.Port); err != nil {
+			return fmt.Errorf("failed to start %s listener: %w", l.Transport, err)
+		}
+	default:
+		return fmt.Errorf("unsupported listener transport: %s", config.Transport)
+	}
+
+	m.listeners = append(m.listeners, l)
+	m.running = true
+	return nil
+}
+
+// startUDPAndTCP starts the UDP and/or TCP transports of a listener; the caller holds m.mu
+func (m *Manager) startUDPAndTCP(l *listener) error {
+	if l.Transport != "TCP" {
+		l.udp = NewUDPTransport()
+		if m.handler != nil {
+			l.udp.RegisterHandler(m.handler)
+		}
+		if m.flowHandler != nil {
+			l.udp.RegisterFlowHandler(m.flowHandler)
+		}
+		if err := l.udp.StartOn(l.Address, l.Port); err != nil {
+			return fmt.Errorf("failed to start UDP listener: %w", err)
+		}
+	}
+
+	if l.Transport != "UDP" {
+		l.tcp = NewTCPTransport()
+		if m.handler != nil {
+			l.tcp.RegisterHandler(m.handler)
+		}
+		if m.flowHandler != nil {
+			l.tcp.RegisterFlowHandler(m.flowHandler)
+		}
+		l.tcp.SetProxyProtocol(m.proxyProto)
+		if err := l.tcp.StartOn(l.Address, l.Port); err != nil {
+			if l.udp != nil {
+				l.udp.Stop()
+			}
+			return fmt.Errorf("failed to start TCP listener: %w", err)
+		}
+	}
+	return nil
+}
+
+// AdvertisedAddress returns the host and port this server is known by when
+// sending to addr over transport, taken from the listener the message leaves
+// from. An empty host or zero port means no listener configures one. Without a
+// listener for the transport, as when TLS or WebSocket transports listen on all
+// interfaces on their own ports, only the host of the interface is returned.
+func (m *Manager) AdvertisedAddress(transport string, addr net.Addr) (string, int) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if l := m.listenerFor(strings.ToUpper(transport), addr); l != nil {
+		return l.advertised()
+	}
+	if l := m.listenerFor("", addr); l != nil {
+		host, _ := l.advertised()
+		return host, 0
+	}
+	return "", 0
+}
+
+// IsAdvertisedAddress reports whether host and port are the advertised or bound
+// address of a listener
+func (m *Manager) IsAdvertisedAddress(host string, port int) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	host = strings.Trim(host, "[]")
+	for _, l := range m.listeners {
+		advertisedHost, advertisedPort := l.advertised()
+		if advertisedHost != "" && strings.EqualFold(host, advertisedHost) && port == advertisedPort {
+			return true
+		}
+		if ip := net.ParseIP(host); ip != nil && ip.Equal(net.ParseIP(l.Address)) && port == l.boundPort() {
+			return true
+		}
+	}
+	return false
+}
+
+// udpFor returns the UDP transport to send to addr from; the caller holds m.mu
+func (m *Manager) udpFor(addr net.Addr) *UDPTransport {
+	if l := m.listenerFor("UDP", addr); l != nil {
+		return l.udp
+	}
+	return m.udpTransport
+}
+
+// tcpFor returns the TCP transport to send to addr from, preferring one that
+// already holds a connection to it; the caller holds m.mu
+func (m *Manager) tcpFor(addr net.Addr) *TCPTransport {
+	if m.tcpTransport.HasConnection(addr) {
+		return m.tcpTransport
+	}
+	if l := m.listenerFor("TCP", addr); l != nil {
+		return l.tcp
+	}
+	return m.tcpTransport
+}
+
+// udpTransports returns every UDP transport, started or not; the caller holds m.mu
+func (m *Manager) udpTransports() []*UDPTransport {
+	transports := []*UDPTransport{m.udpTransport}
+	for _, l := range m.listeners {
+		if l.udp != nil {
+			transports = append(transports, l.udp)
+		}
+	}
+	return transports
+}
+
+// tcpTransports returns every TCP transport, started or not; the caller holds m.mu
+func (m *Manager) tcpTransports() []*TCPTransport {
+	transports := []*TCPTransport{m.tcpTransport}
+	for _, l := range m.listeners {
+		if l.tcp != nil {
+			transports = append(transports, l.tcp)
+		}
+	}
+	return transports
+}
+
+// tlsFor returns the TLS transport to send to addr from, preferring one that
+// already holds a connection to it, or nil if none is running; the caller holds m.mu
+func (m *Manager) tlsFor(addr net.Addr) *TLSTransport {
+	if m.isTLSRunning() && m.tlsTransport.HasConnection(addr) {
+		return m.tlsTransport
+	}
+	if l := m.listenerFor("TLS", addr); l != nil {
+		return l.tls
+	}
+	if m.isTLSRunning() {
+		return m.tlsTransport
+	}
+	return nil
+}
+
+// tlsTransports returns every TLS transport that has been created; the caller holds m.mu
+func (m *Manager) tlsTransports() []*TLSTransport {
+	var transports []*TLSTransport
+	if m.tlsTransport != nil {
+		transports = append(transports, m.tlsTransport)
+	}
+	for _, l := range m.listeners {
+		if l.tls != nil {
+			transports = append(transports, l.tls)
+		}
+	}
+	return transports
+}
+
+// webSocketTransports returns every WS and WSS transport that has been created;
+// the caller holds m.mu
+func (m *Manager) webSocketTransports() []*WebSocketTransport {
+	var transports []*WebSocketTransport
+	for _, transport := range []*WebSocketTransport{m.wsTransport, m.wssTransport} {
+		if transport != nil {
+			transports = append(transports, transport)
+		}
+	}
+	for _, l := range m.listeners {
+		if l.ws != nil {
+			transports = append(transports, l.ws)
+		}
+	}
+	return transports
+}
+
+// listenerFor picks the listener carrying transport, or any listener when
+// transport is empty, to send to addr from. A listener holding a connection to
+// addr comes first; otherwise the listener bound to the local address the
+// kernel routes addr through, then one bound to all interfaces, then the first.
+// The caller holds m.mu.
+func (m *Manager) listenerFor(transport string, addr net.Addr) *listener {
+	var candidates []*listener
+	for _, l := range m.listeners {
+		if l.carries(transport) {
+			candidates = append(candidates, l)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	if len(candidates) == 1 {
+		return candidates[0]
+	}
+
+	if transport != "" && transport != "UDP" {
+		for _, l := range candidates {
+			if l.hasConnection(addr) {
+				return l
+			}
+		}
+	}
+
+	if local := m.routes.localIPFor(addr); local != nil {
+		for _, l := range candidates {
+			if ip := net.ParseIP(l.Address); ip != nil && ip.Equal(local) {
+				return l
+			}
+		}
+	}
+	for _, l := range candidates {
+		if ip := net.ParseIP(l.Address); ip == nil || ip.IsUnspecified() {
+			return l
+		}
+	}
+	return candidates[0]
+}
+
+// carries reports whether the listener runs transport, or any transport when
+// transport is empty
+func (l *listener) carries(transport string) bool {
+	switch transport {
+	case "":
+		return l.carries("UDP") || l.carries("TCP") || l.carries("TLS") || l.carries("WS") || l.carries("WSS")
+	case "UDP":
+		return l.udp != nil && l.udp.IsRunning()
+	case "TCP":
+		return l.tcp != nil && l.tcp.IsRunning()
+	case "TLS":
+		return l.tls != nil && l.tls.IsRunning()
+	case "WS", "WSS":
+		return l.ws != nil && l.ws.IsRunning() && l.ws.Name() == transport
+	default:
+		return false
+	}
+}
+
+// hasConnection reports whether a stream transport of the listener holds a
+// connection to addr
+func (l *listener) hasConnection(addr net.Addr) bool {
+	return l.tcp != nil && l.tcp.HasConnection(addr) ||
+		l.tls != nil && l.tls.HasConnection(addr) ||
+		l.ws != nil && l.ws.HasConnection(addr)
+}
+
+// advertised returns the host and port of a listener as seen from outside. A
+// listener bound to all interfaces has no host of its own, and one bound to port
+// 0 reports the port it was given.
+func (l *listener) advertised() (string, int) {
+	host := l.AdvertisedHost
+	if host == "" {
+		if ip := net.ParseIP(l.Address); ip != nil && !ip.IsUnspecified() {
+			host = l.Address
+		}
+	}
+
+	port := l.AdvertisedPort
+	if port == 0 {
+		port = l.boundPort()
+	}
+	return host, port
+}
+
+// boundPort returns the port the listener's socket is bound to
+func (l *listener) boundPort() int {
+	if l.Port != 0 {
+		return l.Port
+	}
+	switch addr := l.localAddr().(type) {
+	case *net.UDPAddr:
+		return addr.Port
+	case *net.TCPAddr:
+		return addr.Port
+	default:
+		return 0
+	}
+}
+
+// localAddr returns the address the listener's socket is bound to
+func (l *listener) localAddr() net.Addr {
+	switch {
+	case l.udp != nil:
+		return l.udp.LocalAddr()
+	case l.tcp != nil:
+		return l.tcp.LocalAddr()
+	case l.tls != nil:
+		return l.tls.LocalAddr()
+	case l.ws != nil:
+		return l.ws.LocalAddr()
+	default:
+		return nil
+	}
+}
+
+// routeCache remembers the local address the kernel routes destinations
+// through, so that picking a listener does not look the route up on every send
+type routeCache struct {
+	mu      sync.Mutex
+	entries map[string]route
+}
+
+type route struct {
+	local   net.IP
+	expires time.Time
+}
+
+// localIPFor returns the local address the kernel would send to addr from.
+// Connecting a UDP socket only looks up the route; nothing is sent.
+func (c *routeCache) localIPFor(addr net.Addr) net.IP {
+	var remote net.UDPAddr
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		remote = net.UDPAddr{IP: a.IP, Port: a.Port, Zone: a.Zone}
+	case *net.TCPAddr:
+		remote = net.UDPAddr{IP: a.IP, Port: a.Port, Zone: a.Zone}
+	case *NamedAddr:
+		remote = net.UDPAddr{IP: a.IP, Port: a.Port, Zone: a.Zone}
+	default:
+		return nil
+	}
+	if remote.Port == 0 {
+		remote.Port = 5060 // Default SIP port
+	}
+
+	// The route depends on the destination address only
+	key := (&net.IPAddr{IP: remote.IP, Zone: remote.Zone}).String()
+	now := time.Now()
+	c.mu.Lock()
+	cached, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.local
+	}
+
+	var local net.IP
+	if conn, err := net.DialUDP("udp", nil, &remote); err == nil {
+		local = conn.LocalAddr().(*net.UDPAddr).IP
+		conn.Close()
+	}
+
+	c.mu.Lock()
+	if c.entries == nil || len(c.entries) >= routeCacheSize {
+		c.entries = make(map[string]route)
+	}
+	c.entries[key] = route{local: local, expires: now.Add(routeCacheTTL)}
+	c.mu.Unlock()
+	return local
+}
@@ -1,17 +1,45 @@
 package transport
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"net"
+	"regexp"
+	"sort"
 	"strings"
 	"sync"
 )
 
+// ErrMessageTooLarge is returned by SendMessage when a request is too large for
+// UDP and cannot be sent over TCP either; proxies answer it with 513
+var ErrMessageTooLarge = errors.New("message too large for any available transport")
+
+const (
+	// defaultPathMTU is assumed for destinations whose path MTU is unknown
+	defaultPathMTU = 1500
+	// udpMTUMargin is how close to the path MTU a request may come before it
+	// must be sent over a congestion controlled transport (RFC 3261 section 18.1.1)
+	udpMTUMargin = 200
+	// udpHeaderOverhead is the size of the IPv6 and UDP headers around a datagram
+	udpHeaderOverhead = 48
+)
+
+// viaSentProtocol matches the sent-protocol of a Via header line and captures its transport
+var viaSentProtocol = regexp.MustCompile(`(?im)^(?:via|v)[ \t]*:[ \t]*SIP[ \t]*/[ \t]*2\.0[ \t]*/[ \t]*([a-z]+)`)
+
 // Manager implements the TransportManager interface
 type Manager struct {
 	udpTransport *UDPTransport
 	tcpTransport *TCPTransport
+	tlsTransport *TLSTransport
+	wsTransport  *WebSocketTransport
+	wssTransport *WebSocketTransport
+	listeners    []*listener
+	routes       routeCache
 	handler      MessageHandler
+	flowHandler  FlowHandler
+	proxyProto   bool
 	running      bool
 	mu           sync.RWMutex
 }
@@ -60,7 +88,112 @@ func (m *Manager) StartTCP(port int) error {
 	return nil
 }
 
-// SendMessage sends a SIP message using the appropriate transport
+// StartTLS starts the TLS transport on the specified port
+func (m *Manager) StartTLS(port int, config TLSConfig) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.tlsTransport != nil && m.tlsTransport.IsRunning() {
+		return fmt.Errorf("failed to start TLS transport: TLS transport already running")
+	}
+
+	m.tlsTransport = NewTLSTransport(config)
+	if m.handler != nil {
+		m.tlsTransport.RegisterHandler(m.handler)
+	}
+	if m.flowHandler != nil {
+		m.tlsTransport.RegisterFlowHandler(m.flowHandler)
+	}
+	m.tlsTransport.SetProxyProtocol(m.proxyProto)
+
+	err := m.tlsTransport.Start(port)
+	if err != nil {
+		return fmt.Errorf("failed to start TLS transport: %w", err)
+	}
+
+	m.running = true
+	return nil
+}
+
+// StartWebSocket starts the SIP over WebSocket (WS) transport on the specified port
+func (m *Manager) StartWebSocket(port int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if isWebSocketRunning(m.wsTransport) {
+		return fmt.Errorf("failed to start WS transport: WS transport already running")
+	}
+
+	m.wsTransport = NewWebSocketTransport()
+	if err := m.startWebSocket(m.wsTransport, "", port); err != nil {
+		return fmt.Errorf("failed to start WS transport: %w", err)
+	}
+	return nil
+}
+
+// StartSecureWebSocket starts the SIP over secure WebSocket (WSS) transport on the specified port
+func (m *Manager) StartSecureWebSocket(port int, config TLSConfig) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if isWebSocketRunning(m.wssTransport) {
+		return fmt.Errorf("failed to start WSS transport: WSS transport already running")
+	}
+
+	m.wssTransport = NewSecureWebSocketTransport(config)
+	if err := m.startWebSocket(m.wssTransport, "", port); err != nil {
+		return fmt.Errorf("failed to start WSS transport: %w", err)
+	}
+	return nil
+}
+
+// startWebSocket registers the handlers with a WebSocket transport and starts it
+// on address and port; the caller holds m.mu
+func (m *Manager) startWebSocket(transport *WebSocketTransport, address string, port int) error {
+	if m.handler != nil {
+		transport.RegisterHandler(m.handler)
+	}
+	if m.flowHandler != nil {
+		transport.RegisterFlowHandler(m.flowHandler)
+	}
+	if err := transport.StartOn(address, port); err != nil {
+		return err
+	}
+	m.running = true
+	return nil
+}
+
+// ReloadTLSCertificates re-reads the certificate files of the TLS and WSS
+// transports without dropping established connections
+func (m *Manager) ReloadTLSCertificates() error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	reloaded := false
+	for _, transport := range m.tlsTransports() {
+		if transport.IsRunning() {
+			if err := transport.Reload(); err != nil {
+				return err
+			}
+			reloaded = true
+		}
+	}
+	for _, transport := range m.webSocketTransports() {
+		if transport.Name() == "WSS" && transport.IsRunning() {
+			if err := transport.Reload(); err != nil {
+				return err
+			}
+			reloaded = true
+		}
+	}
+	if !reloaded {
+		return fmt.Errorf("TLS transport not running")
+	}
+	return nil
+}
+
+// SendMessage sends a SIP message using the appropriate transport. Requests too
+// large for UDP are moved to TCP; see sendLargeRequest.
 func (m *Manager) SendMessage(msg []byte, transport string, addr net.Addr) error {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -71,24 +204,70 @@ func (m *Manager) SendMessage(msg []byte, transport string, addr net.Addr) error
 
 	// Determine transport method
 	transportMethod := m.selectTransport(msg, transport, addr)
+	if udpAddr, ok := addr.(*net.UDPAddr); ok && strings.ToUpper(transportMethod) == "TCP" {
+		return m.sendLargeRequest(msg, udpAddr)
+	}
 
 	switch strings.ToUpper(transportMethod) {
 	case "UDP":
-		if !m.udpTransport.IsRunning() {
+		udp := m.udpFor(addr)
+		if !udp.IsRunning() {
 			return fmt.Errorf("UDP transport not running")
 		}
-		return m.udpTransport.SendMessage(msg, addr)
+		return udp.SendMessage(msg, addr)
 	case "TCP":
-		if !m.tcpTransport.IsRunning() {
+		tcp := m.tcpFor(addr)
+		if !tcp.IsRunning() {
 			return fmt.Errorf("TCP transport not running")
 		}
-		return m.tcpTransport.SendMessage(msg, addr)
+		return tcp.SendMessage(msg, addr)
+	case "TLS":
+		tls := m.tlsFor(addr)
+		if tls == nil {
+			return fmt.Errorf("TLS transport not running")
+		}
+		return tls.SendMessage(msg, addr)
+	case "WS", "WSS":
+		transport := m.webSocketFor(strings.ToUpper(transportMethod), addr)
+		if transport == nil {
+			return fmt.Errorf("%s transport not running", strings.ToUpper(transportMethod))
+		}
+		return transport.SendMessage(msg, addr)
 	default:
 		return fmt.Errorf("unsupported transport: %s", transportMethod)
 	}
 }
 
-// RegisterHandler registers a message handler for both transports
+// HasConnection reports whether a connection to addr is open on a stream transport,
+// so that a message can be sent over it instead of opening a new one (RFC 5923)
+func (m *Manager) HasConnection(transport string, addr net.Addr) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	switch strings.ToUpper(transport) {
+	case "TCP":
+		for _, transport := range m.tcpTransports() {
+			if transport.HasConnection(addr) {
+				return true
+			}
+		}
+		return false
+	case "TLS":
+		for _, transport := range m.tlsTransports() {
+			if transport.HasConnection(addr) {
+				return true
+			}
+		}
+		return false
+	case "WS", "WSS":
+		transport := m.webSocketFor(strings.ToUpper(transport), addr)
+		return transport != nil && transport.HasConnection(addr)
+	default:
+		return false
+	}
+}
+
+// RegisterHandler registers a message handler for all transports
 func (m *Manager) RegisterHandler(handler MessageHandler) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -96,32 +275,108 @@ func (m *Manager) RegisterHandler(handler MessageHandler) {
 	m.handler = handler
 
 	// Register with existing transports if they're running
-	if m.udpTransport.IsRunning() {
-		m.udpTransport.RegisterHandler(handler)
+	for _, transport := range m.udpTransports() {
+		if transport.IsRunning() {
+			transport.RegisterHandler(handler)
+		}
+	}
+	for _, transport := range m.tcpTransports() {
+		if transport.IsRunning() {
+			transport.RegisterHandler(handler)
+		}
+	}
+	for _, transport := range m.tlsTransports() {
+		if transport.IsRunning() {
+			transport.RegisterHandler(handler)
+		}
+	}
+	for _, transport := range m.webSocketTransports() {
+		if transport.IsRunning() {
+			transport.RegisterHandler(handler)
+		}
 	}
-	if m.tcpTransport.IsRunning() {
-		m.tcpTransport.RegisterHandler(handler)
+}
+
+// RegisterFlowHandler registers the handler told about failed flows on all transports.
+// Listeners, TLS and WebSocket transports created later
+// pick it up when they are started.
+func (m *Manager) RegisterFlowHandler(handler FlowHandler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.flowHandler = handler
+
+	for _, transport := range m.udpTransports() {
+		transport.RegisterFlowHandler(handler)
+	}
+	for _, transport := range m.tcpTransports() {
+		transport.RegisterFlowHandler(handler)
+	}
+	for _, transport := range m.tlsTransports() {
+		transport.RegisterFlowHandler(handler)
+	}
+	for _, transport := range m.webSocketTransports() {
+		transport.RegisterFlowHandler(handler)
 	}
 }
 
-// Stop stops both UDP and TCP transports
+// SetProxyProtocol makes the TCP and TLS transports, including those started
+// later, expect a PROXY protocol header on every accepted connection and report
+// the client address it names as the source of their messages
+func (m *Manager) SetProxyProtocol(enabled bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.proxyProto = enabled
+
+	for _, transport := range m.tcpTransports() {
+		transport.SetProxyProtocol(enabled)
+	}
+	for _, transport := range m.tlsTransports() {
+		transport.SetProxyProtocol(enabled)
+	}
+}
+
+// Stop stops all transports
 func (m *Manager) Stop() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	var errors []string
 
-	// Stop UDP transport
-	if m.udpTransport.IsRunning() {
-		if err := m.udpTransport.Stop(); err != nil {
-			errors = append(errors, fmt.Sprintf("UDP: %v", err))
+	// Stop UDP transports
+	for _, transport := range m.udpTransports() {
+		if transport.IsRunning() {
+			if err := transport.Stop(); err != nil {
+				errors = append(errors, fmt.Sprintf("UDP: %v", err))
+			}
+		}
+	}
+
+	// Stop TCP transports
+	for _, transport := range m.tcpTransports() {
+		if transport.IsRunning() {
+			if err := transport.Stop(); err != nil {
+				errors = append(errors, fmt.Sprintf("TCP: %v", err))
+			}
 		}
 	}
 
-	// Stop TCP transport
-	if m.tcpTransport.IsRunning() {
-		if err := m.tcpTransport.Stop(); err != nil {
-			errors = append(errors, fmt.Sprintf("TCP: %v", err))
+	// Stop TLS transports
+	for _, transport := range m.tlsTransports() {
+		if transport.IsRunning() {
+			if err := transport.Stop(); err != nil {
+				errors = append(errors, fmt.Sprintf("TLS: %v", err))
+			}
+		}
+	}
+
+	// Stop WebSocket transports
+	for _, transport := range m.webSocketTransports() {
+		if transport.IsRunning() {
+			if err := transport.Stop(); err != nil {
+				errors = append(errors, fmt.Sprintf("%s: %v", transport.Name(), err))
+			}
 		}
 	}
 
@@ -134,36 +389,100 @@ func (m *Manager) Stop() error {
 	return nil
 }
 
-// selectTransport determines which transport to use based on message size and preferences
+// selectTransport determines which transport to use based on message size and preferences.
+// A request bound for UDP that comes within udpMTUMargin bytes of the path MTU must
+// be sent over TCP instead (RFC 3261 section 18.1.1); responses always follow the
+// request.
 func (m *Manager) selectTransport(msg []byte, preferredTransport string, addr net.Addr) string {
-	// If a specific transport is requested, use it
-	if preferredTransport != "" {
-		return preferredTransport
+	transport := strings.ToUpper(preferredTransport)
+	if transport == "" {
+		// Check if the address type suggests a specific transport
+		switch addr.(type) {
+		case *net.TCPAddr, *NamedAddr:
+			transport = "TCP"
+		default:
+			// Default to UDP for SIP (RFC 3261 recommendation)
+			transport = "UDP"
+		}
 	}
 
-	// RFC 3261: Use TCP for messages larger than 1300 bytes (conservative MTU)
-	const maxUDPSize = 1300
-	if len(msg) > maxUDPSize {
+	if transport == "UDP" && isRequest(msg) && len(msg) > defaultPathMTU-udpMTUMargin {
 		return "TCP"
 	}
+	return transport
+}
 
-	// Check if the address type suggests a specific transport
-	switch addr.(type) {
-	case *net.UDPAddr:
-		return "UDP"
-	case *net.TCPAddr:
-		return "TCP"
+// sendLargeRequest sends a request that is too large for UDP over TCP to the same
+// address, changing the transport in the top Via to match (RFC 3261 section
+// 18.1.1). If the target cannot be reached over TCP, the request still goes over
+// UDP as long as it fits in a single datagram; otherwise ErrMessageTooLarge is
+// returned. The caller holds m.mu.
+func (m *Manager) sendLargeRequest(msg []byte, addr *net.UDPAddr) error {
+	tcpErr := fmt.Errorf("TCP transport not running")
+	tcpAddr := &net.TCPAddr{IP: addr.IP, Port: addr.Port, Zone: addr.Zone}
+	if tcp := m.tcpFor(tcpAddr); tcp.IsRunning() {
+		if tcpErr = tcp.SendMessage(viaToTCP(msg), tcpAddr); tcpErr == nil {
+			return nil
+		}
 	}
 
-	// Default to UDP for SIP (RFC 3261 recommendation)
-	return "UDP"
+	if udp := m.udpFor(addr); len(msg) <= defaultPathMTU-udpHeaderOverhead && udp.IsRunning() {
+		return udp.SendMessage(msg, addr)
+	}
+	return fmt.Errorf("%w: %d byte request to %s: %v", ErrMessageTooLarge, len(msg), addr, tcpErr)
 }
 
-// IsRunning returns true if either transport is running
+// isRequest reports whether a serialized SIP message is a request
+func isRequest(msg []byte) bool {
+	return len(msg) > 0 && !bytes.HasPrefix(msg, []byte("SIP/"))
+}
+
+// viaToTCP returns a copy of msg whose top Via names TCP instead of UDP. Both
+// are three letters, so Content-Length and framing are unaffected.
+func viaToTCP(msg []byte) []byte {
+	headerEnd := bytes.Index(msg, []byte("\r\n\r\n"))
+	if headerEnd == -1 {
+		headerEnd = len(msg)
+	}
+	match := viaSentProtocol.FindSubmatchIndex(msg[:headerEnd])
+	if match == nil || !strings.EqualFold(string(msg[match[2]:match[3]]), "UDP") {
+		return msg
+	}
+
+	result := make([]byte, len(msg))
+	copy(result, msg)
+	copy(result[match[2]:match[3]], "TCP")
+	return result
+}
+
+// IsRunning returns true if any transport is running
 func (m *Manager) IsRunning() bool {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	return m.running && (m.udpTransport.IsRunning() || m.tcpTransport.IsRunning())
+	if !m.running {
+		return false
+	}
+	for _, transport := range m.udpTransports() {
+		if transport.IsRunning() {
+			return true
+		}
+	}
+	for _, transport := range m.tcpTransports() {
+		if transport.IsRunning() {
+			return true
+		}
+	}
+	for _, transport := range m.tlsTransports() {
+		if transport.IsRunning() {
+			return true
+		}
+	}
+	for _, transport := range m.webSocketTransports() {
+		if transport.IsRunning() {
+			return true
+		}
+	}
+	return false
 }
 
 // GetUDPLocalAddr returns the local address of the UDP transport
@@ -180,6 +499,16 @@ func (m *Manager) GetTCPLocalAddr() net.Addr {
 	return m.tcpTransport.LocalAddr()
 }
 
+// GetTLSLocalAddr returns the local address of the TLS transport
+func (m *Manager) GetTLSLocalAddr() net.Addr {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.tlsTransport == nil {
+		return nil
+	}
+	return m.tlsTransport.LocalAddr()
+}
+
 // IsUDPRunning returns true if UDP transport is running
 func (m *Manager) IsUDPRunning() bool {
 	m.mu.RLock()
@@ -197,4 +526,63 @@ func (m *Manager) IsTCPRunning() bool {
 // GetTransportForMessage returns the recommended transport for a message
 func (m *Manager) GetTransportForMessage(msg []byte, addr net.Addr) string {
 	return m.selectTransport(msg, "", addr)
-}
\ No newline at end of file
+}
+
+// IsTLSRunning returns true if TLS transport is running
+func (m *Manager) IsTLSRunning() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.isTLSRunning()
+}
+
+// isTLSRunning reports whether the TLS transport is running; the caller holds m.mu
+func (m *Manager) isTLSRunning() bool {
+	return m.tlsTransport != nil && m.tlsTransport.IsRunning()
+}
+
+// GetWebSocketLocalAddr returns the local address of the WS transport
+func (m *Manager) GetWebSocketLocalAddr() net.Addr {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.wsTransport == nil {
+		return nil
+	}
+	return m.wsTransport.LocalAddr()
+}
+
+// GetSecureWebSocketLocalAddr returns the local address of the WSS transport
+func (m *Manager) GetSecureWebSocketLocalAddr() net.Addr {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.wssTransport == nil {
+		return nil
+	}
+	return m.wssTransport.LocalAddr()
+}
+
+// webSocketFor returns the WebSocket transport holding the connection from addr.
+// Contacts registered over WSS still carry ;transport=ws (RFC 7118 section 5.3),
+// so the connection decides between WS and WSS; preferred breaks ties.
+// The caller holds m.mu.
+func (m *Manager) webSocketFor(preferred string, addr net.Addr) *WebSocketTransport {
+	transports := m.webSocketTransports()
+	sort.SliceStable(transports, func(i, j int) bool {
+		return transports[i].Name() == preferred && transports[j].Name() != preferred
+	})
+	for _, transport := range transports {
+		if transport.IsRunning() && transport.HasConnection(addr) {
+			return transport
+		}
+	}
+	for _, transport := range transports {
+		if transport.IsRunning() {
+			return transport
+		}
+	}
+	return nil
+}
+
+// isWebSocketRunning reports whether an optional WebSocket transport is running
+func isWebSocketRunning(transport *WebSocketTransport) bool {
+	return transport != nil && transport.IsRunning()
+}
@@ -1,7 +1,9 @@
 package transport
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"strings"
 	"sync"
@@ -225,6 +227,13 @@ func TestManager_TransportSelection(t *testing.T) {
 	if transport != "TCP" {
 		t.Errorf("Expected TCP for TCP address, got %s", transport)
 	}
+
+	// Responses are never moved off the transport their request arrived on
+	largeResponse := []byte(fmt.Sprintf("SIP/2.0 200 OK\r\nContent-Length: %d\r\n\r\n%s", len(largeBody), largeBody))
+	transport = manager.GetTransportForMessage(largeResponse, udpAddr)
+	if transport != "UDP" {
+		t.Errorf("Expected UDP for large response, got %s", transport)
+	}
 }
 
 func TestManager_SendMessageNotRunning(t *testing.T) {
@@ -474,4 +483,303 @@ func TestManager_LocalAddresses(t *testing.T) {
 	if _, ok := tcpAddr.(*net.TCPAddr); !ok {
 		t.Errorf("Expected TCP address, got %T", tcpAddr)
 	}
-}
\ No newline at end of file
+}
+
+func TestManager_SendMessageWebSocket(t *testing.T) {
+	manager := NewManager()
+	handler := &mockMessageHandler{}
+	manager.RegisterHandler(handler)
+
+	if err := manager.StartWebSocket(0); err != nil {
+		t.Fatalf("Failed to start WS: %v", err)
+	}
+	defer manager.Stop()
+
+	if !manager.IsRunning() {
+		t.Error("Manager should be running when WS is running")
+	}
+
+	client := connectTestWebSocket(t, manager.GetWebSocketLocalAddr())
+	defer client.conn.Close()
+	client.writeFrame(t, true, wsOpText, []byte(testTLSRequest))
+	messages := waitForMessages(t, handler, 1)
+
+	// Contacts carry ;transport=ws whether the client uses WS or WSS
+	if err := manager.SendMessage([]byte(testTLSResponse), "wss", messages[0].addr); err != nil {
+		t.Fatalf("Failed to send over WS: %v", err)
+	}
+	if _, payload := client.readFrame(t); string(payload) != testTLSResponse {
+		t.Errorf("Received %q, expected %q", payload, testTLSResponse)
+	}
+
+	if err := manager.StartWebSocket(0); err == nil {
+		t.Error("Expected error when starting WS twice")
+	}
+	if err := manager.ReloadTLSCertificates(); err == nil {
+		t.Error("Expected error reloading certificates without TLS or WSS")
+	}
+}
+
+func TestManager_HasConnection(t *testing.T) {
+	manager := NewManager()
+	handler := &mockMessageHandler{}
+	manager.RegisterHandler(handler)
+
+	if err := manager.StartTCP(0); err != nil {
+		t.Fatalf("Failed to start TCP: %v", err)
+	}
+	defer manager.Stop()
+
+	client, err := net.Dial("tcp", manager.GetTCPLocalAddr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+	if _, err := client.Write([]byte(testTLSRequest)); err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+	messages := waitForMessages(t, handler, 1)
+
+	if !manager.HasConnection("tcp", messages[0].addr) {
+		t.Error("Expected open TCP connection for the client flow")
+	}
+	if manager.HasConnection("TLS", messages[0].addr) || manager.HasConnection("UDP", messages[0].addr) {
+		t.Error("Expected no connection on other transports")
+	}
+}
+
+// largeInvite builds an INVITE of roughly size bytes whose top Via names UDP
+func largeInvite(size int) []byte {
+	body := strings.Repeat("a", size)
+	return []byte(fmt.Sprintf("INVITE sip:bob@example.com SIP/2.0\r\n"+
+		"Via: SIP/2.0/UDP proxy.example.com;branch=z9hG4bK-1\r\n"+
+		"Via: SIP/2.0/UDP client.example.com;branch=z9hG4bK-2\r\n"+
+		"Content-Length: %d\r\n\r\n%s", len(body), body))
+}
+
+func TestManager_LargeRequestSwitchesToTCP(t *testing.T) {
+	manager := NewManager()
+	if err := manager.StartUDP(0); err != nil {
+		t.Fatalf("Failed to start UDP: %v", err)
+	}
+	if err := manager.StartTCP(0); err != nil {
+		t.Fatalf("Failed to start TCP: %v", err)
+	}
+	defer manager.Stop()
+
+	// The target listens for TCP on the port its UDP address names
+	listener, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	target := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: listener.Addr().(*net.TCPAddr).Port}
+
+	invite := largeInvite(1400)
+	if err := manager.SendMessage(invite, "UDP", target); err != nil {
+		t.Fatalf("Failed to send large request: %v", err)
+	}
+
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("Failed to accept: %v", err)
+	}
+	defer conn.Close()
+	received := make([]byte, len(invite))
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := io.ReadFull(conn, received); err != nil {
+		t.Fatalf("Failed to read request over TCP: %v", err)
+	}
+	if !strings.Contains(string(received), "Via: SIP/2.0/TCP proxy.example.com") {
+		t.Errorf("Expected top Via to name TCP, got %q", received[:120])
+	}
+	if !strings.Contains(string(received), "Via: SIP/2.0/UDP client.example.com") {
+		t.Error("Expected lower Via headers to be left alone")
+	}
+}
+
+func TestManager_LargeRequestWithoutTCP(t *testing.T) {
+	manager := NewManager()
+	if err := manager.StartUDP(0); err != nil {
+		t.Fatalf("Failed to start UDP: %v", err)
+	}
+	defer manager.Stop()
+
+	target, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer target.Close()
+
+	// A request that still fits in one datagram falls back to UDP
+	invite := largeInvite(1250)
+	if err := manager.SendMessage(invite, "UDP", target.LocalAddr()); err != nil {
+		t.Fatalf("Expected fallback to UDP, got %v", err)
+	}
+	buf := make([]byte, 2048)
+	target.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := target.Read(buf)
+	if err != nil {
+		t.Fatalf("Failed to read request over UDP: %v", err)
+	}
+	if n != len(invite) || !strings.Contains(string(buf[:n]), "Via: SIP/2.0/UDP proxy.example.com") {
+		t.Errorf("Expected the request unchanged over UDP, got %d bytes", n)
+	}
+
+	// One that would be fragmented cannot be carried at all
+	err = manager.SendMessage(largeInvite(3000), "UDP", target.LocalAddr())
+	if !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("Expected ErrMessageTooLarge, got %v", err)
+	}
+}
+
+func TestManager_Listeners(t *testing.T) {
+	manager := NewManager()
+	defer manager.Stop()
+
+	if err := manager.StartListener(Listener{Address: "127.0.0.1", Transport: "udp", AdvertisedHost: "203.0.113.5", AdvertisedPort: 5060}); err != nil {
+		t.Fatalf("Failed to start loopback listener: %v", err)
+	}
+	if err := manager.StartListener(Listener{Transport: "udp"}); err != nil {
+		t.Fatalf("Failed to start wildcard listener: %v", err)
+	}
+	if err := manager.StartListener(Listener{Transport: "sctp"}); err == nil {
+		t.Error("Expected error for unsupported listener transport")
+	}
+
+	peer, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer peer.Close()
+	target := peer.LocalAddr().(*net.UDPAddr)
+
+	// A destination routed through the loopback interface is sent to from the
+	// listener bound to it, which advertises its public address
+	if err := manager.SendMessage([]byte("OPTIONS sip:peer SIP/2.0\r\n\r\n"), "UDP", target); err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+	buf := make([]byte, 1024)
+	peer.SetReadDeadline(time.Now().Add(time.Second))
+	_, from, err := peer.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatalf("Failed to receive: %v", err)
+	}
+	manager.mu.RLock()
+	bound := manager.listeners[0].udp.LocalAddr().(*net.UDPAddr)
+	manager.mu.RUnlock()
+	if from.Port != bound.Port {
+		t.Errorf("Expected message to leave from the loopback listener on port %d, got %s", bound.Port, from)
+	}
+
+	host, port := manager.AdvertisedAddress("UDP", target)
+	if host != "203.0.113.5" || port != 5060 {
+		t.Errorf("Expected advertised address of the loopback listener, got %s:%d", host, port)
+	}
+
+	// Both the advertised and the bound address name the listener
+	if !manager.IsAdvertisedAddress("203.0.113.5", 5060) || !manager.IsAdvertisedAddress("127.0.0.1", bound.Port) {
+		t.Error("Expected the advertised and bound addresses of the loopback listener to be recognized")
+	}
+	if manager.IsAdvertisedAddress("203.0.113.5", 5070) || manager.IsAdvertisedAddress("198.51.100.1", 5060) {
+		t.Error("Expected other addresses not to be recognized")
+	}
+
+	// Other destinations use the listener bound to all interfaces, which has no
+	// host of its own and advertises the port it was given
+	host, port = manager.AdvertisedAddress("UDP", &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 5060})
+	if host != "" || port == 0 || port == 5060 {
+		t.Errorf("Expected wildcard listener port without host, got %s:%d", host, port)
+	}
+
+	// TLS listens on its own port, so only the host is advertised
+	host, port = manager.AdvertisedAddress("TLS", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 5061})
+	if host != "203.0.113.5" || port != 0 {
+		t.Errorf("Expected advertised host without port for TLS, got %s:%d", host, port)
+	}
+
+	if host, port := NewManager().AdvertisedAddress("UDP", target); host != "" || port != 0 {
+		t.Errorf("Expected no advertised address without listeners, got %s:%d", host, port)
+	}
+}
+
+func TestManager_TLSAndWebSocketListeners(t *testing.T) {
+	serverConfig := writeTestCertificate(t, t.TempDir(), "server")
+	server := NewTLSTransport(serverConfig)
+	handler := &mockMessageHandler{}
+	server.RegisterHandler(handler)
+	if err := server.Start(0); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	clientConfig := writeTestCertificate(t, t.TempDir(), "client")
+	clientConfig.CAFile = serverConfig.CertFile
+	manager := NewManager()
+	defer manager.Stop()
+	if err := manager.StartListener(Listener{Address: "127.0.0.1", Transport: "tls", AdvertisedHost: "203.0.113.5", AdvertisedPort: 5061, TLS: clientConfig}); err != nil {
+		t.Fatalf("Failed to start TLS listener: %v", err)
+	}
+	if err := manager.StartListener(Listener{Address: "127.0.0.1", Transport: "ws", AdvertisedHost: "203.0.113.5"}); err != nil {
+		t.Fatalf("Failed to start WS listener: %v", err)
+	}
+
+	// TLS requests leave from the TLS listener, which advertises its own port
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: server.LocalAddr().(*net.TCPAddr).Port}
+	if err := manager.SendMessage([]byte(testTLSRequest), "TLS", addr); err != nil {
+		t.Fatalf("Failed to send over the TLS listener: %v", err)
+	}
+	waitForMessages(t, handler, 1)
+	if !manager.HasConnection("TLS", addr) {
+		t.Error("Expected the TLS listener to hold the connection")
+	}
+	if host, port := manager.AdvertisedAddress("TLS", addr); host != "203.0.113.5" || port != 5061 {
+		t.Errorf("Expected advertised address of the TLS listener, got %s:%d", host, port)
+	}
+
+	// A listener without an advertised port advertises the port it was given
+	manager.mu.RLock()
+	wsPort := manager.listeners[1].ws.LocalAddr().(*net.TCPAddr).Port
+	manager.mu.RUnlock()
+	if host, port := manager.AdvertisedAddress("WS", addr); host != "203.0.113.5" || port != wsPort {
+		t.Errorf("Expected advertised address 203.0.113.5:%d of the WS listener, got %s:%d", wsPort, host, port)
+	}
+
+	// No listener carries WSS, so only the host of the interface is known
+	if host, port := manager.AdvertisedAddress("WSS", addr); host != "203.0.113.5" || port != 0 {
+		t.Errorf("Expected advertised host without port for WSS, got %s:%d", host, port)
+	}
+
+	if err := manager.ReloadTLSCertificates(); err != nil {
+		t.Errorf("Expected the TLS listener's certificate to reload: %v", err)
+	}
+}
+
+func TestRouteCache(t *testing.T) {
+	var routes routeCache
+	loopback := net.IPv4(127, 0, 0, 1)
+
+	if local := routes.localIPFor(&net.UDPAddr{IP: loopback, Port: 5060}); !local.IsLoopback() {
+		t.Fatalf("Expected a loopback address, got %v", local)
+	}
+	// The route is looked up per destination address, whatever the port or transport
+	routes.localIPFor(&net.TCPAddr{IP: loopback, Port: 5061})
+	if len(routes.entries) != 1 {
+		t.Fatalf("Expected one remembered route, got %d", len(routes.entries))
+	}
+
+	cached := net.IPv4(192, 0, 2, 1)
+	routes.entries["127.0.0.1"] = route{local: cached, expires: time.Now().Add(time.Minute)}
+	if local := routes.localIPFor(&net.UDPAddr{IP: loopback}); !local.Equal(cached) {
+		t.Errorf("Expected the remembered route %v, got %v", cached, local)
+	}
+
+	routes.entries["127.0.0.1"] = route{local: cached, expires: time.Now().Add(-time.Second)}
+	if local := routes.localIPFor(&net.UDPAddr{IP: loopback}); !local.IsLoopback() {
+		t.Errorf("Expected an expired route to be looked up again, got %v", local)
+	}
+
+	if local := routes.localIPFor(&net.IPAddr{IP: loopback}); local != nil {
+		t.Errorf("Expected no route for an unsupported address type, got %v", local)
+	}
+}
@@ -0,0 +1,86 @@
+package transport
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+// proxyV2Header builds a version 2 header with the given command byte, family
+// byte and address block
+func proxyV2Header(command, family byte, addresses []byte) []byte {
+	header := append([]byte{}, proxyV2Signature...)
+	header = append(header, command, family, 0, 0)
+	binary.BigEndian.PutUint16(header[14:16], uint16(len(addresses)))
+	return append(header, addresses...)
+}
+
+func TestReadProxyHeader(t *testing.T) {
+	ipv4 := []byte{192, 0, 2, 10, 198, 51, 100, 1, 0x9C, 0x40, 0x13, 0xC4}
+	ipv6 := make([]byte, 36)
+	copy(ipv6, net.ParseIP("2001:db8::10"))
+	copy(ipv6[16:], net.ParseIP("2001:db8::1"))
+	binary.BigEndian.PutUint16(ipv6[32:34], 40000)
+	binary.BigEndian.PutUint16(ipv6[34:36], 5060)
+	withTLV := append(append([]byte{}, ipv4...), 0x04, 0x00, 0x01, 0xFF)
+
+	tests := []struct {
+		name        string
+		header      []byte
+		expected    string // empty keeps the balancer address
+		expectError bool
+	}{
+		{"v1 IPv4", []byte("PROXY TCP4 192.0.2.10 198.51.100.1 40000 5060\r\n"), "192.0.2.10:40000", false},
+		{"v1 IPv6", []byte("PROXY TCP6 2001:db8::10 2001:db8::1 40000 5060\r\n"), "[2001:db8::10]:40000", false},
+		{"v1 unknown", []byte("PROXY UNKNOWN\r\n"), "", false},
+		{"v1 malformed", []byte("PROXY TCP4 192.0.2.10\r\n"), "", true},
+		{"v1 bad address", []byte("PROXY TCP4 client 198.51.100.1 40000 5060\r\n"), "", true},
+		{"v2 IPv4", proxyV2Header(0x21, 0x11, ipv4), "192.0.2.10:40000", false},
+		{"v2 IPv6", proxyV2Header(0x21, 0x21, ipv6), "[2001:db8::10]:40000", false},
+		{"v2 with TLVs", proxyV2Header(0x21, 0x11, withTLV), "192.0.2.10:40000", false},
+		{"v2 local", proxyV2Header(0x20, 0x00, nil), "", false},
+		{"v2 bad version", proxyV2Header(0x11, 0x11, ipv4), "", true},
+		{"v2 truncated", proxyV2Header(0x21, 0x11, ipv4[:8]), "", true},
+		{"missing header", []byte("REGISTER sip:example.com SIP/2.0\r\n\r\n"), "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := net.Pipe()
+			defer server.Close()
+			defer client.Close()
+
+			payload := []byte("OPTIONS")
+			go func() {
+				client.SetWriteDeadline(time.Now().Add(time.Second))
+				client.Write(append(append([]byte{}, tt.header...), payload...))
+			}()
+
+			conn, err := readProxyHeader(server)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			expected := tt.expected
+			if expected == "" {
+				expected = server.RemoteAddr().String()
+			}
+			if conn.RemoteAddr().String() != expected {
+				t.Errorf("Expected remote address %s, got %s", expected, conn.RemoteAddr())
+			}
+
+			// Reads continue right after the header
+			received := make([]byte, len(payload))
+			if _, err := conn.Read(received); err != nil || string(received) != string(payload) {
+				t.Errorf("Expected %q after the header, got %q (%v)", payload, received, err)
+			}
+		})
+	}
+}
@@ -8,31 +8,95 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// TCPTransport handles TCP transport for SIP messages
+const (
+	tcpDialTimeout  = 10 * time.Second
+	tcpWriteTimeout = 10 * time.Second
+	tcpReadTimeout  = 30 * time.Second
+	tcpIdleTimeout  = 5 * time.Minute // Connections we opened are closed after this long without traffic
+)
+
+// tcpConnection is an open TCP connection that messages can be sent on
+type tcpConnection struct {
+	conn     net.Conn
+	writeMu  sync.Mutex
+	outbound bool         // Opened by this transport rather than by the peer
+	lastUsed atomic.Int64 // Unix nanoseconds of the last message sent or received
+}
+
+// write sends data on the connection; concurrent writers are serialized so that
+// messages are never interleaved
+func (c *tcpConnection) write(data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.touch()
+	c.conn.SetWriteDeadline(time.Now().Add(tcpWriteTimeout))
+	_, err := c.conn.Write(data)
+	return err
+}
+
+// touch records traffic on the connection
+func (c *tcpConnection) touch() {
+	c.lastUsed.Store(time.Now().UnixNano())
+}
+
+// idleFor returns how long the connection has been without traffic
+func (c *tcpConnection) idleFor() time.Duration {
+	return time.Since(time.Unix(0, c.lastUsed.Load()))
+}
+
+// tcpDial is a connection attempt that concurrent senders to the same address wait for
+type tcpDial struct {
+	done       chan struct{}
+	connection *tcpConnection
+	err        error
+}
+
+// TCPTransport handles TCP transport for SIP messages. Connections are indexed by
+// flow, the remote address and port, and reused in both directions (RFC 5923), so
+// requests to a peer that connected to us go over the connection it opened.
+// Keepalive pings are answered on the connection and closed connections are
+// reported to the flow handler. Behind a load balancer, accepted connections can
+// be required to start with a PROXY protocol header naming the real client.
+// Connections the transport opens itself are closed once idle.
 type TCPTransport struct {
 	listener    net.Listener
+	localIP     net.IP
+	proxyProto  bool
 	handler     MessageHandler
+	flowHandler FlowHandler
 	running     bool
 	mu          sync.RWMutex
 	wg          sync.WaitGroup
 	stopChan    chan struct{}
-	connections map[net.Conn]struct{}
+	connections map[string]*tcpConnection
+	dials       map[string]*tcpDial
 	connMu      sync.RWMutex
+	idleTimeout time.Duration
 }
 
 // NewTCPTransport creates a new TCP transport handler
 func NewTCPTransport() *TCPTransport {
 	return &TCPTransport{
 		stopChan:    make(chan struct{}),
-		connections: make(map[net.Conn]struct{}),
+		connections: make(map[string]*tcpConnection),
+		dials:       make(map[string]*tcpDial),
+		idleTimeout: tcpIdleTimeout,
 	}
 }
 
-// Start starts the TCP listener on the specified port
+// Start starts the TCP listener on the specified port of all interfaces
 func (t *TCPTransport) Start(port int) error {
+	return t.StartOn("", port)
+}
+
+// StartOn starts the TCP listener on the specified local address and port.
+// Connections this transport opens originate from the same address.
+func (t *TCPTransport) StartOn(address string, port int) error {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
@@ -40,12 +104,14 @@ func (t *TCPTransport) Start(port int) error {
 		return fmt.Errorf("TCP transport already running")
 	}
 
-	listener, err := net.Listen("tcp4", fmt.Sprintf(":%d", port))
+	hostPort := net.JoinHostPort(address, strconv.Itoa(port))
+	listener, err := net.Listen("tcp", hostPort)
 	if err != nil {
-		return fmt.Errorf("failed to listen on TCP port %d: %w", port, err)
+		return fmt.Errorf("failed to listen on TCP %s: %w", hostPort, err)
 	}
 
 	t.listener = listener
+	t.localIP = net.ParseIP(address)
 	t.running = true
 
 	// Start the connection accepting goroutine
@@ -72,8 +138,8 @@ func (t *TCPTransport) Stop() error {
 
 	// Close all active connections
 	t.connMu.Lock()
-	for conn := range t.connections {
-		conn.Close()
+	for _, connection := range t.connections {
+		connection.conn.Close()
 	}
 	t.connMu.Unlock()
 
@@ -83,12 +149,10 @@ func (t *TCPTransport) Stop() error {
 	return nil
 }
 
-// SendMessage sends a SIP message over TCP
+// SendMessage sends a SIP message over TCP, reusing the open connection for the
+// flow to addr if there is one and connecting otherwise
 func (t *TCPTransport) SendMessage(data []byte, addr net.Addr) error {
-	t.mu.RLock()
-	defer t.mu.RUnlock()
-
-	if !t.running {
+	if !t.IsRunning() {
 		return fmt.Errorf("TCP transport not running")
 	}
 
@@ -97,21 +161,86 @@ func (t *TCPTransport) SendMessage(data []byte, addr net.Addr) error {
 		return fmt.Errorf("invalid address type for TCP transport: %T", addr)
 	}
 
-	// Create a new connection for sending
-	conn, err := net.DialTCP("tcp4", nil, tcpAddr)
+	connection, err := t.connect(tcpAddr)
 	if err != nil {
-		return fmt.Errorf("failed to connect to %s: %w", tcpAddr, err)
+		return err
 	}
-	defer conn.Close()
 
-	_, err = conn.Write(data)
-	if err != nil {
+	if err := connection.write(data); err != nil {
+		connection.conn.Close()
 		return fmt.Errorf("failed to send TCP message: %w", err)
 	}
 
 	return nil
 }
 
+// HasConnection reports whether a connection for the flow to addr is open
+func (t *TCPTransport) HasConnection(addr net.Addr) bool {
+	if addr == nil {
+		return false
+	}
+	t.connMu.RLock()
+	defer t.connMu.RUnlock()
+	_, exists := t.connections[addr.String()]
+	return exists
+}
+
+// connect returns the open connection for the flow to addr, dialing one if there
+// is none. Senders to an address that is being dialed wait for that dial.
+func (t *TCPTransport) connect(addr *net.TCPAddr) (*tcpConnection, error) {
+	key := addr.String()
+
+	t.connMu.Lock()
+	if connection := t.connections[key]; connection != nil {
+		t.connMu.Unlock()
+		return connection, nil
+	}
+	if pending := t.dials[key]; pending != nil {
+		t.connMu.Unlock()
+		<-pending.done
+		return pending.connection, pending.err
+	}
+	pending := &tcpDial{done: make(chan struct{})}
+	t.dials[key] = pending
+	t.connMu.Unlock()
+
+	pending.connection, pending.err = t.dial(addr)
+
+	t.connMu.Lock()
+	delete(t.dials, key)
+	t.connMu.Unlock()
+	close(pending.done)
+
+	return pending.connection, pending.err
+}
+
+// dial opens a TCP connection to addr and starts reading messages from it, so the
+// peer can send requests back over the same connection
+func (t *TCPTransport) dial(addr *net.TCPAddr) (*tcpConnection, error) {
+	dialer := net.Dialer{Timeout: tcpDialTimeout}
+	if t.localIP != nil && !t.localIP.IsUnspecified() {
+		dialer.LocalAddr = &net.TCPAddr{IP: t.localIP}
+	}
+	conn, err := dialer.Dial("tcp", addr.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+
+	// Stop waits for the connection handlers, so only add one while running
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if !t.running {
+		conn.Close()
+		return nil, fmt.Errorf("TCP transport not running")
+	}
+
+	connection := t.track(conn, true)
+	t.wg.Add(1)
+	go t.handleConnection(connection)
+
+	return connection, nil
+}
+
 // RegisterHandler registers a message handler for incoming messages
 func (t *TCPTransport) RegisterHandler(handler MessageHandler) {
 	t.mu.Lock()
@@ -119,6 +248,21 @@ func (t *TCPTransport) RegisterHandler(handler MessageHandler) {
 	t.handler = handler
 }
 
+// RegisterFlowHandler registers the handler told about closed connections
+func (t *TCPTransport) RegisterFlowHandler(handler FlowHandler) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.flowHandler = handler
+}
+
+// SetProxyProtocol makes accepted connections start with a PROXY protocol header,
+// whose client address is then reported as the source of their messages
+func (t *TCPTransport) SetProxyProtocol(enabled bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.proxyProto = enabled
+}
+
 // acceptConnections handles incoming TCP connections
 func (t *TCPTransport) acceptConnections() {
 	defer t.wg.Done()
@@ -159,27 +303,75 @@ func (t *TCPTransport) acceptConnections() {
 			continue
 		}
 
-		// Track the connection
-		t.connMu.Lock()
-		t.connections[conn] = struct{}{}
-		t.connMu.Unlock()
-
 		// Handle the connection in a separate goroutine
 		t.wg.Add(1)
-		go t.handleConnection(conn)
+		go t.handleAccepted(conn)
+	}
+}
+
+// handleAccepted reads the PROXY protocol header of an accepted connection if
+// one is expected, then tracks the connection and reads messages from it
+func (t *TCPTransport) handleAccepted(conn net.Conn) {
+	t.mu.RLock()
+	proxyProto := t.proxyProto
+	t.mu.RUnlock()
+
+	if proxyProto {
+		proxied, err := readProxyHeader(conn)
+		if err != nil {
+			conn.Close()
+			t.wg.Done()
+			return
+		}
+		conn = proxied
 	}
+
+	t.handleConnection(t.track(conn, false))
 }
 
-// handleConnection handles a single TCP connection
-func (t *TCPTransport) handleConnection(conn net.Conn) {
+// track records an open connection under its flow so that messages to its peer reuse it
+func (t *TCPTransport) track(conn net.Conn, outbound bool) *tcpConnection {
+	connection := &tcpConnection{conn: conn, outbound: outbound}
+	connection.touch()
+
+	t.connMu.Lock()
+	t.connections[conn.RemoteAddr().String()] = connection
+	select {
+	case <-t.stopChan:
+		// Stop has already closed the tracked connections
+		conn.Close()
+	default:
+	}
+	t.connMu.Unlock()
+
+	return connection
+}
+
+// handleConnection handles a single TCP connection. A connection the transport
+// opened is closed once it has been idle for the idle timeout; that is not a
+// flow failure.
+func (t *TCPTransport) handleConnection(connection *tcpConnection) {
 	defer t.wg.Done()
+
+	conn := connection.conn
+	idle := false
 	defer func() {
 		conn.Close()
 		t.connMu.Lock()
-		delete(t.connections, conn)
+		current := t.connections[conn.RemoteAddr().String()] == connection
+		if current {
+			delete(t.connections, conn.RemoteAddr().String())
+		}
 		t.connMu.Unlock()
+		if current && !idle {
+			t.flowFailed(conn.RemoteAddr())
+		}
 	}()
 
+	readTimeout := tcpReadTimeout
+	if connection.outbound && t.idleTimeout < readTimeout {
+		readTimeout = t.idleTimeout
+	}
 	reader := bufio.NewReader(conn)
 
 	for {
@@ -190,7 +382,7 @@ func (t *TCPTransport) handleConnection(conn net.Conn) {
 		}
 
 		// Set read timeout
-		conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+		conn.SetReadDeadline(time.Now().Add(readTimeout))
 
 		// Read SIP message from TCP stream
 		message, err := t.readSIPMessage(reader)
@@ -200,12 +392,24 @@ func (t *TCPTransport) handleConnection(conn net.Conn) {
 				return
 			}
 			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				if connection.outbound && connection.idleFor() >= t.idleTimeout {
+					idle = true
+					return
+				}
 				// Timeout, continue to check stop signal
 				continue
 			}
 			// Other error, close connection
 			return
 		}
+		connection.touch()
+
+		if isCRLFPing(message) {
+			if err := connection.write([]byte(crlfPong)); err != nil {
+				return
+			}
+			continue
+		}
 
 		if len(message) > 0 {
 			// Get handler
@@ -225,7 +429,25 @@ func (t *TCPTransport) handleConnection(conn net.Conn) {
 	}
 }
 
-// readSIPMessage reads a complete SIP message from TCP stream
+// flowFailed reports a closed connection to the flow handler unless the transport is stopping
+func (t *TCPTransport) flowFailed(addr net.Addr) {
+	select {
+	case <-t.stopChan:
+		return
+	default:
+	}
+
+	t.mu.RLock()
+	handler := t.flowHandler
+	t.mu.RUnlock()
+
+	if handler != nil {
+		handler.FlowFailed("TCP", addr)
+	}
+}
+
+// readSIPMessage reads a complete SIP message from TCP stream. A keepalive ping
+// is returned as crlfPing; a lone CRLF between messages is returned empty.
 func (t *TCPTransport) readSIPMessage(reader *bufio.Reader) ([]byte, error) {
 	var message []byte
 	var contentLength int = -1
@@ -237,6 +459,14 @@ func (t *TCPTransport) readSIPMessage(reader *bufio.Reader) ([]byte, error) {
 			return nil, err
 		}
 
+		if len(message) == 0 && string(line) == crlfPong {
+			if next, err := reader.Peek(2); err == nil && string(next) == crlfPong {
+				reader.Discard(2)
+				return []byte(crlfPing), nil
+			}
+			return nil, nil
+		}
+
 		message = append(message, line...)
 
 		// Check for end of headers (empty line)
@@ -325,4 +555,11 @@ func (t *TCPTransport) LocalAddr() net.Addr {
 		return t.listener.Addr()
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+// ConnectionCount returns the number of open TCP connections
+func (t *TCPTransport) ConnectionCount() int {
+	t.connMu.RLock()
+	defer t.connMu.RUnlock()
+	return len(t.connections)
+}
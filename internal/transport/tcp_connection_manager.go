@@ -21,6 +21,7 @@ type TCPConnection struct {
 	remoteAddr   net.Addr
 	id           string
 	mu           sync.RWMutex
+	writeMu      sync.Mutex
 }
 
 // NewTCPConnection creates a new managed TCP connection
@@ -59,6 +60,26 @@ func (tc *TCPConnection) IsIdle(idleTimeout time.Duration) bool {
 	return time.Since(tc.lastActivity) > idleTimeout
 }
 
+// Write sends a message on the connection within timeout. Concurrent writers are
+// serialized so that messages are never interleaved.
+func (tc *TCPConnection) Write(data []byte, timeout time.Duration) error {
+	tc.writeMu.Lock()
+	defer tc.writeMu.Unlock()
+
+	if err := tc.SetWriteTimeout(timeout); err != nil {
+		return err
+	}
+	if _, err := tc.writer.Write(data); err != nil {
+		return err
+	}
+	if err := tc.writer.Flush(); err != nil {
+		return err
+	}
+
+	tc.UpdateActivity()
+	return nil
+}
+
 // Close closes the underlying connection
 func (tc *TCPConnection) Close() error {
 	return tc.conn.Close()
@@ -93,6 +114,7 @@ func (tc *TCPConnection) SetWriteTimeout(timeout time.Duration) error {
 // TCPConnectionManager manages a pool of TCP connections with lifecycle management
 type TCPConnectionManager struct {
 	connections    map[string]*TCPConnection
+	flows          map[string]*TCPConnection // Connections by remote address and port
 	mu             sync.RWMutex
 	idleTimeout    time.Duration
 	readTimeout    time.Duration
@@ -147,6 +169,7 @@ func NewTCPConnectionManager(config *TCPConnectionManagerConfig) *TCPConnectionM
 
 	manager := &TCPConnectionManager{
 		connections:  make(map[string]*TCPConnection),
+		flows:        make(map[string]*TCPConnection),
 		idleTimeout:  config.IdleTimeout,
 		readTimeout:  config.ReadTimeout,
 		writeTimeout: config.WriteTimeout,
@@ -168,6 +191,7 @@ func (cm *TCPConnectionManager) AddConnection(conn net.Conn) *TCPConnection {
 	defer cm.mu.Unlock()
 	
 	cm.connections[tcpConn.GetID()] = tcpConn
+	cm.flows[tcpConn.GetRemoteAddr().String()] = tcpConn
 	
 	// Set initial timeouts
 	if cm.readTimeout > 0 {
@@ -189,11 +213,20 @@ func (cm *TCPConnectionManager) RemoveConnection(connectionID string) {
 	
 	if conn, exists := cm.connections[connectionID]; exists {
 		conn.Close()
-		delete(cm.connections, connectionID)
+		cm.remove(conn)
 		cm.logger.Debug("Removed TCP connection", "id", connectionID)
 	}
 }
 
+// remove drops a connection from both indexes; the caller must hold cm.mu
+func (cm *TCPConnectionManager) remove(conn *TCPConnection) {
+	delete(cm.connections, conn.GetID())
+	flow := conn.GetRemoteAddr().String()
+	if cm.flows[flow] == conn {
+		delete(cm.flows, flow)
+	}
+}
+
 // GetConnection retrieves a connection by ID
 func (cm *TCPConnectionManager) GetConnection(connectionID string) (*TCPConnection, bool) {
 	cm.mu.RLock()
@@ -207,6 +240,20 @@ func (cm *TCPConnectionManager) GetConnection(connectionID string) (*TCPConnecti
 	return conn, exists
 }
 
+// GetConnectionByFlow retrieves the connection to the remote address addr, so that
+// requests to a peer reuse the connection it opened (RFC 5923)
+func (cm *TCPConnectionManager) GetConnectionByFlow(addr net.Addr) (*TCPConnection, bool) {
+	if addr == nil {
+		return nil, false
+	}
+
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	conn, exists := cm.flows[addr.String()]
+	return conn, exists
+}
+
 // GetConnectionCount returns the number of active connections
 func (cm *TCPConnectionManager) GetConnectionCount() int {
 	cm.mu.RLock()
@@ -247,7 +294,7 @@ func (cm *TCPConnectionManager) CleanupIdleConnections() int {
 	for _, id := range toRemove {
 		if conn, exists := cm.connections[id]; exists {
 			conn.Close()
-			delete(cm.connections, id)
+			cm.remove(conn)
 			cm.logger.Debug("Cleaned up idle connection", "id", id)
 		}
 	}
@@ -305,6 +352,7 @@ func (cm *TCPConnectionManager) Stop() error {
 	
 	// Clear the connections map
 	cm.connections = make(map[string]*TCPConnection)
+	cm.flows = make(map[string]*TCPConnection)
 	
 	cm.logger.Info("Stopped TCP connection manager", "closed_connections", len(cm.connections))
 	
@@ -1,6 +1,7 @@
 package transport
 
 import (
+	"io"
 	"net"
 	"testing"
 	"time"
@@ -230,6 +231,51 @@ func TestTCPConnectionManager_AddRemoveConnection(t *testing.T) {
 	}
 }
 
+func TestTCPConnectionManager_GetConnectionByFlow(t *testing.T) {
+	manager := NewTCPConnectionManager(nil)
+	defer manager.Stop()
+	
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+	
+	server, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("Failed to accept: %v", err)
+	}
+	
+	tcpConn := manager.AddConnection(server)
+	
+	// The connection is found by the address the peer connected from
+	found, exists := manager.GetConnectionByFlow(client.LocalAddr())
+	if !exists || found != tcpConn {
+		t.Fatalf("Expected to find connection by flow %s", client.LocalAddr())
+	}
+	
+	message := []byte("OPTIONS sip:test@example.com SIP/2.0\r\nContent-Length: 0\r\n\r\n")
+	if err := found.Write(message, time.Second); err != nil {
+		t.Fatalf("Failed to write on connection: %v", err)
+	}
+	buf := make([]byte, len(message))
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := io.ReadFull(client, buf); err != nil || string(buf) != string(message) {
+		t.Errorf("Expected peer to receive %q, got %q (%v)", message, buf, err)
+	}
+	
+	manager.RemoveConnection(tcpConn.GetID())
+	if _, exists := manager.GetConnectionByFlow(client.LocalAddr()); exists {
+		t.Error("Expected flow to be removed with its connection")
+	}
+}
+
 func TestTCPConnectionManager_GetAllConnections(t *testing.T) {
 	manager := NewTCPConnectionManager(nil)
 	defer manager.Stop()
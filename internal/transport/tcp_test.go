@@ -2,6 +2,7 @@ package transport
 
 import (
 	"fmt"
+	"io"
 	"net"
 	"strings"
 	"sync"
@@ -407,4 +408,298 @@ func TestTCPTransport_ConnectionCleanup(t *testing.T) {
 	if connectionCount != 0 {
 		t.Errorf("Expected 0 tracked connections after cleanup, got %d", connectionCount)
 	}
-}
\ No newline at end of file
+}
+
+func TestTCPTransport_DualStack(t *testing.T) {
+	skipWithoutIPv6(t)
+
+	transport := NewTCPTransport()
+	handler := &mockMessageHandler{}
+	transport.RegisterHandler(handler)
+	if err := transport.Start(0); err != nil {
+		t.Fatalf("Failed to start TCP transport: %v", err)
+	}
+	defer transport.Stop()
+
+	port := transport.LocalAddr().(*net.TCPAddr).Port
+	testMessage := []byte("OPTIONS sip:test@[2001:db8::1] SIP/2.0\r\nContent-Length: 0\r\n\r\n")
+
+	// The same listener serves IPv4 and IPv6 clients
+	for _, ip := range []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback} {
+		client, err := net.DialTCP("tcp", nil, &net.TCPAddr{IP: ip, Port: port})
+		if err != nil {
+			t.Fatalf("Failed to connect from %s: %v", ip, err)
+		}
+		defer client.Close()
+		if _, err := client.Write(testMessage); err != nil {
+			t.Fatalf("Failed to send from %s: %v", ip, err)
+		}
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	if messages := handler.getMessages(); len(messages) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(messages))
+	}
+
+	// Outgoing connections to IPv6 peers work as well
+	if err := transport.SendMessage(testMessage, &net.TCPAddr{IP: net.IPv6loopback, Port: port}); err != nil {
+		t.Errorf("Failed to send over IPv6: %v", err)
+	}
+}
+
+func TestTCPTransport_ConnectionReuse(t *testing.T) {
+	transport := NewTCPTransport()
+	handler := &mockMessageHandler{}
+	transport.RegisterHandler(handler)
+	if err := transport.Start(0); err != nil {
+		t.Fatalf("Failed to start TCP transport: %v", err)
+	}
+	defer transport.Stop()
+
+	client, err := net.DialTCP("tcp", nil, transport.LocalAddr().(*net.TCPAddr))
+	if err != nil {
+		t.Fatalf("Failed to create client connection: %v", err)
+	}
+	defer client.Close()
+
+	register := []byte("REGISTER sip:example.com SIP/2.0\r\nContent-Length: 0\r\n\r\n")
+	if _, err := client.Write(register); err != nil {
+		t.Fatalf("Failed to send REGISTER: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	messages := handler.getMessages()
+	if len(messages) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(messages))
+	}
+	flow := messages[0].addr
+	if !transport.HasConnection(flow) {
+		t.Fatalf("Expected connection for flow %s", flow)
+	}
+
+	// A request to the flow goes over the connection the client opened
+	invite := []byte("INVITE sip:alice@10.0.0.7 SIP/2.0\r\nContent-Length: 0\r\n\r\n")
+	if err := transport.SendMessage(invite, flow); err != nil {
+		t.Fatalf("Failed to send over existing connection: %v", err)
+	}
+
+	buf := make([]byte, len(invite))
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := io.ReadFull(client, buf); err != nil {
+		t.Fatalf("Failed to read from client connection: %v", err)
+	}
+	if string(buf) != string(invite) {
+		t.Errorf("Expected %q, got %q", invite, buf)
+	}
+	if count := transport.ConnectionCount(); count != 1 {
+		t.Errorf("Expected the connection to be reused, got %d connections", count)
+	}
+
+	client.Close()
+	time.Sleep(200 * time.Millisecond)
+	if transport.HasConnection(flow) {
+		t.Error("Expected flow to be forgotten once the connection closed")
+	}
+}
+
+func TestTCPTransport_ConcurrentSendsShareDial(t *testing.T) {
+	peer, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer peer.Close()
+
+	accepted := 0
+	var mu sync.Mutex
+	go func() {
+		for {
+			conn, err := peer.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			accepted++
+			mu.Unlock()
+			go io.Copy(io.Discard, conn)
+		}
+	}()
+
+	transport := NewTCPTransport()
+	if err := transport.Start(0); err != nil {
+		t.Fatalf("Failed to start TCP transport: %v", err)
+	}
+	defer transport.Stop()
+
+	message := []byte("OPTIONS sip:peer@example.com SIP/2.0\r\nContent-Length: 0\r\n\r\n")
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := transport.SendMessage(message, peer.Addr()); err != nil {
+				t.Errorf("Failed to send message: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	time.Sleep(100 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if accepted != 1 {
+		t.Errorf("Expected one connection for concurrent sends, got %d", accepted)
+	}
+}
+
+func TestTCPTransport_IdleOutboundConnectionClosed(t *testing.T) {
+	peer, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer peer.Close()
+
+	transport := NewTCPTransport()
+	transport.idleTimeout = 200 * time.Millisecond
+	flowHandler := &mockFlowHandler{}
+	transport.RegisterFlowHandler(flowHandler)
+	if err := transport.Start(0); err != nil {
+		t.Fatalf("Failed to start TCP transport: %v", err)
+	}
+	defer transport.Stop()
+
+	message := []byte("OPTIONS sip:peer@example.com SIP/2.0\r\nContent-Length: 0\r\n\r\n")
+	if err := transport.SendMessage(message, peer.Addr()); err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+	conn, err := peer.Accept()
+	if err != nil {
+		t.Fatalf("Failed to accept: %v", err)
+	}
+	defer conn.Close()
+	if !transport.HasConnection(peer.Addr()) {
+		t.Fatal("Expected the connection to be kept for reuse")
+	}
+
+	// The peer sees the connection close once it has been idle
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := io.ReadAll(conn); err != nil {
+		t.Fatalf("Expected the idle connection to be closed, got: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if transport.HasConnection(peer.Addr()) {
+		t.Error("Expected the idle connection to be forgotten")
+	}
+	if failed := flowHandler.getFailed(); len(failed) != 0 {
+		t.Errorf("Expected closing an idle connection not to fail the flow, got %v", failed)
+	}
+}
+
+func TestTCPTransport_KeepalivePing(t *testing.T) {
+	transport := NewTCPTransport()
+	handler := &mockMessageHandler{}
+	flowHandler := &mockFlowHandler{}
+	transport.RegisterHandler(handler)
+	transport.RegisterFlowHandler(flowHandler)
+	if err := transport.Start(0); err != nil {
+		t.Fatalf("Failed to start TCP transport: %v", err)
+	}
+	defer transport.Stop()
+
+	client, err := net.DialTCP("tcp", nil, transport.LocalAddr().(*net.TCPAddr))
+	if err != nil {
+		t.Fatalf("Failed to create client connection: %v", err)
+	}
+	defer client.Close()
+
+	if _, err := client.Write([]byte(crlfPing)); err != nil {
+		t.Fatalf("Failed to send ping: %v", err)
+	}
+	pong := make([]byte, len(crlfPong))
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := io.ReadFull(client, pong); err != nil {
+		t.Fatalf("Failed to read pong: %v", err)
+	}
+	if string(pong) != crlfPong {
+		t.Errorf("Expected pong %q, got %q", crlfPong, pong)
+	}
+
+	// Messages after a keepalive are still framed correctly
+	register := []byte("REGISTER sip:example.com SIP/2.0\r\nContent-Length: 0\r\n\r\n")
+	if _, err := client.Write(register); err != nil {
+		t.Fatalf("Failed to send REGISTER: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	messages := handler.getMessages()
+	if len(messages) != 1 || string(messages[0].data) != string(register) {
+		t.Fatalf("Expected only the REGISTER to reach the handler, got %+v", messages)
+	}
+
+	client.Close()
+	time.Sleep(200 * time.Millisecond)
+	failed := flowHandler.getFailed()
+	if len(failed) != 1 || failed[0].transport != "TCP" || failed[0].addr.String() != messages[0].addr.String() {
+		t.Errorf("Expected flow %s to be reported as failed, got %+v", messages[0].addr, failed)
+	}
+}
+
+func TestTCPTransport_ProxyProtocol(t *testing.T) {
+	transport := NewTCPTransport()
+	handler := &mockMessageHandler{}
+	transport.RegisterHandler(handler)
+	transport.SetProxyProtocol(true)
+	if err := transport.Start(0); err != nil {
+		t.Fatalf("Failed to start TCP transport: %v", err)
+	}
+	defer transport.Stop()
+
+	client, err := net.DialTCP("tcp", nil, transport.LocalAddr().(*net.TCPAddr))
+	if err != nil {
+		t.Fatalf("Failed to create client connection: %v", err)
+	}
+	defer client.Close()
+
+	register := []byte("REGISTER sip:example.com SIP/2.0\r\nContent-Length: 0\r\n\r\n")
+	header := "PROXY TCP4 192.0.2.10 198.51.100.1 40000 5060\r\n"
+	if _, err := client.Write(append([]byte(header), register...)); err != nil {
+		t.Fatalf("Failed to send REGISTER: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	messages := handler.getMessages()
+	if len(messages) != 1 || string(messages[0].data) != string(register) {
+		t.Fatalf("Expected the REGISTER without the header, got %+v", messages)
+	}
+	if messages[0].addr.String() != "192.0.2.10:40000" {
+		t.Errorf("Expected client address from the PROXY header, got %s", messages[0].addr)
+	}
+
+	// The connection is indexed by the client address so responses reuse it
+	if !transport.HasConnection(messages[0].addr) {
+		t.Error("Expected connection to be tracked under the client address")
+	}
+	if err := transport.SendMessage([]byte("SIP/2.0 200 OK\r\n\r\n"), messages[0].addr); err != nil {
+		t.Fatalf("Failed to send response: %v", err)
+	}
+	response := make([]byte, len("SIP/2.0 200 OK\r\n\r\n"))
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := io.ReadFull(client, response); err != nil {
+		t.Fatalf("Failed to read response over the relayed connection: %v", err)
+	}
+
+	// Connections that do not start with a header are refused
+	direct, err := net.DialTCP("tcp", nil, transport.LocalAddr().(*net.TCPAddr))
+	if err != nil {
+		t.Fatalf("Failed to create client connection: %v", err)
+	}
+	defer direct.Close()
+	if _, err := direct.Write(register); err != nil {
+		t.Fatalf("Failed to send REGISTER: %v", err)
+	}
+	direct.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := direct.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("Expected connection without PROXY header to be closed, got %v", err)
+	}
+	if len(handler.getMessages()) != 1 {
+		t.Error("Expected message without PROXY header to be dropped")
+	}
+}
@@ -0,0 +1,586 @@
+package transport
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultTLSPort is the default port for SIP over TLS (RFC 3261 section 19.1.2)
+const DefaultTLSPort = 5061
+
+const (
+	tlsHandshakeTimeout = 10 * time.Second
+	tlsDialTimeout      = 10 * time.Second
+	tlsWriteTimeout     = 10 * time.Second
+)
+
+// TLSConfig holds the settings of the TLS transport
+type TLSConfig struct {
+	CertFile     string   // PEM certificate chain presented to peers
+	KeyFile      string   // PEM private key of the certificate
+	CAFile       string   // PEM bundle used to verify peers we connect to; system roots if empty
+	MinVersion   string   // "1.2" (default) or "1.3"
+	CipherSuites []string // IANA cipher suite names; Go defaults if empty
+}
+
+// ParseTLSVersion converts a configured TLS version such as "1.2" to its crypto/tls constant.
+// An empty version selects TLS 1.2.
+func ParseTLSVersion(version string) (uint16, error) {
+	switch version {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported TLS version: %s (must be 1.2 or 1.3)", version)
+	}
+}
+
+// ParseCipherSuites converts IANA cipher suite names to their crypto/tls IDs. Only suites
+// without known security issues are accepted.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	suites := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		suites[suite.Name] = suite.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := suites[name]
+		if !ok {
+			return nil, fmt.Errorf("unsupported cipher suite: %s", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// certificateStore holds the server certificate. Reloading swaps the certificate used
+// for new handshakes; established connections keep the one they negotiated.
+type certificateStore struct {
+	certFile    string
+	keyFile     string
+	certificate atomic.Pointer[tls.Certificate]
+}
+
+// load reads the certificate and key files and makes them the current certificate
+func (s *certificateStore) load() error {
+	certificate, err := tls.LoadX509KeyPair(s.certFile, s.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate %s: %w", s.certFile, err)
+	}
+	s.certificate.Store(&certificate)
+	return nil
+}
+
+// getCertificate implements tls.Config.GetCertificate
+func (s *certificateStore) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	certificate := s.certificate.Load()
+	if certificate == nil {
+		return nil, fmt.Errorf("no certificate loaded")
+	}
+	return certificate, nil
+}
+
+// NamedAddr is a TCP address together with the host name it was resolved from,
+// such as the target of an SRV record. TLS connections to it expect the server
+// certificate to be issued for Name.
+type NamedAddr struct {
+	*net.TCPAddr
+	Name string
+}
+
+// tlsDial is a connection attempt that concurrent senders to the same address wait for
+type tlsDial struct {
+	done       chan struct{}
+	connection *tlsConnection
+	err        error
+}
+
+// tlsConnection is an established TLS connection to a peer
+type tlsConnection struct {
+	conn    *tls.Conn
+	key     string
+	writeMu sync.Mutex
+}
+
+// write sends data on the connection; concurrent writers are serialized so that
+// messages are never interleaved
+func (c *tlsConnection) write(data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(tlsWriteTimeout))
+	_, err := c.conn.Write(data)
+	return err
+}
+
+// TLSTransport handles TLS transport for SIP messages (sips: URIs). Connections are kept
+// open and reused in both directions, so responses and requests for a peer that connected
+// to us are sent over the connection it opened.
+type TLSTransport struct {
+	config       TLSConfig
+	certificates *certificateStore
+	serverConfig *tls.Config
+	clientConfig *tls.Config
+	listener     net.Listener
+	localIP      net.IP // Address connections are opened from; any if nil
+	proxyProto   bool
+	handler      MessageHandler
+	flowHandler  FlowHandler
+	running      bool
+	mu           sync.RWMutex
+	wg           sync.WaitGroup
+	stopChan     chan struct{}
+	connections  map[string]*tlsConnection
+	dials        map[string]*tlsDial
+	connMu       sync.RWMutex
+}
+
+// NewTLSTransport creates a new TLS transport handler
+func NewTLSTransport(config TLSConfig) *TLSTransport {
+	return &TLSTransport{
+		config: config,
+		certificates: &certificateStore{
+			certFile: config.CertFile,
+			keyFile:  config.KeyFile,
+		},
+		stopChan:    make(chan struct{}),
+		connections: make(map[string]*tlsConnection),
+		dials:       make(map[string]*tlsDial),
+	}
+}
+
+// Start loads the certificate and starts the TLS listener on the specified port
+// of all interfaces
+func (t *TLSTransport) Start(port int) error {
+	return t.StartOn("", port)
+}
+
+// StartOn loads the certificate and starts the TLS listener on the specified
+// local address and port. Connections this transport opens originate from the
+// same address.
+func (t *TLSTransport) StartOn(address string, port int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.running {
+		return fmt.Errorf("TLS transport already running")
+	}
+
+	minVersion, err := ParseTLSVersion(t.config.MinVersion)
+	if err != nil {
+		return err
+	}
+	cipherSuites, err := ParseCipherSuites(t.config.CipherSuites)
+	if err != nil {
+		return err
+	}
+	if err := t.certificates.load(); err != nil {
+		return err
+	}
+
+	var rootCAs *x509.CertPool
+	if t.config.CAFile != "" {
+		pem, err := os.ReadFile(t.config.CAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read CA file %s: %w", t.config.CAFile, err)
+		}
+		rootCAs = x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in CA file %s", t.config.CAFile)
+		}
+	}
+
+	t.serverConfig = &tls.Config{
+		GetCertificate: t.certificates.getCertificate,
+		MinVersion:     minVersion,
+		CipherSuites:   cipherSuites,
+	}
+	t.clientConfig = &tls.Config{
+		RootCAs:      rootCAs,
+		MinVersion:   minVersion,
+		CipherSuites: cipherSuites,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return t.certificates.getCertificate(nil)
+		},
+	}
+
+	hostPort := net.JoinHostPort(address, strconv.Itoa(port))
+	listener, err := net.Listen("tcp", hostPort)
+	if err != nil {
+		return fmt.Errorf("failed to listen on TLS %s: %w", hostPort, err)
+	}
+
+	t.listener = listener
+	t.localIP = net.ParseIP(address)
+	t.running = true
+
+	t.wg.Add(1)
+	go t.acceptConnections()
+
+	return nil
+}
+
+// Stop stops the TLS transport and closes all connections
+func (t *TLSTransport) Stop() error {
+	t.mu.Lock()
+	if !t.running {
+		t.mu.Unlock()
+		return nil
+	}
+
+	t.running = false
+	close(t.stopChan)
+
+	if t.listener != nil {
+		t.listener.Close()
+	}
+
+	t.connMu.Lock()
+	for _, connection := range t.connections {
+		connection.conn.Close()
+	}
+	t.connMu.Unlock()
+
+	t.mu.Unlock()
+
+	t.wg.Wait()
+	return nil
+}
+
+// Reload re-reads the certificate and key files. New handshakes use the new
+// certificate; established connections are not affected.
+func (t *TLSTransport) Reload() error {
+	return t.certificates.load()
+}
+
+// SendMessage sends a SIP message over TLS, reusing an open connection to addr
+// if there is one and connecting otherwise. A *NamedAddr is connected to with
+// its Name as the expected server name; any other address with its IP.
+func (t *TLSTransport) SendMessage(data []byte, addr net.Addr) error {
+	t.mu.RLock()
+	running := t.running
+	t.mu.RUnlock()
+
+	if !running {
+		return fmt.Errorf("TLS transport not running")
+	}
+	if addr == nil {
+		return fmt.Errorf("no destination address for TLS transport")
+	}
+
+	connection, err := t.connect(addr)
+	if err != nil {
+		return err
+	}
+
+	if err := connection.write(data); err != nil {
+		connection.conn.Close()
+		return fmt.Errorf("failed to send TLS message: %w", err)
+	}
+
+	return nil
+}
+
+// HasConnection reports whether a connection to addr is open
+func (t *TLSTransport) HasConnection(addr net.Addr) bool {
+	if addr == nil {
+		return false
+	}
+	t.connMu.RLock()
+	defer t.connMu.RUnlock()
+	return t.lookup(addr) != nil
+}
+
+// connectionKey identifies the connection to addr. A connection to a NamedAddr
+// has been verified against its name, so it is only reused for that name.
+func connectionKey(addr net.Addr) string {
+	if named, ok := addr.(*NamedAddr); ok && named.Name != "" {
+		return named.Name + "@" + addr.String()
+	}
+	return addr.String()
+}
+
+// lookup returns the open connection to addr. A plain address also matches a
+// connection dialed to a name at that address, so that responses to requests
+// the peer sends on it reuse the connection. The caller holds connMu.
+func (t *TLSTransport) lookup(addr net.Addr) *tlsConnection {
+	if connection := t.connections[connectionKey(addr)]; connection != nil {
+		return connection
+	}
+	if named, ok := addr.(*NamedAddr); ok && named.Name != "" {
+		return nil
+	}
+	peer := addr.String()
+	for _, connection := range t.connections {
+		if connection.conn.RemoteAddr().String() == peer {
+			return connection
+		}
+	}
+	return nil
+}
+
+// connect returns the open connection to addr, dialing one if there is none.
+// Senders to an address that is being dialed wait for that dial.
+func (t *TLSTransport) connect(addr net.Addr) (*tlsConnection, error) {
+	key := connectionKey(addr)
+
+	t.connMu.Lock()
+	if connection := t.lookup(addr); connection != nil {
+		t.connMu.Unlock()
+		return connection, nil
+	}
+	if pending := t.dials[key]; pending != nil {
+		t.connMu.Unlock()
+		<-pending.done
+		return pending.connection, pending.err
+	}
+	pending := &tlsDial{done: make(chan struct{})}
+	t.dials[key] = pending
+	t.connMu.Unlock()
+
+	pending.connection, pending.err = t.dial(addr)
+
+	t.connMu.Lock()
+	delete(t.dials, key)
+	t.connMu.Unlock()
+	close(pending.done)
+
+	return pending.connection, pending.err
+}
+
+// dial opens a TLS connection to addr and starts reading messages from it
+func (t *TLSTransport) dial(addr net.Addr) (*tlsConnection, error) {
+	serverName, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid address for TLS transport: %w", err)
+	}
+	if named, ok := addr.(*NamedAddr); ok && named.Name != "" {
+		serverName = named.Name
+	}
+
+	t.mu.RLock()
+	config := t.clientConfig.Clone()
+	localIP := t.localIP
+	t.mu.RUnlock()
+	config.ServerName = serverName
+
+	dialer := &net.Dialer{Timeout: tlsDialTimeout}
+	if localIP != nil && !localIP.IsUnspecified() {
+		dialer.LocalAddr = &net.TCPAddr{IP: localIP}
+	}
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr.String(), config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+
+	// Stop waits for the connection readers, so only add one while running
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if !t.running {
+		conn.Close()
+		return nil, fmt.Errorf("TLS transport not running")
+	}
+
+	connection := t.track(conn, connectionKey(addr))
+	t.wg.Add(1)
+	go t.readMessages(connection)
+
+	return connection, nil
+}
+
+// RegisterHandler registers a message handler for incoming messages
+func (t *TLSTransport) RegisterHandler(handler MessageHandler) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.handler = handler
+}
+
+// RegisterFlowHandler registers the handler told about closed connections
+func (t *TLSTransport) RegisterFlowHandler(handler FlowHandler) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.flowHandler = handler
+}
+
+// SetProxyProtocol makes accepted connections start with a PROXY protocol header,
+// sent before the TLS handshake, whose client address is then reported as the
+// source of their messages
+func (t *TLSTransport) SetProxyProtocol(enabled bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.proxyProto = enabled
+}
+
+// acceptConnections handles incoming TCP connections and wraps them in TLS
+func (t *TLSTransport) acceptConnections() {
+	defer t.wg.Done()
+
+	for {
+		conn, err := t.listener.Accept()
+		if err != nil {
+			select {
+			case <-t.stopChan:
+				return
+			default:
+			}
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				continue
+			}
+			// Log error but continue accepting
+			continue
+		}
+
+		t.wg.Add(1)
+		go t.handleConnection(conn)
+	}
+}
+
+// handleConnection reads the PROXY protocol header of an accepted connection if
+// one is expected, completes the handshake and reads messages from it
+func (t *TLSTransport) handleConnection(raw net.Conn) {
+	t.mu.RLock()
+	proxyProto := t.proxyProto
+	t.mu.RUnlock()
+
+	if proxyProto {
+		proxied, err := readProxyHeader(raw)
+		if err != nil {
+			raw.Close()
+			t.wg.Done()
+			return
+		}
+		raw = proxied
+	}
+
+	conn := tls.Server(raw, t.serverConfig)
+	conn.SetDeadline(time.Now().Add(tlsHandshakeTimeout))
+	if err := conn.Handshake(); err != nil {
+		conn.Close()
+		t.wg.Done()
+		return
+	}
+	conn.SetDeadline(time.Time{})
+
+	t.readMessages(t.track(conn, conn.RemoteAddr().String()))
+}
+
+// track records an established connection under key so that messages to its peer reuse it
+func (t *TLSTransport) track(conn *tls.Conn, key string) *tlsConnection {
+	connection := &tlsConnection{conn: conn, key: key}
+
+	t.connMu.Lock()
+	t.connections[key] = connection
+	select {
+	case <-t.stopChan:
+		// Stop has already closed the tracked connections
+		conn.Close()
+	default:
+	}
+	t.connMu.Unlock()
+
+	return connection
+}
+
+// readMessages reads SIP messages from a connection until it is closed
+func (t *TLSTransport) readMessages(connection *tlsConnection) {
+	defer t.wg.Done()
+
+	conn := connection.conn
+	defer func() {
+		conn.Close()
+		t.connMu.Lock()
+		current := t.connections[connection.key] == connection
+		if current {
+			delete(t.connections, connection.key)
+		}
+		t.connMu.Unlock()
+		if current {
+			t.flowFailed(conn.RemoteAddr())
+		}
+	}()
+
+	reader := NewStreamingTCPMessageReader(bufio.NewReader(conn))
+
+	for {
+		message, err := reader.ReadMessage()
+		if err != nil {
+			// Connection closed by the peer, on Stop, or the stream is corrupt
+			return
+		}
+
+		if isCRLFPing(message) {
+			if err := connection.write([]byte(crlfPong)); err != nil {
+				return
+			}
+			continue
+		}
+
+		t.mu.RLock()
+		handler := t.handler
+		t.mu.RUnlock()
+
+		if handler != nil {
+			go func() {
+				if err := handler.HandleMessage(message, "TLS", conn.RemoteAddr()); err != nil {
+					// Log error handling message
+					// In a real implementation, this would use proper logging
+				}
+			}()
+		}
+	}
+}
+
+// flowFailed reports a closed connection to the flow handler unless the transport is stopping
+func (t *TLSTransport) flowFailed(addr net.Addr) {
+	select {
+	case <-t.stopChan:
+		return
+	default:
+	}
+
+	t.mu.RLock()
+	handler := t.flowHandler
+	t.mu.RUnlock()
+
+	if handler != nil {
+		handler.FlowFailed("TLS", addr)
+	}
+}
+
+// IsRunning returns true if the TLS transport is running
+func (t *TLSTransport) IsRunning() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.running
+}
+
+// LocalAddr returns the local address of the TLS listener
+func (t *TLSTransport) LocalAddr() net.Addr {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.listener != nil {
+		return t.listener.Addr()
+	}
+	return nil
+}
+
+// ConnectionCount returns the number of open TLS connections
+func (t *TLSTransport) ConnectionCount() int {
+	t.connMu.RLock()
+	defer t.connMu.RUnlock()
+	return len(t.connections)
+}
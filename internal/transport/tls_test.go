@@ -0,0 +1,417 @@
+package transport
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// writeTestCertificate writes a self-signed certificate for 127.0.0.1 and the host
+// name commonName to dir and returns the TLS configuration referring to it
+func writeTestCertificate(t *testing.T, dir, commonName string) TLSConfig {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		DNSNames:              []string{commonName},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	config := TLSConfig{
+		CertFile: filepath.Join(dir, "cert.pem"),
+		KeyFile:  filepath.Join(dir, "key.pem"),
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(config.CertFile, certPEM, 0600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(config.KeyFile, keyPEM, 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	return config
+}
+
+// dialTestTLS connects to a TLS transport, trusting the certificate in config
+func dialTestTLS(t *testing.T, addr net.Addr, config TLSConfig) *tls.Conn {
+	t.Helper()
+
+	pem, err := os.ReadFile(config.CertFile)
+	if err != nil {
+		t.Fatalf("Failed to read certificate: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(pem)
+
+	port := addr.(*net.TCPAddr).Port
+	conn, err := tls.Dial("tcp4", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)), &tls.Config{RootCAs: roots})
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	return conn
+}
+
+// waitForMessages waits until handler has received count messages
+func waitForMessages(t *testing.T, handler *mockMessageHandler, count int) []mockMessage {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if messages := handler.getMessages(); len(messages) >= count {
+			return messages
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Expected %d messages, got %d", count, len(handler.getMessages()))
+	return nil
+}
+
+const testTLSRequest = "OPTIONS sips:example.com SIP/2.0\r\nContent-Length: 0\r\n\r\n"
+const testTLSResponse = "SIP/2.0 200 OK\r\nContent-Length: 0\r\n\r\n"
+
+func TestTLSTransport_StartStop(t *testing.T) {
+	transport := NewTLSTransport(writeTestCertificate(t, t.TempDir(), "server"))
+
+	if err := transport.Start(0); err != nil {
+		t.Fatalf("Failed to start TLS transport: %v", err)
+	}
+	if !transport.IsRunning() {
+		t.Error("Transport should be running after start")
+	}
+	if err := transport.Start(0); err == nil {
+		t.Error("Expected error when starting transport twice")
+	}
+
+	if err := transport.Stop(); err != nil {
+		t.Fatalf("Failed to stop TLS transport: %v", err)
+	}
+	if transport.IsRunning() {
+		t.Error("Transport should not be running after stop")
+	}
+}
+
+func TestTLSTransport_StartErrors(t *testing.T) {
+	valid := writeTestCertificate(t, t.TempDir(), "server")
+
+	tests := []struct {
+		name   string
+		config TLSConfig
+	}{
+		{"missing certificate", TLSConfig{CertFile: "missing.pem", KeyFile: valid.KeyFile}},
+		{"invalid min version", TLSConfig{CertFile: valid.CertFile, KeyFile: valid.KeyFile, MinVersion: "1.0"}},
+		{"unknown cipher suite", TLSConfig{CertFile: valid.CertFile, KeyFile: valid.KeyFile, CipherSuites: []string{"TLS_NULL"}}},
+		{"missing CA file", TLSConfig{CertFile: valid.CertFile, KeyFile: valid.KeyFile, CAFile: "missing.pem"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := NewTLSTransport(tt.config)
+			if err := transport.Start(0); err == nil {
+				transport.Stop()
+				t.Error("Expected error starting TLS transport")
+			}
+		})
+	}
+}
+
+func TestTLSTransport_RespondOnClientConnection(t *testing.T) {
+	config := writeTestCertificate(t, t.TempDir(), "server")
+	transport := NewTLSTransport(config)
+	handler := &mockMessageHandler{}
+	transport.RegisterHandler(handler)
+	if err := transport.Start(0); err != nil {
+		t.Fatalf("Failed to start TLS transport: %v", err)
+	}
+	defer transport.Stop()
+
+	conn := dialTestTLS(t, transport.LocalAddr(), config)
+	defer conn.Close()
+	if _, err := conn.Write([]byte(testTLSRequest)); err != nil {
+		t.Fatalf("Failed to write request: %v", err)
+	}
+
+	messages := waitForMessages(t, handler, 1)
+	if messages[0].transport != "TLS" {
+		t.Errorf("Expected transport TLS, got %s", messages[0].transport)
+	}
+	if string(messages[0].data) != testTLSRequest {
+		t.Errorf("Received %q, expected %q", messages[0].data, testTLSRequest)
+	}
+
+	// The response must come back on the connection the client opened
+	if err := transport.SendMessage([]byte(testTLSResponse), messages[0].addr); err != nil {
+		t.Fatalf("Failed to send response: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	reader := NewStreamingTCPMessageReader(bufio.NewReader(conn))
+	response, err := reader.ReadMessage()
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	if string(response) != testTLSResponse {
+		t.Errorf("Received %q, expected %q", response, testTLSResponse)
+	}
+	if transport.ConnectionCount() != 1 {
+		t.Errorf("Expected 1 connection, got %d", transport.ConnectionCount())
+	}
+}
+
+func TestTLSTransport_SendMessageConnects(t *testing.T) {
+	serverConfig := writeTestCertificate(t, t.TempDir(), "server")
+	server := NewTLSTransport(serverConfig)
+	handler := &mockMessageHandler{}
+	server.RegisterHandler(handler)
+	if err := server.Start(0); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	clientConfig := writeTestCertificate(t, t.TempDir(), "client")
+	clientConfig.CAFile = serverConfig.CertFile
+	client := NewTLSTransport(clientConfig)
+	if err := client.Start(0); err != nil {
+		t.Fatalf("Failed to start client: %v", err)
+	}
+	defer client.Stop()
+
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: server.LocalAddr().(*net.TCPAddr).Port}
+	for i := 0; i < 2; i++ {
+		if err := client.SendMessage([]byte(testTLSRequest), addr); err != nil {
+			t.Fatalf("Failed to send message: %v", err)
+		}
+	}
+
+	waitForMessages(t, handler, 2)
+	if client.ConnectionCount() != 1 {
+		t.Errorf("Expected the connection to be reused, got %d connections", client.ConnectionCount())
+	}
+}
+
+func TestTLSTransport_SendMessageUntrustedServer(t *testing.T) {
+	server := NewTLSTransport(writeTestCertificate(t, t.TempDir(), "server"))
+	if err := server.Start(0); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	// The client does not trust the server's self-signed certificate
+	clientConfig := writeTestCertificate(t, t.TempDir(), "client")
+	clientConfig.CAFile = clientConfig.CertFile
+	client := NewTLSTransport(clientConfig)
+	if err := client.Start(0); err != nil {
+		t.Fatalf("Failed to start client: %v", err)
+	}
+	defer client.Stop()
+
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: server.LocalAddr().(*net.TCPAddr).Port}
+	if err := client.SendMessage([]byte(testTLSRequest), addr); err == nil {
+		t.Error("Expected certificate verification to fail")
+	}
+}
+
+func TestTLSTransport_SendMessageVerifiesServerName(t *testing.T) {
+	serverConfig := writeTestCertificate(t, t.TempDir(), "sip.example.com")
+	server := NewTLSTransport(serverConfig)
+	if err := server.Start(0); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	clientConfig := writeTestCertificate(t, t.TempDir(), "client")
+	clientConfig.CAFile = serverConfig.CertFile
+	client := NewTLSTransport(clientConfig)
+	if err := client.Start(0); err != nil {
+		t.Fatalf("Failed to start client: %v", err)
+	}
+	defer client.Stop()
+
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: server.LocalAddr().(*net.TCPAddr).Port}
+	if err := client.SendMessage([]byte(testTLSRequest), &NamedAddr{TCPAddr: addr, Name: "other.example.com"}); err == nil {
+		t.Error("Expected a certificate for another name to be rejected")
+	}
+	if err := client.SendMessage([]byte(testTLSRequest), &NamedAddr{TCPAddr: addr, Name: "sip.example.com"}); err != nil {
+		t.Errorf("Expected the certificate to be verified against the host name: %v", err)
+	}
+
+	// The connection verified for sip.example.com must not be reused for another name
+	if client.HasConnection(&NamedAddr{TCPAddr: addr, Name: "other.example.com"}) {
+		t.Error("Expected no connection for another name at the same address")
+	}
+	if err := client.SendMessage([]byte(testTLSRequest), &NamedAddr{TCPAddr: addr, Name: "other.example.com"}); err == nil {
+		t.Error("Expected a certificate for another name to be rejected on a new connection")
+	}
+	if !client.HasConnection(&NamedAddr{TCPAddr: addr, Name: "sip.example.com"}) || !client.HasConnection(addr) {
+		t.Error("Expected the connection to be found by name and by peer address")
+	}
+}
+
+func TestTLSTransport_ConcurrentSendsShareDial(t *testing.T) {
+	serverConfig := writeTestCertificate(t, t.TempDir(), "server")
+	server := NewTLSTransport(serverConfig)
+	handler := &mockMessageHandler{}
+	server.RegisterHandler(handler)
+	if err := server.Start(0); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	clientConfig := writeTestCertificate(t, t.TempDir(), "client")
+	clientConfig.CAFile = serverConfig.CertFile
+	client := NewTLSTransport(clientConfig)
+	if err := client.Start(0); err != nil {
+		t.Fatalf("Failed to start client: %v", err)
+	}
+	defer client.Stop()
+
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: server.LocalAddr().(*net.TCPAddr).Port}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := client.SendMessage([]byte(testTLSRequest), addr); err != nil {
+				t.Errorf("Failed to send message: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	waitForMessages(t, handler, 10)
+	if server.ConnectionCount() != 1 {
+		t.Errorf("Expected one connection for concurrent sends, got %d", server.ConnectionCount())
+	}
+}
+
+func TestTLSTransport_SendMessageNotRunning(t *testing.T) {
+	transport := NewTLSTransport(TLSConfig{})
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: DefaultTLSPort}
+
+	if err := transport.SendMessage([]byte(testTLSRequest), addr); err == nil {
+		t.Error("Expected error when sending on stopped transport")
+	}
+}
+
+func TestTLSTransport_ReloadKeepsConnections(t *testing.T) {
+	dir := t.TempDir()
+	oldConfig := writeTestCertificate(t, dir, "old")
+	transport := NewTLSTransport(oldConfig)
+	handler := &mockMessageHandler{}
+	transport.RegisterHandler(handler)
+	if err := transport.Start(0); err != nil {
+		t.Fatalf("Failed to start TLS transport: %v", err)
+	}
+	defer transport.Stop()
+
+	oldConn := dialTestTLS(t, transport.LocalAddr(), oldConfig)
+	defer oldConn.Close()
+
+	// Replace the certificate files and reload
+	newConfig := writeTestCertificate(t, dir, "new")
+	if err := transport.Reload(); err != nil {
+		t.Fatalf("Failed to reload: %v", err)
+	}
+
+	// The established connection keeps working
+	if _, err := oldConn.Write([]byte(testTLSRequest)); err != nil {
+		t.Fatalf("Failed to write on existing connection: %v", err)
+	}
+	waitForMessages(t, handler, 1)
+
+	// New connections are served with the new certificate
+	newConn := dialTestTLS(t, transport.LocalAddr(), newConfig)
+	defer newConn.Close()
+	if cn := newConn.ConnectionState().PeerCertificates[0].Subject.CommonName; cn != "new" {
+		t.Errorf("Expected new certificate, got %s", cn)
+	}
+
+	// A broken certificate file keeps the current certificate in use
+	if err := os.WriteFile(newConfig.CertFile, []byte("garbage"), 0600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	if err := transport.Reload(); err == nil {
+		t.Error("Expected error reloading an invalid certificate")
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(newConn.ConnectionState().PeerCertificates[0])
+	conn, err := tls.Dial("tcp4", transport.LocalAddr().String(), &tls.Config{RootCAs: roots, ServerName: "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("Failed to connect after failed reload: %v", err)
+	}
+	defer conn.Close()
+	if cn := conn.ConnectionState().PeerCertificates[0].Subject.CommonName; cn != "new" {
+		t.Errorf("Expected new certificate to stay in use, got %s", cn)
+	}
+}
+
+func TestParseTLSVersion(t *testing.T) {
+	tests := []struct {
+		version  string
+		expected uint16
+		wantErr  bool
+	}{
+		{"", tls.VersionTLS12, false},
+		{"1.2", tls.VersionTLS12, false},
+		{"1.3", tls.VersionTLS13, false},
+		{"1.1", 0, true},
+		{"TLSv1.2", 0, true},
+	}
+	for _, tt := range tests {
+		version, err := ParseTLSVersion(tt.version)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTLSVersion(%q) error = %v, wantErr %v", tt.version, err, tt.wantErr)
+		}
+		if version != tt.expected {
+			t.Errorf("ParseTLSVersion(%q) = %x, expected %x", tt.version, version, tt.expected)
+		}
+	}
+}
+
+func TestParseCipherSuites(t *testing.T) {
+	suites, err := ParseCipherSuites([]string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384}
+	if len(suites) != len(expected) || suites[0] != expected[0] || suites[1] != expected[1] {
+		t.Errorf("Got %v, expected %v", suites, expected)
+	}
+
+	if suites, err := ParseCipherSuites(nil); err != nil || suites != nil {
+		t.Errorf("Expected Go defaults for no suites, got %v, %v", suites, err)
+	}
+
+	// Insecure suites are rejected
+	if _, err := ParseCipherSuites([]string{"TLS_RSA_WITH_RC4_128_SHA"}); err == nil {
+		t.Error("Expected error for insecure cipher suite")
+	}
+}
@@ -3,29 +3,43 @@ package transport
 import (
 	"fmt"
 	"net"
+	"strconv"
 	"sync"
 	"time"
 )
 
-// UDPTransport handles UDP transport for SIP messages
+// udpFlowTimeout is how long a UDP flow that sends STUN keepalives may stay
+// silent before it is reported as failed; it spans several keepalive intervals
+const udpFlowTimeout = 3 * time.Minute
+
+// UDPTransport handles UDP transport for SIP messages. It answers STUN keepalives
+// itself and reports flows whose keepalives stop to the flow handler.
 type UDPTransport struct {
-	conn     *net.UDPConn
-	handler  MessageHandler
-	running  bool
-	mu       sync.RWMutex
-	wg       sync.WaitGroup
-	stopChan chan struct{}
+	conn        *net.UDPConn
+	handler     MessageHandler
+	flowHandler FlowHandler
+	flows       *udpFlowTracker
+	running     bool
+	mu          sync.RWMutex
+	wg          sync.WaitGroup
+	stopChan    chan struct{}
 }
 
 // NewUDPTransport creates a new UDP transport handler
 func NewUDPTransport() *UDPTransport {
 	return &UDPTransport{
+		flows:    newUDPFlowTracker(udpFlowTimeout),
 		stopChan: make(chan struct{}),
 	}
 }
 
-// Start starts the UDP listener on the specified port
+// Start starts the UDP listener on the specified port of all interfaces
 func (u *UDPTransport) Start(port int) error {
+	return u.StartOn("", port)
+}
+
+// StartOn starts the UDP listener on the specified local address and port
+func (u *UDPTransport) StartOn(address string, port int) error {
 	u.mu.Lock()
 	defer u.mu.Unlock()
 
@@ -33,14 +47,14 @@ func (u *UDPTransport) Start(port int) error {
 		return fmt.Errorf("UDP transport already running")
 	}
 
-	addr, err := net.ResolveUDPAddr("udp4", fmt.Sprintf(":%d", port))
+	addr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(address, strconv.Itoa(port)))
 	if err != nil {
 		return fmt.Errorf("failed to resolve UDP address: %w", err)
 	}
 
 	conn, err := net.ListenUDP("udp", addr)
 	if err != nil {
-		return fmt.Errorf("failed to listen on UDP port %d: %w", port, err)
+		return fmt.Errorf("failed to listen on UDP %s: %w", addr, err)
 	}
 
 	u.conn = conn
@@ -102,6 +116,13 @@ func (u *UDPTransport) RegisterHandler(handler MessageHandler) {
 	u.handler = handler
 }
 
+// RegisterFlowHandler registers the handler told about UDP flows whose keepalives stopped
+func (u *UDPTransport) RegisterFlowHandler(handler FlowHandler) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.flowHandler = handler
+}
+
 // receiveMessages handles incoming UDP messages
 func (u *UDPTransport) receiveMessages() {
 	defer u.wg.Done()
@@ -128,6 +149,7 @@ func (u *UDPTransport) receiveMessages() {
 		conn.SetReadDeadline(time.Now().Add(1 * time.Second))
 
 		n, addr, err := conn.ReadFromUDP(buffer)
+		u.expireFlows()
 		if err != nil {
 			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
 				// Timeout is expected, continue loop to check stop signal
@@ -143,6 +165,13 @@ func (u *UDPTransport) receiveMessages() {
 			continue
 		}
 
+		if isSTUNBindingRequest(buffer[:n]) {
+			u.flows.keepalive(addr, time.Now())
+			conn.WriteToUDP(stunBindingResponse(buffer[:n], addr), addr)
+			continue
+		}
+		u.flows.seen(addr, time.Now())
+
 		if n > 0 && handler != nil {
 			// Make a copy of the received data
 			data := make([]byte, n)
@@ -159,6 +188,24 @@ func (u *UDPTransport) receiveMessages() {
 	}
 }
 
+// expireFlows reports the UDP flows whose keepalives have stopped as failed
+func (u *UDPTransport) expireFlows() {
+	expired := u.flows.expire(time.Now())
+	if len(expired) == 0 {
+		return
+	}
+
+	u.mu.RLock()
+	handler := u.flowHandler
+	u.mu.RUnlock()
+
+	if handler != nil {
+		for _, addr := range expired {
+			handler.FlowFailed("UDP", addr)
+		}
+	}
+}
+
 // IsRunning returns true if the UDP transport is running
 func (u *UDPTransport) IsRunning() bool {
 	u.mu.RLock()
@@ -1,6 +1,7 @@
 package transport
 
 import (
+	"encoding/binary"
 	"fmt"
 	"net"
 	"sync"
@@ -51,6 +52,26 @@ func (m *mockMessageHandler) reset() {
 	m.messages = nil
 }
 
+// mockFlowHandler implements FlowHandler for testing
+type mockFlowHandler struct {
+	failed []mockMessage
+	mu     sync.Mutex
+}
+
+func (m *mockFlowHandler) FlowFailed(transport string, addr net.Addr) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failed = append(m.failed, mockMessage{transport: transport, addr: addr})
+}
+
+func (m *mockFlowHandler) getFailed() []mockMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := make([]mockMessage, len(m.failed))
+	copy(result, m.failed)
+	return result
+}
+
 func TestUDPTransport_StartStop(t *testing.T) {
 	transport := NewUDPTransport()
 
@@ -346,4 +367,137 @@ func TestUDPTransport_ConcurrentOperations(t *testing.T) {
 	if len(messages) != expectedCount {
 		t.Errorf("Expected %d messages, got %d", expectedCount, len(messages))
 	}
-}
\ No newline at end of file
+}
+
+// skipWithoutIPv6 skips tests that need the IPv6 loopback address
+func skipWithoutIPv6(t *testing.T) {
+	t.Helper()
+	listener, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback not available: %v", err)
+	}
+	listener.Close()
+}
+
+func TestUDPTransport_DualStack(t *testing.T) {
+	skipWithoutIPv6(t)
+
+	transport := NewUDPTransport()
+	handler := &mockMessageHandler{}
+	transport.RegisterHandler(handler)
+	if err := transport.Start(0); err != nil {
+		t.Fatalf("Failed to start UDP transport: %v", err)
+	}
+	defer transport.Stop()
+
+	port := transport.LocalAddr().(*net.UDPAddr).Port
+	testMessage := []byte("OPTIONS sip:test@[2001:db8::1] SIP/2.0\r\nContent-Length: 0\r\n\r\n")
+
+	// The same listener serves IPv4 and IPv6 clients
+	for _, ip := range []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback} {
+		client, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: ip, Port: port})
+		if err != nil {
+			t.Fatalf("Failed to create client for %s: %v", ip, err)
+		}
+		defer client.Close()
+		if _, err := client.Write(testMessage); err != nil {
+			t.Fatalf("Failed to send from %s: %v", ip, err)
+		}
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	messages := handler.getMessages()
+	if len(messages) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(messages))
+	}
+
+	// Replies reach IPv6 clients at their source address
+	var ipv6Source *net.UDPAddr
+	for _, message := range messages {
+		if addr := message.addr.(*net.UDPAddr); addr.IP.To4() == nil {
+			ipv6Source = addr
+		}
+	}
+	if ipv6Source == nil {
+		t.Fatal("Expected a message from an IPv6 source")
+	}
+	if err := transport.SendMessage(testMessage, ipv6Source); err != nil {
+		t.Errorf("Failed to send to %s: %v", ipv6Source, err)
+	}
+}
+
+func TestUDPTransport_STUNKeepalive(t *testing.T) {
+	transport := NewUDPTransport()
+	handler := &mockMessageHandler{}
+	transport.RegisterHandler(handler)
+	if err := transport.Start(0); err != nil {
+		t.Fatalf("Failed to start UDP transport: %v", err)
+	}
+	defer transport.Stop()
+
+	client, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: transport.LocalAddr().(*net.UDPAddr).Port})
+	if err != nil {
+		t.Fatalf("Failed to create client connection: %v", err)
+	}
+	defer client.Close()
+
+	request := make([]byte, stunHeaderSize)
+	binary.BigEndian.PutUint16(request[0:2], stunBindingRequest)
+	binary.BigEndian.PutUint32(request[4:8], stunMagicCookie)
+	copy(request[8:], "txn-id-12345")
+	if _, err := client.Write(request); err != nil {
+		t.Fatalf("Failed to send Binding Request: %v", err)
+	}
+
+	response := make([]byte, 1500)
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := client.Read(response)
+	if err != nil {
+		t.Fatalf("Failed to read Binding response: %v", err)
+	}
+	response = response[:n]
+
+	if binary.BigEndian.Uint16(response[0:2]) != stunBindingSuccess {
+		t.Fatalf("Expected Binding Success, got type %#04x", binary.BigEndian.Uint16(response[0:2]))
+	}
+	if string(response[8:stunHeaderSize]) != "txn-id-12345" {
+		t.Errorf("Expected transaction ID to be echoed, got %q", response[8:stunHeaderSize])
+	}
+	if n != 32 || binary.BigEndian.Uint16(response[20:22]) != stunAttrXORMappedAddress {
+		t.Fatalf("Expected a single XOR-MAPPED-ADDRESS, got % x", response)
+	}
+
+	local := client.LocalAddr().(*net.UDPAddr)
+	port := int(binary.BigEndian.Uint16(response[26:28]) ^ uint16(stunMagicCookie>>16))
+	ip := make(net.IP, 4)
+	binary.BigEndian.PutUint32(ip, binary.BigEndian.Uint32(response[28:32])^stunMagicCookie)
+	if port != local.Port || !ip.Equal(local.IP) {
+		t.Errorf("Expected mapped address %s, got %s:%d", local, ip, port)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if messages := handler.getMessages(); len(messages) != 0 {
+		t.Errorf("Expected keepalive not to reach the SIP handler, got %d messages", len(messages))
+	}
+}
+
+func TestUDPFlowTracker_Expire(t *testing.T) {
+	flows := newUDPFlowTracker(time.Minute)
+	start := time.Now()
+	keptAlive := &net.UDPAddr{IP: net.IPv4(203, 0, 113, 5), Port: 40000}
+	silent := &net.UDPAddr{IP: net.IPv4(198, 51, 100, 7), Port: 50000}
+	untracked := &net.UDPAddr{IP: net.IPv4(192, 0, 2, 10), Port: 5060}
+
+	flows.keepalive(keptAlive, start)
+	flows.keepalive(silent, start)
+	flows.seen(keptAlive, start.Add(50*time.Second))
+	flows.seen(untracked, start.Add(50*time.Second))
+
+	expired := flows.expire(start.Add(90 * time.Second))
+	if len(expired) != 1 || expired[0].String() != silent.String() {
+		t.Fatalf("Expected only %s to expire, got %v", silent, expired)
+	}
+	if expired := flows.expire(start.Add(200 * time.Second)); len(expired) != 1 || expired[0].String() != keptAlive.String() {
+		t.Errorf("Expected %s to expire once silent, got %v", keptAlive, expired)
+	}
+}
@@ -0,0 +1,563 @@
+package transport
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// WebSocketSubprotocol is the WebSocket subprotocol negotiated for SIP (RFC 7118 section 4.1)
+const WebSocketSubprotocol = "sip"
+
+const (
+	// webSocketGUID is appended to the client key to compute Sec-WebSocket-Accept (RFC 6455 section 1.3)
+	webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	// maxWebSocketMessageSize bounds a single SIP message carried in WebSocket frames
+	maxWebSocketMessageSize = 64 * 1024
+
+	webSocketHandshakeTimeout = 10 * time.Second
+	webSocketWriteTimeout     = 10 * time.Second
+)
+
+// WebSocket frame opcodes (RFC 6455 section 5.2)
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+)
+
+// WebSocket close status codes (RFC 6455 section 7.4.1)
+const (
+	wsCloseNormal        = 1000
+	wsCloseProtocolError = 1002
+	wsCloseTooBig        = 1009
+)
+
+var (
+	errWebSocketClosed = errors.New("websocket closed by peer")
+	errWebSocketTooBig = fmt.Errorf("websocket message exceeds maximum size %d", maxWebSocketMessageSize)
+)
+
+// webSocketConnection is an upgraded WebSocket connection from a SIP client
+type webSocketConnection struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	writeMu sync.Mutex
+}
+
+// writeFrame sends a single unmasked frame; servers never mask (RFC 6455 section 5.1)
+func (c *webSocketConnection) writeFrame(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	header := make([]byte, 2, 10)
+	header[0] = 0x80 | opcode // FIN
+	switch length := len(payload); {
+	case length < 126:
+		header[1] = byte(length)
+	case length <= 0xFFFF:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(length))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(length))
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(webSocketWriteTimeout))
+	if _, err := c.conn.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// writeClose sends a close frame with the given status code
+func (c *webSocketConnection) writeClose(code uint16) error {
+	return c.writeFrame(wsOpClose, binary.BigEndian.AppendUint16(nil, code))
+}
+
+// readFrame reads one frame and returns its FIN bit, opcode and unmasked payload
+func (c *webSocketConnection) readFrame() (bool, byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin := header[0]&0x80 != 0
+	opcode := header[0] & 0x0F
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, fmt.Errorf("websocket frame uses reserved bits")
+	}
+	if header[1]&0x80 == 0 {
+		return false, 0, nil, fmt.Errorf("websocket client frame is not masked")
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err := io.ReadFull(c.reader, extended[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err := io.ReadFull(c.reader, extended[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+	if length > maxWebSocketMessageSize {
+		return false, 0, nil, errWebSocketTooBig
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.reader, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return fin, opcode, payload, nil
+}
+
+// readMessage reads the next complete data message, answering control frames on the way.
+// Each WebSocket message carries exactly one SIP message (RFC 7118 section 5.2).
+func (c *webSocketConnection) readMessage() ([]byte, error) {
+	var message []byte
+	fragmented := false
+
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case wsOpPing:
+			if err := c.writeFrame(wsOpPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsOpPong:
+			continue
+		case wsOpClose:
+			c.writeClose(wsCloseNormal)
+			return nil, errWebSocketClosed
+		case wsOpText, wsOpBinary:
+			if fragmented {
+				return nil, fmt.Errorf("websocket data frame inside a fragmented message")
+			}
+			message = payload
+		case wsOpContinuation:
+			if !fragmented {
+				return nil, fmt.Errorf("websocket continuation frame without a message")
+			}
+			if len(message)+len(payload) > maxWebSocketMessageSize {
+				return nil, errWebSocketTooBig
+			}
+			message = append(message, payload...)
+		default:
+			return nil, fmt.Errorf("unknown websocket opcode %#x", opcode)
+		}
+
+		if fin {
+			return message, nil
+		}
+		fragmented = true
+	}
+}
+
+// WebSocketTransport handles SIP over WebSocket (RFC 7118) for browser clients.
+// Clients always open the connection, so messages to a client can only be sent
+// over the connection it opened; SendMessage never dials out.
+type WebSocketTransport struct {
+	secure       bool
+	tlsConfig    TLSConfig
+	certificates *certificateStore
+	server       *http.Server
+	listener     net.Listener
+	handler      MessageHandler
+	flowHandler  FlowHandler
+	running      bool
+	mu           sync.RWMutex
+	wg           sync.WaitGroup
+	stopChan     chan struct{}
+	connections  map[string]*webSocketConnection
+	connMu       sync.RWMutex
+}
+
+// NewWebSocketTransport creates a plain WebSocket (WS) transport handler
+func NewWebSocketTransport() *WebSocketTransport {
+	return &WebSocketTransport{
+		stopChan:    make(chan struct{}),
+		connections: make(map[string]*webSocketConnection),
+	}
+}
+
+// NewSecureWebSocketTransport creates a WebSocket over TLS (WSS) transport handler
+func NewSecureWebSocketTransport(config TLSConfig) *WebSocketTransport {
+	t := NewWebSocketTransport()
+	t.secure = true
+	t.tlsConfig = config
+	t.certificates = &certificateStore{
+		certFile: config.CertFile,
+		keyFile:  config.KeyFile,
+	}
+	return t
+}
+
+// Name returns the Via transport of this handler, "WS" or "WSS"
+func (t *WebSocketTransport) Name() string {
+	if t.secure {
+		return "WSS"
+	}
+	return "WS"
+}
+
+// Start starts the WebSocket listener on the specified port of all interfaces
+func (t *WebSocketTransport) Start(port int) error {
+	return t.StartOn("", port)
+}
+
+// StartOn starts the WebSocket listener on the specified local address and port
+func (t *WebSocketTransport) StartOn(address string, port int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.running {
+		return fmt.Errorf("%s transport already running", t.Name())
+	}
+
+	var serverConfig *tls.Config
+	if t.secure {
+		minVersion, err := ParseTLSVersion(t.tlsConfig.MinVersion)
+		if err != nil {
+			return err
+		}
+		cipherSuites, err := ParseCipherSuites(t.tlsConfig.CipherSuites)
+		if err != nil {
+			return err
+		}
+		if err := t.certificates.load(); err != nil {
+			return err
+		}
+		serverConfig = &tls.Config{
+			GetCertificate: t.certificates.getCertificate,
+			MinVersion:     minVersion,
+			CipherSuites:   cipherSuites,
+			NextProtos:     []string{"http/1.1"},
+		}
+	}
+
+	hostPort := net.JoinHostPort(address, strconv.Itoa(port))
+	listener, err := net.Listen("tcp", hostPort)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s %s: %w", t.Name(), hostPort, err)
+	}
+	if serverConfig != nil {
+		listener = tls.NewListener(listener, serverConfig)
+	}
+
+	t.listener = listener
+	t.server = &http.Server{
+		Handler:           http.HandlerFunc(t.handleUpgrade),
+		ReadHeaderTimeout: webSocketHandshakeTimeout,
+	}
+	t.running = true
+
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		t.server.Serve(listener)
+	}()
+
+	return nil
+}
+
+// Stop stops the WebSocket transport and closes all connections
+func (t *WebSocketTransport) Stop() error {
+	t.mu.Lock()
+	if !t.running {
+		t.mu.Unlock()
+		return nil
+	}
+
+	t.running = false
+	close(t.stopChan)
+
+	// Hijacked connections are not tracked by the HTTP server, so close them here
+	t.server.Close()
+	t.connMu.Lock()
+	for _, connection := range t.connections {
+		connection.writeClose(wsCloseNormal)
+		connection.conn.Close()
+	}
+	t.connMu.Unlock()
+
+	t.mu.Unlock()
+
+	t.wg.Wait()
+	return nil
+}
+
+// Reload re-reads the certificate files of a WSS transport without dropping
+// established connections
+func (t *WebSocketTransport) Reload() error {
+	if !t.secure {
+		return fmt.Errorf("WS transport has no certificate")
+	}
+	return t.certificates.load()
+}
+
+// SendMessage sends a SIP message over the WebSocket connection opened from addr
+func (t *WebSocketTransport) SendMessage(data []byte, addr net.Addr) error {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if !t.running {
+		return fmt.Errorf("%s transport not running", t.Name())
+	}
+	if addr == nil {
+		return fmt.Errorf("no destination address for %s transport", t.Name())
+	}
+
+	t.connMu.RLock()
+	connection := t.connections[addr.String()]
+	t.connMu.RUnlock()
+	if connection == nil {
+		return fmt.Errorf("no %s connection to %s", t.Name(), addr)
+	}
+
+	// SIP is UTF-8 text, but bodies may carry arbitrary bytes
+	opcode := byte(wsOpText)
+	if !utf8.Valid(data) {
+		opcode = wsOpBinary
+	}
+	if err := connection.writeFrame(opcode, data); err != nil {
+		connection.conn.Close()
+		return fmt.Errorf("failed to send %s message: %w", t.Name(), err)
+	}
+
+	return nil
+}
+
+// HasConnection reports whether a client at addr is connected to this transport
+func (t *WebSocketTransport) HasConnection(addr net.Addr) bool {
+	if addr == nil {
+		return false
+	}
+	t.connMu.RLock()
+	defer t.connMu.RUnlock()
+	_, exists := t.connections[addr.String()]
+	return exists
+}
+
+// RegisterHandler registers a message handler for incoming messages
+func (t *WebSocketTransport) RegisterHandler(handler MessageHandler) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.handler = handler
+}
+
+// RegisterFlowHandler registers the handler told about closed connections
+func (t *WebSocketTransport) RegisterFlowHandler(handler FlowHandler) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.flowHandler = handler
+}
+
+// handleUpgrade performs the WebSocket opening handshake (RFC 6455 section 4.2)
+// and then reads SIP messages from the connection
+func (t *WebSocketTransport) handleUpgrade(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet ||
+		!headerContainsToken(r.Header, "Connection", "upgrade") ||
+		!headerContainsToken(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "WebSocket upgrade required", http.StatusUpgradeRequired)
+		return
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "Unsupported WebSocket version", http.StatusBadRequest)
+		return
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "Missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return
+	}
+	if !headerContainsToken(r.Header, "Sec-WebSocket-Protocol", WebSocketSubprotocol) {
+		http.Error(w, "The sip WebSocket subprotocol is required", http.StatusBadRequest)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSocket not supported", http.StatusInternalServerError)
+		return
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + webSocketAccept(key) + "\r\n" +
+		"Sec-WebSocket-Protocol: " + WebSocketSubprotocol + "\r\n\r\n"
+	conn.SetDeadline(time.Now().Add(webSocketHandshakeTimeout))
+	if _, err := conn.Write([]byte(response)); err != nil {
+		conn.Close()
+		return
+	}
+	conn.SetDeadline(time.Time{})
+
+	connection := &webSocketConnection{conn: conn, reader: rw.Reader}
+	if !t.track(connection) {
+		return
+	}
+	t.readMessages(connection)
+}
+
+// track records an upgraded connection so that messages to its client use it and
+// adds it to the wait group of Stop. It returns false if the transport is stopping.
+func (t *WebSocketTransport) track(connection *webSocketConnection) bool {
+	t.connMu.Lock()
+	defer t.connMu.Unlock()
+
+	select {
+	case <-t.stopChan:
+		connection.conn.Close()
+		return false
+	default:
+	}
+	t.connections[connection.conn.RemoteAddr().String()] = connection
+	t.wg.Add(1)
+	return true
+}
+
+// readMessages reads SIP messages from a connection until it is closed
+func (t *WebSocketTransport) readMessages(connection *webSocketConnection) {
+	defer t.wg.Done()
+
+	conn := connection.conn
+	defer func() {
+		conn.Close()
+		t.connMu.Lock()
+		current := t.connections[conn.RemoteAddr().String()] == connection
+		if current {
+			delete(t.connections, conn.RemoteAddr().String())
+		}
+		t.connMu.Unlock()
+		if current {
+			t.flowFailed(conn.RemoteAddr())
+		}
+	}()
+
+	for {
+		message, err := connection.readMessage()
+		if err != nil {
+			switch {
+			case errors.Is(err, errWebSocketClosed), errors.Is(err, io.EOF), errors.Is(err, net.ErrClosed):
+			case errors.Is(err, errWebSocketTooBig):
+				connection.writeClose(wsCloseTooBig)
+			default:
+				connection.writeClose(wsCloseProtocolError)
+			}
+			return
+		}
+
+		t.mu.RLock()
+		handler := t.handler
+		t.mu.RUnlock()
+
+		if handler != nil {
+			go func() {
+				if err := handler.HandleMessage(message, t.Name(), conn.RemoteAddr()); err != nil {
+					// Log error handling message
+					// In a real implementation, this would use proper logging
+				}
+			}()
+		}
+	}
+}
+
+// flowFailed reports a closed connection to the flow handler unless the transport is stopping
+func (t *WebSocketTransport) flowFailed(addr net.Addr) {
+	select {
+	case <-t.stopChan:
+		return
+	default:
+	}
+
+	t.mu.RLock()
+	handler := t.flowHandler
+	t.mu.RUnlock()
+
+	if handler != nil {
+		handler.FlowFailed(t.Name(), addr)
+	}
+}
+
+// IsRunning returns true if the WebSocket transport is running
+func (t *WebSocketTransport) IsRunning() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.running
+}
+
+// LocalAddr returns the local address of the WebSocket listener
+func (t *WebSocketTransport) LocalAddr() net.Addr {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.listener != nil {
+		return t.listener.Addr()
+	}
+	return nil
+}
+
+// ConnectionCount returns the number of open WebSocket connections
+func (t *WebSocketTransport) ConnectionCount() int {
+	t.connMu.RLock()
+	defer t.connMu.RUnlock()
+	return len(t.connections)
+}
+
+// webSocketAccept computes the Sec-WebSocket-Accept value for a client key
+func webSocketAccept(key string) string {
+	hash := sha1.Sum([]byte(key + webSocketGUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// headerContainsToken reports whether a comma separated header contains token, ignoring case
+func headerContainsToken(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
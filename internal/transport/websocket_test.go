@@ -0,0 +1,362 @@
+package transport
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testWebSocketClient is a minimal RFC 6455 client speaking the sip subprotocol
+type testWebSocketClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// dialTestWebSocket connects to addr and performs the opening handshake
+func dialTestWebSocket(t *testing.T, conn net.Conn, protocol string) (*testWebSocketClient, *http.Response) {
+	t.Helper()
+
+	request := "GET / HTTP/1.1\r\n" +
+		"Host: example.com\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n" +
+		"Sec-WebSocket-Version: 13\r\n"
+	if protocol != "" {
+		request += "Sec-WebSocket-Protocol: " + protocol + "\r\n"
+	}
+	if _, err := conn.Write([]byte(request + "\r\n")); err != nil {
+		t.Fatalf("Failed to write handshake: %v", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("Failed to read handshake response: %v", err)
+	}
+	return &testWebSocketClient{conn: conn, reader: reader}, response
+}
+
+// connectTestWebSocket dials a plain WS transport and completes the handshake
+func connectTestWebSocket(t *testing.T, addr net.Addr) *testWebSocketClient {
+	t.Helper()
+
+	conn, err := net.Dial("tcp4", addr.String())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	client, response := dialTestWebSocket(t, conn, "sip")
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected 101 Switching Protocols, got %s", response.Status)
+	}
+	return client
+}
+
+// writeFrame sends a masked frame as browsers do
+func (c *testWebSocketClient) writeFrame(t *testing.T, fin bool, opcode byte, payload []byte) {
+	t.Helper()
+
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	frame := []byte{first}
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, 0x80|byte(len(payload)))
+	case len(payload) <= 0xFFFF:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+	mask := []byte{0x12, 0x34, 0x56, 0x78}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		t.Fatalf("Failed to write frame: %v", err)
+	}
+}
+
+// readFrame reads one unmasked server frame
+func (c *testWebSocketClient) readFrame(t *testing.T) (byte, []byte) {
+	t.Helper()
+
+	c.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		t.Fatalf("Failed to read frame: %v", err)
+	}
+	if header[1]&0x80 != 0 {
+		t.Error("Server frames must not be masked")
+	}
+	length := int(header[1] & 0x7F)
+	if length == 126 {
+		var extended [2]byte
+		io.ReadFull(c.reader, extended[:])
+		length = int(binary.BigEndian.Uint16(extended[:]))
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		t.Fatalf("Failed to read frame payload: %v", err)
+	}
+	return header[0] & 0x0F, payload
+}
+
+func TestWebSocketAccept(t *testing.T) {
+	// Example from RFC 6455 section 1.3
+	if accept := webSocketAccept("dGhlIHNhbXBsZSBub25jZQ=="); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Unexpected accept value %s", accept)
+	}
+}
+
+func TestWebSocketTransport_StartStop(t *testing.T) {
+	transport := NewWebSocketTransport()
+
+	if err := transport.Start(0); err != nil {
+		t.Fatalf("Failed to start WS transport: %v", err)
+	}
+	if !transport.IsRunning() {
+		t.Error("Transport should be running after start")
+	}
+	if err := transport.Start(0); err == nil {
+		t.Error("Expected error when starting transport twice")
+	}
+
+	client := connectTestWebSocket(t, transport.LocalAddr())
+	defer client.conn.Close()
+
+	if err := transport.Stop(); err != nil {
+		t.Fatalf("Failed to stop WS transport: %v", err)
+	}
+	if transport.IsRunning() {
+		t.Error("Transport should not be running after stop")
+	}
+
+	// Clients are told that the server is going away
+	if opcode, _ := client.readFrame(t); opcode != wsOpClose {
+		t.Errorf("Expected close frame on stop, got opcode %#x", opcode)
+	}
+}
+
+func TestWebSocketTransport_Handshake(t *testing.T) {
+	transport := NewWebSocketTransport()
+	if err := transport.Start(0); err != nil {
+		t.Fatalf("Failed to start WS transport: %v", err)
+	}
+	defer transport.Stop()
+
+	tests := []struct {
+		name     string
+		protocol string
+		status   int
+	}{
+		{"sip subprotocol", "sip", http.StatusSwitchingProtocols},
+		{"sip among others", "chat, sip", http.StatusSwitchingProtocols},
+		{"missing subprotocol", "", http.StatusBadRequest},
+		{"other subprotocol", "chat", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := net.Dial("tcp4", transport.LocalAddr().String())
+			if err != nil {
+				t.Fatalf("Failed to connect: %v", err)
+			}
+			defer conn.Close()
+
+			_, response := dialTestWebSocket(t, conn, tt.protocol)
+			if response.StatusCode != tt.status {
+				t.Fatalf("Expected status %d, got %s", tt.status, response.Status)
+			}
+			if tt.status != http.StatusSwitchingProtocols {
+				return
+			}
+			if accept := response.Header.Get("Sec-WebSocket-Accept"); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+				t.Errorf("Unexpected Sec-WebSocket-Accept %s", accept)
+			}
+			if protocol := response.Header.Get("Sec-WebSocket-Protocol"); protocol != "sip" {
+				t.Errorf("Expected sip subprotocol, got %q", protocol)
+			}
+		})
+	}
+}
+
+func TestWebSocketTransport_RequiresUpgrade(t *testing.T) {
+	transport := NewWebSocketTransport()
+	if err := transport.Start(0); err != nil {
+		t.Fatalf("Failed to start WS transport: %v", err)
+	}
+	defer transport.Stop()
+
+	response, err := http.Get("http://" + transport.LocalAddr().String() + "/")
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusUpgradeRequired {
+		t.Errorf("Expected 426 Upgrade Required, got %s", response.Status)
+	}
+}
+
+func TestWebSocketTransport_ExchangeMessages(t *testing.T) {
+	transport := NewWebSocketTransport()
+	handler := &mockMessageHandler{}
+	transport.RegisterHandler(handler)
+	if err := transport.Start(0); err != nil {
+		t.Fatalf("Failed to start WS transport: %v", err)
+	}
+	defer transport.Stop()
+
+	client := connectTestWebSocket(t, transport.LocalAddr())
+	defer client.conn.Close()
+
+	// Control frames are answered and never reach the handler
+	client.writeFrame(t, true, wsOpPing, []byte("ping"))
+	if opcode, payload := client.readFrame(t); opcode != wsOpPong || string(payload) != "ping" {
+		t.Errorf("Expected pong echoing ping, got opcode %#x payload %q", opcode, payload)
+	}
+
+	// One WebSocket message carries one SIP message, even when fragmented
+	client.writeFrame(t, true, wsOpText, []byte(testTLSRequest))
+	client.writeFrame(t, false, wsOpText, []byte(testTLSRequest[:10]))
+	client.writeFrame(t, true, wsOpContinuation, []byte(testTLSRequest[10:]))
+
+	messages := waitForMessages(t, handler, 2)
+	for _, message := range messages {
+		if message.transport != "WS" {
+			t.Errorf("Expected transport WS, got %s", message.transport)
+		}
+		if string(message.data) != testTLSRequest {
+			t.Errorf("Received %q, expected %q", message.data, testTLSRequest)
+		}
+	}
+
+	// Responses go back over the connection the client opened
+	if !transport.HasConnection(messages[0].addr) {
+		t.Fatalf("Expected a connection from %s", messages[0].addr)
+	}
+	if err := transport.SendMessage([]byte(testTLSResponse), messages[0].addr); err != nil {
+		t.Fatalf("Failed to send response: %v", err)
+	}
+	if opcode, payload := client.readFrame(t); opcode != wsOpText || string(payload) != testTLSResponse {
+		t.Errorf("Expected text frame with response, got opcode %#x payload %q", opcode, payload)
+	}
+
+	// The server never dials out to unknown clients
+	unknown := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1}
+	if err := transport.SendMessage([]byte(testTLSResponse), unknown); err == nil {
+		t.Error("Expected error sending to a client without a connection")
+	}
+}
+
+func TestWebSocketTransport_RejectsInvalidFrames(t *testing.T) {
+	transport := NewWebSocketTransport()
+	if err := transport.Start(0); err != nil {
+		t.Fatalf("Failed to start WS transport: %v", err)
+	}
+	defer transport.Stop()
+
+	tests := []struct {
+		name   string
+		send   func(client *testWebSocketClient)
+		status uint16
+	}{
+		{
+			name: "oversized message",
+			send: func(client *testWebSocketClient) {
+				client.writeFrame(t, true, wsOpText, []byte(strings.Repeat("a", maxWebSocketMessageSize+1)))
+			},
+			status: wsCloseTooBig,
+		},
+		{
+			name: "unexpected continuation",
+			send: func(client *testWebSocketClient) {
+				client.writeFrame(t, true, wsOpContinuation, []byte("a"))
+			},
+			status: wsCloseProtocolError,
+		},
+		{
+			name: "unmasked frame",
+			send: func(client *testWebSocketClient) {
+				client.conn.Write([]byte{0x81, 0x01, 'a'})
+			},
+			status: wsCloseProtocolError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := connectTestWebSocket(t, transport.LocalAddr())
+			defer client.conn.Close()
+
+			tt.send(client)
+			opcode, payload := client.readFrame(t)
+			if opcode != wsOpClose || len(payload) < 2 {
+				t.Fatalf("Expected close frame, got opcode %#x payload %q", opcode, payload)
+			}
+			if status := binary.BigEndian.Uint16(payload); status != tt.status {
+				t.Errorf("Expected close status %d, got %d", tt.status, status)
+			}
+		})
+	}
+}
+
+func TestWebSocketTransport_Secure(t *testing.T) {
+	config := writeTestCertificate(t, t.TempDir(), "server")
+	transport := NewSecureWebSocketTransport(config)
+	handler := &mockMessageHandler{}
+	transport.RegisterHandler(handler)
+	if err := transport.Start(0); err != nil {
+		t.Fatalf("Failed to start WSS transport: %v", err)
+	}
+	defer transport.Stop()
+
+	pem, err := os.ReadFile(config.CertFile)
+	if err != nil {
+		t.Fatalf("Failed to read certificate: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(pem)
+	conn, err := tls.Dial("tcp4", transport.LocalAddr().String(), &tls.Config{RootCAs: roots, ServerName: "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	client, response := dialTestWebSocket(t, conn, "sip")
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected 101 Switching Protocols, got %s", response.Status)
+	}
+	client.writeFrame(t, true, wsOpText, []byte(testTLSRequest))
+
+	messages := waitForMessages(t, handler, 1)
+	if messages[0].transport != "WSS" {
+		t.Errorf("Expected transport WSS, got %s", messages[0].transport)
+	}
+	if err := transport.Reload(); err != nil {
+		t.Errorf("Failed to reload certificate: %v", err)
+	}
+}
+
+func TestWebSocketTransport_StartErrors(t *testing.T) {
+	transport := NewSecureWebSocketTransport(TLSConfig{CertFile: "missing.pem", KeyFile: "missing.pem"})
+	if err := transport.Start(0); err == nil {
+		transport.Stop()
+		t.Error("Expected error starting WSS transport without a certificate")
+	}
+
+	if err := NewWebSocketTransport().Reload(); err == nil {
+		t.Error("Expected error reloading a WS transport")
+	}
+}
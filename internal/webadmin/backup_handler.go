@@ -0,0 +1,74 @@
+package webadmin
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/zurustar/xylitol2/internal/logging"
+)
+
+// WebBackupHandler handles HTTP requests for database backups
+type WebBackupHandler struct {
+	backupProvider BackupProvider
+	logger         logging.Logger
+}
+
+// HandleBackup creates a consistent snapshot of the live database and streams
+// it to the client as a file download
+func (h *WebBackupHandler) HandleBackup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if h.backupProvider == nil {
+		http.Error(w, "Backup is not available for the configured database driver", http.StatusServiceUnavailable)
+		return
+	}
+
+	tmpDir, err := os.MkdirTemp("", "sipserver-backup-")
+	if err != nil {
+		h.logger.Error("Failed to create backup directory", logging.Field{Key: "error", Value: err})
+		http.Error(w, "Failed to create backup", http.StatusInternalServerError)
+		return
+	}
+	defer os.RemoveAll(tmpDir)
+
+	filename := fmt.Sprintf("sipserver-%s.db", time.Now().UTC().Format("20060102-150405"))
+	backupPath := filepath.Join(tmpDir, filename)
+	if err := h.backupProvider.Backup(backupPath); err != nil {
+		h.logger.Error("Database backup failed", logging.Field{Key: "error", Value: err})
+		http.Error(w, "Failed to create backup", http.StatusInternalServerError)
+		return
+	}
+
+	file, err := os.Open(backupPath)
+	if err != nil {
+		h.logger.Error("Failed to open backup file", logging.Field{Key: "error", Value: err})
+		http.Error(w, "Failed to create backup", http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		h.logger.Error("Failed to stat backup file", logging.Field{Key: "error", Value: err})
+		http.Error(w, "Failed to create backup", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("Database backup created",
+		logging.Field{Key: "file", Value: filename},
+		logging.Field{Key: "size", Value: info.Size()},
+	)
+
+	w.Header().Set("Content-Type", "application/vnd.sqlite3")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("Content-Length", fmt.Sprint(info.Size()))
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, file)
+}
@@ -0,0 +1,75 @@
+package webadmin
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// MockBackupProvider writes fixed content as the backup
+type MockBackupProvider struct {
+	content string
+	err     error
+}
+
+func (m *MockBackupProvider) Backup(destPath string) error {
+	if m.err != nil {
+		return m.err
+	}
+	return os.WriteFile(destPath, []byte(m.content), 0644)
+}
+
+func TestBackupHandler_Download(t *testing.T) {
+	server := NewServer(NewMockUserManager(), NewSimpleHuntGroupManager(), &SimpleHuntGroupEngine{}, &SimpleLogger{})
+	server.SetBackupProvider(&MockBackupProvider{content: "snapshot"})
+
+	req := httptest.NewRequest("POST", "/admin/backup", nil)
+	w := httptest.NewRecorder()
+
+	server.backupHandler.HandleBackup(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if w.Body.String() != "snapshot" {
+		t.Errorf("Expected backup content in body, got %q", w.Body.String())
+	}
+	disposition := w.Header().Get("Content-Disposition")
+	if !strings.HasPrefix(disposition, "attachment;") || !strings.Contains(disposition, ".db") {
+		t.Errorf("Unexpected Content-Disposition: %s", disposition)
+	}
+}
+
+func TestBackupHandler_Errors(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		provider       BackupProvider
+		expectedStatus int
+	}{
+		{"method not allowed", "GET", &MockBackupProvider{}, http.StatusMethodNotAllowed},
+		{"no provider", "POST", nil, http.StatusServiceUnavailable},
+		{"backup failure", "POST", &MockBackupProvider{err: fmt.Errorf("disk full")}, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewServer(NewMockUserManager(), NewSimpleHuntGroupManager(), &SimpleHuntGroupEngine{}, &SimpleLogger{})
+			if tt.provider != nil {
+				server.SetBackupProvider(tt.provider)
+			}
+
+			req := httptest.NewRequest(tt.method, "/admin/backup", nil)
+			w := httptest.NewRecorder()
+
+			server.backupHandler.HandleBackup(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
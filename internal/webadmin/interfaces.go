@@ -3,6 +3,7 @@ package webadmin
 import (
 	"github.com/zurustar/xylitol2/internal/database"
 	"github.com/zurustar/xylitol2/internal/huntgroup"
+	"github.com/zurustar/xylitol2/internal/transaction"
 )
 
 // WebAdminServer defines the interface for the web administration interface
@@ -12,6 +13,16 @@ type WebAdminServer interface {
 	RegisterRoutes()
 }
 
+// BackupProvider creates consistent snapshots of the live database
+type BackupProvider interface {
+	Backup(destPath string) error
+}
+
+// TransactionHistory lists the most recent SIP transactions
+type TransactionHistory interface {
+	Recent() []transaction.TransactionRecord
+}
+
 // UserHandler handles HTTP requests for user management
 type UserHandler struct {
 	userManager database.UserManager
@@ -36,4 +47,6 @@ type HuntGroupHandler struct {
 // GET /admin/huntgroups/{id}/members - List hunt group members
 // POST /admin/huntgroups/{id}/members - Add hunt group member
 // DELETE /admin/huntgroups/{id}/members/{member_id} - Remove hunt group member
-// GET /admin/huntgroups/{id}/statistics - Get hunt group statistics
\ No newline at end of file
+// GET /admin/huntgroups/{id}/statistics - Get hunt group statistics
+// POST /admin/backup - Download a consistent database snapshot
+// GET /admin/transactions - List the most recent SIP transactions
\ No newline at end of file
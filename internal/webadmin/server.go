@@ -13,13 +13,15 @@ import (
 
 // Server implements the WebAdminServer interface
 type Server struct {
-	userManager      database.UserManager
-	huntGroupManager huntgroup.HuntGroupManager
-	huntGroupEngine  huntgroup.HuntGroupEngine
-	logger           logging.Logger
-	server           *http.Server
-	userHandler      *WebUserHandler
-	huntGroupHandler *WebHuntGroupHandler
+	userManager        database.UserManager
+	huntGroupManager   huntgroup.HuntGroupManager
+	huntGroupEngine    huntgroup.HuntGroupEngine
+	logger             logging.Logger
+	server             *http.Server
+	userHandler        *WebUserHandler
+	huntGroupHandler   *WebHuntGroupHandler
+	backupHandler      *WebBackupHandler
+	transactionHandler *WebTransactionHandler
 }
 
 // NewServer creates a new web admin server
@@ -34,15 +36,27 @@ func NewServer(userManager database.UserManager, huntGroupManager huntgroup.Hunt
 	}
 
 	return &Server{
-		userManager:      userManager,
-		huntGroupManager: huntGroupManager,
-		huntGroupEngine:  huntGroupEngine,
-		logger:           logger,
-		userHandler:      userHandler,
-		huntGroupHandler: huntGroupHandler,
+		userManager:        userManager,
+		huntGroupManager:   huntGroupManager,
+		huntGroupEngine:    huntGroupEngine,
+		logger:             logger,
+		userHandler:        userHandler,
+		huntGroupHandler:   huntGroupHandler,
+		backupHandler:      &WebBackupHandler{logger: logger},
+		transactionHandler: &WebTransactionHandler{},
 	}
 }
 
+// SetBackupProvider enables the database backup endpoint
+func (s *Server) SetBackupProvider(provider BackupProvider) {
+	s.backupHandler.backupProvider = provider
+}
+
+// SetTransactionHistory enables the recent transactions endpoint
+func (s *Server) SetTransactionHistory(history TransactionHistory) {
+	s.transactionHandler.history = history
+}
+
 // Start starts the web admin server on the specified port
 func (s *Server) Start(port int) error {
 	mux := http.NewServeMux()
@@ -113,6 +127,12 @@ func (s *Server) registerRoutesOnMux(mux *http.ServeMux) {
 	mux.HandleFunc("/admin/huntgroups/edit/", s.huntGroupHandler.HandleEditHuntGroupPage)
 	mux.HandleFunc("/admin/huntgroups/members/", s.huntGroupHandler.HandleHuntGroupMembers)
 	mux.HandleFunc("/admin/huntgroups/statistics/", s.huntGroupHandler.HandleHuntGroupStatistics)
+
+	// Database backup endpoint
+	mux.HandleFunc("/admin/backup", s.backupHandler.HandleBackup)
+
+	// Recent SIP transactions
+	mux.HandleFunc("/admin/transactions", s.transactionHandler.HandleTransactions)
 }
 
 // WebUserHandler handles HTTP requests for user management
@@ -0,0 +1,28 @@
+package webadmin
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// WebTransactionHandler handles HTTP requests for the recent SIP transactions
+type WebTransactionHandler struct {
+	history TransactionHistory
+}
+
+// HandleTransactions lists the most recent SIP transactions, newest first, with
+// their state, retransmissions, timeouts and transport errors
+func (h *WebTransactionHandler) HandleTransactions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if h.history == nil {
+		http.Error(w, "Transaction history is not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.history.Recent())
+}
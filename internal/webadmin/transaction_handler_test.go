@@ -0,0 +1,65 @@
+package webadmin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zurustar/xylitol2/internal/transaction"
+)
+
+func TestTransactionHandler_List(t *testing.T) {
+	history := transaction.NewHistory(10)
+	history.OnTransactionEvent(transaction.Event{Type: transaction.EventCreated, TransactionID: "t1", Method: "INVITE", State: transaction.StateProceeding})
+	history.OnTransactionEvent(transaction.Event{Type: transaction.EventCreated, TransactionID: "t2", Method: "BYE", State: transaction.StateTrying})
+
+	server := NewServer(NewMockUserManager(), NewSimpleHuntGroupManager(), &SimpleHuntGroupEngine{}, &SimpleLogger{})
+	server.SetTransactionHistory(history)
+
+	req := httptest.NewRequest("GET", "/admin/transactions", nil)
+	w := httptest.NewRecorder()
+
+	server.transactionHandler.HandleTransactions(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var records []transaction.TransactionRecord
+	if err := json.NewDecoder(w.Body).Decode(&records); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(records) != 2 || records[0].ID != "t2" || records[1].Method != "INVITE" {
+		t.Errorf("Expected t2 and t1, got %+v", records)
+	}
+}
+
+func TestTransactionHandler_Errors(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		history        TransactionHistory
+		expectedStatus int
+	}{
+		{"method not allowed", "POST", transaction.NewHistory(10), http.StatusMethodNotAllowed},
+		{"no history", "GET", nil, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewServer(NewMockUserManager(), NewSimpleHuntGroupManager(), &SimpleHuntGroupEngine{}, &SimpleLogger{})
+			if tt.history != nil {
+				server.SetTransactionHistory(tt.history)
+			}
+
+			req := httptest.NewRequest(tt.method, "/admin/transactions", nil)
+			w := httptest.NewRecorder()
+
+			server.transactionHandler.HandleTransactions(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
@@ -137,10 +137,42 @@ func (m *mockTransportManager) StartTCP(port int) error {
 	return nil
 }
 
+func (m *mockTransportManager) StartTLS(port int, config transport.TLSConfig) error {
+	return nil
+}
+
+func (m *mockTransportManager) ReloadTLSCertificates() error {
+	return nil
+}
+
+func (m *mockTransportManager) StartWebSocket(port int) error {
+	return nil
+}
+
+func (m *mockTransportManager) StartSecureWebSocket(port int, config transport.TLSConfig) error {
+	return nil
+}
+
 func (m *mockTransportManager) SendMessage(msg []byte, transport string, addr net.Addr) error {
 	return nil
 }
 
+func (m *mockTransportManager) HasConnection(transport string, addr net.Addr) bool {
+	return false
+}
+
+func (m *mockTransportManager) RegisterFlowHandler(handler transport.FlowHandler) {}
+
+func (m *mockTransportManager) SetProxyProtocol(enabled bool) {}
+
+func (m *mockTransportManager) StartListener(listener transport.Listener) error {
+	return nil
+}
+
+func (m *mockTransportManager) AdvertisedAddress(transport string, addr net.Addr) (string, int) {
+	return "", 0
+}
+
 func (m *mockTransportManager) RegisterHandler(handler transport.MessageHandler) {
 	m.handler = handler
 }